@@ -10,65 +10,265 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/zalando-incubator/stackset-controller/controller"
 	"github.com/zalando-incubator/stackset-controller/pkg/clientset"
+	"github.com/zalando-incubator/stackset-controller/pkg/crds"
 	"gopkg.in/alecthomas/kingpin.v2"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/transport"
 )
 
 const (
-	defaultInterval        = "10s"
-	defaultMetricsAddress  = ":7979"
-	defaultClientGOTimeout = 30 * time.Second
+	defaultInterval         = "10s"
+	defaultWorkers          = "10"
+	defaultStackWorkers     = "4"
+	defaultMetricsAddress   = ":7979"
+	defaultClientGOTimeout  = "30s"
+	defaultClientQPS        = "100"
+	defaultClientBurst      = "500"
+	defaultLogFormat        = "text"
+	defaultCRDTimeout       = time.Minute
+	defaultHealthThreshold  = "5m"
+	defaultResyncPeriod     = "0s"
+	defaultListPageSize     = "0"
+	defaultReconcileTimeout = "2m"
+	defaultWebhookAddress   = ":8443"
 )
 
+// version is set at build time, see the LDFLAGS in the Makefile.
+var version = "unknown"
+
 var (
 	config struct {
-		Debug                 bool
-		Interval              time.Duration
-		APIServer             *url.URL
-		MetricsAddress        string
-		NoTrafficScaledownTTL time.Duration
-		ControllerID          string
+		Debug                     bool
+		Interval                  time.Duration
+		APIServer                 *url.URL
+		MetricsAddress            string
+		NoTrafficScaledownTTL     time.Duration
+		ControllerID              string
+		StackSetSelector          string
+		Shard                     string
+		Workers                   int
+		StackWorkers              int
+		Namespaces                []string
+		ExcludedNamespaces        []string
+		ClientTimeout             time.Duration
+		ClientQPS                 float32
+		ClientBurst               int
+		LogFormat                 string
+		SubsystemLogLevels        map[string]string
+		ReconcileToken            string
+		DebugToken                string
+		EnablePprof               bool
+		APIToken                  string
+		WebhookAddress            string
+		WebhookTLSCertFile        string
+		WebhookTLSKeyFile         string
+		DefaultIngressAnnotations map[string]string
+		DefaultResourceRequests   map[string]string
+		ObserveOnly               bool
+		InstallCRDs               bool
+		HealthThreshold           time.Duration
+		ResyncPeriod              time.Duration
+		ListPageSize              int64
+		ReconcileTimeout          time.Duration
 	}
 )
 
 func main() {
 	kingpin.Flag("debug", "Enable debug logging.").BoolVar(&config.Debug)
+	kingpin.Flag("log-format", "Format of the log messages, text or json.").Default(defaultLogFormat).EnumVar(&config.LogFormat, "text", "json")
+	kingpin.Flag("subsystem-log-level", "Log level of a subsystem (traffic, gc, resources or autoscaling) as <subsystem>=<level>, e.g. traffic=debug. Can be repeated. Subsystems default to the global log level.").StringMapVar(&config.SubsystemLogLevels)
 	kingpin.Flag("interval", "Interval between syncing ingresses.").
 		Default(defaultInterval).DurationVar(&config.Interval)
 	kingpin.Flag("apiserver", "API server url.").URLVar(&config.APIServer)
 	kingpin.Flag("metrics-address", "defines where to serve metrics").Default(defaultMetricsAddress).StringVar(&config.MetricsAddress)
 	kingpin.Flag("controller-id", "ID of the controller used to determine ownership of StackSet resources").StringVar(&config.ControllerID)
+	kingpin.Flag("workers", "Maximum number of StackSets reconciled concurrently, 0 means unlimited.").Default(defaultWorkers).IntVar(&config.Workers)
+	kingpin.Flag("stack-workers", "Maximum number of stacks of a StackSet whose resources are reconciled concurrently, 0 means unlimited.").Default(defaultStackWorkers).IntVar(&config.StackWorkers)
+	kingpin.Flag("stackset-selector", "Label selector of the StackSets managed by the controller, e.g. to split them between several controller deployments.").StringVar(&config.StackSetSelector)
+	kingpin.Flag("shard", "Only manage the StackSets of this shard, in the format <index>/<count>, e.g. 2/5, to split them between several controller deployments.").StringVar(&config.Shard)
+	kingpin.Flag("namespace", "Only manage the StackSets of this namespace, can be repeated. Defaults to all namespaces.").StringsVar(&config.Namespaces)
+	kingpin.Flag("exclude-namespace", "Don't manage the StackSets of this namespace, can be repeated.").StringsVar(&config.ExcludedNamespaces)
+	kingpin.Flag("client-timeout", "Timeout of a single request to the API server, including reading the response.").Default(defaultClientGOTimeout).DurationVar(&config.ClientTimeout)
+	kingpin.Flag("client-qps", "Maximum number of requests per second sent to the API server.").Default(defaultClientQPS).Float32Var(&config.ClientQPS)
+	kingpin.Flag("client-burst", "Maximum burst of requests sent to the API server.").Default(defaultClientBurst).IntVar(&config.ClientBurst)
+	kingpin.Flag("reconcile-token", "Bearer token authenticating the requests to trigger the reconciliation of a StackSet via POST /reconcile/<namespace>/<name>. The endpoint is disabled if not set.").Envar("RECONCILE_TOKEN").StringVar(&config.ReconcileToken)
+	kingpin.Flag("debug-token", "Bearer token authenticating the requests for the internal model of a StackSet via GET /debug/stacksets/<namespace>/<name> and for the subsystem log levels via /log-levels/. The endpoints are disabled if not set.").Envar("DEBUG_TOKEN").StringVar(&config.DebugToken)
+	kingpin.Flag("enable-pprof", "Serve the runtime profiles of the controller on /debug/pprof/, authenticated with the --debug-token.").BoolVar(&config.EnablePprof)
+	kingpin.Flag("api-token", "Bearer token authenticating the requests to the read-only API for dashboards on /api/stacksets. The API is disabled if not set.").Envar("API_TOKEN").StringVar(&config.APIToken)
+	kingpin.Flag("webhook-address", "Address the admission webhooks are served on over TLS.").Default(defaultWebhookAddress).StringVar(&config.WebhookAddress)
+	kingpin.Flag("webhook-tls-cert-file", "Certificate file of the admission webhooks. The webhooks are disabled if not set.").StringVar(&config.WebhookTLSCertFile)
+	kingpin.Flag("webhook-tls-key-file", "Private key file of the admission webhooks.").StringVar(&config.WebhookTLSKeyFile)
+	kingpin.Flag("default-ingress-annotation", "Annotation added to the ingress of the StackSets by the mutating webhook unless it's set, as <key>=<value>. Can be repeated.").StringMapVar(&config.DefaultIngressAnnotations)
+	kingpin.Flag("default-resource-request", "Resource request set on the containers of the stacks by the mutating webhook unless they request or limit the resource, as <resource>=<quantity>, e.g. cpu=100m. Can be repeated.").StringMapVar(&config.DefaultResourceRequests)
+	kingpin.Flag("observe-only", "Compute the state of the StackSets and report the changes the controller would make, but only apply them as server-side dry runs.").BoolVar(&config.ObserveOnly)
+	kingpin.Flag("install-crds", "Create or update the StackSet and Stack CRDs on startup.").BoolVar(&config.InstallCRDs)
+	kingpin.Flag("health-threshold", "Maximum time since the last finished reconciliation pass before /healthz and /readyz fail.").Default(defaultHealthThreshold).DurationVar(&config.HealthThreshold)
+	kingpin.Flag("resync-period", "Period after which the informers resync their caches and all StackSets are reconciled again, 0 disables resyncs.").Default(defaultResyncPeriod).DurationVar(&config.ResyncPeriod)
+	kingpin.Flag("list-page-size", "Number of objects the informers list per request when they're (re)started, 0 lists all objects at once from the watch cache of the API server.").Default(defaultListPageSize).Int64Var(&config.ListPageSize)
+	kingpin.Flag("reconcile-timeout", "Maximum duration of the reconciliation of a single StackSet, after which the remaining changes are skipped and it's requeued, 0 means unlimited.").Default(defaultReconcileTimeout).DurationVar(&config.ReconcileTimeout)
 	kingpin.Parse()
 
 	if config.Debug {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	if config.LogFormat == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	// The CRDs would only be installed as a dry run and never become available
+	if config.ObserveOnly && config.InstallCRDs {
+		log.Fatal("--install-crds can't be used with --observe-only")
+	}
+
+	stacksetSelector, err := labels.Parse(config.StackSetSelector)
+	if err != nil {
+		log.Fatalf("Invalid StackSet selector: %v", err)
+	}
+
+	shard, err := controller.ParseShard(config.Shard)
+	if err != nil {
+		log.Fatalf("Invalid shard: %v", err)
+	}
+
+	if config.EnablePprof && config.DebugToken == "" {
+		log.Fatal("The profiling endpoints require a --debug-token")
+	}
+
+	admissionDefaults := controller.AdmissionDefaults{
+		IngressAnnotations: config.DefaultIngressAnnotations,
+		ResourceRequests:   make(apiv1.ResourceList, len(config.DefaultResourceRequests)),
+	}
+	for name, value := range config.DefaultResourceRequests {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			log.Fatalf("Invalid default request of %s: %v", name, err)
+		}
+		admissionDefaults.ResourceRequests[apiv1.ResourceName(name)] = quantity
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	kubeConfig, err := configureKubeConfig(config.APIServer, defaultClientGOTimeout, ctx.Done())
+	kubeConfig, err := configureKubeConfig(config.APIServer, config.ClientTimeout, config.ClientQPS, config.ClientBurst, ctx.Done())
 	if err != nil {
 		log.Fatalf("Failed to setup Kubernetes config: %v", err)
 	}
 
+	if config.ObserveOnly {
+		log.Info("Running in observe-only mode, changes are only applied as dry runs")
+		kubeConfig.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return &dryRunTransport{next: rt}
+		}
+	}
+
 	client, err := clientset.NewForConfig(kubeConfig)
 	if err != nil {
 		log.Fatalf("Failed to initialize Kubernetes client: %v.", err)
 	}
 
-	controller := controller.NewStackSetController(
+	// API servers without server-side dry runs would persist the changes
+	if config.ObserveOnly {
+		serverVersion, err := client.Discovery().ServerVersion()
+		if err != nil {
+			log.Fatalf("Failed to get the API server version: %v", err)
+		}
+		supported, err := dryRunSupported(serverVersion)
+		if err != nil {
+			log.Fatalf("Failed to check the API server version: %v", err)
+		}
+		if !supported {
+			log.Fatalf("Observe-only mode requires server-side dry runs, which Kubernetes %s doesn't support", serverVersion.GitVersion)
+		}
+	}
+
+	if config.InstallCRDs {
+		dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+		if err != nil {
+			log.Fatalf("Failed to initialize Kubernetes client: %v.", err)
+		}
+		err = crds.Install(dynamicClient)
+		if err != nil {
+			log.Fatalf("Failed to install the CRDs: %v", err)
+		}
+		err = crds.WaitForResources(client.Discovery(), defaultCRDTimeout)
+		if err != nil {
+			log.Fatalf("Failed waiting for the CRDs: %v", err)
+		}
+	}
+
+	err = crds.Check(client.Discovery())
+	if err != nil {
+		log.Fatalf("Missing CRDs: %v", err)
+	}
+
+	stacksetController := controller.NewStackSetController(
 		client,
+		config.Namespaces,
+		config.ExcludedNamespaces,
 		config.ControllerID,
+		stacksetSelector,
+		shard,
 		config.Interval,
+		config.Workers,
+		config.StackWorkers,
 	)
+	stacksetController.ConfigureInformers(config.ResyncPeriod, config.ListPageSize)
+	stacksetController.ConfigureReconcileTimeout(config.ReconcileTimeout)
+	stacksetController.ConfigureVersion(version)
+	stacksetController.ConfigureAdmissionDefaults(admissionDefaults)
+	for subsystem, name := range config.SubsystemLogLevels {
+		level, err := log.ParseLevel(name)
+		if err != nil {
+			log.Fatalf("Invalid log level of the %s subsystem: %v", subsystem, err)
+		}
+		err = stacksetController.SetLogLevel(subsystem, level)
+		if err != nil {
+			log.Fatalf("Invalid subsystem log level: %v", err)
+		}
+	}
+
+	err = stacksetController.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatalf("Failed to register metrics: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", stacksetController.HealthHandler(config.HealthThreshold))
+	mux.Handle("/readyz", stacksetController.ReadyHandler(config.HealthThreshold))
+	if config.ReconcileToken != "" {
+		mux.Handle(controller.ReconcilePathPrefix, stacksetController.ReconcileHandler(config.ReconcileToken))
+	}
+	if config.DebugToken != "" {
+		mux.Handle(controller.DebugPathPrefix, stacksetController.DebugHandler(config.DebugToken))
+		mux.Handle(controller.LogLevelPathPrefix, stacksetController.LogLevelHandler(config.DebugToken))
+	}
+	if config.APIToken != "" {
+		apiHandler := stacksetController.APIHandler(config.APIToken)
+		mux.Handle(controller.APIPathPrefix, apiHandler)
+		mux.Handle(controller.APIPathPrefix+"/", apiHandler)
+	}
+	if config.EnablePprof {
+		mux.Handle(controller.PprofPathPrefix, controller.PprofHandler(config.DebugToken))
+	}
 
 	go handleSigterm(cancel)
-	go serveMetrics(config.MetricsAddress)
-	controller.Run(ctx)
+	go serveMetrics(config.MetricsAddress, mux)
+	if config.WebhookTLSCertFile != "" {
+		webhookMux := http.NewServeMux()
+		webhookMux.Handle(controller.MutatingWebhookPath, stacksetController.MutatingWebhookHandler())
+		webhookMux.Handle(controller.ValidatingWebhookPath, stacksetController.ValidatingWebhookHandler())
+		webhookMux.Handle(controller.ConversionWebhookPath, controller.ConversionWebhookHandler())
+		go serveWebhooks(config.WebhookAddress, config.WebhookTLSCertFile, config.WebhookTLSKeyFile, webhookMux)
+	}
+	stacksetController.Run(ctx)
 }
 
 // handleSigterm handles SIGTERM signal sent to the process.
@@ -80,8 +280,10 @@ func handleSigterm(cancelFunc func()) {
 	cancelFunc()
 }
 
-// configureKubeConfig configures a kubeconfig.
-func configureKubeConfig(apiServerURL *url.URL, timeout time.Duration, stopCh <-chan struct{}) (*rest.Config, error) {
+// configureKubeConfig configures a kubeconfig. Every request to the API server
+// is aborted after the timeout and limited to qps requests per second with
+// the given burst.
+func configureKubeConfig(apiServerURL *url.URL, timeout time.Duration, qps float32, burst int, stopCh <-chan struct{}) (*rest.Config, error) {
 	tr := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   timeout,
@@ -114,8 +316,8 @@ func configureKubeConfig(apiServerURL *url.URL, timeout time.Duration, stopCh <-
 			Host:      apiServerURL.String(),
 			Timeout:   timeout,
 			Transport: tr,
-			QPS:       100.0,
-			Burst:     500,
+			QPS:       qps,
+			Burst:     burst,
 		}, nil
 	}
 
@@ -137,15 +339,21 @@ func configureKubeConfig(apiServerURL *url.URL, timeout time.Duration, stopCh <-
 
 	config.Timeout = timeout
 	config.Transport = tr
-	config.QPS = 100.0
-	config.Burst = 500
+	config.QPS = qps
+	config.Burst = burst
 	// disable TLSClientConfig to make the custom Transport work
 	config.TLSClientConfig = rest.TLSClientConfig{}
 	return config, nil
 }
 
 // gather go metrics
-func serveMetrics(address string) {
-	http.Handle("/metrics", promhttp.Handler())
-	log.Fatal(http.ListenAndServe(address, nil))
+func serveMetrics(address string, mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Fatal(http.ListenAndServe(address, mux))
+}
+
+// serveWebhooks serves the admission webhooks over TLS, as required by the
+// API server.
+func serveWebhooks(address, certFile, keyFile string, mux *http.ServeMux) {
+	log.Fatal(http.ListenAndServeTLS(address, certFile, keyFile, mux))
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sversion "k8s.io/apimachinery/pkg/version"
+)
+
+// dryRunMinMinorVersion is the first minor version of Kubernetes 1.x whose
+// API server supports server-side dry runs.
+const dryRunMinMinorVersion = 13
+
+// dryRunTransport turns every request changing the cluster into a
+// server-side dry run and logs it as drift, so that the controller can
+// observe a cluster without changing it. The API server still validates
+// the changes and returns their result, but doesn't persist them.
+type dryRunTransport struct {
+	next http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	}
+
+	dryRunURL := *req.URL
+	query := dryRunURL.Query()
+	query.Set("dryRun", metav1.DryRunAll)
+	dryRunURL.RawQuery = query.Encode()
+
+	dryRun := req.WithContext(req.Context())
+	dryRun.URL = &dryRunURL
+
+	log.WithFields(log.Fields{"method": req.Method, "path": req.URL.Path}).Infof("Drift: %s %s", req.Method, req.URL.Path)
+	return t.next.RoundTrip(dryRun)
+}
+
+// dryRunSupported returns true if an API server of the version supports
+// server-side dry runs. Older API servers ignore the dryRun parameter and
+// persist the changes.
+func dryRunSupported(info *k8sversion.Info) (bool, error) {
+	major, err := strconv.Atoi(strings.TrimSuffix(info.Major, "+"))
+	if err != nil {
+		return false, fmt.Errorf("invalid major version %q", info.Major)
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+	if err != nil {
+		return false, fmt.Errorf("invalid minor version %q", info.Minor)
+	}
+	return major > 1 || major == 1 && minor >= dryRunMinMinorVersion, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	k8sversion "k8s.io/apimachinery/pkg/version"
+)
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return httptest.NewRecorder().Result(), nil
+}
+
+func TestDryRunTransport(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		dryRun bool
+	}{
+		{method: http.MethodGet},
+		{method: http.MethodPost, dryRun: true},
+		{method: http.MethodPut, dryRun: true},
+		{method: http.MethodPatch, dryRun: true},
+		{method: http.MethodDelete, dryRun: true},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			next := &recordingTransport{}
+			transport := &dryRunTransport{next: next}
+
+			req := httptest.NewRequest(tc.method, "https://kubernetes/apis/apps/v1/namespaces/default/deployments/foo?timeout=30s", nil)
+			_, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+
+			require.Len(t, next.requests, 1)
+			query := next.requests[0].URL.Query()
+			require.Equal(t, "30s", query.Get("timeout"))
+			if tc.dryRun {
+				require.Equal(t, "All", query.Get("dryRun"))
+			} else {
+				require.Empty(t, query.Get("dryRun"))
+			}
+
+			// the original request isn't changed
+			require.Empty(t, req.URL.Query().Get("dryRun"))
+		})
+	}
+}
+
+func TestDryRunSupported(t *testing.T) {
+	for _, tc := range []struct {
+		major     string
+		minor     string
+		supported bool
+		err       bool
+	}{
+		{major: "1", minor: "12", supported: false},
+		{major: "1", minor: "12+", supported: false},
+		{major: "1", minor: "13", supported: true},
+		{major: "1", minor: "14+", supported: true},
+		{major: "2", minor: "0", supported: true},
+		{major: "1", minor: "", err: true},
+		{major: "", minor: "13", err: true},
+	} {
+		t.Run(tc.major+"."+tc.minor, func(t *testing.T) {
+			supported, err := dryRunSupported(&k8sversion.Info{Major: tc.major, Minor: tc.minor})
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.supported, supported)
+		})
+	}
+}
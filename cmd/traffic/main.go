@@ -23,6 +23,8 @@ var (
 		Stack     string
 		Traffic   float64
 		Namespace string
+		ChangedBy string
+		Instant   bool
 	}
 )
 
@@ -31,6 +33,8 @@ func main() {
 	kingpin.Arg("stack", "help").StringVar(&config.Stack)
 	kingpin.Arg("traffic", "help").Default("-1").Float64Var(&config.Traffic)
 	kingpin.Flag("namespace", "Namespace of the stackset resource.").Default(defaultNamespace).StringVar(&config.Namespace)
+	kingpin.Flag("changed-by", "Author of the traffic change recorded in the traffic history of the stackset.").Default(os.Getenv("USER")).StringVar(&config.ChangedBy)
+	kingpin.Flag("instant", "Switch the traffic without prescaling the stacks, e.g. for emergency rollbacks.").BoolVar(&config.Instant)
 	kingpin.Parse()
 
 	kubeconfig, err := newKubeConfig()
@@ -44,6 +48,8 @@ func main() {
 	}
 
 	trafficSwitcher := traffic.NewSwitcher(client)
+	trafficSwitcher.ChangedBy = config.ChangedBy
+	trafficSwitcher.Instant = config.Instant
 
 	if config.Stack != "" && config.Traffic != -1 {
 		weight := config.Traffic
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	admission "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MutatingWebhookPath is the path of the webhook applying the
+	// defaults to StackSets and Stacks when they're created or updated.
+	MutatingWebhookPath = "/mutate"
+
+	// ValidatingWebhookPath is the path of the webhook validating the
+	// changes to StackSets and Stacks.
+	ValidatingWebhookPath = "/validate"
+
+	// maxAdmissionReviewSize is the maximum size of the admission and
+	// conversion reviews accepted by the webhooks.
+	maxAdmissionReviewSize = 3 * 1024 * 1024
+)
+
+// admitFunc decides about an admission request. A nil response allows the
+// request without changes.
+type admitFunc func(request *admission.AdmissionRequest) (*admission.AdmissionResponse, error)
+
+// admissionHandler returns an HTTP handler answering the admission reviews
+// sent by the API server with the decision of the admit function. Errors of
+// the admit function reject the request with the error as message.
+func admissionHandler(admit admitFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAdmissionReviewSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the admission review: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var review admission.AdmissionReview
+		err = json.Unmarshal(body, &review)
+		if err != nil || review.Request == nil {
+			http.Error(w, "invalid admission review", http.StatusBadRequest)
+			return
+		}
+
+		response, err := admit(review.Request)
+		if err != nil {
+			response = &admission.AdmissionResponse{
+				Result: &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Message: err.Error(),
+					Reason:  metav1.StatusReasonInvalid,
+					Code:    http.StatusUnprocessableEntity,
+				},
+			}
+		} else if response == nil {
+			response = &admission.AdmissionResponse{Allowed: true}
+		}
+		response.UID = review.Request.UID
+
+		writeJSON(w, admission.AdmissionReview{
+			TypeMeta: review.TypeMeta,
+			Response: response,
+		})
+	})
+}
+
+// specPatch returns a JSON patch replacing the spec of an object, as
+// expected in the response of a mutating webhook.
+func specPatch(spec interface{}) ([]byte, error) {
+	return json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/spec", "value": spec},
+	})
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	apps "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// adoptingStackSet returns the stackset adopting an unowned stack with the
+// heritage label of the stackset, if adoption is enabled for it.
+func adoptingStackSet(stacksets map[types.UID]*core.StackSetContainer, stack *zv1.Stack) *core.StackSetContainer {
+	if len(stack.OwnerReferences) > 0 {
+		return nil
+	}
+
+	name, ok := stack.Labels[core.StacksetHeritageLabelKey]
+	if !ok {
+		return nil
+	}
+
+	for _, ssc := range stacksets {
+		if ssc.AdoptResources && ssc.StackSet.Namespace == stack.Namespace && ssc.StackSet.Name == name {
+			return ssc
+		}
+	}
+	return nil
+}
+
+// adoptingStack returns the stack adopting an unowned resource with the same
+// name, if adoption is enabled for its stackset.
+func adoptingStack(stacksets map[types.UID]*core.StackSetContainer, resourceMeta metav1.ObjectMeta) *core.StackContainer {
+	if len(resourceMeta.OwnerReferences) > 0 {
+		return nil
+	}
+
+	for _, ssc := range stacksets {
+		if !ssc.AdoptResources {
+			continue
+		}
+		for _, sc := range ssc.StackContainers {
+			if sc.Namespace() == resourceMeta.Namespace && sc.Name() == resourceMeta.Name {
+				return sc
+			}
+		}
+	}
+	return nil
+}
+
+// adoptStack sets the stackset as the owner of a stack created outside of
+// the controller.
+func (c *StackSetController) adoptStack(ssc *core.StackSetContainer, stack *zv1.Stack) (*zv1.Stack, error) {
+	updated := stack.DeepCopy()
+	ssc.AdoptStack(updated)
+
+	result, err := c.client.ZalandoV1().Stacks(updated.Namespace).Update(updated)
+	if err != nil {
+		return nil, err
+	}
+	fixupStackTypeMeta(result)
+
+	c.recorder.Eventf(
+		ssc.StackSet,
+		apiv1.EventTypeNormal,
+		"AdoptedStack",
+		"Adopted stack %s",
+		result.Name)
+	return result, nil
+}
+
+// adoptDeployment sets the stack as the owner of a deployment created
+// outside of the controller.
+func (c *StackSetController) adoptDeployment(sc *core.StackContainer, deployment *apps.Deployment) (*apps.Deployment, error) {
+	updated := deployment.DeepCopy()
+	sc.AdoptResource(&updated.ObjectMeta)
+
+	patch, err := strategicMergePatch(deployment, updated, apps.Deployment{})
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.client.AppsV1().Deployments(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	c.recorder.Eventf(
+		sc.Stack,
+		apiv1.EventTypeNormal,
+		"AdoptedDeployment",
+		"Adopted Deployment %s",
+		result.Name)
+	return result, nil
+}
+
+// adoptService sets the stack as the owner of a service created outside of
+// the controller.
+func (c *StackSetController) adoptService(sc *core.StackContainer, service *apiv1.Service) (*apiv1.Service, error) {
+	updated := service.DeepCopy()
+	sc.AdoptResource(&updated.ObjectMeta)
+
+	patch, err := strategicMergePatch(service, updated, apiv1.Service{})
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.client.CoreV1().Services(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	c.recorder.Eventf(
+		sc.Stack,
+		apiv1.EventTypeNormal,
+		"AdoptedService",
+		"Adopted Service %s",
+		result.Name)
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestAdoptResources(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Annotations = map[string]string{AdoptResourcesAnnotationKey: "true"}
+
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.OwnerReferences = nil
+	stack.Labels = map[string]string{core.StacksetHeritageLabelKey: stackset.Name}
+
+	unowned := metav1.ObjectMeta{Name: stack.Name, Namespace: stack.Namespace}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	err = env.CreateDeployments([]apps.Deployment{{ObjectMeta: unowned}})
+	require.NoError(t, err)
+
+	err = env.CreateServices([]v1.Service{{ObjectMeta: unowned}})
+	require.NoError(t, err)
+
+	resources, err := env.controller.collectResources()
+	require.NoError(t, err)
+
+	sc, ok := resources[stackset.UID].StackContainers[stack.UID]
+	require.True(t, ok)
+	require.Len(t, sc.Stack.OwnerReferences, 1)
+	require.Equal(t, stackset.UID, sc.Stack.OwnerReferences[0].UID)
+
+	require.NotNil(t, sc.Resources.Deployment)
+	require.Equal(t, stackOwned(stack).OwnerReferences, sc.Resources.Deployment.OwnerReferences)
+	require.True(t, core.IsResourceUpToDate(sc.Stack, sc.Resources.Deployment.ObjectMeta))
+
+	require.NotNil(t, sc.Resources.Service)
+	require.Equal(t, stackOwned(stack).OwnerReferences, sc.Resources.Service.OwnerReferences)
+	require.True(t, core.IsResourceUpToDate(sc.Stack, sc.Resources.Service.ObjectMeta))
+
+	require.Len(t, recorder.Events, 3)
+	require.Equal(t, "Normal AdoptedStack Adopted stack foo-v1", <-recorder.Events)
+	require.Equal(t, "Normal AdoptedDeployment Adopted Deployment foo-v1", <-recorder.Events)
+	require.Equal(t, "Normal AdoptedService Adopted Service foo-v1", <-recorder.Events)
+}
+
+func TestAdoptResourcesDisabled(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.OwnerReferences = nil
+	stack.Labels = map[string]string{core.StacksetHeritageLabelKey: stackset.Name}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	resources, err := env.controller.collectResources()
+	require.NoError(t, err)
+	require.Empty(t, resources[stackset.UID].StackContainers)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// APIPathPrefix is the path prefix of the read-only API for deployment
+// dashboards.
+const APIPathPrefix = "/api/stacksets"
+
+// APIHandler returns an HTTP handler serving a read-only API for deployment
+// dashboards, based on the last reconciliation of the StackSets. GET
+// /api/stacksets lists the StackSets with their readiness and traffic weights,
+// GET /api/stacksets/<namespace>/<name>/stacks the stacks of a StackSet with
+// their readiness, replicas and traffic weights. The responses are JSON. The
+// requests must carry the token as a bearer token.
+func (c *StackSetController) APIHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !validBearerToken(r, token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, APIPathPrefix), "/")
+		if path == "" {
+			writeJSON(w, c.stacksetSummaries())
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "stacks" {
+			http.Error(w, "expected "+APIPathPrefix+" or "+APIPathPrefix+"/<namespace>/<name>/stacks", http.StatusNotFound)
+			return
+		}
+
+		container := c.reconciledContainer(types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+		if container == nil {
+			http.Error(w, "stackset wasn't reconciled yet", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, container.StackSummaries())
+	})
+}
+
+// stacksetSummaries returns the summaries of the reconciled StackSets sorted
+// by namespace and name.
+func (c *StackSetController) stacksetSummaries() []*core.StackSetSummary {
+	c.Lock()
+	containers := make([]*core.StackSetContainer, 0, len(c.reconciledContainers))
+	for _, container := range c.reconciledContainers {
+		containers = append(containers, container)
+	}
+	c.Unlock()
+
+	result := make([]*core.StackSetSummary, 0, len(containers))
+	for _, container := range containers {
+		result = append(result, container.Summary())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// writeJSON writes the value as indented JSON.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
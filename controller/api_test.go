@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAPIHandler(t *testing.T) {
+	env := NewTestEnvironment()
+
+	for _, name := range []string{"foo", "bar"} {
+		stackset := testStackset(name, "default", types.UID(name))
+		stack := testStack(name+"-v1", "default", types.UID(name+"-v1"), stackset)
+		env.controller.containerReconciled(&core.StackSetContainer{
+			StackSet: &stackset,
+			StackContainers: map[types.UID]*core.StackContainer{
+				stack.UID: {Stack: &stack},
+			},
+		})
+	}
+
+	for _, tc := range []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{
+			name:          "the stacksets are listed with a token",
+			method:        http.MethodGet,
+			path:          "/api/stacksets",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "the stacks are listed with a token",
+			method:        http.MethodGet,
+			path:          "/api/stacksets/default/foo/stacks",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "only GET is allowed",
+			method:        http.MethodDelete,
+			path:          "/api/stacksets/default/foo/stacks",
+			authorization: "Bearer secret",
+			expected:      http.StatusMethodNotAllowed,
+		},
+		{
+			name:          "requests with a wrong token are rejected",
+			method:        http.MethodGet,
+			path:          "/api/stacksets",
+			authorization: "Bearer wrong",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "stacksets which weren't reconciled aren't found",
+			method:        http.MethodGet,
+			path:          "/api/stacksets/default/baz/stacks",
+			authorization: "Bearer secret",
+			expected:      http.StatusNotFound,
+		},
+		{
+			name:          "unknown paths aren't found",
+			method:        http.MethodGet,
+			path:          "/api/stacksets/default/foo",
+			authorization: "Bearer secret",
+			expected:      http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			request.Header.Set("Authorization", tc.authorization)
+			recorder := httptest.NewRecorder()
+			env.controller.APIHandler("secret").ServeHTTP(recorder, request)
+			require.Equal(t, tc.expected, recorder.Code)
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/api/stacksets", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	env.controller.APIHandler("secret").ServeHTTP(recorder, request)
+	var stacksets []core.StackSetSummary
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stacksets))
+	require.Len(t, stacksets, 2)
+	require.Equal(t, "bar", stacksets[0].Name)
+	require.Equal(t, "foo", stacksets[1].Name)
+	require.EqualValues(t, 1, stacksets[1].Stacks)
+
+	request = httptest.NewRequest(http.MethodGet, "/api/stacksets/default/foo/stacks", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	env.controller.APIHandler("secret").ServeHTTP(recorder, request)
+	var stacks []core.StackSummary
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &stacks))
+	require.Len(t, stacks, 1)
+	require.Equal(t, "foo-v1", stacks[0].Name)
+}
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	zlisters "github.com/zalando-incubator/stackset-controller/pkg/client/listers/zalando.org/v1"
+	apps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2beta1"
+	v1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v2beta1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	extensionslisters "k8s.io/client-go/listers/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
+)
+
+// resourceCache provides the resources owned by the StackSets of a namespace
+// from informer caches, so that they don't have to be listed from the API
+// server on every reconciliation.
+type resourceCache struct {
+	ingresses   extensionslisters.IngressLister
+	stacks      zlisters.StackLister
+	deployments appslisters.DeploymentLister
+	services    corelisters.ServiceLister
+	hpas        autoscalinglisters.HorizontalPodAutoscalerLister
+}
+
+// startResourceCaches starts the informers of the resources owned by the
+// StackSets in the watched namespaces and waits up to the cache sync timeout
+// for them to be synced. If they aren't synced by then, an error is returned
+// and the resources are listed from the API server until they are.
+func (c *StackSetController) startResourceCaches(ctx context.Context) error {
+	var (
+		caches []*resourceCache
+		synced []cache.InformerSynced
+	)
+	for _, namespace := range c.watchedNamespaces() {
+		namespace := namespace
+
+		stackInformer := c.newCacheInformer(
+			ctx,
+			&cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return c.client.ZalandoV1().Stacks(namespace).List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return c.client.ZalandoV1().Stacks(namespace).Watch(options)
+				},
+			},
+			&zv1.Stack{},
+		)
+		ingressInformer := c.newCacheInformer(
+			ctx,
+			trimmedListWatch(
+				func(options metav1.ListOptions) (runtime.Object, error) {
+					return c.client.ExtensionsV1beta1().Ingresses(namespace).List(options)
+				},
+				func(options metav1.ListOptions) (watch.Interface, error) {
+					return c.client.ExtensionsV1beta1().Ingresses(namespace).Watch(options)
+				},
+			),
+			&extensions.Ingress{},
+		)
+		deploymentInformer := c.newCacheInformer(
+			ctx,
+			trimmedListWatch(
+				func(options metav1.ListOptions) (runtime.Object, error) {
+					return c.client.AppsV1().Deployments(namespace).List(options)
+				},
+				func(options metav1.ListOptions) (watch.Interface, error) {
+					return c.client.AppsV1().Deployments(namespace).Watch(options)
+				},
+			),
+			&apps.Deployment{},
+		)
+		serviceInformer := c.newCacheInformer(
+			ctx,
+			trimmedListWatch(
+				func(options metav1.ListOptions) (runtime.Object, error) {
+					return c.client.CoreV1().Services(namespace).List(options)
+				},
+				func(options metav1.ListOptions) (watch.Interface, error) {
+					return c.client.CoreV1().Services(namespace).Watch(options)
+				},
+			),
+			&v1.Service{},
+		)
+		hpaInformer := c.newCacheInformer(
+			ctx,
+			trimmedListWatch(
+				func(options metav1.ListOptions) (runtime.Object, error) {
+					return c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).List(options)
+				},
+				func(options metav1.ListOptions) (watch.Interface, error) {
+					return c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Watch(options)
+				},
+			),
+			&autoscaling.HorizontalPodAutoscaler{},
+		)
+
+		resources := &resourceCache{
+			ingresses:   extensionslisters.NewIngressLister(ingressInformer.GetIndexer()),
+			stacks:      zlisters.NewStackLister(stackInformer.GetIndexer()),
+			deployments: appslisters.NewDeploymentLister(deploymentInformer.GetIndexer()),
+			services:    corelisters.NewServiceLister(serviceInformer.GetIndexer()),
+			hpas:        autoscalinglisters.NewHorizontalPodAutoscalerLister(hpaInformer.GetIndexer()),
+		}
+		for _, informer := range []cache.SharedIndexInformer{stackInformer, ingressInformer, deploymentInformer, serviceInformer, hpaInformer} {
+			synced = append(synced, informer.HasSynced)
+			go informer.Run(ctx.Done())
+		}
+		caches = append(caches, resources)
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, c.cacheSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced...) {
+		go func() {
+			if cache.WaitForCacheSync(ctx.Done(), synced...) {
+				c.setResourceCaches(caches)
+				c.logger.Info("Synced resource caches")
+			}
+		}()
+		return fmt.Errorf("timed out waiting for the resource caches to sync")
+	}
+
+	c.setResourceCaches(caches)
+	return nil
+}
+
+// setResourceCaches makes the resources be read from the synced caches.
+func (c *StackSetController) setResourceCaches(caches []*resourceCache) {
+	c.Lock()
+	defer c.Unlock()
+	c.resourceCaches = caches
+}
+
+// newCacheInformer returns an informer caching the objects of the ListWatch.
+func (c *StackSetController) newCacheInformer(ctx context.Context, listWatch *cache.ListWatch, objType runtime.Object) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		c.pagedListWatch(ctx, listWatch),
+		objType,
+		c.resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// pagedListWatch returns a ListWatch which lists the objects in pages of the
+// configured list page size. The pages are read from etcd instead of the
+// watch cache of the API server, which doesn't support paging, so this
+// trades a higher load of the API server for smaller responses. Without a
+// page size the ListWatch is returned unchanged.
+func (c *StackSetController) pagedListWatch(ctx context.Context, listWatch *cache.ListWatch) *cache.ListWatch {
+	if c.listPageSize <= 0 {
+		return listWatch
+	}
+	listPager := pager.New(pager.SimplePageFunc(listWatch.ListFunc))
+	listPager.PageSize = c.listPageSize
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.ResourceVersion = ""
+			return listPager.List(ctx, options)
+		},
+		WatchFunc: listWatch.WatchFunc,
+		// the pages are already limited to the configured page size
+		DisableChunking: true,
+	}
+}
+
+// trimmedListWatch returns a ListWatch which trims the listed and watched
+// objects before they're cached, see trimObject.
+func trimmedListWatch(listFunc cache.ListFunc, watchFunc cache.WatchFunc) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := listFunc(options)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				trimObject(item)
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			watcher, err := watchFunc(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(watcher, func(event watch.Event) (watch.Event, bool) {
+				trimObject(event.Object)
+				return event, true
+			}), nil
+		},
+	}
+}
+
+// trimObject removes the parts of an object which the controller never reads
+// to reduce the memory used by the caches. Only objects which the controller
+// patches, and never updates as a whole, may be trimmed, otherwise the
+// removed parts would be lost on the next update.
+func trimObject(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	annotations := accessor.GetAnnotations()
+	if _, ok := annotations[v1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, v1.LastAppliedConfigAnnotation)
+		accessor.SetAnnotations(annotations)
+	}
+}
+
+// cachedResources returns the synced resource caches or nil if they aren't
+// available.
+func (c *StackSetController) cachedResources() []*resourceCache {
+	c.Lock()
+	defer c.Unlock()
+	return c.resourceCaches
+}
+
+// listIngresses returns the Ingresses of the watched namespaces.
+func (c *StackSetController) listIngresses() ([]extensions.Ingress, error) {
+	caches := c.cachedResources()
+	if caches == nil {
+		var result []extensions.Ingress
+		for _, namespace := range c.watchedNamespaces() {
+			list, err := c.client.ExtensionsV1beta1().Ingresses(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, list.Items...)
+		}
+		return result, nil
+	}
+
+	var result []extensions.Ingress
+	for _, resources := range caches {
+		ingresses, err := resources.ingresses.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, ingress := range ingresses {
+			result = append(result, *ingress.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+// listStacks returns the Stacks of the watched namespaces.
+func (c *StackSetController) listStacks() ([]zv1.Stack, error) {
+	caches := c.cachedResources()
+	if caches == nil {
+		var result []zv1.Stack
+		for _, namespace := range c.watchedNamespaces() {
+			list, err := c.client.ZalandoV1().Stacks(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, list.Items...)
+		}
+		return result, nil
+	}
+
+	var result []zv1.Stack
+	for _, resources := range caches {
+		stacks, err := resources.stacks.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, stack := range stacks {
+			result = append(result, *stack.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+// listDeployments returns the Deployments of the watched namespaces.
+func (c *StackSetController) listDeployments() ([]apps.Deployment, error) {
+	caches := c.cachedResources()
+	if caches == nil {
+		var result []apps.Deployment
+		for _, namespace := range c.watchedNamespaces() {
+			list, err := c.client.AppsV1().Deployments(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, list.Items...)
+		}
+		return result, nil
+	}
+
+	var result []apps.Deployment
+	for _, resources := range caches {
+		deployments, err := resources.deployments.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, deployment := range deployments {
+			result = append(result, *deployment.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+// listServices returns the Services of the watched namespaces.
+func (c *StackSetController) listServices() ([]v1.Service, error) {
+	caches := c.cachedResources()
+	if caches == nil {
+		var result []v1.Service
+		for _, namespace := range c.watchedNamespaces() {
+			list, err := c.client.CoreV1().Services(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, list.Items...)
+		}
+		return result, nil
+	}
+
+	var result []v1.Service
+	for _, resources := range caches {
+		services, err := resources.services.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			result = append(result, *service.DeepCopy())
+		}
+	}
+	return result, nil
+}
+
+// listHPAs returns the HPAs of the watched namespaces.
+func (c *StackSetController) listHPAs() ([]autoscaling.HorizontalPodAutoscaler, error) {
+	caches := c.cachedResources()
+	if caches == nil {
+		var result []autoscaling.HorizontalPodAutoscaler
+		for _, namespace := range c.watchedNamespaces() {
+			list, err := c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).List(metav1.ListOptions{})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, list.Items...)
+		}
+		return result, nil
+	}
+
+	var result []autoscaling.HorizontalPodAutoscaler
+	for _, resources := range caches {
+		hpas, err := resources.hpas.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, hpa := range hpas {
+			result = append(result, *hpa.DeepCopy())
+		}
+	}
+	return result, nil
+}
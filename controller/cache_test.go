@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCollectResourcesFromCache(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", "default", "abc", stackset)
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+	deployment := apps.Deployment{ObjectMeta: stackOwned(stack)}
+	deployment.Annotations = map[string]string{
+		v1.LastAppliedConfigAnnotation: `{"kind":"Deployment"}`,
+		"foo":                          "bar",
+	}
+	err = env.CreateDeployments([]apps.Deployment{deployment})
+	require.NoError(t, err)
+	err = env.CreateServices([]v1.Service{{ObjectMeta: stackOwned(stack)}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = env.controller.startResourceCaches(ctx)
+	require.NoError(t, err)
+	require.Len(t, env.controller.cachedResources(), 1)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+
+	require.Len(t, containers["123"].StackContainers, 1)
+	sc := containers["123"].StackContainers["abc"]
+	require.NotNil(t, sc)
+	require.NotNil(t, sc.Resources.Deployment)
+	require.NotNil(t, sc.Resources.Service)
+	require.Nil(t, sc.Resources.HPA)
+
+	// the cached objects are trimmed
+	require.Equal(t, map[string]string{"foo": "bar"}, sc.Resources.Deployment.Annotations)
+
+	// the objects are copied from the cache
+	sc.Resources.Deployment.Labels = map[string]string{"changed": "true"}
+	deployments, err := env.controller.listDeployments()
+	require.NoError(t, err)
+	require.Len(t, deployments, 1)
+	require.Empty(t, deployments[0].Labels)
+}
+
+func TestResourceCachesSyncTimeout(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.cacheSyncTimeout = 10 * time.Millisecond
+
+	// the deployments can't be listed at first, so the caches don't sync
+	failing := int32(1)
+	kubeClient := env.client.(*testClient).Interface.(*fake.Clientset)
+	kubeClient.PrependReactor("list", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return true, nil, fmt.Errorf("unavailable")
+		}
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := env.controller.startResourceCaches(ctx)
+	require.Error(t, err)
+	require.Nil(t, env.controller.cachedResources())
+
+	// the caches are used as soon as they are synced
+	atomic.StoreInt32(&failing, 0)
+	deadline := time.Now().Add(10 * time.Second)
+	for env.controller.cachedResources() == nil {
+		require.True(t, time.Now().Before(deadline), "resource caches not synced")
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Len(t, env.controller.cachedResources(), 1)
+}
+
+func TestPagedListWatch(t *testing.T) {
+	env := NewTestEnvironment()
+
+	var listed []metav1.ListOptions
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			listed = append(listed, options)
+			return &v1.ServiceList{Items: []v1.Service{{}, {}}}, nil
+		},
+	}
+
+	// without a page size the ListWatch is used as is
+	require.Equal(t, listWatch, env.controller.pagedListWatch(context.Background(), listWatch))
+
+	env.controller.ConfigureInformers(time.Minute, 10)
+	list, err := env.controller.pagedListWatch(context.Background(), listWatch).List(metav1.ListOptions{ResourceVersion: "0"})
+	require.NoError(t, err)
+	require.Len(t, list.(*v1.ServiceList).Items, 2)
+	require.Equal(t, []metav1.ListOptions{{Limit: 10}}, listed)
+}
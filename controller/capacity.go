@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/clientset"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// podCapacity counts the pods which the scheduler marked as unschedulable,
+// e.g. because the cluster is out of capacity and the cluster autoscaler
+// didn't add nodes (yet).
+type podCapacity struct {
+	client clientset.Interface
+}
+
+func (p *podCapacity) UnschedulablePods(namespace string, selector map[string]string) (int, error) {
+	opts := metav1.ListOptions{
+		LabelSelector: labels.Set(selector).String(),
+	}
+
+	pods, err := p.client.CoreV1().Pods(namespace).List(opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pods in namespace %s: %v", namespace, err)
+	}
+
+	unschedulable := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodPending && podUnschedulable(pod) {
+			unschedulable++
+		}
+	}
+	return unschedulable, nil
+}
+
+// podUnschedulable returns true if the scheduler failed to find a node for
+// the pod.
+func podUnschedulable(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled {
+			return condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUnschedulablePods(t *testing.T) {
+	env := NewTestEnvironment()
+
+	pod := func(name string, labels map[string]string, phase v1.PodPhase, scheduled v1.ConditionStatus, reason string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Status: v1.PodStatus{
+				Phase: phase,
+				Conditions: []v1.PodCondition{
+					{Type: v1.PodScheduled, Status: scheduled, Reason: reason},
+				},
+			},
+		}
+	}
+	stackLabels := map[string]string{"stack-version": "v2"}
+
+	for _, p := range []*v1.Pod{
+		pod("unschedulable-1", stackLabels, v1.PodPending, v1.ConditionFalse, v1.PodReasonUnschedulable),
+		pod("unschedulable-2", stackLabels, v1.PodPending, v1.ConditionFalse, v1.PodReasonUnschedulable),
+		pod("scheduled", stackLabels, v1.PodPending, v1.ConditionTrue, ""),
+		pod("running", stackLabels, v1.PodRunning, v1.ConditionTrue, ""),
+		pod("other-stack", map[string]string{"stack-version": "v1"}, v1.PodPending, v1.ConditionFalse, v1.PodReasonUnschedulable),
+	} {
+		_, err := env.client.CoreV1().Pods(p.Namespace).Create(p)
+		require.NoError(t, err)
+	}
+
+	capacity := &podCapacity{client: env.client}
+	pods, err := capacity.UnschedulablePods("default", stackLabels)
+	require.NoError(t, err)
+	require.Equal(t, 2, pods)
+}
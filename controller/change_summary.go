@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// changeAction is how a resource was changed by a reconciliation.
+type changeAction int
+
+const (
+	changeCreated changeAction = iota
+	changeUpdated
+	changeDeleted
+)
+
+// changeSummary collects the changes a reconciliation of a StackSet makes to
+// its resources and the traffic. The resources of the stacks are reconciled
+// concurrently, so it's safe for concurrent use.
+type changeSummary struct {
+	sync.Mutex
+	created []string
+	updated []string
+	deleted []string
+	traffic []string
+}
+
+// resourceChanged records a change of a resource.
+func (s *changeSummary) resourceChanged(action changeAction, kind, name string) {
+	s.Lock()
+	defer s.Unlock()
+
+	resource := kind + "/" + name
+	switch action {
+	case changeCreated:
+		s.created = append(s.created, resource)
+	case changeUpdated:
+		s.updated = append(s.updated, resource)
+	case changeDeleted:
+		s.deleted = append(s.deleted, resource)
+	}
+}
+
+// trafficSwitched records the changes of the actual traffic weights.
+func (s *changeSummary) trafficSwitched(changes []core.TrafficChange) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, change := range changes {
+		s.traffic = append(s.traffic, change.String())
+	}
+}
+
+// result returns the collected changes sorted by name, or nil if nothing
+// changed.
+func (s *changeSummary) result(now time.Time) *zv1.ChangeSummary {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.created)+len(s.updated)+len(s.deleted)+len(s.traffic) == 0 {
+		return nil
+	}
+
+	for _, list := range [][]string{s.created, s.updated, s.deleted, s.traffic} {
+		sort.Strings(list)
+	}
+	return &zv1.ChangeSummary{
+		Time:    metav1.NewTime(now),
+		Created: s.created,
+		Updated: s.updated,
+		Deleted: s.deleted,
+		Traffic: s.traffic,
+	}
+}
+
+// startChangeSummary starts collecting the changes made by a reconciliation
+// of a StackSet.
+func (c *StackSetController) startChangeSummary(uid types.UID) {
+	c.Lock()
+	defer c.Unlock()
+	c.changeSummaries[uid] = &changeSummary{}
+}
+
+// finishChangeSummary stops collecting the changes made by a reconciliation
+// of a StackSet and returns them, or nil if nothing changed.
+func (c *StackSetController) finishChangeSummary(uid types.UID, now time.Time) *zv1.ChangeSummary {
+	c.Lock()
+	summary, ok := c.changeSummaries[uid]
+	delete(c.changeSummaries, uid)
+	c.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return summary.result(now)
+}
+
+// changeSummary returns the changes collected for the reconciliation of a
+// StackSet, or nil if it isn't being reconciled.
+func (c *StackSetController) changeSummary(uid types.UID) *changeSummary {
+	c.Lock()
+	defer c.Unlock()
+	return c.changeSummaries[uid]
+}
+
+// stacksetResourceChanged records a change of a resource of a StackSet.
+func (c *StackSetController) stacksetResourceChanged(stackset *zv1.StackSet, action changeAction, kind, name string) {
+	if summary := c.changeSummary(stackset.UID); summary != nil {
+		summary.resourceChanged(action, kind, name)
+	}
+}
+
+// stackResourceChanged records a change of a resource of a Stack in the
+// changes of its StackSet.
+func (c *StackSetController) stackResourceChanged(stack *zv1.Stack, action changeAction, kind, name string) {
+	uid, ok := getOwnerUID(stack.ObjectMeta)
+	if !ok {
+		return
+	}
+	if summary := c.changeSummary(uid); summary != nil {
+		summary.resourceChanged(action, kind, name)
+	}
+}
+
+// trafficSwitched records the changes of the actual traffic weights of the
+// Stacks of a StackSet.
+func (c *StackSetController) trafficSwitched(stackset *zv1.StackSet, changes []core.TrafficChange) {
+	if summary := c.changeSummary(stackset.UID); summary != nil {
+		summary.trafficSwitched(changes)
+	}
+}
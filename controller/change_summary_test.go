@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestChangeSummary(t *testing.T) {
+	env := NewTestEnvironment()
+	now := time.Now().Truncate(time.Second)
+
+	// Changes outside of a reconciliation aren't collected
+	env.controller.stacksetResourceChanged(&testStackSet, changeCreated, "Stack", "foo-v1")
+	env.controller.startChangeSummary(testStackSet.UID)
+	require.Nil(t, env.controller.finishChangeSummary(testStackSet.UID, now))
+
+	env.controller.startChangeSummary(testStackSet.UID)
+	generate := func() *apps.Deployment {
+		return &apps.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-v1", Namespace: testStackSet.Namespace},
+		}
+	}
+	err := env.controller.ReconcileStackDeployment(&baseTestStack, nil, generate)
+	require.NoError(t, err)
+	env.controller.stacksetResourceChanged(&testStackSet, changeUpdated, "Ingress", "foo")
+	env.controller.stacksetResourceChanged(&testStackSet, changeDeleted, "Stack", "foo-v0")
+	env.controller.trafficSwitched(&testStackSet, []core.TrafficChange{
+		{StackName: "foo-v1", OldTrafficWeight: 0, NewTrafficWeight: 100},
+	})
+
+	require.Equal(t, &zv1.ChangeSummary{
+		Time:    metav1.NewTime(now),
+		Created: []string{"Deployment/foo-v1"},
+		Updated: []string{"Ingress/foo"},
+		Deleted: []string{"Stack/foo-v0"},
+		Traffic: []string{"foo-v1: 0.0% to 100.0%"},
+	}, env.controller.finishChangeSummary(testStackSet.UID, now))
+
+	// The collected changes are gone once the reconciliation is finished
+	require.Nil(t, env.controller.finishChangeSummary(testStackSet.UID, now))
+}
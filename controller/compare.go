@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ownedMetadataEqual returns true if the labels and the annotations owned by
+// the controller are the same on the generated and the existing resource.
+// All the labels are owned by the controller, the annotations except the
+// ones set by Kubernetes, e.g. the revision of a deployment.
+func ownedMetadataEqual(generated, existing metav1.Object) bool {
+	return equality.Semantic.DeepEqual(generated.GetLabels(), existing.GetLabels()) &&
+		equality.Semantic.DeepEqual(ownedAnnotations(generated.GetAnnotations()), ownedAnnotations(existing.GetAnnotations()))
+}
+
+// ownedAnnotations returns the annotations which aren't set by Kubernetes.
+func ownedAnnotations(annotations map[string]string) map[string]string {
+	result := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		if i := strings.Index(key, "/"); i >= 0 {
+			prefix := key[:i]
+			if prefix == "kubernetes.io" || strings.HasSuffix(prefix, ".kubernetes.io") ||
+				prefix == "k8s.io" || strings.HasSuffix(prefix, ".k8s.io") {
+				continue
+			}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// equalWithDefaults returns true if the generated value is equal to the
+// existing one after the fields left unset in the generated one are
+// defaulted from the existing one, e.g. the ones defaulted by the API
+// server. Unlike with equality.Semantic.DeepDerivative, lists and maps must
+// have the same items, so that e.g. an environment variable added to a
+// container out-of-band is detected. Both values must be of the same type.
+func equalWithDefaults(generated, existing interface{}) bool {
+	defaulted := reflect.New(reflect.TypeOf(generated)).Elem()
+	defaulted.Set(reflect.ValueOf(generated))
+	defaultFrom(defaulted, reflect.ValueOf(existing))
+	return equality.Semantic.DeepEqual(defaulted.Interface(), existing)
+}
+
+// defaultFrom sets the zero fields of value to the ones of the existing value
+// of the same type. Items of lists are only defaulted if both lists have the
+// same length, items of maps aren't defaulted. Pointers are replaced instead
+// of being changed, so that the generated value passed to equalWithDefaults
+// isn't modified.
+func defaultFrom(value, existing reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if existing.IsNil() {
+			return
+		}
+		if value.IsNil() {
+			value.Set(existing)
+			return
+		}
+		defaulted := reflect.New(value.Type().Elem())
+		defaulted.Elem().Set(value.Elem())
+		defaultFrom(defaulted.Elem(), existing.Elem())
+		value.Set(defaulted)
+	case reflect.Struct:
+		if !exportedFields(value.Type()) {
+			if isZero(value) {
+				value.Set(existing)
+			}
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			defaultFrom(value.Field(i), existing.Field(i))
+		}
+	case reflect.Slice:
+		if value.Len() != existing.Len() {
+			return
+		}
+		if value.Len() == 0 {
+			value.Set(existing)
+			return
+		}
+		defaulted := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		reflect.Copy(defaulted, value)
+		for i := 0; i < defaulted.Len(); i++ {
+			defaultFrom(defaulted.Index(i), existing.Index(i))
+		}
+		value.Set(defaulted)
+	case reflect.Map:
+		if value.Len() == 0 && existing.Len() == 0 {
+			value.Set(existing)
+		}
+	default:
+		if isZero(value) {
+			value.Set(existing)
+		}
+	}
+}
+
+// exportedFields returns true if all the fields of the struct type are
+// exported. Structs with unexported fields, e.g. resource quantities, are
+// defaulted as a whole.
+func exportedFields(structType reflect.Type) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).PkgPath != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(value reflect.Value) bool {
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
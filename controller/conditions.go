@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// warningConditions maps the stack conditions indicating a problem to the
+// status they have in that case.
+var warningConditions = map[zv1.StackConditionType]apiv1.ConditionStatus{
+	zv1.StackConditionAutoscalerValid:      apiv1.ConditionFalse,
+	zv1.StackConditionReplicasConflict:     apiv1.ConditionTrue,
+	zv1.StackConditionFailed:               apiv1.ConditionTrue,
+	zv1.StackConditionDeletionBlocked:      apiv1.ConditionTrue,
+	zv1.StackConditionInsufficientCapacity: apiv1.ConditionTrue,
+	zv1.StackConditionBackendPortValid:     apiv1.ConditionFalse,
+}
+
+// recordConditionTransitions emits an event for every condition of the stack
+// which was added or changed its status. Conditions indicating a problem are
+// reported as warnings.
+func (c *StackSetController) recordConditionTransitions(stack *zv1.Stack, previous, current []zv1.StackCondition) {
+	for _, condition := range current {
+		if existing := findStackCondition(previous, condition.Type); existing != nil && existing.Status == condition.Status {
+			continue
+		}
+
+		eventType := apiv1.EventTypeNormal
+		if status, ok := warningConditions[condition.Type]; ok && status == condition.Status {
+			eventType = apiv1.EventTypeWarning
+		}
+		reason := condition.Reason
+		if reason == "" {
+			reason = "ConditionChanged"
+		}
+		message := fmt.Sprintf("Condition %s changed to %s", condition.Type, condition.Status)
+		if condition.Message != "" {
+			message += ": " + condition.Message
+		}
+		c.recorder.Event(stack, eventType, reason, message)
+	}
+}
+
+// findStackCondition returns the condition of the specified type or nil if
+// it's not set.
+func findStackCondition(conditions []zv1.StackCondition, conditionType zv1.StackConditionType) *zv1.StackCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestConditionTransitionEvents(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", "default", "abc", stackset)
+	stack.Spec.Autoscaler = &zv1.Autoscaler{MaxReplicas: 0}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	reconcile := func() {
+		container := &core.StackSetContainer{
+			StackSet: &stackset,
+			StackContainers: map[types.UID]*core.StackContainer{
+				stack.UID: {Stack: &stack},
+			},
+			TrafficReconciler: &core.SimpleTrafficReconciler{},
+		}
+		err := container.UpdateFromResources()
+		require.NoError(t, err)
+		err = env.controller.ReconcileStatuses(container)
+		require.NoError(t, err)
+	}
+
+	// the invalid autoscaler is reported once
+	reconcile()
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Warning InvalidAutoscaler Condition AutoscalerValid changed to False: maxReplicas must be at least 1", <-recorder.Events)
+
+	stack.Status.Conditions = []zv1.StackCondition{{Type: zv1.StackConditionAutoscalerValid, Status: v1.ConditionFalse}}
+	reconcile()
+	require.Empty(t, recorder.Events)
+
+	// and so is the fixed one
+	stack.Spec.Autoscaler.MaxReplicas = 3
+	reconcile()
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal ValidAutoscaler Condition AutoscalerValid changed to True", <-recorder.Events)
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConversionWebhookPath is the path of the webhook converting StackSets and
+// Stacks between the versions of the zalando.org API.
+const ConversionWebhookPath = "/convert"
+
+// conversionReview is the ConversionReview of apiextensions.k8s.io/v1beta1
+// sent by the API server to convert custom resources, which isn't part of
+// the vendored Kubernetes API.
+type conversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *conversionRequest  `json:"request,omitempty"`
+	Response        *conversionResponse `json:"response,omitempty"`
+}
+
+type conversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+type conversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           metav1.Status          `json:"result"`
+}
+
+// conversionKey identifies the conversion of a kind between two versions,
+// e.g. of StackSets from zalando.org/v1 to zalando.org/v2.
+type conversionKey struct {
+	kind string
+	from string
+	to   string
+}
+
+// conversionFunc converts an object to another version. The API version of
+// the converted object is set by the caller.
+type conversionFunc func(object *unstructured.Unstructured) error
+
+// conversions are the conversions between the versions of the zalando.org
+// API. Every new version must register the conversions from and to all the
+// served versions with registerConversion.
+var conversions = map[conversionKey]conversionFunc{}
+
+// registerConversion registers the conversion of a kind between two API
+// versions.
+func registerConversion(kind, from, to string, convert conversionFunc) {
+	conversions[conversionKey{kind: kind, from: from, to: to}] = convert
+}
+
+// convertObject converts a StackSet or Stack to the desired API version.
+func convertObject(object *unstructured.Unstructured, desiredAPIVersion string) error {
+	from := object.GetAPIVersion()
+	if from == desiredAPIVersion {
+		return nil
+	}
+
+	convert, ok := conversions[conversionKey{kind: object.GetKind(), from: from, to: desiredAPIVersion}]
+	if !ok {
+		return fmt.Errorf("conversion of %s from %s to %s is not supported", object.GetKind(), from, desiredAPIVersion)
+	}
+	err := convert(object)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s %s/%s from %s to %s: %v", object.GetKind(), object.GetNamespace(), object.GetName(), from, desiredAPIVersion, err)
+	}
+	object.SetAPIVersion(desiredAPIVersion)
+	return nil
+}
+
+// convertObjects converts the objects of a conversion request to the desired
+// API version.
+func convertObjects(request *conversionRequest) ([]runtime.RawExtension, error) {
+	result := make([]runtime.RawExtension, 0, len(request.Objects))
+	for _, raw := range request.Objects {
+		var object unstructured.Unstructured
+		err := object.UnmarshalJSON(raw.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the object: %v", err)
+		}
+
+		err = convertObject(&object, request.DesiredAPIVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		converted, err := object.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, runtime.RawExtension{Raw: converted})
+	}
+	return result, nil
+}
+
+// ConversionWebhookHandler returns an HTTP handler for the conversion webhook
+// of StackSets and Stacks, used by the API server once several versions of
+// the zalando.org API are served. The conversion fails as a whole if one of
+// the objects can't be converted.
+func ConversionWebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAdmissionReviewSize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read the conversion review: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var review conversionReview
+		err = json.Unmarshal(body, &review)
+		if err != nil || review.Request == nil {
+			http.Error(w, "invalid conversion review", http.StatusBadRequest)
+			return
+		}
+
+		response := &conversionResponse{
+			UID:    review.Request.UID,
+			Result: metav1.Status{Status: metav1.StatusSuccess},
+		}
+		converted, err := convertObjects(review.Request)
+		if err != nil {
+			response.Result = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: err.Error(),
+			}
+		} else {
+			response.ConvertedObjects = converted
+		}
+
+		writeJSON(w, conversionReview{
+			TypeMeta: review.TypeMeta,
+			Response: response,
+		})
+	})
+}
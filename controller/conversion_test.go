@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func convert(t *testing.T, desiredAPIVersion string, objects ...map[string]interface{}) *conversionResponse {
+	request := &conversionRequest{UID: "review-uid", DesiredAPIVersion: desiredAPIVersion}
+	for _, object := range objects {
+		raw, err := json.Marshal(object)
+		require.NoError(t, err)
+		request.Objects = append(request.Objects, runtime.RawExtension{Raw: raw})
+	}
+	body, err := json.Marshal(conversionReview{Request: request})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	ConversionWebhookHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ConversionWebhookPath, bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var review conversionReview
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+	require.NotNil(t, review.Response)
+	require.EqualValues(t, "review-uid", review.Response.UID)
+	return review.Response
+}
+
+func TestConversionWebhook(t *testing.T) {
+	registerConversion("Stack", "zalando.org/v1", "zalando.org/v2", func(object *unstructured.Unstructured) error {
+		return unstructured.SetNestedField(object.Object, "converted", "spec", "note")
+	})
+	defer delete(conversions, conversionKey{kind: "Stack", from: "zalando.org/v1", to: "zalando.org/v2"})
+
+	stack := map[string]interface{}{
+		"apiVersion": "zalando.org/v1",
+		"kind":       "Stack",
+		"metadata":   map[string]interface{}{"name": "foo-v1", "namespace": "default"},
+		"spec":       map[string]interface{}{},
+	}
+	convertedStack := map[string]interface{}{
+		"apiVersion": "zalando.org/v2",
+		"kind":       "Stack",
+		"metadata":   map[string]interface{}{"name": "foo-v1", "namespace": "default"},
+		"spec":       map[string]interface{}{"note": "converted"},
+	}
+
+	response := convert(t, "zalando.org/v2", stack, convertedStack)
+	require.Equal(t, metav1.StatusSuccess, response.Result.Status)
+	require.Len(t, response.ConvertedObjects, 2)
+	for _, raw := range response.ConvertedObjects {
+		var object map[string]interface{}
+		require.NoError(t, json.Unmarshal(raw.Raw, &object))
+		require.Equal(t, convertedStack, object)
+	}
+
+	response = convert(t, "zalando.org/v2", map[string]interface{}{
+		"apiVersion": "zalando.org/v1",
+		"kind":       "StackSet",
+		"metadata":   map[string]interface{}{"name": "foo", "namespace": "default"},
+	})
+	require.Equal(t, metav1.StatusFailure, response.Result.Status)
+	require.Equal(t, "conversion of StackSet from zalando.org/v1 to zalando.org/v2 is not supported", response.Result.Message)
+	require.Empty(t, response.ConvertedObjects)
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DebugPathPrefix is the path prefix of the endpoint returning the internal
+// model of a StackSet.
+const DebugPathPrefix = "/debug/stacksets/"
+
+// DebugHandler returns an HTTP handler which returns the internal model of a
+// StackSet as of its last reconciliation on GET
+// /debug/stacksets/<namespace>/<name> as JSON, including the traffic weights,
+// the prescaling state, the pending removals and the differences between the
+// existing and the generated resources of its stacks. The requests must carry
+// the token as a bearer token.
+func (c *StackSetController) DebugHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !validBearerToken(r, token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, DebugPathPrefix), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected "+DebugPathPrefix+"<namespace>/<name>", http.StatusNotFound)
+			return
+		}
+
+		container := c.reconciledContainer(types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+		if container == nil {
+			http.Error(w, "stackset wasn't reconciled yet", http.StatusNotFound)
+			return
+		}
+
+		state := container.DebugState()
+		for _, stack := range state.Stacks {
+			for _, sc := range container.StackContainers {
+				if sc.Name() == stack.Name {
+					stack.ResourceDiffs = stackResourceDiffs(sc)
+				}
+			}
+		}
+
+		writeJSON(w, state)
+	})
+}
+
+// containerReconciled keeps the model of a StackSet computed by its last
+// reconciliation for the debug endpoint.
+func (c *StackSetController) containerReconciled(container *core.StackSetContainer) {
+	c.Lock()
+	defer c.Unlock()
+	c.reconciledContainers[container.StackSet.UID] = container
+}
+
+// reconciledContainer returns the model of a StackSet computed by its last
+// reconciliation or nil if it wasn't reconciled yet.
+func (c *StackSetController) reconciledContainer(name types.NamespacedName) *core.StackSetContainer {
+	c.Lock()
+	defer c.Unlock()
+	for _, container := range c.reconciledContainers {
+		if container.StackSet.Namespace == name.Namespace && container.StackSet.Name == name.Name {
+			return container
+		}
+	}
+	return nil
+}
+
+// pruneReconciledContainers forgets the models of the StackSets which don't
+// exist anymore.
+func (c *StackSetController) pruneReconciledContainers(stackContainers map[types.UID]*core.StackSetContainer) {
+	c.Lock()
+	defer c.Unlock()
+	for uid := range c.reconciledContainers {
+		if _, ok := stackContainers[uid]; !ok {
+			delete(c.reconciledContainers, uid)
+		}
+	}
+}
+
+// stackResourceDiffs returns the differences between the specs of the
+// existing and the generated resources of a stack by kind. Resources which
+// match the generated ones, ignoring the fields the controller doesn't set,
+// are left out.
+func stackResourceDiffs(sc *core.StackContainer) map[string]string {
+	diffs := make(map[string]string)
+	diff := func(kind string, generated, existing interface{}) {
+		if !equality.Semantic.DeepDerivative(generated, existing) {
+			diffs[kind] = cmp.Diff(existing, generated, cmpopts.IgnoreUnexported(resource.Quantity{}))
+		}
+	}
+
+	if deployment := sc.GenerateDeployment(); sc.Resources.Deployment != nil {
+		diff("deployment", deployment.Spec, sc.Resources.Deployment.Spec)
+	}
+
+	service, err := sc.GenerateService()
+	if err != nil {
+		diffs["service"] = "failed to generate: " + err.Error()
+	} else if service != nil && sc.Resources.Service != nil {
+		diff("service", service.Spec, sc.Resources.Service.Spec)
+	}
+
+	ingress, err := sc.GenerateIngress()
+	if err != nil {
+		diffs["ingress"] = "failed to generate: " + err.Error()
+	} else if ingress != nil && sc.Resources.Ingress != nil {
+		diff("ingress", ingress.Spec, sc.Resources.Ingress.Spec)
+	}
+
+	hpa, err := sc.GenerateHPA()
+	if err != nil {
+		diffs["hpa"] = "failed to generate: " + err.Error()
+	} else if hpa != nil && sc.Resources.HPA != nil {
+		diff("hpa", hpa.Spec, sc.Resources.HPA.Spec)
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+	return diffs
+}
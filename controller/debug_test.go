@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDebugHandler(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", "default", "abc", stackset)
+	stack.Spec.PodTemplate.Spec.Containers = []v1.Container{{Name: "app", Image: "app:v2"}}
+
+	env.controller.containerReconciled(&core.StackSetContainer{
+		StackSet: &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{
+			stack.UID: {
+				Stack: &stack,
+				Resources: core.StackResources{
+					Deployment: &appsv1.Deployment{
+						Spec: appsv1.DeploymentSpec{
+							Template: v1.PodTemplateSpec{
+								Spec: v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "app:v1"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	for _, tc := range []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{
+			name:          "the model is returned with a token",
+			method:        http.MethodGet,
+			path:          "/debug/stacksets/default/foo",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "only GET is allowed",
+			method:        http.MethodPost,
+			path:          "/debug/stacksets/default/foo",
+			authorization: "Bearer secret",
+			expected:      http.StatusMethodNotAllowed,
+		},
+		{
+			name:          "requests with a wrong token are rejected",
+			method:        http.MethodGet,
+			path:          "/debug/stacksets/default/foo",
+			authorization: "Bearer wrong",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "stacksets which weren't reconciled aren't found",
+			method:        http.MethodGet,
+			path:          "/debug/stacksets/default/bar",
+			authorization: "Bearer secret",
+			expected:      http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			request.Header.Set("Authorization", tc.authorization)
+			recorder := httptest.NewRecorder()
+			env.controller.DebugHandler("secret").ServeHTTP(recorder, request)
+			require.Equal(t, tc.expected, recorder.Code)
+
+			if tc.expected == http.StatusOK {
+				require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+				var state core.StackSetDebugState
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &state))
+				require.Equal(t, "foo", state.Name)
+				require.Len(t, state.Stacks, 1)
+				require.Equal(t, "foo-v1", state.Stacks[0].Name)
+				require.Contains(t, state.Stacks[0].ResourceDiffs, "deployment")
+				require.Contains(t, state.Stacks[0].ResourceDiffs["deployment"], "app:v1")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	admission "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// AdmissionDefaults are the platform defaults the mutating webhook applies to
+// StackSets and Stacks, in addition to the defaults of the controller.
+type AdmissionDefaults struct {
+	// IngressAnnotations are added to the ingress of StackSets unless
+	// they're already set.
+	IngressAnnotations map[string]string
+
+	// ResourceRequests are requested by the containers of the stacks
+	// which neither request nor limit the resource themselves.
+	ResourceRequests v1.ResourceList
+}
+
+// ConfigureAdmissionDefaults sets the platform defaults applied by the
+// mutating webhook.
+func (c *StackSetController) ConfigureAdmissionDefaults(defaults AdmissionDefaults) {
+	c.admissionDefaults = defaults
+}
+
+// MutatingWebhookHandler returns an HTTP handler for the mutating admission
+// webhook of StackSets and Stacks. It stores the defaults of the controller
+// and the platform defaults in the spec when they're created or updated, so
+// that the generated resources are stable and the defaults are visible.
+func (c *StackSetController) MutatingWebhookHandler() http.Handler {
+	return admissionHandler(c.admitDefaults)
+}
+
+// admitDefaults returns a patch applying the defaults to the spec of the
+// StackSet or Stack of the admission request, or nil if nothing is missing.
+func (c *StackSetController) admitDefaults(request *admission.AdmissionRequest) (*admission.AdmissionResponse, error) {
+	if request.Operation != admission.Create && request.Operation != admission.Update {
+		return nil, nil
+	}
+
+	switch request.Kind.Kind {
+	case "StackSet":
+		var stackset zv1.StackSet
+		err := json.Unmarshal(request.Object.Raw, &stackset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the StackSet: %v", err)
+		}
+		original := stackset.Spec.DeepCopy()
+		c.defaultStackSetSpec(&stackset.Spec)
+		return specPatchResponse(original, &stackset.Spec)
+	case "Stack":
+		var stack zv1.Stack
+		err := json.Unmarshal(request.Object.Raw, &stack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the Stack: %v", err)
+		}
+		original := stack.Spec.DeepCopy()
+		c.defaultStackSpec(&stack.Spec)
+		return specPatchResponse(original, &stack.Spec)
+	}
+	return nil, nil
+}
+
+// specPatchResponse returns a response patching the spec if it was changed
+// by the defaults.
+func specPatchResponse(original, defaulted interface{}) (*admission.AdmissionResponse, error) {
+	if equality.Semantic.DeepEqual(original, defaulted) {
+		return nil, nil
+	}
+
+	patch, err := specPatch(defaulted)
+	if err != nil {
+		return nil, err
+	}
+	patchType := admission.PatchTypeJSONPatch
+	return &admission.AdmissionResponse{
+		Allowed:   true,
+		Patch:     patch,
+		PatchType: &patchType,
+	}, nil
+}
+
+// defaultStackSetSpec applies the lifecycle defaults of the controller and
+// the platform defaults to the spec of a StackSet and its stack template.
+func (c *StackSetController) defaultStackSetSpec(spec *zv1.StackSetSpec) {
+	if spec.StackLifecycle.Limit == nil {
+		limit := int32(core.DefaultStackLifecycleLimit)
+		spec.StackLifecycle.Limit = &limit
+	}
+	if spec.StackLifecycle.ScaledownTTLSeconds == nil {
+		ttl := int64(core.DefaultScaledownTTL / time.Second)
+		spec.StackLifecycle.ScaledownTTLSeconds = &ttl
+	}
+
+	if spec.Ingress != nil && len(c.admissionDefaults.IngressAnnotations) > 0 {
+		if spec.Ingress.Annotations == nil {
+			spec.Ingress.Annotations = make(map[string]string, len(c.admissionDefaults.IngressAnnotations))
+		}
+		for key, value := range c.admissionDefaults.IngressAnnotations {
+			if _, ok := spec.Ingress.Annotations[key]; !ok {
+				spec.Ingress.Annotations[key] = value
+			}
+		}
+	}
+
+	c.defaultStackSpec(&spec.StackTemplate.Spec.StackSpec)
+}
+
+// defaultStackSpec applies the protocol of the service ports and the
+// platform resource requests to the spec of a stack.
+func (c *StackSetController) defaultStackSpec(spec *zv1.StackSpec) {
+	if spec.Service != nil {
+		for i := range spec.Service.Ports {
+			if spec.Service.Ports[i].Protocol == "" {
+				spec.Service.Ports[i].Protocol = v1.ProtocolTCP
+			}
+		}
+	}
+
+	if len(c.admissionDefaults.ResourceRequests) == 0 {
+		return
+	}
+	containers := spec.PodTemplate.Spec.Containers
+	for i := range containers {
+		for name, quantity := range c.admissionDefaults.ResourceRequests {
+			if _, ok := containers[i].Resources.Requests[name]; ok {
+				continue
+			}
+			// The requests of resources with a limit default to the limit
+			if _, ok := containers[i].Resources.Limits[name]; ok {
+				continue
+			}
+			if containers[i].Resources.Requests == nil {
+				containers[i].Resources.Requests = make(v1.ResourceList, len(c.admissionDefaults.ResourceRequests))
+			}
+			containers[i].Resources.Requests[name] = quantity.DeepCopy()
+		}
+	}
+}
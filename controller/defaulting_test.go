@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	admission "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// admissionReview sends an admission review for the object to the handler
+// and returns the response.
+func admissionReview(t *testing.T, handler http.Handler, operation admission.Operation, kind string, object interface{}) *admission.AdmissionResponse {
+	raw, err := json.Marshal(object)
+	require.NoError(t, err)
+
+	return sendAdmissionReview(t, handler, &admission.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: kind},
+		Operation: operation,
+		Object:    runtime.RawExtension{Raw: raw},
+	})
+}
+
+// sendAdmissionReview sends an admission review with the request to the
+// handler and returns the response.
+func sendAdmissionReview(t *testing.T, handler http.Handler, request *admission.AdmissionRequest) *admission.AdmissionResponse {
+	request.UID = "review-uid"
+	body, err := json.Marshal(admission.AdmissionReview{Request: request})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var review admission.AdmissionReview
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+	require.NotNil(t, review.Response)
+	require.EqualValues(t, "review-uid", review.Response.UID)
+	return review.Response
+}
+
+// patchedSpec decodes the spec replaced by the JSON patch of a response.
+func patchedSpec(t *testing.T, response *admission.AdmissionResponse, spec interface{}) {
+	require.NotNil(t, response.PatchType)
+	require.Equal(t, admission.PatchTypeJSONPatch, *response.PatchType)
+
+	var patch []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal(response.Patch, &patch))
+	require.Len(t, patch, 1)
+	require.Equal(t, "replace", patch[0].Op)
+	require.Equal(t, "/spec", patch[0].Path)
+	require.NoError(t, json.Unmarshal(patch[0].Value, spec))
+}
+
+func TestMutatingWebhookStackSet(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.ConfigureAdmissionDefaults(AdmissionDefaults{
+		IngressAnnotations: map[string]string{"example.org/team": "platform", "example.org/tier": "1"},
+		ResourceRequests:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("256Mi")},
+	})
+	handler := env.controller.MutatingWebhookHandler()
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.Ingress = &zv1.StackSetIngressSpec{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"example.org/tier": "2"}},
+	}
+	stackset.Spec.StackTemplate.Spec.Service = &zv1.StackServiceSpec{
+		Ports: []v1.ServicePort{{Port: 80}, {Port: 53, Protocol: v1.ProtocolUDP}},
+	}
+	stackset.Spec.StackTemplate.Spec.PodTemplate.Spec.Containers = []v1.Container{
+		{
+			Name: "app",
+			Resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+		},
+	}
+
+	response := admissionReview(t, handler, admission.Create, "StackSet", stackset)
+	require.True(t, response.Allowed)
+
+	var spec zv1.StackSetSpec
+	patchedSpec(t, response, &spec)
+	require.EqualValues(t, 10, *spec.StackLifecycle.Limit)
+	require.EqualValues(t, 300, *spec.StackLifecycle.ScaledownTTLSeconds)
+	require.Equal(t, map[string]string{"example.org/team": "platform", "example.org/tier": "2"}, spec.Ingress.Annotations)
+	require.Equal(t, v1.ProtocolTCP, spec.StackTemplate.Spec.Service.Ports[0].Protocol)
+	require.Equal(t, v1.ProtocolUDP, spec.StackTemplate.Spec.Service.Ports[1].Protocol)
+	requests := spec.StackTemplate.Spec.PodTemplate.Spec.Containers[0].Resources.Requests
+	require.Equal(t, "100m", requests.Cpu().String())
+	require.NotContains(t, requests, v1.ResourceMemory)
+
+	// StackSets which already have the defaults aren't patched
+	stackset.Spec = spec
+	response = admissionReview(t, handler, admission.Update, "StackSet", stackset)
+	require.True(t, response.Allowed)
+	require.Nil(t, response.Patch)
+}
+
+func TestMutatingWebhookStack(t *testing.T) {
+	env := NewTestEnvironment()
+	handler := env.controller.MutatingWebhookHandler()
+
+	stack := testStack("foo-v1", "default", "abc", testStackset("foo", "default", "123"))
+	stack.Spec.Service = &zv1.StackServiceSpec{Ports: []v1.ServicePort{{Port: 80}}}
+
+	response := admissionReview(t, handler, admission.Create, "Stack", stack)
+	require.True(t, response.Allowed)
+
+	var spec zv1.StackSpec
+	patchedSpec(t, response, &spec)
+	require.Equal(t, v1.ProtocolTCP, spec.Service.Ports[0].Protocol)
+}
+
+func TestAdmissionHandlerInvalidReview(t *testing.T) {
+	handler := admissionHandler(func(request *admission.AdmissionRequest) (*admission.AdmissionResponse, error) {
+		return nil, nil
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{}"))))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"fmt"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+)
+
+// deprecatedAnnotations are the StackSet annotations which were superseded
+// by fields of the spec, each with its replacement.
+var deprecatedAnnotations = []struct {
+	annotation  string
+	replacement string
+}{
+	{PrescaleStacksAnnotationKey, "spec.trafficPolicy.prescaling"},
+	{ResetHPAMinReplicasDelayAnnotationKey, "spec.trafficPolicy.prescaling.timeout"},
+}
+
+// stacksetDeprecations returns the deprecated features used by a StackSet,
+// each described with its replacement.
+func stacksetDeprecations(stackset *zv1.StackSet) []string {
+	var result []string
+
+	if stackset.Spec.StackTemplate.Spec.HorizontalPodAutoscaler != nil {
+		result = append(result, "spec.stackTemplate.spec.horizontalPodAutoscaler is deprecated, use spec.stackTemplate.spec.autoscaler instead")
+	}
+
+	if stackset.Spec.Ingress != nil {
+		result = append(result, "the ingresses are created as extensions/v1beta1, which is deprecated in favour of networking.k8s.io/v1 and is going to be switched with the next API version")
+	}
+
+	for _, deprecated := range deprecatedAnnotations {
+		if _, ok := stackset.Annotations[deprecated.annotation]; ok {
+			result = append(result, fmt.Sprintf("the %s annotation is deprecated, use %s instead", deprecated.annotation, deprecated.replacement))
+		}
+	}
+	return result
+}
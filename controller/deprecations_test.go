@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+)
+
+func TestStacksetDeprecations(t *testing.T) {
+	stackset := testStackset("foo", "default", "123")
+	require.Empty(t, stacksetDeprecations(&stackset))
+
+	stackset.Spec.StackTemplate.Spec.HorizontalPodAutoscaler = &zv1.HorizontalPodAutoscaler{MaxReplicas: 3}
+	stackset.Spec.Ingress = &zv1.StackSetIngressSpec{}
+	stackset.Annotations = map[string]string{
+		PrescaleStacksAnnotationKey:       "yes",
+		AggregateAutoscalingAnnotationKey: "true",
+	}
+
+	deprecations := stacksetDeprecations(&stackset)
+	require.Len(t, deprecations, 3)
+	require.Contains(t, deprecations[0], "horizontalPodAutoscaler")
+	require.Contains(t, deprecations[1], "extensions/v1beta1")
+	require.Contains(t, deprecations[2], PrescaleStacksAnnotationKey)
+	require.Contains(t, deprecations[2], "spec.trafficPolicy.prescaling")
+}
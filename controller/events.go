@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// recordPrescalingTransitions emits an event when the prescaling of a stack
+// starts or ends.
+func (c *StackSetController) recordPrescalingTransitions(stack *zv1.Stack, previous, current zv1.PrescalingStatus) {
+	switch {
+	case current.Active && !previous.Active:
+		c.recorder.Eventf(
+			stack,
+			apiv1.EventTypeNormal,
+			"PrescalingStarted",
+			"Started prescaling to %d replicas for %.1f%% of the traffic",
+			current.Replicas,
+			current.DesiredTrafficWeight)
+	case !current.Active && previous.Active:
+		c.recorder.Event(
+			stack,
+			apiv1.EventTypeNormal,
+			"PrescalingFinished",
+			"Finished prescaling")
+	}
+}
+
+// recordDesiredTrafficChanges emits a single event listing the stacks whose
+// desired traffic weight changed, if any.
+func (c *StackSetController) recordDesiredTrafficChanges(stackset *zv1.StackSet, changes []core.TrafficChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].StackName < changes[j].StackName
+	})
+	var changeMessages []string
+	for _, change := range changes {
+		changeMessages = append(changeMessages, change.String())
+	}
+
+	c.recorder.Eventf(
+		stackset,
+		apiv1.EventTypeNormal,
+		"DesiredTrafficChanged",
+		"Changed desired traffic: %s",
+		strings.Join(changeMessages, ", "))
+}
+
+// recordRemovalDecisions emits an event for every stack which was newly
+// selected to be deleted, explaining why and by which rule. The events are
+// recorded on the StackSet, so that they can still be looked up once the
+// stack is gone.
+func (c *StackSetController) recordRemovalDecisions(ssc *core.StackSetContainer) {
+	for _, sc := range ssc.StackContainers {
+		reason := sc.RemovalReason()
+		if reason == "" {
+			continue
+		}
+		if existing := findStackCondition(sc.Stack.Status.Conditions, zv1.StackConditionScheduledForRemoval); existing != nil && existing.Status == apiv1.ConditionTrue {
+			continue
+		}
+		c.recorder.Eventf(
+			ssc.StackSet,
+			apiv1.EventTypeNormal,
+			"SelectedStackForRemoval",
+			"Selected stack %s for removal: %s",
+			sc.Name(),
+			reason)
+	}
+}
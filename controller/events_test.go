@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestPrescalingTransitionEvents(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", "default", "abc", stackset)
+
+	inactive := zv1.PrescalingStatus{}
+	active := zv1.PrescalingStatus{Active: true, Replicas: 5, DesiredTrafficWeight: 50}
+
+	env.controller.recordPrescalingTransitions(&stack, inactive, active)
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal PrescalingStarted Started prescaling to 5 replicas for 50.0% of the traffic", <-recorder.Events)
+
+	// ongoing prescaling isn't reported again
+	env.controller.recordPrescalingTransitions(&stack, active, active)
+	require.Empty(t, recorder.Events)
+
+	env.controller.recordPrescalingTransitions(&stack, active, inactive)
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal PrescalingFinished Finished prescaling", <-recorder.Events)
+}
+
+func TestDesiredTrafficChangeEvents(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	stackset := testStackset("foo", "default", "123")
+
+	env.controller.recordDesiredTrafficChanges(&stackset, nil)
+	require.Empty(t, recorder.Events)
+
+	env.controller.recordDesiredTrafficChanges(&stackset, []core.TrafficChange{
+		{StackName: "foo-v2", OldTrafficWeight: 0, NewTrafficWeight: 50},
+		{StackName: "foo-v1", OldTrafficWeight: 100, NewTrafficWeight: 50},
+	})
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal DesiredTrafficChanged Changed desired traffic: foo-v1: 100.0% to 50.0%, foo-v2: 0.0% to 50.0%", <-recorder.Events)
+}
+
+func TestRemovalDecisionEvents(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", "default", "abc", stackset)
+	stack.Annotations = map[string]string{core.ExpiresAtAnnotationKey: "2020-01-01T00:00:00Z"}
+
+	container := &core.StackSetContainer{
+		StackSet:        &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{stack.UID: {Stack: &stack}},
+	}
+	container.MarkExpiredStacks(time.Now())
+
+	env.controller.recordRemovalDecisions(container)
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Normal SelectedStackForRemoval Selected stack foo-v1 for removal: expired at 2020-01-01T00:00:00Z (rule: expiry annotation)", <-recorder.Events)
+
+	// stacks which were already selected before aren't reported again
+	stack.Status.Conditions = []zv1.StackCondition{{Type: zv1.StackConditionScheduledForRemoval, Status: v1.ConditionTrue}}
+	env.controller.recordRemovalDecisions(container)
+	require.Empty(t, recorder.Events)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+)
+
+const forecastTimeLayout = "15:04"
+
+// trafficForecastMinReplicas returns the highest number of replicas required
+// by the traffic forecast schedules active at the given time. A schedule is
+// active from its lead time before the start of the spike until the spike is
+// over. Invalid schedules are skipped and reported as error.
+func trafficForecastMinReplicas(schedules []zv1.TrafficForecastSchedule, now time.Time) (int32, error) {
+	var (
+		minReplicas int32
+		invalid     []string
+	)
+
+	for _, schedule := range schedules {
+		active, err := forecastScheduleActive(schedule, now)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", schedule.Name, err))
+			continue
+		}
+		if active && schedule.MinReplicas > minReplicas {
+			minReplicas = schedule.MinReplicas
+		}
+	}
+
+	if len(invalid) > 0 {
+		return minReplicas, fmt.Errorf("invalid traffic forecast schedules: %s", strings.Join(invalid, ", "))
+	}
+	return minReplicas, nil
+}
+
+// forecastScheduleActive checks whether the given time is within the boost
+// window of one of the occurrences of the schedule.
+func forecastScheduleActive(schedule zv1.TrafficForecastSchedule, now time.Time) (bool, error) {
+	location := time.UTC
+	if schedule.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	startTime, err := time.Parse(forecastTimeLayout, schedule.Time)
+	if err != nil {
+		return false, fmt.Errorf("time must be in the format HH:MM")
+	}
+
+	days, err := forecastDays(schedule.Days)
+	if err != nil {
+		return false, err
+	}
+
+	var leadTime time.Duration
+	if schedule.LeadTime != nil {
+		leadTime = schedule.LeadTime.Duration
+	}
+
+	// The boost window of the spike of the previous or the next day might
+	// include the current time as well
+	local := now.In(location)
+	for offset := -1; offset <= 1; offset++ {
+		start := time.Date(local.Year(), local.Month(), local.Day()+offset, startTime.Hour(), startTime.Minute(), 0, 0, location)
+		if len(days) > 0 && !days[start.Weekday()] {
+			continue
+		}
+		if !now.Before(start.Add(-leadTime)) && now.Before(start.Add(schedule.Duration.Duration)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// forecastDays parses a list of abbreviated week days (e.g. Mon, Tue).
+func forecastDays(days []string) (map[time.Weekday]bool, error) {
+	result := make(map[time.Weekday]bool, len(days))
+	for _, day := range days {
+		found := false
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(day, weekday.String()[:3]) {
+				result[weekday] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown day %s", day)
+		}
+	}
+	return result, nil
+}
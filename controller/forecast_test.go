@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTrafficForecastMinReplicas(t *testing.T) {
+	// Monday
+	now := time.Date(2019, time.March, 4, 8, 50, 0, 0, time.UTC)
+
+	morningSpike := zv1.TrafficForecastSchedule{
+		Name:        "morning",
+		Days:        []string{"Mon", "tue", "Wed", "Thu", "Fri"},
+		Time:        "09:00",
+		Duration:    metav1.Duration{Duration: time.Hour},
+		LeadTime:    &metav1.Duration{Duration: 15 * time.Minute},
+		MinReplicas: 10,
+	}
+
+	for _, tc := range []struct {
+		name             string
+		schedules        []zv1.TrafficForecastSchedule
+		now              time.Time
+		expectedReplicas int32
+		expectedErr      string
+	}{
+		{
+			name: "no schedules",
+			now:  now,
+		},
+		{
+			name:             "within the lead time",
+			schedules:        []zv1.TrafficForecastSchedule{morningSpike},
+			now:              now,
+			expectedReplicas: 10,
+		},
+		{
+			name:             "during the spike",
+			schedules:        []zv1.TrafficForecastSchedule{morningSpike},
+			now:              now.Add(time.Hour),
+			expectedReplicas: 10,
+		},
+		{
+			name:      "before the lead time",
+			schedules: []zv1.TrafficForecastSchedule{morningSpike},
+			now:       now.Add(-10 * time.Minute),
+		},
+		{
+			name:      "after the spike",
+			schedules: []zv1.TrafficForecastSchedule{morningSpike},
+			now:       now.Add(time.Hour + 10*time.Minute),
+		},
+		{
+			name:      "on another day",
+			schedules: []zv1.TrafficForecastSchedule{morningSpike},
+			now:       now.AddDate(0, 0, -1),
+		},
+		{
+			name: "spike lasting past midnight",
+			schedules: []zv1.TrafficForecastSchedule{
+				{Name: "night", Days: []string{"Sun"}, Time: "23:00", Duration: metav1.Duration{Duration: 4 * time.Hour}, MinReplicas: 5},
+			},
+			now:              time.Date(2019, time.March, 4, 1, 0, 0, 0, time.UTC),
+			expectedReplicas: 5,
+		},
+		{
+			name: "time zone of the schedule is respected",
+			schedules: []zv1.TrafficForecastSchedule{
+				{Name: "morning", Time: "10:00", TimeZone: "Europe/Berlin", Duration: metav1.Duration{Duration: time.Hour}, MinReplicas: 5},
+			},
+			now:              time.Date(2019, time.March, 4, 9, 30, 0, 0, time.UTC),
+			expectedReplicas: 5,
+		},
+		{
+			name: "the highest replicas of overlapping schedules are used",
+			schedules: []zv1.TrafficForecastSchedule{
+				morningSpike,
+				{Name: "sale", Time: "08:00", Duration: metav1.Duration{Duration: 2 * time.Hour}, MinReplicas: 20},
+			},
+			now:              now,
+			expectedReplicas: 20,
+		},
+		{
+			name: "invalid schedules are skipped",
+			schedules: []zv1.TrafficForecastSchedule{
+				morningSpike,
+				{Name: "broken", Time: "9am", Duration: metav1.Duration{Duration: time.Hour}, MinReplicas: 20},
+				{Name: "weekend", Days: []string{"Saturday"}, Time: "09:00", Duration: metav1.Duration{Duration: time.Hour}, MinReplicas: 20},
+			},
+			now:              now,
+			expectedReplicas: 10,
+			expectedErr:      "invalid traffic forecast schedules: broken: time must be in the format HH:MM, weekend: unknown day Saturday",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			replicas, err := trafficForecastMinReplicas(tc.schedules, tc.now)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expectedReplicas, replicas)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// healthState tracks the progress of the controller for the health and
+// readiness endpoints.
+type healthState struct {
+	started     time.Time
+	watchSynced bool
+	lastPass    time.Time
+}
+
+// controllerStarted records the start of the main loop.
+func (c *StackSetController) controllerStarted(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.health.started = now
+}
+
+// watchSynced records that the StackSet watcher is synced.
+func (c *StackSetController) watchSynced() {
+	c.Lock()
+	defer c.Unlock()
+	c.health.watchSynced = true
+}
+
+// passFinished records the end of a full reconciliation pass.
+func (c *StackSetController) passFinished(now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.health.lastPass = now
+}
+
+// checkHealth returns an error if the controller didn't finish a full
+// reconciliation pass within the threshold, i.e. if it's stuck.
+func (c *StackSetController) checkHealth(now time.Time, threshold time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
+
+	// The first pass is expected within the threshold after the start
+	last := c.health.lastPass
+	if last.IsZero() {
+		last = c.health.started
+	}
+	if !last.IsZero() && now.Sub(last) > threshold {
+		return fmt.Errorf("no reconciliation pass finished in the last %s", now.Sub(last).Round(time.Second))
+	}
+	return nil
+}
+
+// checkReadiness returns an error if the controller isn't ready to manage
+// the StackSets yet, or isn't healthy anymore.
+func (c *StackSetController) checkReadiness(now time.Time, threshold time.Duration) error {
+	c.Lock()
+	started, watchSynced, cachesSynced, lastPass := !c.health.started.IsZero(), c.health.watchSynced, c.resourceCaches != nil, c.health.lastPass
+	c.Unlock()
+
+	switch {
+	case !started:
+		return fmt.Errorf("controller not started")
+	case !watchSynced:
+		return fmt.Errorf("StackSet watcher not synced")
+	case !cachesSynced:
+		return fmt.Errorf("resource caches not synced")
+	case lastPass.IsZero():
+		return fmt.Errorf("no reconciliation pass finished yet")
+	}
+	return c.checkHealth(now, threshold)
+}
+
+// HealthHandler returns an HTTP handler for liveness probes, which fails if
+// the controller didn't finish a full reconciliation pass within the
+// threshold, so that a stuck controller is restarted.
+func (c *StackSetController) HealthHandler(threshold time.Duration) http.Handler {
+	return probeHandler(func() error {
+		return c.checkHealth(time.Now(), threshold)
+	})
+}
+
+// ReadyHandler returns an HTTP handler for readiness probes, which only
+// succeeds once the StackSet watcher and the resource caches are synced and
+// the controller finished a full reconciliation pass within the threshold.
+func (c *StackSetController) ReadyHandler(threshold time.Duration) http.Handler {
+	return probeHandler(func() error {
+		return c.checkReadiness(time.Now(), threshold)
+	})
+}
+
+func probeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := check()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
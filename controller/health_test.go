@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHealth(t *testing.T) {
+	env := NewTestEnvironment()
+	now := time.Now()
+
+	// the controller is healthy before it's started
+	require.NoError(t, env.controller.checkHealth(now, time.Minute))
+
+	// the first pass is expected within the threshold after the start
+	env.controller.controllerStarted(now)
+	require.NoError(t, env.controller.checkHealth(now.Add(30*time.Second), time.Minute))
+	require.Error(t, env.controller.checkHealth(now.Add(2*time.Minute), time.Minute))
+
+	// the last pass must have finished within the threshold
+	env.controller.passFinished(now.Add(2 * time.Minute))
+	require.NoError(t, env.controller.checkHealth(now.Add(2*time.Minute), time.Minute))
+	require.Error(t, env.controller.checkHealth(now.Add(4*time.Minute), time.Minute))
+}
+
+func TestCheckReadiness(t *testing.T) {
+	env := NewTestEnvironment()
+	now := time.Now()
+
+	require.Error(t, env.controller.checkReadiness(now, time.Minute))
+
+	env.controller.controllerStarted(now)
+	require.Error(t, env.controller.checkReadiness(now, time.Minute))
+
+	env.controller.watchSynced()
+	require.Error(t, env.controller.checkReadiness(now, time.Minute))
+
+	env.controller.resourceCaches = []*resourceCache{}
+	require.Error(t, env.controller.checkReadiness(now, time.Minute))
+
+	env.controller.passFinished(now)
+	require.NoError(t, env.controller.checkReadiness(now, time.Minute))
+
+	// a stuck controller isn't ready
+	require.Error(t, env.controller.checkReadiness(now.Add(2*time.Minute), time.Minute))
+}
+
+func TestProbeHandlers(t *testing.T) {
+	env := NewTestEnvironment()
+
+	recorder := httptest.NewRecorder()
+	env.controller.HealthHandler(time.Minute).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	env.controller.ReadyHandler(time.Minute).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
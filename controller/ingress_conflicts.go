@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ingressRoute is a host and path routed to the stacks of a StackSet by its
+// ingress.
+type ingressRoute struct {
+	host string
+	path string
+}
+
+func (r ingressRoute) String() string {
+	return r.host + r.path
+}
+
+// ingressRoutes returns the routes of the ingress of a StackSet.
+func ingressRoutes(stackset *zv1.StackSet) []ingressRoute {
+	if stackset.Spec.Ingress == nil {
+		return nil
+	}
+
+	result := make([]ingressRoute, 0, len(stackset.Spec.Ingress.Hosts))
+	for _, host := range stackset.Spec.Ingress.Hosts {
+		result = append(result, ingressRoute{
+			host: strings.ToLower(host),
+			path: stackset.Spec.Ingress.Path,
+		})
+	}
+	return result
+}
+
+// ingressConflicts returns the routes of the StackSet which are also routed
+// by one of the other StackSets, mapped to the other StackSet. The traffic
+// weights of the ingresses of both StackSets apply to the same requests
+// then, which leaves the routing undefined.
+func ingressConflicts(stackset *zv1.StackSet, others []zv1.StackSet) map[ingressRoute]*zv1.StackSet {
+	routes := ingressRoutes(stackset)
+	if len(routes) == 0 {
+		return nil
+	}
+
+	result := make(map[ingressRoute]*zv1.StackSet)
+	for i := range others {
+		other := &others[i]
+		if other.Namespace == stackset.Namespace && other.Name == stackset.Name {
+			continue
+		}
+		if other.DeletionTimestamp != nil {
+			continue
+		}
+
+		for _, otherRoute := range ingressRoutes(other) {
+			for _, route := range routes {
+				if route == otherRoute {
+					result[route] = other
+				}
+			}
+		}
+	}
+	return result
+}
+
+// conflictMessages describes the conflicting routes, sorted by route.
+func conflictMessages(conflicts map[ingressRoute]*zv1.StackSet) []string {
+	result := make([]string, 0, len(conflicts))
+	for route, other := range conflicts {
+		result = append(result, fmt.Sprintf("%s is already routed by StackSet %s/%s", route, other.Namespace, other.Name))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// listStackSets lists the StackSets of the watched namespaces from the API
+// server.
+func (c *StackSetController) listStackSets() ([]zv1.StackSet, error) {
+	var result []zv1.StackSet
+	for _, namespace := range c.watchedNamespaces() {
+		stacksets, err := c.client.ZalandoV1().StackSets(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, stacksets.Items...)
+	}
+	return result, nil
+}
+
+// validateIngressRoutes rejects a StackSet whose ingress routes a host and
+// path which is already routed by another StackSet. Only the routes added by
+// an update are checked, so that the StackSet which declared a route first
+// keeps it.
+func (c *StackSetController) validateIngressRoutes(stackset, old *zv1.StackSet) error {
+	existing := make(map[ingressRoute]bool)
+	for _, route := range ingressRoutes(old) {
+		existing[route] = true
+	}
+	added := false
+	for _, route := range ingressRoutes(stackset) {
+		if !existing[route] {
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+
+	others, err := c.listStackSets()
+	if err != nil {
+		return fmt.Errorf("failed to list the StackSets: %v", err)
+	}
+
+	conflicts := ingressConflicts(stackset, others)
+	for route := range conflicts {
+		if existing[route] {
+			delete(conflicts, route)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("spec.ingress: %s", strings.Join(conflictMessages(conflicts), ", "))
+}
+
+// auditIngressConflicts logs the StackSets whose ingress routes a host and
+// path which is also routed by another StackSet, e.g. because they were
+// created before the validating webhook was enabled. A warning event is
+// emitted for the StackSet created last.
+func (c *StackSetController) auditIngressConflicts() error {
+	stacksets, err := c.listStackSets()
+	if err != nil {
+		return err
+	}
+
+	for i := range stacksets {
+		stackset := &stacksets[i]
+		var older []zv1.StackSet
+		for j := range stacksets {
+			if createdAfter(stackset, &stacksets[j]) {
+				older = append(older, stacksets[j])
+			}
+		}
+		conflicts := ingressConflicts(stackset, older)
+		if len(conflicts) == 0 {
+			continue
+		}
+
+		message := strings.Join(conflictMessages(conflicts), ", ")
+		c.objectLogger(stackset.Namespace, "stackset", stackset.Name).Warnf("Conflicting ingress routes: %s", message)
+		c.recorder.Eventf(
+			stackset,
+			apiv1.EventTypeWarning,
+			"IngressRouteConflict",
+			"Conflicting ingress routes: %s",
+			message)
+	}
+	return nil
+}
+
+// createdAfter returns true if the StackSet was created after the other one,
+// ordered by name if they were created at the same time.
+func createdAfter(stackset, other *zv1.StackSet) bool {
+	if !stackset.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return other.CreationTimestamp.Before(&stackset.CreationTimestamp)
+	}
+	return other.Namespace+"/"+other.Name < stackset.Namespace+"/"+stackset.Name
+}
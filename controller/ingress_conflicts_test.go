@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	admission "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func ingressStackset(name, namespace string, uid types.UID, path string, hosts ...string) zv1.StackSet {
+	stackset := testStackset(name, namespace, uid)
+	stackset.Spec.Ingress = &zv1.StackSetIngressSpec{Hosts: hosts, Path: path}
+	return stackset
+}
+
+func TestValidateIngressRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		stackset zv1.StackSet
+		message  string
+	}{
+		{
+			name:     "other host",
+			stackset: ingressStackset("bar", "default", "456", "", "bar.example.org"),
+		},
+		{
+			name:     "same host on another path",
+			stackset: ingressStackset("bar", "default", "456", "/bar", "foo.example.org"),
+		},
+		{
+			name:     "same host",
+			stackset: ingressStackset("bar", "default", "456", "", "bar.example.org", "FOO.example.org"),
+			message:  "spec.ingress: foo.example.org is already routed by StackSet default/foo",
+		},
+		{
+			name:     "same host in another namespace",
+			stackset: ingressStackset("foo", "other", "456", "", "foo.example.org"),
+			message:  "spec.ingress: foo.example.org is already routed by StackSet default/foo",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+			require.NoError(t, env.CreateStacksets([]zv1.StackSet{ingressStackset("foo", "default", "123", "", "foo.example.org")}))
+
+			response := admissionReview(t, env.controller.ValidatingWebhookHandler(), admission.Create, "StackSet", tc.stackset)
+			if tc.message == "" {
+				require.True(t, response.Allowed)
+				return
+			}
+			require.False(t, response.Allowed)
+			require.Equal(t, tc.message, response.Result.Message)
+		})
+	}
+}
+
+func TestValidateExistingIngressRoutes(t *testing.T) {
+	env := NewTestEnvironment()
+	handler := env.controller.ValidatingWebhookHandler()
+
+	foo := ingressStackset("foo", "default", "123", "", "foo.example.org")
+	bar := ingressStackset("bar", "default", "456", "", "foo.example.org")
+	require.NoError(t, env.CreateStacksets([]zv1.StackSet{foo, bar}))
+
+	update := func(old, updated zv1.StackSet) *admission.AdmissionResponse {
+		oldRaw, err := json.Marshal(old)
+		require.NoError(t, err)
+		updatedRaw, err := json.Marshal(updated)
+		require.NoError(t, err)
+		return sendAdmissionReview(t, handler, &admission.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: "StackSet"},
+			Operation: admission.Update,
+			Object:    runtime.RawExtension{Raw: updatedRaw},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+		})
+	}
+
+	// conflicts stored before the validation don't block other updates
+	updated := *bar.DeepCopy()
+	updated.Labels = map[string]string{"team": "bar"}
+	require.True(t, update(bar, updated).Allowed)
+
+	// but no new ones can be added
+	updated.Spec.Ingress.Hosts = append(updated.Spec.Ingress.Hosts, "FOO.example.org", "bar.example.org")
+	withoutConflict := ingressStackset("bar", "default", "456", "", "bar.example.org")
+	response := update(withoutConflict, updated)
+	require.False(t, response.Allowed)
+	require.Equal(t, "spec.ingress: foo.example.org is already routed by StackSet default/foo", response.Result.Message)
+}
+
+func TestAuditIngressConflicts(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	created := time.Now()
+	foo := ingressStackset("foo", "default", "123", "", "foo.example.org")
+	foo.CreationTimestamp = metav1.NewTime(created)
+	bar := ingressStackset("bar", "default", "456", "", "foo.example.org", "bar.example.org")
+	bar.CreationTimestamp = metav1.NewTime(created.Add(time.Minute))
+	baz := ingressStackset("baz", "default", "789", "", "baz.example.org")
+	require.NoError(t, env.CreateStacksets([]zv1.StackSet{foo, bar, baz}))
+
+	require.NoError(t, env.controller.auditIngressConflicts())
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Warning IngressRouteConflict Conflicting ingress routes: foo.example.org is already routed by StackSet default/foo", <-recorder.Events)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	lifecycleHookEventPreDelete = "PreDelete"
+
+	defaultLifecycleHookTimeout = 10 * time.Second
+	maxLifecycleHookTimeout     = 30 * time.Second
+
+	// lifecycleHookBackoff is the delay before a failed lifecycle hook is
+	// called again. It's doubled with every failed call up to
+	// maxLifecycleHookBackoff.
+	lifecycleHookBackoff    = time.Minute
+	maxLifecycleHookBackoff = 30 * time.Minute
+)
+
+// lifecycleHookPayload is the notification POSTed to a lifecycle hook.
+type lifecycleHookPayload struct {
+	Event     string `json:"event"`
+	Namespace string `json:"namespace"`
+	StackSet  string `json:"stackset"`
+	Stack     string `json:"stack"`
+	Version   string `json:"version"`
+}
+
+// callLifecycleHook notifies the hook about the lifecycle event of a stack
+// and waits for the response. Any response other than 2xx is an error.
+//
+// The hook is called from the network of the controller, with the URL
+// defined by the author of the StackSet. Everyone allowed to edit StackSets
+// can therefore make the controller POST the payload to any HTTP endpoint it
+// can reach. Only the http and https schemes are allowed.
+func callLifecycleHook(hook *zv1.LifecycleHook, event string, stack *zv1.Stack) error {
+	timeout := defaultLifecycleHookTimeout
+	if hook.Timeout != nil && hook.Timeout.Duration > 0 {
+		timeout = hook.Timeout.Duration
+	}
+	if timeout > maxLifecycleHookTimeout {
+		timeout = maxLifecycleHookTimeout
+	}
+
+	hookURL, err := url.Parse(hook.URL)
+	if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") {
+		return fmt.Errorf("invalid %s hook URL %q, must be an http or https URL", event, hook.URL)
+	}
+
+	payload, err := json.Marshal(&lifecycleHookPayload{
+		Event:     event,
+		Namespace: stack.Namespace,
+		StackSet:  stack.Labels[core.StacksetHeritageLabelKey],
+		Stack:     stack.Name,
+		Version:   stack.Labels[core.StackVersionLabelKey],
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(hook.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to call %s hook for stack %s: %v", event, stack.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s hook for stack %s failed with status %d", event, stack.Name, resp.StatusCode)
+	}
+	return nil
+}
+
+// lifecycleHookDue returns true if the hook has to be called for a stack
+// with the result of the previous calls. A hook which succeeded isn't called
+// again, a failed one only after a backoff.
+func lifecycleHookDue(status *zv1.LifecycleHookStatus, now time.Time) bool {
+	if status == nil {
+		return true
+	}
+	if status.Succeeded {
+		return false
+	}
+	if status.LastCallTime == nil || status.FailedAttempts == 0 {
+		return true
+	}
+
+	backoff := lifecycleHookBackoff
+	for i := int32(1); i < status.FailedAttempts && backoff < maxLifecycleHookBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxLifecycleHookBackoff {
+		backoff = maxLifecycleHookBackoff
+	}
+	return !now.Before(status.LastCallTime.Add(backoff))
+}
+
+// lifecycleHookResult returns the status of a lifecycle hook after a call
+// which failed with err, or succeeded if err is nil.
+func lifecycleHookResult(previous *zv1.LifecycleHookStatus, err error, now time.Time) *zv1.LifecycleHookStatus {
+	result := &zv1.LifecycleHookStatus{
+		LastCallTime: &metav1.Time{Time: now},
+	}
+	if previous != nil {
+		result.FailedAttempts = previous.FailedAttempts
+	}
+	if err != nil {
+		result.FailedAttempts++
+		result.Message = err.Error()
+	} else {
+		result.Succeeded = true
+	}
+	return result
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCallLifecycleHook(t *testing.T) {
+	stack := &zv1.Stack{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo-v1",
+			Namespace: "default",
+			Labels: map[string]string{
+				core.StacksetHeritageLabelKey: "foo",
+				core.StackVersionLabelKey:     "v1",
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		status        int
+		expectedError bool
+	}{
+		{
+			name:   "successful hook",
+			status: http.StatusOK,
+		},
+		{
+			name:   "successful hook without content",
+			status: http.StatusNoContent,
+		},
+		{
+			name:          "failed hook",
+			status:        http.StatusInternalServerError,
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var received lifecycleHookPayload
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			err := callLifecycleHook(&zv1.LifecycleHook{URL: server.URL}, lifecycleHookEventPreDelete, stack)
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			expected := lifecycleHookPayload{
+				Event:     lifecycleHookEventPreDelete,
+				Namespace: "default",
+				StackSet:  "foo",
+				Stack:     "foo-v1",
+				Version:   "v1",
+			}
+			require.Equal(t, expected, received)
+		})
+	}
+}
+
+func TestCallLifecycleHookInvalidURL(t *testing.T) {
+	stack := &zv1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "foo-v1"}}
+	err := callLifecycleHook(&zv1.LifecycleHook{URL: "file:///etc/passwd"}, lifecycleHookEventPreDelete, stack)
+	require.Error(t, err)
+}
+
+func TestLifecycleHookDue(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name     string
+		status   *zv1.LifecycleHookStatus
+		expected bool
+	}{
+		{
+			name:     "hook wasn't called yet",
+			expected: true,
+		},
+		{
+			name:   "hook succeeded",
+			status: &zv1.LifecycleHookStatus{Succeeded: true, LastCallTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+		},
+		{
+			name:   "failed hook within the backoff",
+			status: &zv1.LifecycleHookStatus{FailedAttempts: 2, LastCallTime: &metav1.Time{Time: now.Add(-time.Minute)}},
+		},
+		{
+			name:     "failed hook after the backoff",
+			status:   &zv1.LifecycleHookStatus{FailedAttempts: 2, LastCallTime: &metav1.Time{Time: now.Add(-2 * time.Minute)}},
+			expected: true,
+		},
+		{
+			name:     "backoff is limited",
+			status:   &zv1.LifecycleHookStatus{FailedAttempts: 20, LastCallTime: &metav1.Time{Time: now.Add(-maxLifecycleHookBackoff)}},
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, lifecycleHookDue(tc.status, now))
+		})
+	}
+}
+
+func TestCleanupOldStacksPreDeleteHook(t *testing.T) {
+	env := NewTestEnvironment()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.StackLifecycle.PreDeleteHook = &zv1.LifecycleHook{URL: server.URL}
+	stack1 := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack2 := testStack("foo-v2", stackset.Namespace, "abc2", stackset)
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+	err = env.CreateStacks([]zv1.Stack{stack1, stack2})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet: &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{
+			stack1.UID: {Stack: &stack1, PendingRemoval: true},
+			stack2.UID: {Stack: &stack2, PendingRemoval: true},
+		},
+	}
+
+	// the hook is called for one stack at a time, the failure is recorded
+	err = env.controller.CleanupOldStacks(container)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+	failed := 0
+	for _, sc := range container.StackContainers {
+		if hookStatus := sc.PreDeleteHookStatus(); hookStatus != nil {
+			require.False(t, hookStatus.Succeeded)
+			require.Equal(t, int32(1), hookStatus.FailedAttempts)
+			failed++
+		}
+	}
+	require.Equal(t, 1, failed)
+
+	// the failed hook isn't called again within the backoff
+	for _, sc := range container.StackContainers {
+		if sc.PreDeleteHookStatus() == nil {
+			sc.SetPreDeleteHookStatus(&zv1.LifecycleHookStatus{Succeeded: true})
+		}
+	}
+	err = env.controller.CleanupOldStacks(container)
+	require.NoError(t, err)
+	require.Equal(t, 1, calls)
+
+	result, err := env.client.ZalandoV1().Stacks(stackset.Namespace).List(metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+}
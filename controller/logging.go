@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LogLevelPathPrefix is the path prefix of the endpoint changing the
+	// log levels of the subsystems.
+	LogLevelPathPrefix = "/log-levels/"
+
+	// SubsystemTraffic logs the traffic switching and prescaling.
+	SubsystemTraffic = "traffic"
+	// SubsystemGC logs the removal of stacks.
+	SubsystemGC = "gc"
+	// SubsystemResources logs the changes of the resources of the
+	// StackSets and stacks.
+	SubsystemResources = "resources"
+	// SubsystemAutoscaling logs the changes of the autoscalers and the
+	// traffic forecasts.
+	SubsystemAutoscaling = "autoscaling"
+)
+
+// Subsystems are the parts of the controller whose log level can be set
+// independently of the global one.
+var Subsystems = []string{SubsystemTraffic, SubsystemGC, SubsystemResources, SubsystemAutoscaling}
+
+// subsystemLoggers are the loggers of the subsystems. They share the output
+// and the format of the standard logger but have their own levels, which
+// default to the level of the standard logger.
+type subsystemLoggers map[string]*log.Logger
+
+func newSubsystemLoggers() subsystemLoggers {
+	std := log.StandardLogger()
+	loggers := make(subsystemLoggers, len(Subsystems))
+	for _, subsystem := range Subsystems {
+		loggers[subsystem] = &log.Logger{
+			Out:       std.Out,
+			Formatter: std.Formatter,
+			Hooks:     std.Hooks,
+			Level:     std.Level,
+		}
+	}
+	return loggers
+}
+
+// SetLogLevel sets the log level of a subsystem. It's safe to call while
+// the controller is running.
+func (c *StackSetController) SetLogLevel(subsystem string, level log.Level) error {
+	logger, ok := c.subsystemLoggers[subsystem]
+	if !ok {
+		return fmt.Errorf("unknown subsystem %q, expected one of %s", subsystem, strings.Join(Subsystems, ", "))
+	}
+	logger.SetLevel(level)
+	return nil
+}
+
+// logLevels returns the names of the log levels of the subsystems.
+func (c *StackSetController) logLevels() map[string]string {
+	levels := make(map[string]string, len(c.subsystemLoggers))
+	for subsystem, logger := range c.subsystemLoggers {
+		levels[subsystem] = log.Level(atomic.LoadUint32((*uint32)(&logger.Level))).String()
+	}
+	return levels
+}
+
+// withSubsystem returns a logger with the fields of the entry which logs at
+// the level of the subsystem.
+func (c *StackSetController) withSubsystem(subsystem string, entry *log.Entry) *log.Entry {
+	return log.NewEntry(c.subsystemLoggers[subsystem]).WithFields(entry.Data).WithField("subsystem", subsystem)
+}
+
+// LogLevelHandler returns an HTTP handler which returns the log levels of
+// the subsystems on GET /log-levels/ and sets the level of a subsystem to
+// the one in the request body on PUT /log-levels/<subsystem>. The requests
+// must carry the token as a bearer token.
+func (c *StackSetController) LogLevelHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validBearerToken(r, token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		subsystem := strings.TrimPrefix(r.URL.Path, LogLevelPathPrefix)
+		switch {
+		case r.Method == http.MethodGet && subsystem == "":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(c.logLevels())
+		case r.Method == http.MethodPut && subsystem != "":
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := log.ParseLevel(strings.TrimSpace(string(body)))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			err = c.SetLogLevel(subsystem, level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			c.logger.Infof("Set the log level of the %s subsystem to %s", subsystem, level)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut}, ", "))
+			http.Error(w, "expected GET "+LogLevelPathPrefix+" or PUT "+LogLevelPathPrefix+"<subsystem>", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	env := NewTestEnvironment()
+
+	err := env.controller.SetLogLevel(SubsystemTraffic, log.DebugLevel)
+	require.NoError(t, err)
+	require.Equal(t, "debug", env.controller.logLevels()[SubsystemTraffic])
+
+	err = env.controller.SetLogLevel("foo", log.DebugLevel)
+	require.Error(t, err)
+
+	entry := env.controller.withSubsystem(SubsystemTraffic, env.controller.objectLogger("default", "stackset", "foo"))
+	require.Equal(t, "foo", entry.Data["stackset"])
+	require.Equal(t, SubsystemTraffic, entry.Data["subsystem"])
+	require.Equal(t, log.DebugLevel, entry.Logger.Level)
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		authorization string
+		expected      int
+		expectedLevel string
+	}{
+		{
+			name:          "the levels are returned",
+			method:        http.MethodGet,
+			path:          "/log-levels/",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+			expectedLevel: "info",
+		},
+		{
+			name:          "the level of a subsystem is set",
+			method:        http.MethodPut,
+			path:          "/log-levels/traffic",
+			body:          "debug\n",
+			authorization: "Bearer secret",
+			expected:      http.StatusNoContent,
+			expectedLevel: "debug",
+		},
+		{
+			name:          "invalid levels are rejected",
+			method:        http.MethodPut,
+			path:          "/log-levels/traffic",
+			body:          "verbose",
+			authorization: "Bearer secret",
+			expected:      http.StatusBadRequest,
+			expectedLevel: "info",
+		},
+		{
+			name:          "unknown subsystems aren't found",
+			method:        http.MethodPut,
+			path:          "/log-levels/foo",
+			body:          "debug",
+			authorization: "Bearer secret",
+			expected:      http.StatusNotFound,
+			expectedLevel: "info",
+		},
+		{
+			name:          "requests with a wrong token are rejected",
+			method:        http.MethodPut,
+			path:          "/log-levels/traffic",
+			body:          "debug",
+			authorization: "Bearer wrong",
+			expected:      http.StatusUnauthorized,
+			expectedLevel: "info",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+			err := env.controller.SetLogLevel(SubsystemTraffic, log.InfoLevel)
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			request.Header.Set("Authorization", tc.authorization)
+			recorder := httptest.NewRecorder()
+			env.controller.LogLevelHandler("secret").ServeHTTP(recorder, request)
+			require.Equal(t, tc.expected, recorder.Code)
+			require.Equal(t, tc.expectedLevel, env.controller.logLevels()[SubsystemTraffic])
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+)
+
+// cronSchedule is a parsed cron expression with the standard five fields:
+// minute, hour, day of month, month and day of week.
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	// restricted day fields are combined with OR like in cron
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// cronField defines the range of values of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// maintenanceWindowActive returns true if the given time is within one of
+// the maintenance windows. Invalid windows are skipped and reported as
+// error.
+func maintenanceWindowActive(windows []zv1.MaintenanceWindow, now time.Time) (bool, error) {
+	var (
+		active  bool
+		invalid []string
+	)
+
+	for _, window := range windows {
+		windowActive, err := maintenanceWindowActiveAt(window, now)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", window.Name, err))
+			continue
+		}
+		active = active || windowActive
+	}
+
+	if len(invalid) > 0 {
+		return active, fmt.Errorf("invalid maintenance windows: %s", strings.Join(invalid, ", "))
+	}
+	return active, nil
+}
+
+// maintenanceWindowActiveAt checks whether the window was started by its
+// schedule within its duration before the given time.
+func maintenanceWindowActiveAt(window zv1.MaintenanceWindow, now time.Time) (bool, error) {
+	location := time.UTC
+	if window.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(window.TimeZone)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	schedule, err := parseCronSchedule(window.Schedule)
+	if err != nil {
+		return false, err
+	}
+
+	local := now.In(location).Truncate(time.Minute)
+	for start := local; now.Sub(start) < window.Duration.Duration; start = start.Add(-time.Minute) {
+		if schedule.matches(start) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseCronSchedule parses a cron expression, e.g. "0 18 * * Fri".
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("schedule must have %d fields", len(cronFields))
+	}
+
+	values := make([]map[int]bool, len(cronFields))
+	for i, field := range cronFields {
+		value := fields[i]
+		if field.name == "day of week" {
+			value = replaceWeekdayNames(value)
+		}
+
+		parsed, err := parseCronField(value, field)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = parsed
+	}
+
+	// Sunday can be specified as 7 as well
+	if values[4][7] {
+		values[4][0] = true
+	}
+
+	return &cronSchedule{
+		minutes:       values[0],
+		hours:         values[1],
+		daysOfMonth:   values[2],
+		months:        values[3],
+		daysOfWeek:    values[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges (1-5) and
+// steps (*/15, 0-30/10).
+func parseCronField(value string, field cronField) (map[int]bool, error) {
+	max := field.max
+	if field.name == "day of week" {
+		max = 7
+	}
+
+	result := make(map[int]bool)
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %s %q", field.name, part)
+			}
+			part = part[:i]
+		}
+
+		from, to := field.min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", field.name, part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s %q", field.name, part)
+				}
+			}
+		}
+		if from < field.min || to > max || from > to {
+			return nil, fmt.Errorf("%s %q out of range", field.name, part)
+		}
+
+		for v := from; v <= to; v += step {
+			result[v] = true
+		}
+	}
+	return result, nil
+}
+
+// replaceWeekdayNames replaces abbreviated week days (e.g. Mon, Tue) with
+// their numbers.
+func replaceWeekdayNames(value string) string {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := weekday.String()[:3]
+		number := strconv.Itoa(int(weekday))
+		value = strings.Replace(value, name, number, -1)
+		value = strings.Replace(value, strings.ToLower(name), number, -1)
+		value = strings.Replace(value, strings.ToUpper(name), number, -1)
+	}
+	return value
+}
+
+// matches returns true if the schedule matches the minute of the given time.
+func (s *cronSchedule) matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonth := s.daysOfMonth[t.Day()]
+	dayOfWeek := s.daysOfWeek[int(t.Weekday())]
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMaintenanceWindowActive(t *testing.T) {
+	// Friday
+	now := time.Date(2019, time.March, 8, 18, 30, 0, 0, time.UTC)
+
+	weekend := zv1.MaintenanceWindow{
+		Name:     "weekend",
+		Schedule: "0 18 * * Fri",
+		Duration: metav1.Duration{Duration: 62 * time.Hour},
+	}
+
+	for _, tc := range []struct {
+		name           string
+		windows        []zv1.MaintenanceWindow
+		now            time.Time
+		expectedActive bool
+		expectedErr    string
+	}{
+		{
+			name: "no windows",
+			now:  now,
+		},
+		{
+			name:           "within the window",
+			windows:        []zv1.MaintenanceWindow{weekend},
+			now:            now,
+			expectedActive: true,
+		},
+		{
+			name:           "window lasting past midnight",
+			windows:        []zv1.MaintenanceWindow{weekend},
+			now:            now.Add(48 * time.Hour),
+			expectedActive: true,
+		},
+		{
+			name:    "before the window",
+			windows: []zv1.MaintenanceWindow{weekend},
+			now:     now.Add(-time.Hour),
+		},
+		{
+			name:    "after the window",
+			windows: []zv1.MaintenanceWindow{weekend},
+			now:     now.Add(62 * time.Hour),
+		},
+		{
+			name: "lists, ranges and steps",
+			windows: []zv1.MaintenanceWindow{
+				{Name: "releases", Schedule: "*/15 9-17 * * 1-5", Duration: metav1.Duration{Duration: 5 * time.Minute}},
+			},
+			now:            time.Date(2019, time.March, 8, 9, 32, 0, 0, time.UTC),
+			expectedActive: true,
+		},
+		{
+			name: "day of month and day of week are combined",
+			windows: []zv1.MaintenanceWindow{
+				{Name: "monthly", Schedule: "0 0 1 * Sun", Duration: metav1.Duration{Duration: 24 * time.Hour}},
+			},
+			now:            time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC),
+			expectedActive: true,
+		},
+		{
+			name: "time zone of the window is respected",
+			windows: []zv1.MaintenanceWindow{
+				{Name: "evening", Schedule: "0 19 * * *", TimeZone: "Europe/Berlin", Duration: metav1.Duration{Duration: time.Hour}},
+			},
+			now:            now,
+			expectedActive: true,
+		},
+		{
+			name: "invalid windows are skipped",
+			windows: []zv1.MaintenanceWindow{
+				weekend,
+				{Name: "broken", Schedule: "0 18 * *", Duration: metav1.Duration{Duration: time.Hour}},
+				{Name: "late", Schedule: "0 25 * * *", Duration: metav1.Duration{Duration: time.Hour}},
+			},
+			now:            now,
+			expectedActive: true,
+			expectedErr:    `invalid maintenance windows: broken: schedule must have 5 fields, late: hour "25" out of range`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			active, err := maintenanceWindowActive(tc.windows, tc.now)
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expectedActive, active)
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	stackTrafficLabels = []string{"namespace", "stackset", "stack"}
+
+	stackActualTrafficWeightDesc = prometheus.NewDesc(
+		"stackset_stack_traffic_actual_weight",
+		"Percentage of the traffic of the StackSet currently routed to the stack.",
+		stackTrafficLabels,
+		nil,
+	)
+	stackDesiredTrafficWeightDesc = prometheus.NewDesc(
+		"stackset_stack_traffic_desired_weight",
+		"Percentage of the traffic of the StackSet which should be routed to the stack.",
+		stackTrafficLabels,
+		nil,
+	)
+)
+
+// stackTrafficWeights are the traffic weights of a stack as of the last
+// reconciliation of its StackSet.
+type stackTrafficWeights struct {
+	namespace string
+	stackset  string
+	stack     string
+	actual    float64
+	desired   float64
+}
+
+// trafficMetrics exposes the traffic weights of the stacks as Prometheus
+// metrics. The weights are recorded per StackSet, so that the metrics of
+// deleted stacks and StackSets disappear instead of keeping their last value.
+type trafficMetrics struct {
+	weights map[types.UID][]stackTrafficWeights
+	sync.Mutex
+}
+
+func newTrafficMetrics() *trafficMetrics {
+	return &trafficMetrics{
+		weights: make(map[types.UID][]stackTrafficWeights),
+	}
+}
+
+// update records the traffic weights of the stacks of a StackSet, replacing
+// the previously recorded ones.
+func (m *trafficMetrics) update(ssc *core.StackSetContainer) {
+	weights := make([]stackTrafficWeights, 0, len(ssc.StackContainers))
+	for _, sc := range ssc.StackContainers {
+		weights = append(weights, stackTrafficWeights{
+			namespace: ssc.StackSet.Namespace,
+			stackset:  ssc.StackSet.Name,
+			stack:     sc.Name(),
+			actual:    sc.ActualTrafficWeight(),
+			desired:   sc.DesiredTrafficWeight(),
+		})
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.weights[ssc.StackSet.UID] = weights
+}
+
+// prune forgets the traffic weights of the StackSets which don't exist
+// anymore.
+func (m *trafficMetrics) prune(stackContainers map[types.UID]*core.StackSetContainer) {
+	m.Lock()
+	defer m.Unlock()
+	for uid := range m.weights {
+		if _, ok := stackContainers[uid]; !ok {
+			delete(m.weights, uid)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *trafficMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stackActualTrafficWeightDesc
+	ch <- stackDesiredTrafficWeightDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *trafficMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Lock()
+	defer m.Unlock()
+	for _, weights := range m.weights {
+		for _, stack := range weights {
+			ch <- prometheus.MustNewConstMetric(stackActualTrafficWeightDesc, prometheus.GaugeValue, stack.actual, stack.namespace, stack.stackset, stack.stack)
+			ch <- prometheus.MustNewConstMetric(stackDesiredTrafficWeightDesc, prometheus.GaugeValue, stack.desired, stack.namespace, stack.stackset, stack.stack)
+		}
+	}
+}
+
+var (
+	stacksPendingRemovalDesc = prometheus.NewDesc(
+		"stackset_stacks_pending_removal",
+		"Number of stacks of the StackSet pending removal.",
+		[]string{"namespace", "stackset"},
+		nil,
+	)
+	stackPendingRemovalSecondsDesc = prometheus.NewDesc(
+		"stackset_stack_pending_removal_seconds",
+		"Time since the stack is pending removal.",
+		stackTrafficLabels,
+		nil,
+	)
+)
+
+// stacksetPendingRemoval are the stacks of a StackSet pending removal as of
+// its last reconciliation.
+type stacksetPendingRemoval struct {
+	namespace string
+	stackset  string
+	stacks    []zv1.PendingStackRemoval
+}
+
+// removalMetrics exposes the stacks pending removal as Prometheus metrics,
+// so that stacks whose removal is stuck, e.g. because of a failing
+// pre-deletion hook or finalizer, are noticed. Like the traffic weights they
+// are recorded per StackSet.
+type removalMetrics struct {
+	pending map[types.UID]stacksetPendingRemoval
+	now     func() time.Time
+	sync.Mutex
+}
+
+func newRemovalMetrics() *removalMetrics {
+	return &removalMetrics{
+		pending: make(map[types.UID]stacksetPendingRemoval),
+		now:     time.Now,
+	}
+}
+
+// update records the stacks of a StackSet pending removal, replacing the
+// previously recorded ones.
+func (m *removalMetrics) update(ssc *core.StackSetContainer) {
+	m.Lock()
+	defer m.Unlock()
+	m.pending[ssc.StackSet.UID] = stacksetPendingRemoval{
+		namespace: ssc.StackSet.Namespace,
+		stackset:  ssc.StackSet.Name,
+		stacks:    ssc.PendingRemoval(),
+	}
+}
+
+// prune forgets the stacks pending removal of the StackSets which don't
+// exist anymore.
+func (m *removalMetrics) prune(stackContainers map[types.UID]*core.StackSetContainer) {
+	m.Lock()
+	defer m.Unlock()
+	for uid := range m.pending {
+		if _, ok := stackContainers[uid]; !ok {
+			delete(m.pending, uid)
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *removalMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stacksPendingRemovalDesc
+	ch <- stackPendingRemovalSecondsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *removalMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.Lock()
+	defer m.Unlock()
+	now := m.now()
+	for _, stackset := range m.pending {
+		ch <- prometheus.MustNewConstMetric(stacksPendingRemovalDesc, prometheus.GaugeValue, float64(len(stackset.stacks)), stackset.namespace, stackset.stackset)
+		for _, stack := range stackset.stacks {
+			ch <- prometheus.MustNewConstMetric(stackPendingRemovalSecondsDesc, prometheus.GaugeValue, now.Sub(stack.Since.Time).Seconds(), stackset.namespace, stackset.stackset, stack.Name)
+		}
+	}
+}
+
+const (
+	prescalingResultCompleted = "completed"
+	prescalingResultTimedOut  = "timed_out"
+)
+
+// prescalingMetrics exposes the outcome of the prescaling of the stacks, to
+// validate the prescaling settings. A prescaling is recorded when it
+// completes, i.e. the traffic was switched to the stack, or when it times
+// out. A prescaling which times out and completes afterwards is recorded
+// with both results.
+type prescalingMetrics struct {
+	operations        *prometheus.CounterVec
+	duration          *prometheus.HistogramVec
+	requestedReplicas *prometheus.CounterVec
+	readyReplicas     *prometheus.CounterVec
+}
+
+func newPrescalingMetrics() *prescalingMetrics {
+	labels := []string{"namespace", "stackset", "result"}
+	return &prescalingMetrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stackset_prescaling_total",
+			Help: "Number of prescalings of stacks by result, completed or timed_out.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "stackset_prescaling_duration_seconds",
+			Help:    "Time from the start of the prescaling of a stack until it completed or timed out.",
+			Buckets: []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 3600},
+		}, labels),
+		requestedReplicas: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stackset_prescaling_requested_replicas_total",
+			Help: "Number of replicas requested by the prescaling of stacks.",
+		}, labels),
+		readyReplicas: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "stackset_prescaling_ready_replicas_total",
+			Help: "Number of replicas ready when the prescaling of stacks completed or timed out.",
+		}, labels),
+	}
+}
+
+// observe records the prescaling of a stack of the StackSet if it completed
+// or timed out since the previous status.
+func (m *prescalingMetrics) observe(stackset *zv1.StackSet, previous, current zv1.PrescalingStatus, now time.Time) {
+	var result string
+	switch {
+	case current.Phase == previous.Phase:
+		return
+	case current.Phase == zv1.PrescalingPhaseCompleted:
+		result = prescalingResultCompleted
+	case current.Phase == zv1.PrescalingPhaseTimedOut:
+		result = prescalingResultTimedOut
+	default:
+		return
+	}
+
+	labels := []string{stackset.Namespace, stackset.Name, result}
+	m.operations.WithLabelValues(labels...).Inc()
+	if current.StartTime != nil {
+		end := now
+		if current.CompletionTime != nil {
+			end = current.CompletionTime.Time
+		}
+		m.duration.WithLabelValues(labels...).Observe(end.Sub(current.StartTime.Time).Seconds())
+	}
+	m.requestedReplicas.WithLabelValues(labels...).Add(float64(current.Replicas))
+	m.readyReplicas.WithLabelValues(labels...).Add(float64(current.ReadyReplicas))
+}
+
+// Describe implements prometheus.Collector.
+func (m *prescalingMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.operations.Describe(ch)
+	m.duration.Describe(ch)
+	m.requestedReplicas.Describe(ch)
+	m.readyReplicas.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *prescalingMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.operations.Collect(ch)
+	m.duration.Collect(ch)
+	m.requestedReplicas.Collect(ch)
+	m.readyReplicas.Collect(ch)
+}
+
+// RegisterMetrics registers the metrics of the controller, i.e. the traffic
+// weights of the stacks, the stacks pending removal and the outcome of the
+// prescaling, with the registerer.
+func (c *StackSetController) RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{c.trafficMetrics, c.removalMetrics, c.prescalingMetrics} {
+		err := registerer.Register(collector)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
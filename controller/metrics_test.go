@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestTrafficMetrics(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+	err = env.CreateStacks([]zv1.Stack{
+		testStack("foo-v1", "default", "abc", stackset),
+		testStack("foo-v2", "default", "def", stackset),
+	})
+	require.NoError(t, err)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+	err = containers[stackset.UID].UpdateFromResources()
+	require.NoError(t, err)
+
+	metrics := newTrafficMetrics()
+	metrics.update(containers[stackset.UID])
+	require.Len(t, metrics.weights[stackset.UID], 2)
+	require.Equal(t, "foo", metrics.weights[stackset.UID][0].stackset)
+
+	// an actual and a desired weight per stack
+	collected := make(chan prometheus.Metric, 10)
+	metrics.Collect(collected)
+	require.Len(t, collected, 4)
+
+	// the weights of deleted stacksets are dropped
+	metrics.prune(map[types.UID]*core.StackSetContainer{})
+	require.Empty(t, metrics.weights)
+}
+
+func TestPrescalingMetrics(t *testing.T) {
+	stackset := testStackset("foo", "default", "123")
+	start := metav1.NewTime(time.Now().Add(-5 * time.Minute))
+
+	waiting := zv1.PrescalingStatus{Active: true, Replicas: 10, ReadyReplicas: 8, Phase: zv1.PrescalingPhaseWaiting, StartTime: &start}
+	timedOut := waiting
+	timedOut.Phase = zv1.PrescalingPhaseTimedOut
+
+	collected := func(m *prescalingMetrics) int {
+		ch := make(chan prometheus.Metric, 10)
+		m.Collect(ch)
+		return len(ch)
+	}
+
+	metrics := newPrescalingMetrics()
+
+	// ongoing prescalings aren't recorded
+	metrics.observe(&stackset, zv1.PrescalingStatus{}, waiting, time.Now())
+	require.Equal(t, 0, collected(metrics))
+
+	// a prescaling which timed out is recorded once
+	metrics.observe(&stackset, waiting, timedOut, time.Now())
+	require.Equal(t, 4, collected(metrics))
+	metrics.observe(&stackset, timedOut, timedOut, time.Now())
+	require.Equal(t, 4, collected(metrics))
+
+	// and again once it completes
+	completion := metav1.Now()
+	completed := timedOut
+	completed.Phase = zv1.PrescalingPhaseCompleted
+	completed.CompletionTime = &completion
+	metrics.observe(&stackset, timedOut, completed, time.Now())
+	require.Equal(t, 8, collected(metrics))
+}
+
+func TestRemovalMetrics(t *testing.T) {
+	now := time.Now()
+	stackset := testStackset("foo", "default", "123")
+	stackset.Status.PendingRemoval = []zv1.PendingStackRemoval{
+		{Name: "foo-v1", Since: metav1.Time{Time: now.Add(-time.Hour)}},
+		{Name: "foo-v2", Since: metav1.Time{Time: now.Add(-time.Minute)}},
+	}
+	container := &core.StackSetContainer{StackSet: &stackset}
+	err := container.UpdateFromResources()
+	require.NoError(t, err)
+
+	metrics := newRemovalMetrics()
+	metrics.now = func() time.Time { return now }
+	metrics.update(container)
+	require.Len(t, metrics.pending[stackset.UID].stacks, 2)
+
+	// the number of stacks and the time since each of them is pending
+	collected := make(chan prometheus.Metric, 10)
+	metrics.Collect(collected)
+	require.Len(t, collected, 3)
+
+	metrics.prune(map[types.UID]*core.StackSetContainer{})
+	require.Empty(t, metrics.pending)
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// strategicMergePatch returns the strategic merge patch which changes the
+// existing object into the updated one, or nil if they don't differ.
+// Patching only the changed fields instead of updating the whole object
+// doesn't conflict with concurrent changes of other fields, e.g. by the HPA
+// or other controllers. dataStruct must be of the type of the objects.
+func strategicMergePatch(existing, updated, dataStruct interface{}) ([]byte, error) {
+	original, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := strategicpatch.CreateTwoWayMergePatch(original, modified, dataStruct)
+	if err != nil {
+		return nil, err
+	}
+	if string(patch) == "{}" {
+		return nil, nil
+	}
+	return patch, nil
+}
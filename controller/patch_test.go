@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStrategicMergePatch(t *testing.T) {
+	replicas := int32(3)
+	existing := &apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "foo",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": "foo"},
+		},
+		Spec: apps.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	// unchanged objects aren't patched
+	patch, err := strategicMergePatch(existing, existing.DeepCopy(), apps.Deployment{})
+	require.NoError(t, err)
+	require.Nil(t, patch)
+
+	// only the changed fields are patched
+	updated := existing.DeepCopy()
+	updated.Labels["version"] = "v2"
+	updatedReplicas := int32(5)
+	updated.Spec.Replicas = &updatedReplicas
+
+	patch, err = strategicMergePatch(existing, updated, apps.Deployment{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"metadata":{"labels":{"version":"v2"}},"spec":{"replicas":5}}`, string(patch))
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofPathPrefix is the path prefix of the runtime profiling endpoints.
+const PprofPathPrefix = "/debug/pprof/"
+
+// PprofHandler returns an HTTP handler serving the runtime profiles of the
+// controller, e.g. CPU profiles on /debug/pprof/profile?seconds=30 and heap
+// profiles on /debug/pprof/heap, in the format of `go tool pprof`. The
+// requests must carry the token as a bearer token.
+//
+// Importing net/http/pprof registers the same endpoints on
+// http.DefaultServeMux without authentication, so it must not be used to
+// serve the controller's endpoints.
+func PprofHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PprofPathPrefix, pprof.Index)
+	mux.HandleFunc(PprofPathPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPathPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPathPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPathPrefix+"trace", pprof.Trace)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validBearerToken(r, token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPprofHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{
+			name:          "the profiles are listed with a token",
+			path:          "/debug/pprof/",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "a profile is returned with a token",
+			path:          "/debug/pprof/heap",
+			authorization: "Bearer secret",
+			expected:      http.StatusOK,
+		},
+		{
+			name:          "requests with a wrong token are rejected",
+			path:          "/debug/pprof/heap",
+			authorization: "Bearer wrong",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:     "requests without a token are rejected",
+			path:     "/debug/pprof/heap",
+			expected: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			request.Header.Set("Authorization", tc.authorization)
+			recorder := httptest.NewRecorder()
+			PprofHandler("secret").ServeHTTP(recorder, request)
+			require.Equal(t, tc.expected, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// managedByAnnotationKey is set to the ID of the controller instance
+	// which last created or updated a resource, or to defaultManagedBy if
+	// it has no ID.
+	managedByAnnotationKey = "stackset-controller.zalando.org/managed-by"
+	// controllerVersionAnnotationKey is set to the version of the
+	// controller which last created or updated a resource.
+	controllerVersionAnnotationKey = "stackset-controller.zalando.org/controller-version"
+	// lastReconcileTimeAnnotationKey is set to the time a resource was last
+	// created or updated by the controller.
+	lastReconcileTimeAnnotationKey = "stackset-controller.zalando.org/last-reconcile-time"
+
+	defaultManagedBy = "stackset-controller"
+)
+
+// provenanceAnnotationKeys are the annotations recording which controller
+// instance last wrote a resource. They change on every write and are
+// ignored when checking whether a resource is up to date.
+var provenanceAnnotationKeys = []string{
+	managedByAnnotationKey,
+	controllerVersionAnnotationKey,
+	lastReconcileTimeAnnotationKey,
+}
+
+// ConfigureVersion sets the version of the controller recorded on the
+// resources it creates or updates. It must be called before Run.
+func (c *StackSetController) ConfigureVersion(version string) {
+	c.version = version
+}
+
+// stampProvenance records the controller instance, its version and the
+// current time in the annotations of a resource about to be written.
+func (c *StackSetController) stampProvenance(obj metav1.Object) {
+	managedBy := c.controllerID
+	if managedBy == "" {
+		managedBy = defaultManagedBy
+	}
+
+	annotations := make(map[string]string, len(obj.GetAnnotations())+len(provenanceAnnotationKeys))
+	for key, value := range obj.GetAnnotations() {
+		annotations[key] = value
+	}
+	annotations[managedByAnnotationKey] = managedBy
+	annotations[controllerVersionAnnotationKey] = c.version
+	annotations[lastReconcileTimeAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	obj.SetAnnotations(annotations)
+}
+
+// withoutProvenance returns the annotations without the ones recording the
+// controller instance which last wrote the resource, or nil if no others are
+// set.
+func withoutProvenance(annotations map[string]string) map[string]string {
+	result := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		result[key] = value
+	}
+	for _, key := range provenanceAnnotationKeys {
+		delete(result, key)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStampProvenance(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.ConfigureVersion("v1.2.3")
+
+	meta := metav1.ObjectMeta{Annotations: map[string]string{"foo": "bar"}}
+	env.controller.stampProvenance(&meta)
+	require.Equal(t, "bar", meta.Annotations["foo"])
+	require.Equal(t, defaultManagedBy, meta.Annotations[managedByAnnotationKey])
+	require.Equal(t, "v1.2.3", meta.Annotations[controllerVersionAnnotationKey])
+	_, err := time.Parse(time.RFC3339, meta.Annotations[lastReconcileTimeAnnotationKey])
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"foo": "bar"}, withoutProvenance(meta.Annotations))
+	delete(meta.Annotations, "foo")
+	require.Nil(t, withoutProvenance(meta.Annotations))
+
+	env.controller.controllerID = "cluster-a"
+	env.controller.stampProvenance(&meta)
+	require.Equal(t, "cluster-a", meta.Annotations[managedByAnnotationKey])
+}
+
+func TestStackSetIngressProvenanceDoesntTriggerUpdates(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	generate := func() (*extensions.Ingress, error) {
+		return &extensions.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		}, nil
+	}
+	err = env.controller.ReconcileStackSetIngress(&stackset, nil, generate)
+	require.NoError(t, err)
+
+	created, err := env.client.ExtensionsV1beta1().Ingresses("default").Get("foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, created.Annotations, lastReconcileTimeAnnotationKey)
+
+	err = env.controller.ReconcileStackSetIngress(&stackset, created, generate)
+	require.NoError(t, err)
+
+	current, err := env.client.ExtensionsV1beta1().Ingresses("default").Get("foo", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, created, current)
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxUnreportedFailures is the number of failed reconciliations of a
+// StackSet which are kept until they're recorded in its status.
+const maxUnreportedFailures = 10
+
+// reconcileFailure is a reconciliation of a StackSet which was aborted with
+// an error before its status was updated.
+type reconcileFailure struct {
+	err  error
+	time time.Time
+}
+
+// recordStackError records an error of the reconciliation of a Stack in the
+// status of its StackSet.
+func recordStackError(container *core.StackSetContainer, sc *core.StackContainer, err error) {
+	container.RecordError(fmt.Errorf("stack %s: %v", sc.Name(), err), time.Now())
+}
+
+// unreportedFailure keeps an aborted reconciliation of a StackSet until the
+// status of the StackSet is updated by a later one.
+func (c *StackSetController) unreportedFailure(uid types.UID, err error, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	failures := append(c.unreportedFailures[uid], reconcileFailure{err: err, time: now})
+	if len(failures) > maxUnreportedFailures {
+		failures = failures[len(failures)-maxUnreportedFailures:]
+	}
+	c.unreportedFailures[uid] = failures
+}
+
+// recordUnreportedFailures records the aborted reconciliations of a StackSet
+// in its status. They're kept until the status was updated successfully.
+func (c *StackSetController) recordUnreportedFailures(container *core.StackSetContainer) {
+	c.Lock()
+	failures := c.unreportedFailures[container.StackSet.UID]
+	c.Unlock()
+
+	for _, failure := range failures {
+		container.RecordError(failure.err, failure.time)
+	}
+}
+
+// failuresReported forgets the aborted reconciliations of a StackSet once
+// they were recorded in its status.
+func (c *StackSetController) failuresReported(uid types.UID) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.unreportedFailures, uid)
+}
+
+// pruneUnreportedFailures forgets the aborted reconciliations of the
+// StackSets which don't exist anymore.
+func (c *StackSetController) pruneUnreportedFailures(stackContainers map[types.UID]*core.StackSetContainer) {
+	c.Lock()
+	defer c.Unlock()
+	for uid := range c.unreportedFailures {
+		if _, ok := stackContainers[uid]; !ok {
+			delete(c.unreportedFailures, uid)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// ReconcilePathPrefix is the path prefix of the endpoint triggering
+	// the reconciliation of a StackSet.
+	ReconcilePathPrefix = "/reconcile/"
+
+	// maxPendingReconcileRequests is the number of requested
+	// reconciliations which can be queued before further requests are
+	// rejected.
+	maxPendingReconcileRequests = 100
+)
+
+// ReconcileHandler returns an HTTP handler which triggers the immediate
+// reconciliation of a StackSet on POST /reconcile/<namespace>/<name>, e.g.
+// right after a deployment pipeline applied a new version. The requests must
+// carry the token as a bearer token. The reconciliation is only queued, the
+// handler doesn't wait for it.
+func (c *StackSetController) ReconcileHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !validBearerToken(r, token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, ReconcilePathPrefix), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected "+ReconcilePathPrefix+"<namespace>/<name>", http.StatusNotFound)
+			return
+		}
+
+		select {
+		case c.reconcileRequests <- types.NamespacedName{Namespace: parts[0], Name: parts[1]}:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "too many pending reconciliations", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// validBearerToken returns true if the request is authenticated with the
+// token. Requests are never authenticated with an empty token.
+func validBearerToken(r *http.Request, token string) bool {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(token)) == 1
+}
+
+// reconcileRequested reconciles a single StackSet right away, resetting its
+// backoff. Requests for StackSets which aren't managed by the controller are
+// ignored.
+func (c *StackSetController) reconcileRequested(name types.NamespacedName) error {
+	var uid types.UID
+	for id, stackset := range c.stacksetStore {
+		if stackset.Namespace == name.Namespace && stackset.Name == name.Name {
+			uid = id
+			break
+		}
+	}
+	if uid == "" {
+		c.objectLogger(name.Namespace, "stackset", name.Name).Infof("Ignoring the requested reconciliation of the unknown StackSet %s", name)
+		return nil
+	}
+
+	stackContainers, err := c.collectResources()
+	if err != nil {
+		return err
+	}
+	container, ok := stackContainers[uid]
+	if !ok {
+		return nil
+	}
+
+	c.resetBackoff(uid)
+	return c.reconcileStackSets(map[types.UID]*core.StackSetContainer{uid: container})
+}
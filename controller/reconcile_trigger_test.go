@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReconcileHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		token         string
+		expected      int
+	}{
+		{
+			name:          "reconciliations are requested with a token",
+			method:        http.MethodPost,
+			path:          "/reconcile/default/foo",
+			authorization: "Bearer secret",
+			token:         "secret",
+			expected:      http.StatusAccepted,
+		},
+		{
+			name:          "only POST is allowed",
+			method:        http.MethodGet,
+			path:          "/reconcile/default/foo",
+			authorization: "Bearer secret",
+			token:         "secret",
+			expected:      http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "requests without a token are rejected",
+			method:   http.MethodPost,
+			path:     "/reconcile/default/foo",
+			token:    "secret",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:          "requests with a wrong token are rejected",
+			method:        http.MethodPost,
+			path:          "/reconcile/default/foo",
+			authorization: "Bearer wrong",
+			token:         "secret",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "requests are rejected without a configured token",
+			method:        http.MethodPost,
+			path:          "/reconcile/default/foo",
+			authorization: "Bearer ",
+			expected:      http.StatusUnauthorized,
+		},
+		{
+			name:          "the namespace and name are required",
+			method:        http.MethodPost,
+			path:          "/reconcile/foo",
+			authorization: "Bearer secret",
+			token:         "secret",
+			expected:      http.StatusNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			env.controller.ReconcileHandler(tc.token).ServeHTTP(recorder, request)
+			require.Equal(t, tc.expected, recorder.Code)
+
+			if tc.expected == http.StatusAccepted {
+				require.Len(t, env.controller.reconcileRequests, 1)
+				require.Equal(t, types.NamespacedName{Namespace: "default", Name: "foo"}, <-env.controller.reconcileRequests)
+			} else {
+				require.Empty(t, env.controller.reconcileRequests)
+			}
+		})
+	}
+}
+
+func TestReconcileRequested(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.StackTemplate.Spec.Version = "v1"
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	// unknown stacksets are ignored
+	err = env.controller.reconcileRequested(types.NamespacedName{Namespace: "default", Name: "bar"})
+	require.NoError(t, err)
+
+	// the requested stackset is reconciled even if it's backing off
+	env.controller.reconcileFailed(stackset.UID, time.Now())
+	err = env.controller.reconcileRequested(types.NamespacedName{Namespace: "default", Name: "foo"})
+	require.NoError(t, err)
+	require.False(t, env.controller.backoffActive(stackset.UID, time.Now()))
+
+	_, err = env.client.ZalandoV1().Stacks(stackset.Namespace).Get("foo-v1", metav1.GetOptions{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/clientset"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kube_record "k8s.io/client-go/tools/record"
+)
+
+const (
+	customMetricsRequestsPerSecondPath = "/apis/custom.metrics.k8s.io/v1beta1/namespaces/%s/ingresses.extensions/%s/requests-per-second,%s"
+)
+
+// metricValueList is the subset of the custom metrics API MetricValueList
+// needed to read the value of an object metric.
+type metricValueList struct {
+	Items []struct {
+		Value resource.Quantity `json:"value"`
+	} `json:"items"`
+}
+
+// customMetricsRequestsPerSecond reads the requests per second of a stack
+// from the custom metrics API. The metric is the same one used by the
+// `Ingress` autoscaler metric, i.e. it's served by the kube-metrics-adapter
+// for the StackSet ingress with the stack as backend. Failures are logged and
+// recorded as events of the StackSet, because the prescaling falls back to the
+// replicas of the stacks instead of failing.
+type customMetricsRequestsPerSecond struct {
+	client   clientset.Interface
+	recorder kube_record.EventRecorder
+	logger   *log.Entry
+	stackset *zv1.StackSet
+}
+
+func (p *customMetricsRequestsPerSecond) StackRequestsPerSecond(namespace, stacksetName, stackName string) (float64, error) {
+	rps, err := p.requestsPerSecond(namespace, stacksetName, stackName)
+	if err != nil {
+		p.logger.Warnf("Prescaling based on the replicas instead of the requests per second: %v", err)
+		p.recorder.Eventf(
+			p.stackset,
+			apiv1.EventTypeWarning,
+			"FailedGetRequestsPerSecond",
+			"Prescaling based on the replicas instead of the requests per second: %v", err)
+	}
+	return rps, err
+}
+
+func (p *customMetricsRequestsPerSecond) requestsPerSecond(namespace, stacksetName, stackName string) (float64, error) {
+	data, err := p.client.Discovery().RESTClient().Get().
+		AbsPath(fmt.Sprintf(customMetricsRequestsPerSecondPath, namespace, stacksetName, stackName)).
+		DoRaw()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get requests per second for stack %s/%s: %v", namespace, stackName, err)
+	}
+	return parseRequestsPerSecond(data)
+}
+
+// parseRequestsPerSecond returns the sum of the values of a MetricValueList.
+func parseRequestsPerSecond(data []byte) (float64, error) {
+	var metrics metricValueList
+	err := json.Unmarshal(data, &metrics)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse metric values: %v", err)
+	}
+
+	total := 0.0
+	for _, item := range metrics.Items {
+		total += float64(item.Value.MilliValue()) / 1000
+	}
+	return total, nil
+}
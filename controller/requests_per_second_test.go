@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestsPerSecond(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected float64
+		err      bool
+	}{
+		{
+			name:     "single value",
+			data:     `{"kind":"MetricValueList","items":[{"metricName":"requests-per-second,my-app-v1","value":"1500m"}]}`,
+			expected: 1.5,
+		},
+		{
+			name:     "multiple values are summed up",
+			data:     `{"items":[{"value":"10"},{"value":"20"}]}`,
+			expected: 30,
+		},
+		{
+			name:     "no values",
+			data:     `{"items":[]}`,
+			expected: 0,
+		},
+		{
+			name: "invalid data",
+			data: `{"items":[{"value":"foo"}]}`,
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rps, err := parseRequestsPerSecond([]byte(tc.data))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, rps)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/clientset"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	podMetricsPath = "/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods"
+)
+
+// podMetricsList is the subset of the resource metrics API PodMetricsList
+// needed to read the usage of the containers.
+type podMetricsList struct {
+	Items []struct {
+		Containers []struct {
+			Name  string          `json:"name"`
+			Usage v1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// metricsAPIResourceUsage reads the resource usage of the containers of a
+// stack from the resource metrics API served by the metrics-server.
+type metricsAPIResourceUsage struct {
+	client clientset.Interface
+}
+
+func (p *metricsAPIResourceUsage) ContainerResourceUsage(namespace, selector string) (map[string]v1.ResourceList, error) {
+	data, err := p.client.Discovery().RESTClient().Get().
+		AbsPath(fmt.Sprintf(podMetricsPath, namespace)).
+		Param("labelSelector", selector).
+		DoRaw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics for %s/%s: %v", namespace, selector, err)
+	}
+	return parseContainerResourceUsage(data)
+}
+
+// parseContainerResourceUsage returns the highest usage of each container
+// across the pods of a PodMetricsList.
+func parseContainerResourceUsage(data []byte) (map[string]v1.ResourceList, error) {
+	var metrics podMetricsList
+	err := json.Unmarshal(data, &metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pod metrics: %v", err)
+	}
+
+	result := make(map[string]v1.ResourceList)
+	for _, pod := range metrics.Items {
+		for _, container := range pod.Containers {
+			if _, ok := result[container.Name]; !ok {
+				result[container.Name] = make(v1.ResourceList)
+			}
+			for name, quantity := range container.Usage {
+				if current, ok := result[container.Name][name]; !ok || quantity.Cmp(current) > 0 {
+					result[container.Name][name] = quantity
+				}
+			}
+		}
+	}
+	return result, nil
+}
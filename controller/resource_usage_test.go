@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestParseContainerResourceUsage(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected map[string]v1.ResourceList
+		err      bool
+	}{
+		{
+			name: "single pod",
+			data: `{"kind":"PodMetricsList","items":[{"metadata":{"name":"my-app-v1-abc"},"containers":[{"name":"app","usage":{"cpu":"250m","memory":"100Mi"}}]}]}`,
+			expected: map[string]v1.ResourceList{
+				"app": {
+					v1.ResourceCPU:    resource.MustParse("250m"),
+					v1.ResourceMemory: resource.MustParse("100Mi"),
+				},
+			},
+		},
+		{
+			name: "highest usage across pods",
+			data: `{"items":[{"containers":[{"name":"app","usage":{"cpu":"250m","memory":"200Mi"}},{"name":"sidecar","usage":{"cpu":"10m"}}]},{"containers":[{"name":"app","usage":{"cpu":"500m","memory":"100Mi"}}]}]}`,
+			expected: map[string]v1.ResourceList{
+				"app": {
+					v1.ResourceCPU:    resource.MustParse("500m"),
+					v1.ResourceMemory: resource.MustParse("200Mi"),
+				},
+				"sidecar": {
+					v1.ResourceCPU: resource.MustParse("10m"),
+				},
+			},
+		},
+		{
+			name:     "no pods",
+			data:     `{"items":[]}`,
+			expected: map[string]v1.ResourceList{},
+		},
+		{
+			name: "invalid data",
+			data: `{"items":[{"containers":[{"name":"app","usage":{"cpu":"foo"}}]}]}`,
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			usage, err := parseContainerResourceUsage([]byte(tc.data))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, usage, len(tc.expected))
+			for container, resources := range tc.expected {
+				require.Len(t, usage[container], len(resources))
+				for name, quantity := range resources {
+					require.Zero(t, quantity.Cmp(usage[container][name]), "container %s, resource %s", container, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Shard is the share of the StackSets managed by a controller instance when
+// the StackSets are split between several instances.
+type Shard struct {
+	// Index is the 1-based index of the shard.
+	Index int
+	// Count is the total number of shards. Zero or one disable sharding.
+	Count int
+}
+
+// ParseShard parses a shard in the format <index>/<count>, e.g. 2/5. An
+// empty value disables sharding.
+func ParseShard(value string) (Shard, error) {
+	if value == "" {
+		return Shard{}, nil
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return Shard{}, fmt.Errorf("invalid shard %s, expected <index>/<count>", value)
+	}
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard index %s: %v", parts[0], err)
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard count %s: %v", parts[1], err)
+	}
+	if count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("invalid shard %s, the index must be between 1 and the count", value)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// Contains returns true if the StackSet with the UID belongs to the shard.
+// The StackSets are assigned with rendezvous hashing: every StackSet belongs
+// to the shard with the highest hash of its UID and the shard index, so that
+// changing the number of shards only moves the StackSets of the added or
+// removed shards.
+func (s Shard) Contains(uid types.UID) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	owner := 0
+	var highest uint64
+	for index := 1; index <= s.Count; index++ {
+		if weight := shardWeight(uid, index); index == 1 || weight > highest {
+			owner = index
+			highest = weight
+		}
+	}
+	return owner == s.Index
+}
+
+// shardWeight returns the hash of the UID of a StackSet for a shard.
+func shardWeight(uid types.UID, index int) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(uid))
+	hash.Write([]byte{'/'})
+	hash.Write([]byte(strconv.Itoa(index)))
+	return hash.Sum64()
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseShard(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected Shard
+		valid    bool
+	}{
+		{value: "", expected: Shard{}, valid: true},
+		{value: "2/5", expected: Shard{Index: 2, Count: 5}, valid: true},
+		{value: "1/1", expected: Shard{Index: 1, Count: 1}, valid: true},
+		{value: "0/5"},
+		{value: "6/5"},
+		{value: "1/0"},
+		{value: "2"},
+		{value: "a/5"},
+		{value: "2/b"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			shard, err := ParseShard(tc.value)
+			if !tc.valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, shard)
+		})
+	}
+}
+
+func TestShardContains(t *testing.T) {
+	var uids []types.UID
+	for i := 0; i < 100; i++ {
+		uids = append(uids, types.UID(fmt.Sprintf("uid-%d", i)))
+	}
+
+	// every stackset belongs to exactly one shard
+	owners := make(map[types.UID]int)
+	for _, uid := range uids {
+		for index := 1; index <= 5; index++ {
+			if (Shard{Index: index, Count: 5}).Contains(uid) {
+				require.Zero(t, owners[uid], "stackset %s belongs to several shards", uid)
+				owners[uid] = index
+			}
+		}
+		require.NotZero(t, owners[uid], "stackset %s doesn't belong to a shard", uid)
+	}
+
+	// adding a shard only moves stacksets to the new shard
+	for _, uid := range uids {
+		for index := 1; index <= 6; index++ {
+			if (Shard{Index: index, Count: 6}).Contains(uid) && index != 6 {
+				require.Equal(t, owners[uid], index)
+			}
+		}
+	}
+
+	// without sharding all the stacksets are managed
+	for _, uid := range uids {
+		require.True(t, Shard{}.Contains(uid))
+	}
+}
@@ -1,13 +1,18 @@
 package controller
 
 import (
+	"sort"
+	"strings"
+
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
 	"github.com/zalando-incubator/stackset-controller/pkg/core"
 	apps "k8s.io/api/apps/v1"
 	"k8s.io/api/autoscaling/v2beta1"
 	apiv1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func pint32Equal(p1, p2 *int32) bool {
@@ -20,6 +25,76 @@ func pint32Equal(p1, p2 *int32) bool {
 	return false
 }
 
+// hpaAnnotationsEqual compares the HPA annotations managed by the controller
+// which change independently of the stack generation.
+func hpaAnnotationsEqual(a1, a2 map[string]string) bool {
+	for _, key := range []string{
+		core.AutoscalerProfileAnnotationKey,
+	} {
+		if a1[key] != a2[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// managedAnnotationsKey lists the annotations of an HPA set by the
+// controller when the HPA fields are reconciled with field ownership.
+const managedAnnotationsKey = "stackset-controller.zalando.org/managed-annotations"
+
+// withManagedAnnotations records the annotations generated by the controller
+// in the managed annotations of the HPA.
+func withManagedAnnotations(hpa *v2beta1.HorizontalPodAutoscaler) *v2beta1.HorizontalPodAutoscaler {
+	keys := make([]string, 0, len(hpa.Annotations))
+	for key := range hpa.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := hpa.DeepCopy()
+	if result.Annotations == nil {
+		result.Annotations = make(map[string]string)
+	}
+	result.Annotations[managedAnnotationsKey] = strings.Join(keys, ",")
+	return result
+}
+
+// mergeHPA updates the fields of an existing HPA generated by the
+// controller and leaves labels, annotations and spec fields added by other
+// controllers untouched. Annotations previously generated by the controller
+// but no longer generated are removed.
+func mergeHPA(existing, generated *v2beta1.HorizontalPodAutoscaler) *v2beta1.HorizontalPodAutoscaler {
+	generated = withManagedAnnotations(generated)
+	updated := existing.DeepCopy()
+
+	labels := updated.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for key, value := range generated.Labels {
+		labels[key] = value
+	}
+	updated.SetLabels(labels)
+
+	annotations := updated.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	for _, key := range strings.Split(existing.Annotations[managedAnnotationsKey], ",") {
+		delete(annotations, key)
+	}
+	for key, value := range generated.Annotations {
+		annotations[key] = value
+	}
+	updated.SetAnnotations(annotations)
+
+	updated.Spec.ScaleTargetRef = generated.Spec.ScaleTargetRef
+	updated.Spec.MinReplicas = generated.Spec.MinReplicas
+	updated.Spec.MaxReplicas = generated.Spec.MaxReplicas
+	updated.Spec.Metrics = generated.Spec.Metrics
+	return updated
+}
+
 // syncObjectMeta copies metadata elements such as labels or annotations from source to target
 func syncObjectMeta(target, source metav1.Object) {
 	target.SetLabels(source.GetLabels())
@@ -29,8 +104,27 @@ func syncObjectMeta(target, source metav1.Object) {
 func (c *StackSetController) ReconcileStackDeployment(stack *zv1.Stack, existing *apps.Deployment, generateUpdated func() *apps.Deployment) error {
 	deployment := generateUpdated()
 
+	// Deployment removed
+	if deployment == nil {
+		if existing != nil {
+			err := c.client.AppsV1().Deployments(existing.Namespace).Delete(existing.Name, &metav1.DeleteOptions{})
+			if err != nil {
+				return err
+			}
+			c.recorder.Eventf(
+				stack,
+				apiv1.EventTypeNormal,
+				"DeletedDeployment",
+				"Deleted Deployment %s",
+				existing.Name)
+			c.stackResourceChanged(stack, changeDeleted, "Deployment", existing.Name)
+		}
+		return nil
+	}
+
 	// Create new deployment
 	if existing == nil {
+		c.stampProvenance(deployment)
 		_, err := c.client.AppsV1().Deployments(deployment.Namespace).Create(deployment)
 		if err != nil {
 			return err
@@ -41,11 +135,13 @@ func (c *StackSetController) ReconcileStackDeployment(stack *zv1.Stack, existing
 			"CreatedDeployment",
 			"Created Deployment %s",
 			deployment.Name)
+		c.stackResourceChanged(stack, changeCreated, "Deployment", deployment.Name)
 		return nil
 	}
 
-	// Check if we need to update the deployment
-	if core.IsResourceUpToDate(stack, existing.ObjectMeta) && deployment.Spec.Replicas == nil {
+	// Check if we need to update the deployment. Fields which aren't
+	// generated, e.g. the ones defaulted by the API server, are ignored.
+	if ownedMetadataEqual(deployment, existing) && equality.Semantic.DeepDerivative(deployment.Spec, existing.Spec) && equalWithDefaults(deployment.Spec.Template, existing.Spec.Template) {
 		return nil
 	}
 
@@ -53,8 +149,18 @@ func (c *StackSetController) ReconcileStackDeployment(stack *zv1.Stack, existing
 	syncObjectMeta(updated, deployment)
 	updated.Spec = deployment.Spec
 	updated.Spec.Selector = existing.Spec.Selector
+	c.stampProvenance(updated)
+
+	patch, err := strategicMergePatch(existing, updated, apps.Deployment{})
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
 
-	_, err := c.client.AppsV1().Deployments(updated.Namespace).Update(updated)
+	c.withSubsystem(SubsystemResources, c.objectLogger(stack.Namespace, "stack", stack.Name)).Debugf("Patching Deployment %s: %s", updated.Name, patch)
+	_, err = c.client.AppsV1().Deployments(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
 	if err != nil {
 		return err
 	}
@@ -64,10 +170,14 @@ func (c *StackSetController) ReconcileStackDeployment(stack *zv1.Stack, existing
 		"UpdatedDeployment",
 		"Updated Deployment %s",
 		deployment.Name)
+	c.stackResourceChanged(stack, changeUpdated, "Deployment", deployment.Name)
 	return nil
 }
 
-func (c *StackSetController) ReconcileStackHPA(stack *zv1.Stack, existing *v2beta1.HorizontalPodAutoscaler, generateUpdated func() (*v2beta1.HorizontalPodAutoscaler, error)) error {
+// ReconcileStackHPA creates, updates or deletes the HPA of a stack. With
+// fieldOwnership only the fields generated by the controller are updated,
+// otherwise the whole HPA is overwritten.
+func (c *StackSetController) ReconcileStackHPA(stack *zv1.Stack, existing *v2beta1.HorizontalPodAutoscaler, fieldOwnership bool, generateUpdated func() (*v2beta1.HorizontalPodAutoscaler, error)) error {
 	hpa, err := generateUpdated()
 	if err != nil {
 		return err
@@ -86,12 +196,17 @@ func (c *StackSetController) ReconcileStackHPA(stack *zv1.Stack, existing *v2bet
 				"DeletedHPA",
 				"Deleted HPA %s",
 				existing.Namespace)
+			c.stackResourceChanged(stack, changeDeleted, "HPA", existing.Name)
 		}
 		return nil
 	}
 
 	// Create new HPA
 	if existing == nil {
+		if fieldOwnership {
+			hpa = withManagedAnnotations(hpa)
+		}
+		c.stampProvenance(hpa)
 		_, err := c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(hpa.Namespace).Create(hpa)
 		if err != nil {
 			return err
@@ -102,19 +217,36 @@ func (c *StackSetController) ReconcileStackHPA(stack *zv1.Stack, existing *v2bet
 			"CreatedHPA",
 			"Created HPA %s",
 			hpa.Name)
+		c.stackResourceChanged(stack, changeCreated, "HPA", hpa.Name)
 		return nil
 	}
 
 	// Check if we need to update the HPA
-	if core.IsResourceUpToDate(stack, existing.ObjectMeta) && pint32Equal(existing.Spec.MinReplicas, hpa.Spec.MinReplicas) {
+	if ownedMetadataEqual(hpa, existing) && equality.Semantic.DeepDerivative(hpa.Spec, existing.Spec) && pint32Equal(existing.Spec.MinReplicas, hpa.Spec.MinReplicas) && equality.Semantic.DeepEqual(existing.Spec.Metrics, hpa.Spec.Metrics) && hpaAnnotationsEqual(existing.Annotations, hpa.Annotations) {
 		return nil
 	}
 
-	updated := existing.DeepCopy()
-	syncObjectMeta(updated, hpa)
-	updated.Spec = hpa.Spec
+	var updated *v2beta1.HorizontalPodAutoscaler
+	if fieldOwnership {
+		updated = mergeHPA(existing, hpa)
+	} else {
+		updated = existing.DeepCopy()
+		syncObjectMeta(updated, hpa)
+		updated.Spec = hpa.Spec
+	}
+
+	c.stampProvenance(updated)
+
+	patch, err := strategicMergePatch(existing, updated, v2beta1.HorizontalPodAutoscaler{})
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
 
-	_, err = c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(updated.Namespace).Update(updated)
+	c.withSubsystem(SubsystemAutoscaling, c.objectLogger(stack.Namespace, "stack", stack.Name)).Debugf("Patching HPA %s: %s", updated.Name, patch)
+	_, err = c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
 	if err != nil {
 		return err
 	}
@@ -124,6 +256,7 @@ func (c *StackSetController) ReconcileStackHPA(stack *zv1.Stack, existing *v2bet
 		"UpdatedHPA",
 		"Updated HPA %s",
 		hpa.Name)
+	c.stackResourceChanged(stack, changeUpdated, "HPA", hpa.Name)
 	return nil
 }
 
@@ -135,6 +268,7 @@ func (c *StackSetController) ReconcileStackService(stack *zv1.Stack, existing *a
 
 	// Create new service
 	if existing == nil {
+		c.stampProvenance(service)
 		_, err := c.client.CoreV1().Services(service.Namespace).Create(service)
 		if err != nil {
 			return err
@@ -145,11 +279,12 @@ func (c *StackSetController) ReconcileStackService(stack *zv1.Stack, existing *a
 			"CreatedService",
 			"Created Service %s",
 			service.Name)
+		c.stackResourceChanged(stack, changeCreated, "Service", service.Name)
 		return nil
 	}
 
 	// Check if we need to update the service
-	if core.IsResourceUpToDate(stack, existing.ObjectMeta) {
+	if ownedMetadataEqual(service, existing) && equality.Semantic.DeepDerivative(service.Spec, existing.Spec) && equalWithDefaults(service.Spec.Ports, existing.Spec.Ports) {
 		return nil
 	}
 
@@ -157,8 +292,18 @@ func (c *StackSetController) ReconcileStackService(stack *zv1.Stack, existing *a
 	syncObjectMeta(updated, service)
 	updated.Spec = service.Spec
 	updated.Spec.ClusterIP = existing.Spec.ClusterIP // ClusterIP is immutable
+	c.stampProvenance(updated)
 
-	_, err = c.client.CoreV1().Services(updated.Namespace).Update(updated)
+	patch, err := strategicMergePatch(existing, updated, apiv1.Service{})
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
+
+	c.withSubsystem(SubsystemResources, c.objectLogger(stack.Namespace, "stack", stack.Name)).Debugf("Patching Service %s: %s", updated.Name, patch)
+	_, err = c.client.CoreV1().Services(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
 	if err != nil {
 		return err
 	}
@@ -168,6 +313,7 @@ func (c *StackSetController) ReconcileStackService(stack *zv1.Stack, existing *a
 		"UpdatedService",
 		"Updated Service %s",
 		service.Name)
+	c.stackResourceChanged(stack, changeUpdated, "Service", service.Name)
 	return nil
 }
 
@@ -190,12 +336,14 @@ func (c *StackSetController) ReconcileStackIngress(stack *zv1.Stack, existing *e
 				"DeletedIngress",
 				"Deleted Ingress %s",
 				existing.Namespace)
+			c.stackResourceChanged(stack, changeDeleted, "Ingress", existing.Name)
 		}
 		return nil
 	}
 
 	// Create new Ingress
 	if existing == nil {
+		c.stampProvenance(ingress)
 		_, err := c.client.ExtensionsV1beta1().Ingresses(ingress.Namespace).Create(ingress)
 		if err != nil {
 			return err
@@ -206,19 +354,30 @@ func (c *StackSetController) ReconcileStackIngress(stack *zv1.Stack, existing *e
 			"CreatedIngress",
 			"Created Ingress %s",
 			ingress.Name)
+		c.stackResourceChanged(stack, changeCreated, "Ingress", ingress.Name)
 		return nil
 	}
 
 	// Check if we need to update the Ingress
-	if core.IsResourceUpToDate(stack, existing.ObjectMeta) {
+	if ownedMetadataEqual(ingress, existing) && equality.Semantic.DeepDerivative(ingress.Spec, existing.Spec) && equalWithDefaults(ingress.Spec.Rules, existing.Spec.Rules) {
 		return nil
 	}
 
 	updated := existing.DeepCopy()
 	syncObjectMeta(updated, ingress)
 	updated.Spec = ingress.Spec
+	c.stampProvenance(updated)
+
+	patch, err := strategicMergePatch(existing, updated, extensions.Ingress{})
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
 
-	_, err = c.client.ExtensionsV1beta1().Ingresses(updated.Namespace).Update(updated)
+	c.withSubsystem(SubsystemResources, c.objectLogger(stack.Namespace, "stack", stack.Name)).Debugf("Patching Ingress %s: %s", updated.Name, patch)
+	_, err = c.client.ExtensionsV1beta1().Ingresses(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
 	if err != nil {
 		return err
 	}
@@ -228,5 +387,6 @@ func (c *StackSetController) ReconcileStackIngress(stack *zv1.Stack, existing *e
 		"UpdatedIngress",
 		"Updated Ingress %s",
 		ingress.Name)
+	c.stackResourceChanged(stack, changeUpdated, "Ingress", ingress.Name)
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
 	apps "k8s.io/api/apps/v1"
 	autoscaling "k8s.io/api/autoscaling/v2beta1"
 	v1 "k8s.io/api/core/v1"
@@ -69,6 +70,33 @@ func TestReconcileStackDeployment(t *testing.T) {
 			},
 		},
 	}
+	defaultedPodTemplateSpec := v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:                     "foo",
+					Image:                    "nginx",
+					TerminationMessagePath:   "/dev/termination-log",
+					TerminationMessagePolicy: v1.TerminationMessageReadFile,
+					ImagePullPolicy:          v1.PullAlways,
+				},
+			},
+			RestartPolicy: v1.RestartPolicyAlways,
+		},
+	}
+	envPodTemplateSpec := v1.PodTemplateSpec{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:  "foo",
+					Image: "nginx",
+					Env:   []v1.EnvVar{{Name: "DEBUG", Value: "true"}},
+				},
+			},
+		},
+	}
+	labeledTestStackOwned := *baseTestStackOwned.DeepCopy()
+	labeledTestStackOwned.Labels = map[string]string{"debug": "true"}
 
 	for _, tc := range []struct {
 		name     string
@@ -146,22 +174,99 @@ func TestReconcileStackDeployment(t *testing.T) {
 			},
 		},
 		{
-			name:  "deployment is not updated if the stack version remains the same and replica count is unset",
+			name:  "deployment is not updated if it matches the generated one and replica count is unset",
+			stack: baseTestStack,
+			existing: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas:             &exampleReplicas,
+					RevisionHistoryLimit: &exampleReplicas,
+					Template:             defaultedPodTemplateSpec,
+				},
+			},
+			updated: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: nil,
+					Template: examplePodTemplateSpec,
+				},
+			},
+			expected: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas:             &exampleReplicas,
+					RevisionHistoryLimit: &exampleReplicas,
+					Template:             defaultedPodTemplateSpec,
+				},
+			},
+		},
+		{
+			name:  "deployment is updated if an environment variable was added out-of-band",
 			stack: baseTestStack,
 			existing: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
+					Template: envPodTemplateSpec,
+				},
+			},
+			updated: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
+					Template: examplePodTemplateSpec,
+				},
+			},
+			expected: &apps.Deployment{
 				ObjectMeta: baseTestStackOwned,
 				Spec: apps.DeploymentSpec{
 					Replicas: &exampleReplicas,
 					Template: examplePodTemplateSpec,
 				},
 			},
+		},
+		{
+			name:  "deployment is updated if a label was added out-of-band",
+			stack: baseTestStack,
+			existing: &apps.Deployment{
+				ObjectMeta: labeledTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
+					Template: examplePodTemplateSpec,
+				},
+			},
 			updated: &apps.Deployment{
 				ObjectMeta: baseTestStackOwned,
 				Spec: apps.DeploymentSpec{
-					Replicas: nil,
+					Replicas: &exampleReplicas,
+					Template: examplePodTemplateSpec,
+				},
+			},
+			expected: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
+					Template: examplePodTemplateSpec,
+				},
+			},
+		},
+		{
+			name:  "deployment is updated if it was changed out-of-band",
+			stack: baseTestStack,
+			existing: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
 					Template: updatedPodTemplateSpec,
 				},
 			},
+			updated: &apps.Deployment{
+				ObjectMeta: baseTestStackOwned,
+				Spec: apps.DeploymentSpec{
+					Replicas: &exampleReplicas,
+					Template: examplePodTemplateSpec,
+				},
+			},
 			expected: &apps.Deployment{
 				ObjectMeta: baseTestStackOwned,
 				Spec: apps.DeploymentSpec{
@@ -226,11 +331,34 @@ func TestReconcileStackDeployment(t *testing.T) {
 
 			updated, err := env.client.AppsV1().Deployments(tc.stack.Namespace).Get(tc.stack.Name, metav1.GetOptions{})
 			require.NoError(t, err)
+			updated.Annotations = withoutProvenance(updated.Annotations)
 			require.Equal(t, tc.expected, updated)
 		})
 	}
 }
 
+func TestReconcileStackDeploymentRemoved(t *testing.T) {
+	env := NewTestEnvironment()
+
+	err := env.CreateStacksets([]zv1.StackSet{testStackSet})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{baseTestStack})
+	require.NoError(t, err)
+
+	existing := &apps.Deployment{ObjectMeta: baseTestStackOwned}
+	err = env.CreateDeployments([]apps.Deployment{*existing})
+	require.NoError(t, err)
+
+	err = env.controller.ReconcileStackDeployment(&baseTestStack, existing, func() *apps.Deployment {
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = env.client.AppsV1().Deployments(baseTestStack.Namespace).Get(baseTestStack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+}
+
 func TestReconcileStackService(t *testing.T) {
 	examplePorts := []v1.ServicePort{
 		{
@@ -298,7 +426,7 @@ func TestReconcileStackService(t *testing.T) {
 			},
 		},
 		{
-			name:  "service is not updated if the stack version remains the same",
+			name:  "service is not updated if it matches the generated one",
 			stack: baseTestStack,
 			existing: &v1.Service{
 				ObjectMeta: baseTestStackOwned,
@@ -310,7 +438,31 @@ func TestReconcileStackService(t *testing.T) {
 			updated: &v1.Service{
 				ObjectMeta: baseTestStackOwned,
 				Spec: v1.ServiceSpec{
-					Ports: exampleUpdatedPorts,
+					Ports: examplePorts,
+				},
+			},
+			expected: &v1.Service{
+				ObjectMeta: baseTestStackOwned,
+				Spec: v1.ServiceSpec{
+					Ports:     examplePorts,
+					ClusterIP: exampleClusterIP,
+				},
+			},
+		},
+		{
+			name:  "service is updated if it was changed out-of-band",
+			stack: baseTestStack,
+			existing: &v1.Service{
+				ObjectMeta: baseTestStackOwned,
+				Spec: v1.ServiceSpec{
+					Ports:     exampleUpdatedPorts,
+					ClusterIP: exampleClusterIP,
+				},
+			},
+			updated: &v1.Service{
+				ObjectMeta: baseTestStackOwned,
+				Spec: v1.ServiceSpec{
+					Ports: examplePorts,
 				},
 			},
 			expected: &v1.Service{
@@ -343,6 +495,7 @@ func TestReconcileStackService(t *testing.T) {
 
 			updated, err := env.client.CoreV1().Services(tc.stack.Namespace).Get(tc.stack.Name, metav1.GetOptions{})
 			require.NoError(t, err)
+			updated.Annotations = withoutProvenance(updated.Annotations)
 			require.Equal(t, tc.expected, updated)
 		})
 	}
@@ -373,12 +526,35 @@ func TestReconcileStackHPA(t *testing.T) {
 	exampleMinReplicas := int32(3)
 	exampleUpdatedMinReplicas := int32(5)
 
+	profileTestStackOwned := stackOwned(baseTestStack)
+	profileTestStackOwned.Annotations = map[string]string{
+		"stackset-controller.zalando.org/stack-generation":         "1",
+		"alpha.stackset-controller.zalando.org/autoscaler-profile": "weekend",
+	}
+
+	externalTestStackOwned := stackOwned(baseTestStack)
+	externalTestStackOwned.Labels = map[string]string{"ops-tool": "enabled"}
+	externalTestStackOwned.Annotations = map[string]string{
+		"stackset-controller.zalando.org/stack-generation":         "1",
+		"alpha.stackset-controller.zalando.org/autoscaler-profile": "weekend",
+		"stackset-controller.zalando.org/managed-annotations":      "alpha.stackset-controller.zalando.org/autoscaler-profile,stackset-controller.zalando.org/stack-generation",
+		"ops-tool.example.org/scale-down-delay":                    "5m",
+	}
+	mergedTestStackOwned := stackOwned(baseTestStack)
+	mergedTestStackOwned.Labels = map[string]string{"ops-tool": "enabled"}
+	mergedTestStackOwned.Annotations = map[string]string{
+		"stackset-controller.zalando.org/stack-generation":    "2",
+		"stackset-controller.zalando.org/managed-annotations": "stackset-controller.zalando.org/stack-generation",
+		"ops-tool.example.org/scale-down-delay":               "5m",
+	}
+
 	for _, tc := range []struct {
-		name     string
-		stack    zv1.Stack
-		existing *autoscaling.HorizontalPodAutoscaler
-		updated  *autoscaling.HorizontalPodAutoscaler
-		expected *autoscaling.HorizontalPodAutoscaler
+		name           string
+		stack          zv1.Stack
+		fieldOwnership bool
+		existing       *autoscaling.HorizontalPodAutoscaler
+		updated        *autoscaling.HorizontalPodAutoscaler
+		expected       *autoscaling.HorizontalPodAutoscaler
 	}{
 		{
 			name:  "HPA is created if it doesn't exist",
@@ -471,24 +647,136 @@ func TestReconcileStackHPA(t *testing.T) {
 			},
 		},
 		{
-			name:  "HPA is not updated if the stack version remains the same and min. replicas are unchanged",
+			name:  "HPA is updated if max. replicas is changed",
 			stack: baseTestStack,
 			existing: &autoscaling.HorizontalPodAutoscaler{
 				ObjectMeta: baseTestStackOwned,
 				Spec: autoscaling.HorizontalPodAutoscalerSpec{
 					MinReplicas: &exampleMinReplicas,
-					MaxReplicas: 5,
+					MaxReplicas: 10,
 					Metrics:     exampleMetrics,
 				},
 			},
 			updated: &autoscaling.HorizontalPodAutoscaler{
 				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			expected: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+		},
+		{
+			name:  "HPA is updated if the autoscaler profile is changed",
+			stack: baseTestStack,
+			existing: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			updated: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: profileTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleUpdatedMetrics,
+				},
+			},
+			expected: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: profileTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleUpdatedMetrics,
+				},
+			},
+		},
+		{
+			name:  "HPA is updated if the metrics of the autoscaler profile are changed",
+			stack: baseTestStack,
+			existing: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: profileTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			updated: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: profileTestStackOwned,
 				Spec: autoscaling.HorizontalPodAutoscalerSpec{
 					MinReplicas: &exampleMinReplicas,
 					MaxReplicas: 5,
 					Metrics:     exampleUpdatedMetrics,
 				},
 			},
+			expected: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: profileTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleUpdatedMetrics,
+				},
+			},
+		},
+		{
+			name:  "HPA is updated if only the metrics are changed",
+			stack: baseTestStack,
+			existing: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     append(exampleMetrics, exampleUpdatedMetrics...),
+				},
+			},
+			updated: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			expected: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+		},
+		{
+			name:  "HPA is not updated if the stack version, replicas and metrics are unchanged",
+			stack: baseTestStack,
+			existing: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			updated: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: baseTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
 			expected: &autoscaling.HorizontalPodAutoscaler{
 				ObjectMeta: baseTestStackOwned,
 				Spec: autoscaling.HorizontalPodAutoscalerSpec{
@@ -498,6 +786,35 @@ func TestReconcileStackHPA(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:           "HPA fields added by other controllers are kept with field ownership",
+			stack:          updatedTestStack,
+			fieldOwnership: true,
+			existing: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: externalTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleMinReplicas,
+					MaxReplicas: 5,
+					Metrics:     exampleMetrics,
+				},
+			},
+			updated: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: updatedTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleUpdatedMinReplicas,
+					MaxReplicas: 7,
+					Metrics:     exampleUpdatedMetrics,
+				},
+			},
+			expected: &autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: mergedTestStackOwned,
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					MinReplicas: &exampleUpdatedMinReplicas,
+					MaxReplicas: 7,
+					Metrics:     exampleUpdatedMetrics,
+				},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			env := NewTestEnvironment()
@@ -513,7 +830,7 @@ func TestReconcileStackHPA(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			err = env.controller.ReconcileStackHPA(&tc.stack, tc.existing, func() (*autoscaling.HorizontalPodAutoscaler, error) {
+			err = env.controller.ReconcileStackHPA(&tc.stack, tc.existing, tc.fieldOwnership, func() (*autoscaling.HorizontalPodAutoscaler, error) {
 				return tc.updated, nil
 			})
 			require.NoError(t, err)
@@ -521,6 +838,7 @@ func TestReconcileStackHPA(t *testing.T) {
 			updated, err := env.client.AutoscalingV2beta1().HorizontalPodAutoscalers(tc.stack.Namespace).Get(tc.stack.Name, metav1.GetOptions{})
 			if tc.expected != nil {
 				require.NoError(t, err)
+				updated.Annotations = withoutProvenance(updated.Annotations)
 				require.Equal(t, tc.expected, updated)
 			} else {
 				require.True(t, errors.IsNotFound(err))
@@ -625,7 +943,7 @@ func TestReconcileStackIngress(t *testing.T) {
 			},
 		},
 		{
-			name:  "ingress is not updated if the stack version remains the same",
+			name:  "ingress is not updated if it matches the generated one",
 			stack: baseTestStack,
 			existing: &extensions.Ingress{
 				ObjectMeta: baseTestStackOwned,
@@ -634,11 +952,33 @@ func TestReconcileStackIngress(t *testing.T) {
 				},
 			},
 			updated: &extensions.Ingress{
+				ObjectMeta: baseTestStackOwned,
+				Spec: extensions.IngressSpec{
+					Rules: exampleRules,
+				},
+			},
+			expected: &extensions.Ingress{
+				ObjectMeta: baseTestStackOwned,
+				Spec: extensions.IngressSpec{
+					Rules: exampleRules,
+				},
+			},
+		},
+		{
+			name:  "ingress is updated if it was changed out-of-band",
+			stack: baseTestStack,
+			existing: &extensions.Ingress{
 				ObjectMeta: baseTestStackOwned,
 				Spec: extensions.IngressSpec{
 					Rules: exampleUpdatedRules,
 				},
 			},
+			updated: &extensions.Ingress{
+				ObjectMeta: baseTestStackOwned,
+				Spec: extensions.IngressSpec{
+					Rules: exampleRules,
+				},
+			},
 			expected: &extensions.Ingress{
 				ObjectMeta: baseTestStackOwned,
 				Spec: extensions.IngressSpec{
@@ -669,6 +1009,7 @@ func TestReconcileStackIngress(t *testing.T) {
 			updated, err := env.client.ExtensionsV1beta1().Ingresses(tc.stack.Namespace).Get(tc.stack.Name, metav1.GetOptions{})
 			if tc.expected != nil {
 				require.NoError(t, err)
+				updated.Annotations = withoutProvenance(updated.Annotations)
 				require.Equal(t, tc.expected, updated)
 			} else {
 				require.True(t, errors.IsNotFound(err))
@@ -676,3 +1017,51 @@ func TestReconcileStackIngress(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileStackResourcesUnchanged(t *testing.T) {
+	env := NewTestEnvironment()
+
+	err := env.CreateStacksets([]zv1.StackSet{testStackSet})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{baseTestStack})
+	require.NoError(t, err)
+
+	deployment := &apps.Deployment{ObjectMeta: stackOwned(baseTestStack)}
+	deployment.ResourceVersion = "1"
+	service := &v1.Service{ObjectMeta: stackOwned(baseTestStack)}
+	service.ResourceVersion = "1"
+	err = env.CreateDeployments([]apps.Deployment{*deployment})
+	require.NoError(t, err)
+	err = env.CreateServices([]v1.Service{*service})
+	require.NoError(t, err)
+
+	ssc := &core.StackSetContainer{StackSet: &testStackSet}
+	sc := &core.StackContainer{
+		Stack: &baseTestStack,
+		Resources: core.StackResources{
+			Deployment: deployment,
+			Service:    service,
+		},
+	}
+
+	err = env.controller.ReconcileStackResources(ssc, sc)
+	require.NoError(t, err)
+
+	// the unchanged resources aren't reconciled again
+	err = env.client.AppsV1().Deployments(baseTestStack.Namespace).Delete(baseTestStack.Name, &metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	err = env.controller.ReconcileStackResources(ssc, sc)
+	require.NoError(t, err)
+	_, err = env.client.AppsV1().Deployments(baseTestStack.Namespace).Get(baseTestStack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// the resources are reconciled once they changed
+	sc.Resources.Deployment = nil
+
+	err = env.controller.ReconcileStackResources(ssc, sc)
+	require.NoError(t, err)
+	_, err = env.client.AppsV1().Deployments(baseTestStack.Namespace).Get(baseTestStack.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+}
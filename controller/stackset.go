@@ -2,7 +2,11 @@ package controller
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,33 +27,90 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
 	kube_record "k8s.io/client-go/tools/record"
+	clientretry "k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
 )
 
 const (
 	PrescaleStacksAnnotationKey               = "alpha.stackset-controller.zalando.org/prescale-stacks"
 	ResetHPAMinReplicasDelayAnnotationKey     = "alpha.stackset-controller.zalando.org/reset-hpa-min-replicas-delay"
+	PrescaleStacksByRPSAnnotationKey          = "alpha.stackset-controller.zalando.org/prescale-stacks-by-rps"
+	AggregateAutoscalingAnnotationKey         = "alpha.stackset-controller.zalando.org/aggregate-autoscaling"
+	FreezeAutoscalersAnnotationKey            = "alpha.stackset-controller.zalando.org/freeze-autoscalers-window"
+	HPAToleranceAnnotationKey                 = "alpha.stackset-controller.zalando.org/hpa-tolerance"
+	HPACPUInitializationPeriodAnnotationKey   = "alpha.stackset-controller.zalando.org/hpa-cpu-initialization-period"
+	RecommendResourcesAnnotationKey           = "alpha.stackset-controller.zalando.org/recommend-resources"
+	HPAFieldOwnershipAnnotationKey            = "alpha.stackset-controller.zalando.org/hpa-field-ownership"
+	AdoptResourcesAnnotationKey               = "alpha.stackset-controller.zalando.org/adopt-resources"
+	InstantTrafficSwitchAnnotationKey         = "alpha.stackset-controller.zalando.org/instant-traffic-switch"
 	StacksetControllerControllerAnnotationKey = "stackset-controller.zalando.org/controller"
 
 	reasonFailedManageStackSet = "FailedManageStackSet"
 
 	defaultResetMinReplicasDelay = 10 * time.Minute
+
+	// maxPrescalingBufferPercent is the largest head-room in percent which
+	// can be added to the prescaled replicas.
+	maxPrescalingBufferPercent = 100
+
+	// maxReconcileBackoff is the longest delay before a StackSet which
+	// failed to reconcile is retried.
+	maxReconcileBackoff = 10 * time.Minute
+
+	// defaultCacheSyncTimeout is how long the controller waits for the
+	// resource caches to sync before it lists the resources from the API
+	// server instead.
+	defaultCacheSyncTimeout = time.Minute
 )
 
 // StackSetController is the main controller. It watches for changes to
 // stackset resources and starts and maintains other controllers per
 // stackset resource.
 type StackSetController struct {
-	logger         *log.Entry
-	client         clientset.Interface
-	controllerID   string
-	interval       time.Duration
-	stacksetEvents chan stacksetEvent
-	stacksetStore  map[types.UID]zv1.StackSet
-	recorder       kube_record.EventRecorder
+	logger             *log.Entry
+	client             clientset.Interface
+	namespaces         []string
+	excludedNamespaces []string
+	controllerID       string
+	stacksetSelector   labels.Selector
+	shard              Shard
+	interval           time.Duration
+	workers            int
+	stackWorkers       int
+	rateLimiter        workqueue.RateLimiter
+	retryAfter         map[types.UID]time.Time
+	resourceHashes     map[types.UID]string
+	reconcileIDs       map[types.UID]string
+	stacksetEvents     chan stacksetEvent
+	reconcileRequests  chan types.NamespacedName
+	stacksetStore      map[types.UID]zv1.StackSet
+	resourceCaches     []*resourceCache
+	cacheSyncTimeout   time.Duration
+	resyncPeriod       time.Duration
+	listPageSize       int64
+	reconcileTimeout   time.Duration
+	version            string
+	admissionDefaults  AdmissionDefaults
+	trafficMetrics     *trafficMetrics
+	removalMetrics     *removalMetrics
+	prescalingMetrics  *prescalingMetrics
+	subsystemLoggers   subsystemLoggers
+	// unreportedFailures are the aborted reconciliations of the StackSets
+	// which aren't recorded in their status yet
+	unreportedFailures map[types.UID][]reconcileFailure
+	// changeSummaries collect the changes made by the running
+	// reconciliations of the StackSets
+	changeSummaries map[types.UID]*changeSummary
+	// reconciledContainers are the models of the StackSets computed by
+	// their last reconciliation, exposed by the debug endpoint
+	reconciledContainers map[types.UID]*core.StackSetContainer
+	health               healthState
+	recorder             kube_record.EventRecorder
 	sync.Mutex
 }
 
@@ -67,40 +128,135 @@ func (ee *eventedError) Error() string {
 	return ee.err.Error()
 }
 
-// NewStackSetController initializes a new StackSetController.
-func NewStackSetController(client clientset.Interface, controllerID string, interval time.Duration) *StackSetController {
+// NewStackSetController initializes a new StackSetController. It only
+// manages the StackSets of the namespaces, or of all namespaces if none are
+// passed, except for the excluded namespaces. Only the StackSets matching the
+// selector are considered, all of them if it's nil, and of these only the
+// ones belonging to the shard. Up to workers StackSets are reconciled
+// concurrently, and the resources of up to stackWorkers stacks of each of
+// them.
+func NewStackSetController(client clientset.Interface, namespaces, excludedNamespaces []string, controllerID string, stacksetSelector labels.Selector, shard Shard, interval time.Duration, workers, stackWorkers int) *StackSetController {
+	if stacksetSelector == nil {
+		stacksetSelector = labels.Everything()
+	}
 	return &StackSetController{
-		logger:         log.WithFields(log.Fields{"controller": "stackset"}),
-		client:         client,
-		controllerID:   controllerID,
-		stacksetEvents: make(chan stacksetEvent, 1),
-		stacksetStore:  make(map[types.UID]zv1.StackSet),
-		interval:       interval,
-		recorder:       recorder.CreateEventRecorder(client),
+		logger:               log.WithFields(log.Fields{"controller": "stackset"}),
+		client:               client,
+		namespaces:           namespaces,
+		excludedNamespaces:   excludedNamespaces,
+		controllerID:         controllerID,
+		stacksetSelector:     stacksetSelector,
+		shard:                shard,
+		workers:              workers,
+		stackWorkers:         stackWorkers,
+		rateLimiter:          workqueue.NewItemExponentialFailureRateLimiter(interval, maxReconcileBackoff),
+		retryAfter:           make(map[types.UID]time.Time),
+		resourceHashes:       make(map[types.UID]string),
+		reconcileIDs:         make(map[types.UID]string),
+		stacksetEvents:       make(chan stacksetEvent, 1),
+		reconcileRequests:    make(chan types.NamespacedName, maxPendingReconcileRequests),
+		stacksetStore:        make(map[types.UID]zv1.StackSet),
+		interval:             interval,
+		cacheSyncTimeout:     defaultCacheSyncTimeout,
+		trafficMetrics:       newTrafficMetrics(),
+		removalMetrics:       newRemovalMetrics(),
+		prescalingMetrics:    newPrescalingMetrics(),
+		subsystemLoggers:     newSubsystemLoggers(),
+		reconciledContainers: make(map[types.UID]*core.StackSetContainer),
+		unreportedFailures:   make(map[types.UID][]reconcileFailure),
+		changeSummaries:      make(map[types.UID]*changeSummary),
+		recorder:             recorder.CreateEventRecorder(client),
 	}
 }
 
+// ConfigureInformers sets the period after which the informers resync their
+// caches, 0 disables resyncs, and the number of objects they list per
+// request when they're (re)started, 0 lists all objects at once from the
+// watch cache of the API server. It must be called before Run.
+func (c *StackSetController) ConfigureInformers(resyncPeriod time.Duration, listPageSize int64) {
+	c.resyncPeriod = resyncPeriod
+	c.listPageSize = listPageSize
+}
+
+// ConfigureReconcileTimeout sets the time after which the reconciliation of
+// a StackSet is aborted and requeued, 0 doesn't limit it. It must be called
+// before Run.
+func (c *StackSetController) ConfigureReconcileTimeout(timeout time.Duration) {
+	c.reconcileTimeout = timeout
+}
+
+// stacksetLogger returns a logger with the namespace and name of the
+// StackSet and the ID of its current reconciliation, if any.
 func (c *StackSetController) stacksetLogger(ssc *core.StackSetContainer) *log.Entry {
-	return c.logger.WithFields(map[string]interface{}{
+	fields := log.Fields{
 		"namespace": ssc.StackSet.Namespace,
 		"stackset":  ssc.StackSet.Name,
-	})
+	}
+	if reconcileID := c.reconcileID(ssc.StackSet.UID); reconcileID != "" {
+		fields["reconcile_id"] = reconcileID
+	}
+	return c.logger.WithFields(fields)
 }
 
 func (c *StackSetController) stackLogger(ssc *core.StackSetContainer, sc *core.StackContainer) *log.Entry {
-	return c.logger.WithFields(map[string]interface{}{
-		"namespace": ssc.StackSet.Namespace,
-		"stackset":  ssc.StackSet.Name,
-		"stack":     sc.Name(),
+	return c.stacksetLogger(ssc).WithField("stack", sc.Name())
+}
+
+// objectLogger returns a logger with the namespace and the kind and name of
+// an object, for messages outside of the reconciliation of a StackSet.
+func (c *StackSetController) objectLogger(namespace, kind, name string) *log.Entry {
+	return c.logger.WithFields(log.Fields{
+		"namespace": namespace,
+		kind:        name,
 	})
 }
 
+// reconcileID returns the ID of the current reconciliation of a StackSet, or
+// an empty string if it isn't being reconciled.
+func (c *StackSetController) reconcileID(uid types.UID) string {
+	c.Lock()
+	defer c.Unlock()
+	return c.reconcileIDs[uid]
+}
+
+// startReconcile assigns a new random ID to the reconciliation of a
+// StackSet, which is logged with every message about it so that the
+// messages of a single reconciliation can be correlated.
+func (c *StackSetController) startReconcile(uid types.UID) {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	c.Lock()
+	defer c.Unlock()
+	c.reconcileIDs[uid] = hex.EncodeToString(id)
+}
+
+// finishReconcile forgets the ID of the reconciliation of a StackSet.
+func (c *StackSetController) finishReconcile(uid types.UID) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.reconcileIDs, uid)
+}
+
 // Run runs the main loop of the StackSetController. Before the loops it
 // sets up a watcher to watch StackSet resources. The watch will send
 // changes over a channel which is polled from the main loop.
 func (c *StackSetController) Run(ctx context.Context) {
+	c.controllerStarted(time.Now())
 	c.startWatch(ctx)
 
+	// Fall back to listing the resources from the API server until the
+	// caches are synced
+	err := c.startResourceCaches(ctx)
+	if err != nil {
+		c.logger.Warnf("Listing resources until the caches are synced: %v", err)
+	}
+
+	err = c.auditIngressConflicts()
+	if err != nil {
+		c.logger.Errorf("Failed to audit the ingress routes: %v", err)
+	}
+
 	nextCheck := time.Now().Add(-c.interval)
 
 	for {
@@ -113,26 +269,26 @@ func (c *StackSetController) Run(ctx context.Context) {
 				c.logger.Errorf("Failed to collect resources: %v", err)
 				continue
 			}
+			c.pruneResourceHashes(stackContainers)
+			c.trafficMetrics.prune(stackContainers)
+			c.removalMetrics.prune(stackContainers)
+			c.pruneReconciledContainers(stackContainers)
+			c.pruneUnreportedFailures(stackContainers)
 
-			var reconcileGroup errgroup.Group
-			for stackset, container := range stackContainers {
-				container := container
-
-				reconcileGroup.Go(func() error {
-					if _, ok := c.stacksetStore[stackset]; ok {
-						err := c.ReconcileStackSet(container)
-						if err != nil {
-							c.stacksetLogger(container).Errorf("unable to reconcile a stackset: %v", err)
-							return c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
-						}
-					}
-					return nil
-				})
+			err = c.reconcileStackSets(stackContainers)
+			if err != nil {
+				c.logger.Errorf("Failed waiting for reconcilers: %v", err)
 			}
 
-			err = reconcileGroup.Wait()
+			err = c.ReconcileOrphanedStacks(stackContainers)
 			if err != nil {
-				c.logger.Errorf("Failed waiting for reconcilers: %v", err)
+				c.logger.Errorf("Failed to reconcile orphaned stacks: %v", err)
+			}
+			c.passFinished(time.Now())
+		case name := <-c.reconcileRequests:
+			err := c.reconcileRequested(name)
+			if err != nil {
+				c.objectLogger(name.Namespace, "stackset", name.Name).Errorf("Failed to reconcile the requested StackSet %s: %v", name, err)
 			}
 		case e := <-c.stacksetEvents:
 			stackset := *e.StackSet
@@ -140,8 +296,9 @@ func (c *StackSetController) Run(ctx context.Context) {
 
 			// update/delete existing entry
 			if _, ok := c.stacksetStore[stackset.UID]; ok {
-				if e.Deleted || !c.hasOwnership(&stackset) {
+				if e.Deleted || !c.manages(&stackset) {
 					delete(c.stacksetStore, stackset.UID)
+					c.resetBackoff(stackset.UID)
 					continue
 				}
 
@@ -151,11 +308,11 @@ func (c *StackSetController) Run(ctx context.Context) {
 			}
 
 			// check if stackset should be managed by the controller
-			if !c.hasOwnership(&stackset) {
+			if !c.manages(&stackset) {
 				continue
 			}
 
-			c.logger.Infof("Adding entry for StackSet %s/%s", stackset.Namespace, stackset.Name)
+			c.objectLogger(stackset.Namespace, "stackset", stackset.Name).Infof("Adding entry for StackSet %s/%s", stackset.Namespace, stackset.Name)
 			c.stacksetStore[stackset.UID] = stackset
 		case <-ctx.Done():
 			c.logger.Info("Terminating main controller loop.")
@@ -164,6 +321,135 @@ func (c *StackSetController) Run(ctx context.Context) {
 	}
 }
 
+// reconcileStackSets reconciles the StackSets with a pool of workers. Every
+// StackSet is handled by a single worker and the next pass only starts once
+// all of them are done, so a StackSet is never reconciled concurrently. The
+// first error is returned after all the StackSets were reconciled. StackSets
+// which failed to reconcile are retried with an exponential backoff.
+func (c *StackSetController) reconcileStackSets(stackContainers map[types.UID]*core.StackSetContainer) error {
+	now := time.Now()
+
+	// StackSets switching traffic are reconciled first, so that the switch
+	// isn't delayed by the routine reconciliation of idle StackSets
+	var switching, idle []*core.StackSetContainer
+	for stackset, container := range stackContainers {
+		if _, ok := c.stacksetStore[stackset]; !ok || c.backoffActive(stackset, now) {
+			continue
+		}
+		if container.TrafficSwitchPending() {
+			switching = append(switching, container)
+		} else {
+			idle = append(idle, container)
+		}
+	}
+
+	queue := make(chan *core.StackSetContainer, len(switching)+len(idle))
+	for _, container := range append(switching, idle...) {
+		queue <- container
+	}
+	close(queue)
+
+	workers := c.workers
+	if workers <= 0 || workers > len(queue) {
+		workers = len(queue)
+	}
+
+	var reconcileGroup errgroup.Group
+	for i := 0; i < workers; i++ {
+		reconcileGroup.Go(func() error {
+			var result error
+			for container := range queue {
+				c.startReconcile(container.StackSet.UID)
+				err := c.ReconcileStackSet(container)
+				if err != nil {
+					delay := c.reconcileFailed(container.StackSet.UID, now)
+					c.unreportedFailure(container.StackSet.UID, err, time.Now())
+					c.stacksetLogger(container).Errorf("unable to reconcile a stackset, retrying in %s: %v", delay, err)
+					err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+					if result == nil {
+						result = err
+					}
+				} else {
+					c.resetBackoff(container.StackSet.UID)
+					c.failuresReported(container.StackSet.UID)
+				}
+				c.containerReconciled(container)
+				c.finishReconcile(container.StackSet.UID)
+			}
+			return result
+		})
+	}
+	return reconcileGroup.Wait()
+}
+
+// backoffActive returns true if the StackSet failed to reconcile and the
+// delay before it's retried isn't over yet.
+func (c *StackSetController) backoffActive(uid types.UID, now time.Time) bool {
+	c.Lock()
+	defer c.Unlock()
+	retryAfter, ok := c.retryAfter[uid]
+	return ok && now.Before(retryAfter)
+}
+
+// reconcileFailed delays the next reconciliation of a StackSet, doubling the
+// delay with every consecutive failure, and returns the delay.
+func (c *StackSetController) reconcileFailed(uid types.UID, now time.Time) time.Duration {
+	delay := c.rateLimiter.When(uid)
+
+	c.Lock()
+	defer c.Unlock()
+	c.retryAfter[uid] = now.Add(delay)
+	return delay
+}
+
+// resetBackoff resets the backoff of a StackSet, e.g. once it was
+// reconciled successfully.
+func (c *StackSetController) resetBackoff(uid types.UID) {
+	c.rateLimiter.Forget(uid)
+
+	c.Lock()
+	defer c.Unlock()
+	delete(c.retryAfter, uid)
+}
+
+// resourcesUnchanged returns true if the resources of the Stack were already
+// reconciled with the same hash.
+func (c *StackSetController) resourcesUnchanged(uid types.UID, hash string) bool {
+	c.Lock()
+	defer c.Unlock()
+	return hash != "" && c.resourceHashes[uid] == hash
+}
+
+// resourcesReconciled stores the hash of the reconciled resources of a Stack.
+func (c *StackSetController) resourcesReconciled(uid types.UID, hash string) {
+	c.Lock()
+	defer c.Unlock()
+	if hash == "" {
+		delete(c.resourceHashes, uid)
+		return
+	}
+	c.resourceHashes[uid] = hash
+}
+
+// pruneResourceHashes forgets the resource hashes of the Stacks which don't
+// exist anymore.
+func (c *StackSetController) pruneResourceHashes(stackContainers map[types.UID]*core.StackSetContainer) {
+	c.Lock()
+	defer c.Unlock()
+	for uid := range c.resourceHashes {
+		found := false
+		for _, container := range stackContainers {
+			if _, ok := container.StackContainers[uid]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(c.resourceHashes, uid)
+		}
+	}
+}
+
 // collectResources collects resources for all stacksets at once and stores them per StackSet/Stack so that we don't
 // overload the API requests with unnecessary requests
 func (c *StackSetController) collectResources() (map[types.UID]*core.StackSetContainer, error) {
@@ -175,16 +461,102 @@ func (c *StackSetController) collectResources() (map[types.UID]*core.StackSetCon
 			StackContainers:   map[types.UID]*core.StackContainer{},
 			TrafficReconciler: &core.SimpleTrafficReconciler{},
 		}
+		stacksetContainer.SetDeprecations(stacksetDeprecations(&stackset))
 
-		// use prescaling logic if enabled with an annotation
-		if _, ok := stackset.Annotations[PrescaleStacksAnnotationKey]; ok {
+		// use prescaling logic if enabled with an annotation or in the spec
+		var prescalingSpec *zv1.PrescalingSpec
+		if stackset.Spec.TrafficPolicy != nil {
+			prescalingSpec = stackset.Spec.TrafficPolicy.Prescaling
+		}
+		if _, ok := stackset.Annotations[PrescaleStacksAnnotationKey]; ok || prescalingSpec != nil {
 			resetDelay := defaultResetMinReplicasDelay
 			if resetDelayValue, ok := getResetMinReplicasDelay(stackset.Annotations); ok {
 				resetDelay = resetDelayValue
 			}
-			stacksetContainer.TrafficReconciler = &core.PrescalingTrafficReconciler{
+			reconciler := &core.PrescalingTrafficReconciler{
 				ResetHPAMinReplicasTimeout: resetDelay,
 			}
+			if prescalingSpec != nil {
+				if prescalingSpec.Timeout != nil {
+					reconciler.ResetHPAMinReplicasTimeout = prescalingSpec.Timeout.Duration
+				}
+				if prescalingSpec.Cooldown != nil {
+					reconciler.Cooldown = prescalingSpec.Cooldown.Duration
+				}
+				if prescalingSpec.BufferPercent < 0 || prescalingSpec.BufferPercent > maxPrescalingBufferPercent {
+					c.recorder.Eventf(
+						stacksetContainer.StackSet,
+						apiv1.EventTypeWarning,
+						"InvalidPrescalingBuffer",
+						"Ignoring bufferPercent %d, it must be between 0 and %d", prescalingSpec.BufferPercent, maxPrescalingBufferPercent)
+				} else {
+					reconciler.BufferPercent = prescalingSpec.BufferPercent
+				}
+				reconciler.ReadinessThresholdPercent = prescalingSpec.ReadinessThresholdPercent
+				if prescalingSpec.CapacityCheck {
+					reconciler.Capacity = &podCapacity{client: c.client}
+				}
+			}
+			if _, ok := stackset.Annotations[PrescaleStacksByRPSAnnotationKey]; ok {
+				reconciler.RequestsPerSecond = &customMetricsRequestsPerSecond{
+					client:   c.client,
+					recorder: c.recorder,
+					logger:   c.stacksetLogger(stacksetContainer),
+					stackset: stacksetContainer.StackSet,
+				}
+			}
+			stacksetContainer.TrafficReconciler = reconciler
+		}
+
+		// switch the traffic without prescaling if requested for the current switch
+		if _, ok := stackset.Annotations[InstantTrafficSwitchAnnotationKey]; ok {
+			stacksetContainer.TrafficReconciler = &core.SimpleTrafficReconciler{}
+		}
+
+		// aggregate the autoscalers of the stacks if enabled with an annotation
+		if _, ok := stackset.Annotations[AggregateAutoscalingAnnotationKey]; ok {
+			stacksetContainer.AggregateAutoscaling = true
+		}
+
+		// pin the HPAs during traffic switches if enabled with an annotation
+		if freezeWindow, ok := getDurationAnnotation(stackset.Annotations, FreezeAutoscalersAnnotationKey); ok {
+			stacksetContainer.AutoscalerFreezeWindow = freezeWindow
+		}
+
+		// select the active autoscaler profile, the annotation takes precedence over the spec
+		stacksetContainer.AutoscalerProfile = stackset.Spec.AutoscalerProfile
+		if profile, ok := stackset.Annotations[core.AutoscalerProfileAnnotationKey]; ok {
+			stacksetContainer.AutoscalerProfile = profile
+		}
+
+		// override the HPA tolerance and CPU initialization period if set with an annotation
+		if tolerance, ok := getToleranceAnnotation(stackset.Annotations, HPAToleranceAnnotationKey); ok {
+			stacksetContainer.HPATolerance = tolerance
+		}
+		if period, ok := getDurationAnnotation(stackset.Annotations, HPACPUInitializationPeriodAnnotationKey); ok {
+			stacksetContainer.HPACPUInitializationPeriod = period
+		}
+
+		// report recommended resource requests if enabled with an annotation
+		if _, ok := stackset.Annotations[RecommendResourcesAnnotationKey]; ok {
+			stacksetContainer.ResourceUsage = &metricsAPIResourceUsage{client: c.client}
+		}
+
+		// only reconcile the generated HPA fields if enabled with an annotation
+		if _, ok := stackset.Annotations[HPAFieldOwnershipAnnotationKey]; ok {
+			stacksetContainer.HPAFieldOwnership = true
+		}
+
+		// take over resources created outside of the controller if enabled with an annotation
+		if _, ok := stackset.Annotations[AdoptResourcesAnnotationKey]; ok {
+			stacksetContainer.AdoptResources = true
+		}
+
+		// query the analysis checks of traffic ramps and rollbacks
+		if stackset.Spec.TrafficPolicy != nil && (stackset.Spec.TrafficPolicy.Ramp != nil || stackset.Spec.TrafficPolicy.Rollback != nil) {
+			stacksetContainer.TrafficAnalysis = &httpTrafficAnalysis{
+				client: &http.Client{Timeout: defaultTrafficAnalysisTimeout},
+			}
 		}
 
 		stacksets[uid] = stacksetContainer
@@ -219,13 +591,13 @@ func (c *StackSetController) collectResources() (map[types.UID]*core.StackSetCon
 }
 
 func (c *StackSetController) collectIngresses(stacksets map[types.UID]*core.StackSetContainer) error {
-	ingresses, err := c.client.ExtensionsV1beta1().Ingresses(v1.NamespaceAll).List(metav1.ListOptions{})
+	ingresses, err := c.listIngresses()
 	if err != nil {
 		return fmt.Errorf("failed to list Ingresses: %v", err)
 	}
 
 Items:
-	for _, i := range ingresses.Items {
+	for _, i := range ingresses {
 		ingress := i
 		if uid, ok := getOwnerUID(ingress.ObjectMeta); ok {
 			// stackset ingress
@@ -247,12 +619,12 @@ Items:
 }
 
 func (c *StackSetController) collectStacks(stacksets map[types.UID]*core.StackSetContainer) error {
-	stacks, err := c.client.ZalandoV1().Stacks(v1.NamespaceAll).List(metav1.ListOptions{})
+	stacks, err := c.listStacks()
 	if err != nil {
 		return fmt.Errorf("failed to list Stacks: %v", err)
 	}
 
-	for _, stack := range stacks.Items {
+	for _, stack := range stacks {
 		if uid, ok := getOwnerUID(stack.ObjectMeta); ok {
 			if s, ok := stacksets[uid]; ok {
 				stack := stack
@@ -264,17 +636,29 @@ func (c *StackSetController) collectStacks(stacksets map[types.UID]*core.StackSe
 				continue
 			}
 		}
+
+		// adopt stacks created outside of the controller
+		if s := adoptingStackSet(stacksets, &stack); s != nil {
+			adopted, err := c.adoptStack(s, &stack)
+			if err != nil {
+				c.objectLogger(stack.Namespace, "stack", stack.Name).Errorf("Failed to adopt Stack %s/%s: %v", stack.Namespace, stack.Name, err)
+				continue
+			}
+			s.StackContainers[adopted.UID] = &core.StackContainer{
+				Stack: adopted,
+			}
+		}
 	}
 	return nil
 }
 
 func (c *StackSetController) collectDeployments(stacksets map[types.UID]*core.StackSetContainer) error {
-	deployments, err := c.client.AppsV1().Deployments(v1.NamespaceAll).List(metav1.ListOptions{})
+	deployments, err := c.listDeployments()
 	if err != nil {
 		return fmt.Errorf("failed to list Deployments: %v", err)
 	}
 
-	for _, d := range deployments.Items {
+	for _, d := range deployments {
 		deployment := d
 		if uid, ok := getOwnerUID(deployment.ObjectMeta); ok {
 			for _, stackset := range stacksets {
@@ -283,19 +667,30 @@ func (c *StackSetController) collectDeployments(stacksets map[types.UID]*core.St
 					break
 				}
 			}
+			continue
+		}
+
+		// adopt deployments created outside of the controller
+		if s := adoptingStack(stacksets, deployment.ObjectMeta); s != nil {
+			adopted, err := c.adoptDeployment(s, &deployment)
+			if err != nil {
+				c.objectLogger(deployment.Namespace, "stack", deployment.Name).Errorf("Failed to adopt Deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+				continue
+			}
+			s.Resources.Deployment = adopted
 		}
 	}
 	return nil
 }
 
 func (c *StackSetController) collectServices(stacksets map[types.UID]*core.StackSetContainer) error {
-	services, err := c.client.CoreV1().Services(v1.NamespaceAll).List(metav1.ListOptions{})
+	services, err := c.listServices()
 	if err != nil {
 		return fmt.Errorf("failed to list Services: %v", err)
 	}
 
 Items:
-	for _, s := range services.Items {
+	for _, s := range services {
 		service := s
 		if uid, ok := getOwnerUID(service.ObjectMeta); ok {
 			for _, stackset := range stacksets {
@@ -312,19 +707,30 @@ Items:
 					}
 				}
 			}
+			continue
+		}
+
+		// adopt services created outside of the controller
+		if s := adoptingStack(stacksets, service.ObjectMeta); s != nil {
+			adopted, err := c.adoptService(s, &service)
+			if err != nil {
+				c.objectLogger(service.Namespace, "stack", service.Name).Errorf("Failed to adopt Service %s/%s: %v", service.Namespace, service.Name, err)
+				continue
+			}
+			s.Resources.Service = adopted
 		}
 	}
 	return nil
 }
 
 func (c *StackSetController) collectHPAs(stacksets map[types.UID]*core.StackSetContainer) error {
-	hpas, err := c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(v1.NamespaceAll).List(metav1.ListOptions{})
+	hpas, err := c.listHPAs()
 	if err != nil {
 		return fmt.Errorf("failed to list HPAs: %v", err)
 	}
 
 Items:
-	for _, h := range hpas.Items {
+	for _, h := range hpas {
 		hpa := h
 		if uid, ok := getOwnerUID(hpa.ObjectMeta); ok {
 			for _, stackset := range stacksets {
@@ -372,8 +778,12 @@ func (c *StackSetController) errorEventf(object runtime.Object, reason string, e
 // Whether it's owner is determined by the value of the
 // 'stackset-controller.zalando.org/controller' annotation. If the value
 // matches the controllerID then it owns it, or if the controllerID is
-// "" and there's no annotation set.
+// "" and there's no annotation set. StackSets which don't match the label
+// selector of the controller are never owned.
 func (c *StackSetController) hasOwnership(stackset *zv1.StackSet) bool {
+	if !c.stacksetSelector.Matches(labels.Set(stackset.Labels)) {
+		return false
+	}
 	if stackset.Annotations != nil {
 		if owner, ok := stackset.Annotations[StacksetControllerControllerAnnotationKey]; ok {
 			return owner == c.controllerID
@@ -382,24 +792,69 @@ func (c *StackSetController) hasOwnership(stackset *zv1.StackSet) bool {
 	return c.controllerID == ""
 }
 
-func (c *StackSetController) startWatch(ctx context.Context) {
-	informer := cache.NewSharedIndexInformer(
-		cache.NewListWatchFromClient(c.client.ZalandoV1().RESTClient(), "stacksets", v1.NamespaceAll, fields.Everything()),
-		&zv1.StackSet{},
-		0, // skip resync
-		cache.Indexers{},
-	)
+// manages returns true if the stackset is managed by the controller: it has
+// to own it, the namespace has to be managed and the stackset has to belong
+// to the shard of the controller.
+func (c *StackSetController) manages(stackset *zv1.StackSet) bool {
+	return c.hasOwnership(stackset) && c.managesNamespace(stackset.Namespace) && c.shard.Contains(stackset.UID)
+}
 
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.add,
-		UpdateFunc: c.update,
-		DeleteFunc: c.del,
-	})
-	go informer.Run(ctx.Done())
-	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+// managesNamespace returns true if the StackSets of the namespace are
+// managed by the controller, based on the configured namespaces and excluded
+// namespaces.
+func (c *StackSetController) managesNamespace(namespace string) bool {
+	for _, excluded := range c.excludedNamespaces {
+		if namespace == excluded {
+			return false
+		}
+	}
+	if len(c.namespaces) == 0 {
+		return true
+	}
+	for _, included := range c.namespaces {
+		if namespace == included {
+			return true
+		}
+	}
+	return false
+}
+
+// watchedNamespaces returns the namespaces the resources are listed and
+// watched in. Without configured namespaces, all of them are watched.
+func (c *StackSetController) watchedNamespaces() []string {
+	if len(c.namespaces) == 0 {
+		return []string{v1.NamespaceAll}
+	}
+	return c.namespaces
+}
+
+func (c *StackSetController) startWatch(ctx context.Context) {
+	var synced []cache.InformerSynced
+	for _, namespace := range c.watchedNamespaces() {
+		listWatch := cache.NewFilteredListWatchFromClient(c.client.ZalandoV1().RESTClient(), "stacksets", namespace, func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.Everything().String()
+			options.LabelSelector = c.stacksetSelector.String()
+		})
+		informer := cache.NewSharedIndexInformer(
+			c.pagedListWatch(ctx, listWatch),
+			&zv1.StackSet{},
+			c.resyncPeriod,
+			cache.Indexers{},
+		)
+
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.add,
+			UpdateFunc: c.update,
+			DeleteFunc: c.del,
+		})
+		go informer.Run(ctx.Done())
+		synced = append(synced, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
 		c.logger.Errorf("Timed out waiting for caches to sync")
 		return
 	}
+	c.watchSynced()
 	c.logger.Info("Synced StackSet watcher")
 }
 
@@ -409,7 +864,7 @@ func (c *StackSetController) add(obj interface{}) {
 		return
 	}
 
-	c.logger.Infof("New StackSet added %s/%s", stackset.Namespace, stackset.Name)
+	c.objectLogger(stackset.Namespace, "stackset", stackset.Name).Infof("New StackSet added %s/%s", stackset.Namespace, stackset.Name)
 	c.stacksetEvents <- stacksetEvent{
 		StackSet: stackset.DeepCopy(),
 	}
@@ -426,13 +881,13 @@ func (c *StackSetController) update(oldObj, newObj interface{}) {
 		return
 	}
 
-	c.logger.Debugf("StackSet %s/%s changed: %s",
+	c.objectLogger(newStackset.Namespace, "stackset", newStackset.Name).Debugf("StackSet %s/%s changed: %s",
 		newStackset.Namespace,
 		newStackset.Name,
 		cmp.Diff(oldStackset, newStackset, cmpopts.IgnoreUnexported(resource.Quantity{})),
 	)
 
-	c.logger.Infof("StackSet updated %s/%s", newStackset.Namespace, newStackset.Name)
+	c.objectLogger(newStackset.Namespace, "stackset", newStackset.Name).Infof("StackSet updated %s/%s", newStackset.Namespace, newStackset.Name)
 	c.stacksetEvents <- stacksetEvent{
 		StackSet: newStackset.DeepCopy(),
 	}
@@ -444,30 +899,29 @@ func (c *StackSetController) del(obj interface{}) {
 		return
 	}
 
-	c.logger.Infof("StackSet deleted %s/%s", stackset.Namespace, stackset.Name)
+	c.objectLogger(stackset.Namespace, "stackset", stackset.Name).Infof("StackSet deleted %s/%s", stackset.Namespace, stackset.Name)
 	c.stacksetEvents <- stacksetEvent{
 		StackSet: stackset.DeepCopy(),
 		Deleted:  true,
 	}
 }
 
+// retryUpdate calls updateFn until it doesn't fail with a conflict, e.g.
+// because the object was edited concurrently, with a short backoff and up to
+// a few times. retry is true if updateFn has to re-read the object and apply
+// its changes again because of a conflict.
 func retryUpdate(updateFn func(retry bool) error) error {
 	retry := false
-	for {
+	return clientretry.RetryOnConflict(clientretry.DefaultBackoff, func() error {
 		err := updateFn(retry)
-		if err != nil {
-			if errors.IsConflict(err) {
-				retry = true
-				continue
-			}
-			return err
-		}
-		return nil
-	}
+		retry = true
+		return err
+	})
 }
 
 // ReconcileStatuses reconciles the statuses of StackSets and Stacks.
 func (c *StackSetController) ReconcileStatuses(ssc *core.StackSetContainer) error {
+	var desiredTrafficChanges []core.TrafficChange
 	for _, sc := range ssc.StackContainers {
 		stack := sc.Stack.DeepCopy()
 		status := *sc.GenerateStackStatus()
@@ -479,13 +933,30 @@ func (c *StackSetController) ReconcileStatuses(ssc *core.StackSetContainer) erro
 				}
 				stack = updated
 			}
-			stack.Status = status
-			_, err := c.client.ZalandoV1().Stacks(sc.Namespace()).UpdateStatus(stack)
-			return err
+			if !equality.Semantic.DeepEqual(status, stack.Status) {
+				stack.Status = status
+				_, err := c.client.ZalandoV1().Stacks(sc.Namespace()).UpdateStatus(stack)
+				return err
+			}
+			return nil
 		})
+		if errors.IsNotFound(err) {
+			// the stack was deleted once its teardown finished
+			continue
+		}
 		if err != nil {
 			return c.errorEventf(sc.Stack, "FailedUpdateStackStatus", err)
 		}
+		c.recordConditionTransitions(sc.Stack, sc.Stack.Status.Conditions, status.Conditions)
+		c.recordPrescalingTransitions(sc.Stack, sc.Stack.Status.Prescaling, status.Prescaling)
+		c.prescalingMetrics.observe(ssc.StackSet, sc.Stack.Status.Prescaling, status.Prescaling, time.Now())
+		if status.DesiredTrafficWeight != sc.Stack.Status.DesiredTrafficWeight {
+			desiredTrafficChanges = append(desiredTrafficChanges, core.TrafficChange{
+				StackName:        sc.Name(),
+				OldTrafficWeight: sc.Stack.Status.DesiredTrafficWeight,
+				NewTrafficWeight: status.DesiredTrafficWeight,
+			})
+		}
 	}
 
 	stackset := ssc.StackSet.DeepCopy()
@@ -509,6 +980,7 @@ func (c *StackSetController) ReconcileStatuses(ssc *core.StackSetContainer) erro
 	if err != nil {
 		return c.errorEventf(ssc.StackSet, "FailedUpdateStackSetStatus", err)
 	}
+	c.recordDesiredTrafficChanges(ssc.StackSet, desiredTrafficChanges)
 	return nil
 }
 
@@ -531,11 +1003,23 @@ func (c *StackSetController) CreateCurrentStack(ssc *core.StackSetContainer) err
 		"CreatedStack",
 		"Created stack %s",
 		newStack.Name())
+	c.stacksetResourceChanged(ssc.StackSet, changeCreated, "Stack", newStack.Name())
 
 	// Persist ObservedStackVersion in the status
 	updated := ssc.StackSet.DeepCopy()
-	updated.Status.ObservedStackVersion = newStackVersion
-	result, err := c.client.ZalandoV1().StackSets(ssc.StackSet.Namespace).UpdateStatus(updated)
+	var result *zv1.StackSet
+	err = retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().StackSets(ssc.StackSet.Namespace).Get(ssc.StackSet.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated = current
+		}
+		updated.Status.ObservedStackVersion = newStackVersion
+		result, err = c.client.ZalandoV1().StackSets(ssc.StackSet.Namespace).UpdateStatus(updated)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -552,12 +1036,58 @@ func (c *StackSetController) CreateCurrentStack(ssc *core.StackSetContainer) err
 
 // CleanupOldStacks deletes stacks that are no longer needed.
 func (c *StackSetController) CleanupOldStacks(ssc *core.StackSetContainer) error {
+	hookCalled := false
 	for _, sc := range ssc.StackContainers {
 		if !sc.PendingRemoval {
 			continue
 		}
 
 		stack := sc.Stack
+
+		// Never delete a stack which is getting traffic, unless it's
+		// being drained
+		if sc.ActualTrafficWeight() > 0 {
+			if sc.Draining() {
+				continue
+			}
+			c.recorder.Eventf(
+				ssc.StackSet,
+				apiv1.EventTypeWarning,
+				"RefusedDeleteStack",
+				"Refused to delete stack %s, it's getting %.1f%% of the traffic",
+				stack.Name,
+				sc.ActualTrafficWeight())
+			continue
+		}
+
+		// Notify the pre-deletion hook, the stack is only deleted once it
+		// succeeded. The result is recorded in the stack status, so failed
+		// calls are only retried after a backoff. As the call blocks the
+		// reconciliation, the hook is called for one stack at a time.
+		if hook := ssc.StackSet.Spec.StackLifecycle.PreDeleteHook; hook != nil {
+			status := sc.PreDeleteHookStatus()
+			if status == nil || !status.Succeeded {
+				now := time.Now()
+				if hookCalled || !lifecycleHookDue(status, now) {
+					continue
+				}
+				hookCalled = true
+
+				err := callLifecycleHook(hook, lifecycleHookEventPreDelete, stack)
+				sc.SetPreDeleteHookStatus(lifecycleHookResult(status, err, now))
+				if err != nil {
+					c.recorder.Eventf(
+						ssc.StackSet,
+						apiv1.EventTypeWarning,
+						"PreDeleteHookFailed",
+						"Not deleting stack %s: %v",
+						stack.Name,
+						err)
+					continue
+				}
+			}
+		}
+
 		err := c.client.ZalandoV1().Stacks(stack.Namespace).Delete(stack.Name, nil)
 		if err != nil {
 			return c.errorEventf(ssc.StackSet, "FailedDeleteStack", err)
@@ -568,80 +1098,550 @@ func (c *StackSetController) CleanupOldStacks(ssc *core.StackSetContainer) error
 			"DeletedExcessStack",
 			"Deleted excess stack %s",
 			stack.Name)
+		c.stacksetResourceChanged(ssc.StackSet, changeDeleted, "Stack", stack.Name)
 	}
 
 	return nil
 }
 
-func (c *StackSetController) ReconcileStackSetIngress(stackset *zv1.StackSet, existing *extensions.Ingress, generateUpdated func() (*extensions.Ingress, error)) error {
-	ingress, err := generateUpdated()
-	if err != nil {
-		return err
+// ReconcileStackFinalizer makes sure the traffic finalizer is set on the
+// stack. If the stack is being deleted, the finalizer is only removed once
+// the stack doesn't get traffic anymore. Until then, the DeletionBlocked
+// condition of the stack is set.
+func (c *StackSetController) ReconcileStackFinalizer(sc *core.StackContainer) error {
+	stack := sc.Stack
+	finalizerSet := hasFinalizer(stack.Finalizers, core.StackTrafficFinalizer)
+
+	if stack.DeletionTimestamp == nil {
+		if finalizerSet {
+			return nil
+		}
+		return c.updateStackFinalizers(sc, func(finalizers []string) []string {
+			if hasFinalizer(finalizers, core.StackTrafficFinalizer) {
+				return finalizers
+			}
+			return append(finalizers, core.StackTrafficFinalizer)
+		})
 	}
 
-	// Ingress removed
-	if ingress == nil {
-		if existing != nil {
-			err := c.client.ExtensionsV1beta1().Ingresses(existing.Namespace).Delete(existing.Name, &metav1.DeleteOptions{})
+	if !finalizerSet {
+		return nil
+	}
+	if sc.ActualTrafficWeight() > 0 {
+		sc.SetDeletionBlocked(true)
+		return nil
+	}
+	sc.SetDeletionBlocked(false)
+	return c.updateStackFinalizers(sc, func(finalizers []string) []string {
+		return removeFinalizer(finalizers, core.StackTrafficFinalizer)
+	})
+}
+
+// updateStackFinalizers updates the finalizers of the stack with updateFn,
+// which is applied again to the current finalizers of the stack if the
+// update conflicts.
+func (c *StackSetController) updateStackFinalizers(sc *core.StackContainer, updateFn func(finalizers []string) []string) error {
+	updated := sc.Stack.DeepCopy()
+	var result *zv1.Stack
+	err := retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().Stacks(updated.Namespace).Get(updated.Name, metav1.GetOptions{})
 			if err != nil {
 				return err
 			}
-			c.recorder.Eventf(
-				stackset,
-				apiv1.EventTypeNormal,
-				"DeletedIngress",
-				"Deleted Ingress %s",
-				existing.Namespace)
+			updated = current
 		}
-		return nil
+		updated.Finalizers = updateFn(updated.Finalizers)
+		var err error
+		result, err = c.client.ZalandoV1().Stacks(updated.Namespace).Update(updated)
+		return err
+	})
+	if err != nil {
+		return err
 	}
+	fixupStackTypeMeta(result)
+	sc.Stack = result
+	return nil
+}
 
-	// Create new Ingress
-	if existing == nil {
-		_, err := c.client.ExtensionsV1beta1().Ingresses(ingress.Namespace).Create(ingress)
+// ReconcileOrphanedStacks releases the traffic finalizer of deleted stacks
+// whose StackSet is gone and tears down their resources. The finalizers of
+// stacks are otherwise only handled while their StackSet is reconciled, so
+// those stacks would stay terminating forever. The StackSet ingress is
+// deleted together with the StackSet, so the stacks don't get traffic
+// anymore.
+func (c *StackSetController) ReconcileOrphanedStacks(stacksets map[types.UID]*core.StackSetContainer) error {
+	orphans, err := c.collectOrphanedStacks(stacksets)
+	if err != nil || len(orphans) == 0 {
+		return err
+	}
+
+	// Collect the resources of the orphaned stacks like the ones of a
+	// StackSet
+	detached := map[types.UID]*core.StackSetContainer{
+		"": {StackContainers: make(map[types.UID]*core.StackContainer, len(orphans))},
+	}
+	for _, sc := range orphans {
+		detached[""].StackContainers[sc.Stack.UID] = sc
+	}
+	for _, collect := range []func(map[types.UID]*core.StackSetContainer) error{
+		c.collectIngresses,
+		c.collectDeployments,
+		c.collectServices,
+		c.collectHPAs,
+	} {
+		err := collect(detached)
 		if err != nil {
 			return err
 		}
-		c.recorder.Eventf(
-			stackset,
-			apiv1.EventTypeNormal,
-			"CreatedIngress",
-			"Created Ingress %s",
-			ingress.Name)
-		return nil
-	}
-
-	// Check if we need to update the Ingress
-	if equality.Semantic.DeepDerivative(ingress.Spec, existing.Spec) && equality.Semantic.DeepEqual(ingress.Annotations, existing.Annotations) {
-		return nil
 	}
 
-	updated := existing.DeepCopy()
-	updated.Spec = ingress.Spec
+	for _, sc := range orphans {
+		logger := c.logger.WithFields(map[string]interface{}{
+			"namespace": sc.Namespace(),
+			"stack":     sc.Name(),
+		})
+		if hasFinalizer(sc.Stack.Finalizers, core.StackTrafficFinalizer) {
+			err := c.updateStackFinalizers(sc, func(finalizers []string) []string {
+				return removeFinalizer(finalizers, core.StackTrafficFinalizer)
+			})
+			if err != nil {
+				err = c.errorEventf(sc.Stack, "FailedManageStack", err)
+				logger.Errorf("Unable to release orphaned stack: %v", err)
+				continue
+			}
+		}
 
-	_, err = c.client.ExtensionsV1beta1().Ingresses(updated.Namespace).Update(ingress)
-	if err != nil {
-		return err
+		err := c.ReconcileStackTeardown(sc)
+		if err != nil {
+			err = c.errorEventf(sc.Stack, "FailedManageStack", err)
+			logger.Errorf("Unable to tear down orphaned stack: %v", err)
+		}
 	}
-	c.recorder.Eventf(
-		stackset,
-		apiv1.EventTypeNormal,
-		"UpdatedIngress",
-		"Updated Ingress %s",
-		ingress.Name)
 	return nil
 }
 
-func (c *StackSetController) ReconcileStackSetResources(ssc *core.StackSetContainer) error {
-	err := c.ReconcileStackSetIngress(ssc.StackSet, ssc.Ingress, ssc.GenerateIngress)
+// collectOrphanedStacks returns the deleted stacks of the controller which
+// don't have a StackSet anymore. Stacks whose owner isn't reconciled by the
+// controller are only considered orphaned if the owner doesn't exist anymore.
+func (c *StackSetController) collectOrphanedStacks(stacksets map[types.UID]*core.StackSetContainer) ([]*core.StackContainer, error) {
+	stacks, err := c.client.ZalandoV1().Stacks(v1.NamespaceAll).List(metav1.ListOptions{})
 	if err != nil {
-		return c.errorEventf(ssc.StackSet, "FailedManageIngress", err)
+		return nil, fmt.Errorf("failed to list Stacks: %v", err)
 	}
 
-	trafficChanges := ssc.TrafficChanges()
-	if len(trafficChanges) != 0 {
-		var changeMessages []string
-		for _, change := range trafficChanges {
+	var result []*core.StackContainer
+	for _, stack := range stacks.Items {
+		if stack.DeletionTimestamp == nil {
+			continue
+		}
+		if !hasFinalizer(stack.Finalizers, core.StackTrafficFinalizer) && !hasFinalizer(stack.Finalizers, core.StackTeardownFinalizer) {
+			continue
+		}
+
+		if uid, ok := getOwnerUID(stack.ObjectMeta); ok {
+			if _, ok := stacksets[uid]; ok {
+				continue
+			}
+			owner, err := c.client.ZalandoV1().StackSets(stack.Namespace).Get(stack.OwnerReferences[0].Name, metav1.GetOptions{})
+			if err == nil && owner.UID == uid {
+				continue
+			}
+			if err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+		}
+
+		stack := stack
+		fixupStackTypeMeta(&stack)
+		result = append(result, &core.StackContainer{Stack: &stack})
+	}
+	return result, nil
+}
+
+// ReconcileStackTeardown makes sure the teardown finalizer is set on the
+// stack. If the stack is being deleted, its resources are deleted one at a
+// time once the traffic finalizer is gone: first the ingress, so no requests
+// are routed to the stack anymore, then the service, the HPA and finally the
+// deployment. The finalizer is removed when all of them are gone.
+func (c *StackSetController) ReconcileStackTeardown(sc *core.StackContainer) error {
+	stack := sc.Stack
+	finalizerSet := hasFinalizer(stack.Finalizers, core.StackTeardownFinalizer)
+
+	if stack.DeletionTimestamp == nil {
+		if finalizerSet {
+			return nil
+		}
+		return c.updateStackFinalizers(sc, func(finalizers []string) []string {
+			if hasFinalizer(finalizers, core.StackTeardownFinalizer) {
+				return finalizers
+			}
+			return append(finalizers, core.StackTeardownFinalizer)
+		})
+	}
+
+	if !finalizerSet || hasFinalizer(stack.Finalizers, core.StackTrafficFinalizer) {
+		return nil
+	}
+
+	deleted, err := c.deleteNextStackResource(sc)
+	if err != nil || deleted {
+		return err
+	}
+	return c.updateStackFinalizers(sc, func(finalizers []string) []string {
+		return removeFinalizer(finalizers, core.StackTeardownFinalizer)
+	})
+}
+
+// deleteNextStackResource deletes the first remaining resource of a stack in
+// the teardown order. The next one is only deleted on a later reconciliation,
+// after the previous one is gone. Resources which are already gone, but still
+// listed because the collected resources are outdated, are skipped. Returns
+// false if there's nothing left to delete.
+func (c *StackSetController) deleteNextStackResource(sc *core.StackContainer) (bool, error) {
+	type stackResource struct {
+		kind   string
+		meta   *metav1.ObjectMeta
+		delete func(name string, options *metav1.DeleteOptions) error
+	}
+
+	var ordered []stackResource
+	resources := sc.Resources
+	if resources.Ingress != nil {
+		ordered = append(ordered, stackResource{"Ingress", &resources.Ingress.ObjectMeta, c.client.ExtensionsV1beta1().Ingresses(resources.Ingress.Namespace).Delete})
+	}
+	if resources.Service != nil {
+		ordered = append(ordered, stackResource{"Service", &resources.Service.ObjectMeta, c.client.CoreV1().Services(resources.Service.Namespace).Delete})
+	}
+	if resources.HPA != nil {
+		ordered = append(ordered, stackResource{"HPA", &resources.HPA.ObjectMeta, c.client.AutoscalingV2beta1().HorizontalPodAutoscalers(resources.HPA.Namespace).Delete})
+	}
+	if resources.Deployment != nil {
+		ordered = append(ordered, stackResource{"Deployment", &resources.Deployment.ObjectMeta, c.client.AppsV1().Deployments(resources.Deployment.Namespace).Delete})
+	}
+
+	for _, resource := range ordered {
+		err := resource.delete(resource.meta.Name, &metav1.DeleteOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		c.recorder.Eventf(
+			sc.Stack,
+			apiv1.EventTypeNormal,
+			"Deleted"+resource.kind,
+			"Deleted %s %s",
+			resource.kind,
+			resource.meta.Name)
+		c.stackResourceChanged(sc.Stack, changeDeleted, resource.kind, resource.meta.Name)
+		return true, nil
+	}
+	return false, nil
+}
+
+// ReconcileInstantTrafficSwitch removes the instant traffic switch annotation
+// from the stackset once the traffic was switched without prescaling, so it
+// only applies to a single switch.
+func (c *StackSetController) ReconcileInstantTrafficSwitch(ssc *core.StackSetContainer) error {
+	if _, ok := ssc.StackSet.Annotations[InstantTrafficSwitchAnnotationKey]; !ok {
+		return nil
+	}
+
+	updated := ssc.StackSet.DeepCopy()
+	var result *zv1.StackSet
+	err := retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().StackSets(updated.Namespace).Get(updated.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated = current
+		}
+		delete(updated.Annotations, InstantTrafficSwitchAnnotationKey)
+
+		var err error
+		result, err = c.client.ZalandoV1().StackSets(updated.Namespace).Update(updated)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fixupStackSetTypeMeta(result)
+	ssc.StackSet = result
+
+	c.recorder.Event(
+		ssc.StackSet,
+		apiv1.EventTypeNormal,
+		"InstantTrafficSwitch",
+		"Switched traffic without prescaling")
+	return nil
+}
+
+// ReconcileTrafficSnapshotRestore clears the traffic snapshot requested in the
+// stackset spec, so it's only restored once. It must only be called after the
+// traffic was managed and the stackset ingress updated successfully,
+// otherwise the request is kept and retried.
+func (c *StackSetController) ReconcileTrafficSnapshotRestore(ssc *core.StackSetContainer) error {
+	name := ssc.StackSet.Spec.TrafficSnapshotRestore
+	if name == "" {
+		return nil
+	}
+
+	restored := false
+	for _, snapshot := range ssc.StackSet.Status.TrafficSnapshots {
+		if snapshot.Name == name {
+			restored = true
+		}
+	}
+
+	updated := ssc.StackSet.DeepCopy()
+	var result *zv1.StackSet
+	err := retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().StackSets(updated.Namespace).Get(updated.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated = current
+		}
+		updated.Spec.TrafficSnapshotRestore = ""
+
+		var err error
+		result, err = c.client.ZalandoV1().StackSets(updated.Namespace).Update(updated)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fixupStackSetTypeMeta(result)
+	ssc.StackSet = result
+
+	if !restored {
+		c.recorder.Eventf(
+			ssc.StackSet,
+			apiv1.EventTypeWarning,
+			"UnknownTrafficSnapshot",
+			"Traffic snapshot %s doesn't exist",
+			name)
+		return nil
+	}
+	c.recorder.Eventf(
+		ssc.StackSet,
+		apiv1.EventTypeNormal,
+		"RestoredTrafficSnapshot",
+		"Restored traffic snapshot %s",
+		name)
+	return nil
+}
+
+// ReconcileStackSetDeletion manages the orphan finalizer of the stackset
+// according to its deletion policy. If a stackset with the Orphan policy is
+// deleted, the owner references are removed from its stacks and its ingress
+// before the finalizer is removed. Returns true if the stackset is being
+// deleted and mustn't be reconciled any further.
+func (c *StackSetController) ReconcileStackSetDeletion(ssc *core.StackSetContainer) (bool, error) {
+	stackset := ssc.StackSet
+	orphan := stackset.Spec.StackLifecycle.OnDelete == zv1.StackSetDeletionPolicyOrphan
+	finalizerSet := hasFinalizer(stackset.Finalizers, core.StackSetOrphanFinalizer)
+
+	if stackset.DeletionTimestamp != nil {
+		if !finalizerSet {
+			return false, nil
+		}
+
+		for _, sc := range ssc.StackContainers {
+			err := c.orphanStack(stackset, sc)
+			if err != nil {
+				return true, err
+			}
+		}
+
+		if ssc.Ingress != nil && hasOwnerReference(ssc.Ingress.OwnerReferences, stackset.UID) {
+			updated := ssc.Ingress.DeepCopy()
+			updated.OwnerReferences = removeOwnerReference(updated.OwnerReferences, stackset.UID)
+			patch, err := strategicMergePatch(ssc.Ingress, updated, extensions.Ingress{})
+			if err != nil {
+				return true, err
+			}
+			_, err = c.client.ExtensionsV1beta1().Ingresses(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
+			if err != nil {
+				return true, err
+			}
+		}
+
+		result, err := c.updateOrphanFinalizer(stackset, false)
+		if err != nil {
+			return true, err
+		}
+		ssc.StackSet = result
+		return true, nil
+	}
+
+	if orphan == finalizerSet {
+		return false, nil
+	}
+
+	result, err := c.updateOrphanFinalizer(stackset, orphan)
+	if err != nil {
+		return false, err
+	}
+	ssc.StackSet = result
+	return false, nil
+}
+
+// updateOrphanFinalizer adds or removes the orphan finalizer of the stackset.
+func (c *StackSetController) updateOrphanFinalizer(stackset *zv1.StackSet, set bool) (*zv1.StackSet, error) {
+	updated := stackset.DeepCopy()
+	var result *zv1.StackSet
+	err := retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().StackSets(updated.Namespace).Get(updated.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated = current
+		}
+		updated.Finalizers = removeFinalizer(updated.Finalizers, core.StackSetOrphanFinalizer)
+		if set {
+			updated.Finalizers = append(updated.Finalizers, core.StackSetOrphanFinalizer)
+		}
+
+		var err error
+		result, err = c.client.ZalandoV1().StackSets(updated.Namespace).Update(updated)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	fixupStackSetTypeMeta(result)
+	return result, nil
+}
+
+// orphanStack removes the owner reference to the stackset from the stack.
+func (c *StackSetController) orphanStack(stackset *zv1.StackSet, sc *core.StackContainer) error {
+	if !hasOwnerReference(sc.Stack.OwnerReferences, stackset.UID) {
+		return nil
+	}
+
+	updated := sc.Stack.DeepCopy()
+	var result *zv1.Stack
+	err := retryUpdate(func(retry bool) error {
+		if retry {
+			current, err := c.client.ZalandoV1().Stacks(updated.Namespace).Get(updated.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			updated = current
+		}
+		updated.OwnerReferences = removeOwnerReference(updated.OwnerReferences, stackset.UID)
+
+		var err error
+		result, err = c.client.ZalandoV1().Stacks(updated.Namespace).Update(updated)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fixupStackTypeMeta(result)
+	sc.Stack = result
+
+	c.recorder.Eventf(
+		stackset,
+		apiv1.EventTypeNormal,
+		"OrphanedStack",
+		"Orphaned stack %s",
+		result.Name)
+	return nil
+}
+
+func (c *StackSetController) ReconcileStackSetIngress(stackset *zv1.StackSet, existing *extensions.Ingress, generateUpdated func() (*extensions.Ingress, error)) error {
+	ingress, err := generateUpdated()
+	if err != nil {
+		return err
+	}
+
+	// Ingress removed
+	if ingress == nil {
+		if existing != nil {
+			err := c.client.ExtensionsV1beta1().Ingresses(existing.Namespace).Delete(existing.Name, &metav1.DeleteOptions{})
+			if err != nil {
+				return err
+			}
+			c.recorder.Eventf(
+				stackset,
+				apiv1.EventTypeNormal,
+				"DeletedIngress",
+				"Deleted Ingress %s",
+				existing.Namespace)
+			c.stacksetResourceChanged(stackset, changeDeleted, "Ingress", existing.Name)
+		}
+		return nil
+	}
+
+	// Create new Ingress
+	if existing == nil {
+		c.stampProvenance(ingress)
+		_, err := c.client.ExtensionsV1beta1().Ingresses(ingress.Namespace).Create(ingress)
+		if err != nil {
+			return err
+		}
+		c.recorder.Eventf(
+			stackset,
+			apiv1.EventTypeNormal,
+			"CreatedIngress",
+			"Created Ingress %s",
+			ingress.Name)
+		c.stacksetResourceChanged(stackset, changeCreated, "Ingress", ingress.Name)
+		return nil
+	}
+
+	// Check if we need to update the Ingress
+	if equality.Semantic.DeepDerivative(ingress.Spec, existing.Spec) && equality.Semantic.DeepEqual(ingress.Annotations, withoutProvenance(existing.Annotations)) {
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	syncObjectMeta(updated, ingress)
+	updated.Spec = ingress.Spec
+	c.stampProvenance(updated)
+
+	patch, err := strategicMergePatch(existing, updated, extensions.Ingress{})
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return nil
+	}
+
+	c.withSubsystem(SubsystemResources, c.objectLogger(stackset.Namespace, "stackset", stackset.Name)).Debugf("Patching Ingress %s: %s", updated.Name, patch)
+	_, err = c.client.ExtensionsV1beta1().Ingresses(updated.Namespace).Patch(updated.Name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		stackset,
+		apiv1.EventTypeNormal,
+		"UpdatedIngress",
+		"Updated Ingress %s",
+		ingress.Name)
+	c.stacksetResourceChanged(stackset, changeUpdated, "Ingress", ingress.Name)
+	return nil
+}
+
+func (c *StackSetController) ReconcileStackSetResources(ssc *core.StackSetContainer) error {
+	err := c.ReconcileStackSetIngress(ssc.StackSet, ssc.Ingress, ssc.GenerateIngress)
+	ssc.SetIngressReconciled(err)
+	if err != nil {
+		return c.errorEventf(ssc.StackSet, "FailedManageIngress", err)
+	}
+
+	trafficChanges := ssc.TrafficChanges()
+	if len(trafficChanges) != 0 {
+		var changeMessages []string
+		for _, change := range trafficChanges {
 			changeMessages = append(changeMessages, change.String())
 		}
 
@@ -651,20 +1651,39 @@ func (c *StackSetController) ReconcileStackSetResources(ssc *core.StackSetContai
 			"TrafficSwitched",
 			"Switched traffic: %s",
 			strings.Join(changeMessages, ", "))
+		c.trafficSwitched(ssc.StackSet, trafficChanges)
 	}
 
 	return nil
 }
 
 func (c *StackSetController) ReconcileStackResources(ssc *core.StackSetContainer, sc *core.StackContainer) error {
-	err := c.ReconcileStackDeployment(sc.Stack, sc.Resources.Deployment, sc.GenerateDeployment)
+	// The resources of deleted stacks are torn down by their finalizer
+	if sc.Stack.DeletionTimestamp != nil {
+		return nil
+	}
+
+	// Skip the stacks whose resources were already reconciled and didn't
+	// change since
+	hash, err := sc.ResourcesHash(ssc.HPAFieldOwnership)
 	if err != nil {
-		return c.errorEventf(sc.Stack, "FailedManageDeployment", err)
+		return err
+	}
+	if c.resourcesUnchanged(sc.Stack.UID, hash) {
+		return nil
 	}
 
-	err = c.ReconcileStackHPA(sc.Stack, sc.Resources.HPA, sc.GenerateHPA)
+	err = c.ReconcileStackDeployment(sc.Stack, sc.Resources.Deployment, sc.GenerateDeployment)
 	if err != nil {
-		return c.errorEventf(sc.Stack, "FailedManageHPA", err)
+		return c.errorEventf(sc.Stack, "FailedManageDeployment", err)
+	}
+
+	// Invalid autoscaler metrics are already reported with the offending
+	// metric by the AutoscalerValid condition of the stack. The existing HPA
+	// is kept and the other resources are reconciled anyway.
+	hpaErr := c.ReconcileStackHPA(sc.Stack, sc.Resources.HPA, ssc.HPAFieldOwnership, sc.GenerateHPA)
+	if _, invalidMetric := hpaErr.(*core.MetricError); hpaErr != nil && !invalidMetric {
+		return c.errorEventf(sc.Stack, "FailedManageHPA", hpaErr)
 	}
 
 	err = c.ReconcileStackService(sc.Stack, sc.Resources.Service, sc.GenerateService)
@@ -677,15 +1696,152 @@ func (c *StackSetController) ReconcileStackResources(ssc *core.StackSetContainer
 		return c.errorEventf(sc.Stack, "FailedManageIngress", err)
 	}
 
+	if hpaErr != nil {
+		return &eventedError{err: hpaErr}
+	}
+
+	c.resourcesReconciled(sc.Stack.UID, hash)
 	return nil
 }
 
+// reconcileStacksResources reconciles the resources of the stacks of a
+// StackSet concurrently, up to stackWorkers stacks at a time or all of them
+// at once if it isn't set. Errors are reported per stack. No further stacks
+// are started once the deadline is exceeded, in which case true is returned.
+func (c *StackSetController) reconcileStacksResources(container *core.StackSetContainer, deadline time.Time) bool {
+	workers := c.stackWorkers
+	if workers <= 0 || workers > len(container.StackContainers) {
+		workers = len(container.StackContainers)
+	}
+	semaphore := make(chan struct{}, workers)
+
+	var (
+		wg       sync.WaitGroup
+		timedOut bool
+	)
+	for _, sc := range container.StackContainers {
+		sc := sc
+		semaphore <- struct{}{}
+		if deadlineExceeded(deadline) {
+			<-semaphore
+			timedOut = true
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			err := c.ReconcileStackResources(container, sc)
+			sc.SetResourcesReconciled(err)
+			if err != nil {
+				err = c.errorEventf(sc.Stack, "FailedManageStack", err)
+				c.withSubsystem(SubsystemResources, c.stackLogger(container, sc)).Errorf("Unable to reconcile stack resources: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, sc := range container.StackContainers {
+		if err := sc.ResourcesError(); err != nil {
+			recordStackError(container, sc, err)
+		}
+	}
+	return timedOut
+}
+
+// reconcileDeadline returns the time by which a reconciliation started at now
+// has to finish, or the zero time if reconciliations aren't limited.
+func (c *StackSetController) reconcileDeadline(now time.Time) time.Time {
+	if c.reconcileTimeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(c.reconcileTimeout)
+}
+
+// deadlineExceeded returns true if the deadline is set and already passed.
+func deadlineExceeded(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// requeue queues the reconciliation of a StackSet right after the current
+// pass. The request is dropped if too many reconciliations are pending, the
+// StackSet is then reconciled in the next pass.
+func (c *StackSetController) requeue(stackset *zv1.StackSet) {
+	select {
+	case c.reconcileRequests <- types.NamespacedName{Namespace: stackset.Namespace, Name: stackset.Name}:
+	default:
+	}
+}
+
+// ReconcileStackSet reconciles a StackSet and its stacks. Once the reconcile
+// timeout is exceeded, the remaining resource updates are skipped, the
+// statuses are updated with the progress so far and the StackSet is requeued.
 func (c *StackSetController) ReconcileStackSet(container *core.StackSetContainer) error {
+	deadline := c.reconcileDeadline(time.Now())
+
+	// Record the previous reconciliations aborted before the status was
+	// updated
+	c.recordUnreportedFailures(container)
+
+	// Collect the changes to the resources for the status, discarding them
+	// if the reconciliation is aborted
+	c.startChangeSummary(container.StackSet.UID)
+	defer c.finishChangeSummary(container.StackSet.UID, time.Now())
+
+	// Orphan the stacks of deleted stacksets if configured. Abort on errors.
+	deleted, err := c.ReconcileStackSetDeletion(container)
+	if err != nil || deleted {
+		return err
+	}
+
+	// Only update the statuses of paused stacksets.
+	if container.StackSet.Spec.Paused {
+		c.stacksetLogger(container).Debug("StackSet is paused, skipping reconciliation")
+
+		err := container.UpdateFromResources()
+		if err != nil {
+			return err
+		}
+		container.UpdateStackConditions()
+		c.trafficMetrics.update(container)
+		c.removalMetrics.update(container)
+		return c.ReconcileStatuses(container)
+	}
+
+	// Keep the traffic and the stacks as they are during maintenance windows
+	maintenance, err := maintenanceWindowActive(container.StackSet.Spec.MaintenanceWindows, time.Now())
+	if err != nil {
+		c.stacksetLogger(container).Warnf("Maintenance windows failed: %v", err)
+		c.recorder.Eventf(
+			container.StackSet,
+			v1.EventTypeWarning,
+			"InvalidMaintenanceWindow",
+			"Failed to evaluate maintenance windows: "+err.Error())
+		container.RecordError(err, time.Now())
+	}
+	if maintenance {
+		c.stacksetLogger(container).Debug("StackSet is in a maintenance window, keeping traffic and stacks")
+	}
+
+	// Apply scheduled traffic switches which are due. Proceed on errors.
+	if !maintenance {
+		err = c.ReconcileScheduledTraffic(container, time.Now())
+		if err != nil {
+			err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+			c.withSubsystem(SubsystemTraffic, c.stacksetLogger(container)).Errorf("Unable to apply scheduled traffic: %v", err)
+			container.RecordError(err, time.Now())
+		}
+	}
+
 	// Create current stack, if needed. Proceed on errors.
-	err := c.CreateCurrentStack(container)
+	err = c.CreateCurrentStack(container)
 	if err != nil {
 		err = c.errorEventf(container.StackSet, "FailedCreateStack", err)
-		c.stacksetLogger(container).Errorf("Unable to create stack: %v", err)
+		c.withSubsystem(SubsystemResources, c.stacksetLogger(container)).Errorf("Unable to create stack: %v", err)
+		container.RecordError(err, time.Now())
 	}
 
 	// Update statuses from external resources (ingresses, deployments, etc). Abort on errors.
@@ -694,44 +1850,157 @@ func (c *StackSetController) ReconcileStackSet(container *core.StackSetContainer
 		return err
 	}
 
+	// Mark stacks which didn't become ready in time as failed
+	container.MarkFailedStacks(time.Now())
+
 	// Update the stacks with the currently selected traffic reconciler. Proceed on errors.
-	err = container.ManageTraffic(time.Now())
+	trafficManaged := false
+	if !maintenance {
+		err = container.ManageTraffic(time.Now())
+		if err != nil {
+			c.withSubsystem(SubsystemTraffic, c.stacksetLogger(container)).Errorf("Traffic reconciliation failed: %v", err)
+			c.recorder.Eventf(
+				container.StackSet,
+				v1.EventTypeWarning,
+				"TrafficNotSwitched",
+				"Failed to switch traffic: "+err.Error())
+			container.RecordError(err, time.Now())
+		} else {
+			trafficManaged = true
+
+			// Prescale the following traffic switches again. Proceed on errors.
+			err = c.ReconcileInstantTrafficSwitch(container)
+			if err != nil {
+				err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+				c.withSubsystem(SubsystemTraffic, c.stacksetLogger(container)).Errorf("Unable to complete the instant traffic switch: %v", err)
+				container.RecordError(err, time.Now())
+			}
+		}
+	}
+
+	// Distribute the aggregated replicas according to the new traffic weights
+	container.DistributeAutoscaling()
+
+	// Record resource recommendations based on the observed usage. Proceed on errors.
+	err = container.UpdateResourceRecommendations(time.Now())
+	if err != nil {
+		c.withSubsystem(SubsystemResources, c.stacksetLogger(container)).Warnf("Resource recommendations failed: %v", err)
+	}
+
+	// Raise the autoscalers ahead of forecasted traffic spikes
+	forecastReplicas, err := trafficForecastMinReplicas(container.StackSet.Spec.TrafficForecast, time.Now())
 	if err != nil {
-		c.stacksetLogger(container).Errorf("Traffic reconciliation failed: %v", err)
+		c.withSubsystem(SubsystemAutoscaling, c.stacksetLogger(container)).Warnf("Traffic forecast failed: %v", err)
 		c.recorder.Eventf(
 			container.StackSet,
 			v1.EventTypeWarning,
-			"TrafficNotSwitched",
-			"Failed to switch traffic: "+err.Error())
+			"InvalidTrafficForecast",
+			"Failed to evaluate traffic forecast: "+err.Error())
+		container.RecordError(err, time.Now())
 	}
+	container.ApplyTrafficForecast(forecastReplicas)
+
+	// Pin the autoscalers while traffic is being switched
+	container.FreezeAutoscalers(time.Now())
+
+	// Hold the autoscalers within the tolerance and CPU initialization period
+	container.HoldAutoscalers(time.Now())
 
-	// Mark stacks that should be removed
-	container.MarkExpiredStacks()
+	// Mark stacks that should be removed and drain their traffic
+	if !maintenance {
+		container.MarkExpiredStacks(time.Now())
+		c.recordRemovalDecisions(container)
+
+		// Announce the stacks newly scheduled within the removal grace period
+		gracePeriod := container.StackSet.Spec.StackLifecycle.RemovalGracePeriod
+		for _, sc := range container.ApplyRemovalGracePeriod(time.Now()) {
+			c.recorder.Eventf(
+				container.StackSet,
+				v1.EventTypeNormal,
+				"ScheduledStackRemoval",
+				"Stack %s is going to be deleted in %s",
+				sc.Name(),
+				gracePeriod.Duration)
+		}
+
+		container.DrainStacks(time.Now())
+	}
 
 	// Reconcile stack resources. Proceed on errors.
+	timedOut := c.reconcileStacksResources(container, deadline)
+
+	// Reconcile stack finalizers. Proceed on errors.
 	for _, sc := range container.StackContainers {
-		err := c.ReconcileStackResources(container, sc)
+		if timedOut || deadlineExceeded(deadline) {
+			timedOut = true
+			break
+		}
+
+		err := c.ReconcileStackFinalizer(sc)
+		if err != nil {
+			err = c.errorEventf(sc.Stack, "FailedManageStack", err)
+			c.withSubsystem(SubsystemGC, c.stackLogger(container, sc)).Errorf("Unable to reconcile stack finalizer: %v", err)
+			recordStackError(container, sc, err)
+		}
+
+		err = c.ReconcileStackTeardown(sc)
 		if err != nil {
 			err = c.errorEventf(sc.Stack, "FailedManageStack", err)
-			c.stackLogger(container, sc).Errorf("Unable to reconcile stack resources: %v", err)
+			c.withSubsystem(SubsystemGC, c.stackLogger(container, sc)).Errorf("Unable to tear down stack: %v", err)
+			recordStackError(container, sc, err)
 		}
 	}
 
-	// Reconcile stackset resources. Proceed on errors.
-	err = c.ReconcileStackSetResources(container)
-	if err != nil {
-		err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
-		c.stacksetLogger(container).Errorf("Unable to reconcile stackset resources: %v", err)
+	// Reconcile stackset resources, unless the resources of the stacks
+	// may be incomplete. Proceed on errors.
+	timedOut = timedOut || deadlineExceeded(deadline)
+	if !timedOut {
+		err = c.ReconcileStackSetResources(container)
+		if err != nil {
+			err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+			c.withSubsystem(SubsystemResources, c.stacksetLogger(container)).Errorf("Unable to reconcile stackset resources: %v", err)
+			container.RecordError(err, time.Now())
+		} else if trafficManaged {
+			// Clear the requested traffic snapshot once its weights were
+			// applied. Proceed on errors.
+			err = c.ReconcileTrafficSnapshotRestore(container)
+			if err != nil {
+				err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+				c.withSubsystem(SubsystemTraffic, c.stacksetLogger(container)).Errorf("Unable to complete the traffic snapshot restore: %v", err)
+				container.RecordError(err, time.Now())
+			}
+		}
 	}
 
 	// Delete old stacks. Proceed on errors.
-	err = c.CleanupOldStacks(container)
-	if err != nil {
-		err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
-		c.stacksetLogger(container).Errorf("Unable to delete old stacks: %v", err)
+	if !maintenance && !timedOut {
+		err = c.CleanupOldStacks(container)
+		if err != nil {
+			err = c.errorEventf(container.StackSet, reasonFailedManageStackSet, err)
+			c.withSubsystem(SubsystemGC, c.stacksetLogger(container)).Errorf("Unable to delete old stacks: %v", err)
+			container.RecordError(err, time.Now())
+		}
 	}
 
-	// Update statuses.
+	// Commit the progress so far and continue right after the current pass
+	// if the deadline was exceeded
+	if timedOut {
+		c.stacksetLogger(container).Warnf("Reconciliation didn't finish within %s, requeuing", c.reconcileTimeout)
+		c.recorder.Eventf(
+			container.StackSet,
+			v1.EventTypeWarning,
+			"ReconcileTimeout",
+			"Reconciliation didn't finish within %s, the remaining changes are applied in the next one",
+			c.reconcileTimeout)
+		c.requeue(container.StackSet)
+	}
+
+	// Update statuses and metrics.
+	container.SetLastChanges(c.finishChangeSummary(container.StackSet.UID, time.Now()))
+	container.SetReconcileTimedOut(timedOut, c.reconcileTimeout)
+	container.UpdateStackConditions()
+	c.trafficMetrics.update(container)
+	c.removalMetrics.update(container)
 	err = c.ReconcileStatuses(container)
 	if err != nil {
 		return err
@@ -743,15 +2012,43 @@ func (c *StackSetController) ReconcileStackSet(container *core.StackSetContainer
 // getResetMinReplicasDelay parses and returns the reset delay if set in the
 // stackset annotation.
 func getResetMinReplicasDelay(annotations map[string]string) (time.Duration, bool) {
-	resetDelayStr, ok := annotations[ResetHPAMinReplicasDelayAnnotationKey]
+	return getDurationAnnotation(annotations, ResetHPAMinReplicasDelayAnnotationKey)
+}
+
+// getDurationAnnotation parses and returns a duration if set in the
+// specified annotation.
+func getDurationAnnotation(annotations map[string]string, key string) (time.Duration, bool) {
+	durationStr, ok := annotations[key]
 	if !ok {
 		return 0, false
 	}
-	resetDelay, err := time.ParseDuration(resetDelayStr)
+	duration, err := time.ParseDuration(durationStr)
 	if err != nil {
 		return 0, false
 	}
-	return resetDelay, true
+	return duration, true
+}
+
+// hasOwnerReference returns true if the owner references contain the owner.
+func hasOwnerReference(references []metav1.OwnerReference, owner types.UID) bool {
+	for _, ref := range references {
+		if ref.UID == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOwnerReference returns a copy of the owner references without the
+// owner.
+func removeOwnerReference(references []metav1.OwnerReference, owner types.UID) []metav1.OwnerReference {
+	var result []metav1.OwnerReference
+	for _, ref := range references {
+		if ref.UID != owner {
+			result = append(result, ref)
+		}
+	}
+	return result
 }
 
 func fixupStackSetTypeMeta(stackset *zv1.StackSet) {
@@ -767,3 +2064,38 @@ func fixupStackTypeMeta(stack *zv1.Stack) {
 	stack.APIVersion = core.APIVersion
 	stack.Kind = core.KindStack
 }
+
+// getToleranceAnnotation parses and returns a tolerance if set in the
+// annotations. The tolerance must be a fraction between 0 and 1.
+func getToleranceAnnotation(annotations map[string]string, key string) (float64, bool) {
+	toleranceStr, ok := annotations[key]
+	if !ok {
+		return 0, false
+	}
+	tolerance, err := strconv.ParseFloat(toleranceStr, 64)
+	if err != nil || tolerance <= 0 || tolerance >= 1 {
+		return 0, false
+	}
+	return tolerance, true
+}
+
+// hasFinalizer returns true if the finalizer is in the list.
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFinalizer returns a copy of the finalizers without the finalizer.
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var result []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			result = append(result, f)
+		}
+	}
+	return result
+}
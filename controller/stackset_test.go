@@ -1,12 +1,14 @@
 package controller
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	ssfake "github.com/zalando-incubator/stackset-controller/pkg/client/clientset/versioned/fake"
 	"github.com/zalando-incubator/stackset-controller/pkg/core"
 	apps "k8s.io/api/apps/v1"
 	autoscaling "k8s.io/api/autoscaling/v2beta1"
@@ -14,8 +16,13 @@ import (
 	extensions "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestGetOwnerUID(t *testing.T) {
@@ -55,6 +62,36 @@ func TestCollectResources(t *testing.T) {
 	testPrescalingCustomStackset := testStackset("foobaz", "namespace", "789")
 	testPrescalingCustomStackset.Annotations = map[string]string{PrescaleStacksAnnotationKey: "", ResetHPAMinReplicasDelayAnnotationKey: "30s"}
 
+	testPrescalingSpecStackset := testStackset("quux", "namespace", "654")
+	testPrescalingSpecStackset.Spec.TrafficPolicy = &zv1.StackSetTrafficSpec{
+		Prescaling: &zv1.PrescalingSpec{
+			Timeout:       &metav1.Duration{Duration: 20 * time.Minute},
+			Cooldown:      &metav1.Duration{Duration: time.Minute},
+			BufferPercent: 20,
+		},
+	}
+
+	testInvalidBufferStackset := testStackset("quuz", "namespace", "655")
+	testInvalidBufferStackset.Spec.TrafficPolicy = &zv1.StackSetTrafficSpec{
+		Prescaling: &zv1.PrescalingSpec{
+			BufferPercent: 150,
+		},
+	}
+
+	testProfileStackset := testStackset("corge", "namespace", "987")
+	testProfileStackset.Spec.AutoscalerProfile = "weekday"
+	testProfileAnnotationStackset := testStackset("grault", "namespace", "988")
+	testProfileAnnotationStackset.Spec.AutoscalerProfile = "weekday"
+	testProfileAnnotationStackset.Annotations = map[string]string{core.AutoscalerProfileAnnotationKey: "sale-event"}
+
+	testHPAOverridesStackset := testStackset("garply", "namespace", "989")
+	testHPAOverridesStackset.Annotations = map[string]string{HPAToleranceAnnotationKey: "0.2", HPACPUInitializationPeriodAnnotationKey: "3m"}
+	testInvalidHPAOverridesStackset := testStackset("waldo", "namespace", "990")
+	testInvalidHPAOverridesStackset.Annotations = map[string]string{HPAToleranceAnnotationKey: "1.5", HPACPUInitializationPeriodAnnotationKey: "abc"}
+
+	testAggregateStackset := testStackset("qux", "namespace", "321")
+	testAggregateStackset.Annotations = map[string]string{AggregateAutoscalingAnnotationKey: "", FreezeAutoscalersAnnotationKey: "15m"}
+
 	for _, tc := range []struct {
 		name        string
 		stacksets   []zv1.StackSet
@@ -64,6 +101,8 @@ func TestCollectResources(t *testing.T) {
 		services    []v1.Service
 		hpas        []autoscaling.HorizontalPodAutoscaler
 		expected    map[types.UID]*core.StackSetContainer
+		// deprecations are the deprecated features expected per StackSet
+		deprecations map[types.UID][]string
 	}{
 		{
 			name: "works correctly without any resources",
@@ -71,6 +110,13 @@ func TestCollectResources(t *testing.T) {
 				testStacksetA,
 				testPrescalingStackset,
 				testPrescalingCustomStackset,
+				testPrescalingSpecStackset,
+				testInvalidBufferStackset,
+				testAggregateStackset,
+				testProfileStackset,
+				testProfileAnnotationStackset,
+				testHPAOverridesStackset,
+				testInvalidHPAOverridesStackset,
 			},
 			expected: map[types.UID]*core.StackSetContainer{
 				testStacksetA.UID: {
@@ -92,6 +138,62 @@ func TestCollectResources(t *testing.T) {
 						ResetHPAMinReplicasTimeout: 30 * time.Second,
 					},
 				},
+				testPrescalingSpecStackset.UID: {
+					StackSet:        &testPrescalingSpecStackset,
+					StackContainers: map[types.UID]*core.StackContainer{},
+					TrafficReconciler: &core.PrescalingTrafficReconciler{
+						ResetHPAMinReplicasTimeout: 20 * time.Minute,
+						Cooldown:                   time.Minute,
+						BufferPercent:              20,
+					},
+				},
+				testInvalidBufferStackset.UID: {
+					StackSet:        &testInvalidBufferStackset,
+					StackContainers: map[types.UID]*core.StackContainer{},
+					TrafficReconciler: &core.PrescalingTrafficReconciler{
+						ResetHPAMinReplicasTimeout: defaultResetMinReplicasDelay,
+					},
+				},
+				testAggregateStackset.UID: {
+					StackSet:               &testAggregateStackset,
+					StackContainers:        map[types.UID]*core.StackContainer{},
+					TrafficReconciler:      &core.SimpleTrafficReconciler{},
+					AggregateAutoscaling:   true,
+					AutoscalerFreezeWindow: 15 * time.Minute,
+				},
+				testProfileStackset.UID: {
+					StackSet:          &testProfileStackset,
+					StackContainers:   map[types.UID]*core.StackContainer{},
+					TrafficReconciler: &core.SimpleTrafficReconciler{},
+					AutoscalerProfile: "weekday",
+				},
+				testProfileAnnotationStackset.UID: {
+					StackSet:          &testProfileAnnotationStackset,
+					StackContainers:   map[types.UID]*core.StackContainer{},
+					TrafficReconciler: &core.SimpleTrafficReconciler{},
+					AutoscalerProfile: "sale-event",
+				},
+				testHPAOverridesStackset.UID: {
+					StackSet:                   &testHPAOverridesStackset,
+					StackContainers:            map[types.UID]*core.StackContainer{},
+					TrafficReconciler:          &core.SimpleTrafficReconciler{},
+					HPATolerance:               0.2,
+					HPACPUInitializationPeriod: 3 * time.Minute,
+				},
+				testInvalidHPAOverridesStackset.UID: {
+					StackSet:          &testInvalidHPAOverridesStackset,
+					StackContainers:   map[types.UID]*core.StackContainer{},
+					TrafficReconciler: &core.SimpleTrafficReconciler{},
+				},
+			},
+			deprecations: map[types.UID][]string{
+				testPrescalingStackset.UID: {
+					"the alpha.stackset-controller.zalando.org/prescale-stacks annotation is deprecated, use spec.trafficPolicy.prescaling instead",
+				},
+				testPrescalingCustomStackset.UID: {
+					"the alpha.stackset-controller.zalando.org/prescale-stacks annotation is deprecated, use spec.trafficPolicy.prescaling instead",
+					"the alpha.stackset-controller.zalando.org/reset-hpa-min-replicas-delay annotation is deprecated, use spec.trafficPolicy.prescaling.timeout instead",
+				},
 			},
 		},
 		{
@@ -234,6 +336,10 @@ func TestCollectResources(t *testing.T) {
 			err = env.CreateHPAs(tc.hpas)
 			require.NoError(t, err)
 
+			for uid, deprecations := range tc.deprecations {
+				tc.expected[uid].SetDeprecations(deprecations)
+			}
+
 			resources, err := env.controller.collectResources()
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, resources)
@@ -241,6 +347,103 @@ func TestCollectResources(t *testing.T) {
 	}
 }
 
+func TestStatusUpdateConflicts(t *testing.T) {
+	env := NewTestEnvironment()
+	ssClient := env.client.(*testClient).ssClient.(*ssfake.Clientset)
+
+	// the status updates of the stackset conflict twice
+	conflicts := 2
+	ssClient.PrependReactor("update", "stacksets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "status" || conflicts == 0 {
+			return false, nil, nil
+		}
+		conflicts--
+		return true, nil, errors.NewConflict(schema.GroupResource{Group: "zalando.org", Resource: "stacksets"}, "foo", fmt.Errorf("edited concurrently"))
+	})
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.StackTemplate.Spec.Version = "v1"
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet:          &stackset,
+		StackContainers:   map[types.UID]*core.StackContainer{},
+		TrafficReconciler: &core.SimpleTrafficReconciler{},
+	}
+	err = env.controller.CreateCurrentStack(container)
+	require.NoError(t, err)
+	require.Zero(t, conflicts)
+
+	updated, err := env.client.ZalandoV1().StackSets(stackset.Namespace).Get(stackset.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "v1", updated.Status.ObservedStackVersion)
+
+	// updates which keep conflicting fail eventually
+	calls := 0
+	err = retryUpdate(func(retry bool) error {
+		require.Equal(t, calls > 0, retry)
+		calls++
+		return errors.NewConflict(schema.GroupResource{Group: "zalando.org", Resource: "stacksets"}, "foo", fmt.Errorf("edited concurrently"))
+	})
+	require.True(t, errors.IsConflict(err))
+	require.True(t, calls > 1)
+}
+
+func TestReconcileStatusesUnchanged(t *testing.T) {
+	env := NewTestEnvironment()
+	ssClient := env.client.(*testClient).ssClient.(*ssfake.Clientset)
+
+	statusUpdates := 0
+	ssClient.PrependReactor("update", "stacks", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "status" {
+			statusUpdates++
+		}
+		return false, nil, nil
+	})
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	deployment := &apps.Deployment{
+		ObjectMeta: stackOwned(stack),
+		Status:     apps.DeploymentStatus{Replicas: 3, ReadyReplicas: 3},
+	}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	reconcile := func() {
+		current, err := env.client.ZalandoV1().Stacks(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		container := &core.StackSetContainer{
+			StackSet: &stackset,
+			StackContainers: map[types.UID]*core.StackContainer{
+				stack.UID: {
+					Stack:     current,
+					Resources: core.StackResources{Deployment: deployment},
+				},
+			},
+		}
+		err = container.UpdateFromResources()
+		require.NoError(t, err)
+
+		err = env.controller.ReconcileStatuses(container)
+		require.NoError(t, err)
+	}
+
+	// the changed status is updated
+	reconcile()
+	require.Equal(t, 1, statusUpdates)
+
+	// the unchanged one isn't updated again
+	reconcile()
+	require.Equal(t, 1, statusUpdates)
+}
+
 func TestCreateCurrentStack(t *testing.T) {
 	env := NewTestEnvironment()
 
@@ -305,6 +508,307 @@ func TestCreateCurrentStack(t *testing.T) {
 	require.True(t, errors.IsNotFound(err))
 }
 
+func TestReconcileStackSetPaused(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.Paused = true
+	stackset.Spec.StackTemplate.Spec.Version = "v1"
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet:          &stackset,
+		StackContainers:   map[types.UID]*core.StackContainer{},
+		TrafficReconciler: &core.SimpleTrafficReconciler{},
+	}
+
+	err = env.controller.ReconcileStackSet(container)
+	require.NoError(t, err)
+
+	// The current stack isn't created while the stackset is paused
+	_, err = env.client.ZalandoV1().Stacks(stackset.Namespace).Get("foo-v1", metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+}
+
+func TestReconcileStackSetsWorkers(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(100)
+	env.controller.workers = 2
+
+	var stacksets []zv1.StackSet
+	for _, uid := range []types.UID{"1", "2", "3", "4", "5"} {
+		stackset := testStackset("foo-"+string(uid), "default", uid)
+		stackset.Spec.StackTemplate.Spec.Version = "v1"
+		stacksets = append(stacksets, stackset)
+	}
+	err := env.CreateStacksets(stacksets)
+	require.NoError(t, err)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+
+	err = env.controller.reconcileStackSets(containers)
+	require.NoError(t, err)
+
+	// All the stacksets are reconciled even though there are less workers
+	for _, stackset := range stacksets {
+		_, err := env.client.ZalandoV1().Stacks(stackset.Namespace).Get(stackset.Name+"-v1", metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+}
+
+func TestReconcileStacksResources(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(100)
+	env.controller.stackWorkers = 2
+
+	stackset := testStackset("foo", "default", "123")
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	var stacks []zv1.Stack
+	for _, version := range []string{"v1", "v2", "v3", "v4", "v5"} {
+		stacks = append(stacks, testStack("foo-"+version, "default", types.UID(version), stackset))
+	}
+	err = env.CreateStacks(stacks)
+	require.NoError(t, err)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+	require.Len(t, containers[stackset.UID].StackContainers, len(stacks))
+
+	timedOut := env.controller.reconcileStacksResources(containers[stackset.UID], time.Time{})
+	require.False(t, timedOut)
+
+	// The resources of all the stacks are reconciled even though there are
+	// less workers
+	for _, stack := range stacks {
+		_, err := env.client.AppsV1().Deployments(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		_, err = env.client.CoreV1().Services(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+}
+
+func TestReconcileStackSetTimeout(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+	env.controller.ConfigureReconcileTimeout(time.Nanosecond)
+
+	stackset := testStackset("foo", "default", "123")
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+	stack := testStack("foo-v1", "default", "abc", stackset)
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+
+	err = env.controller.ReconcileStackSet(containers[stackset.UID])
+	require.NoError(t, err)
+
+	// the resources of the stack are left for the next reconciliation
+	_, err = env.client.AppsV1().Deployments(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	updated, err := env.client.ZalandoV1().StackSets(stackset.Namespace).Get(stackset.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	var timeout *zv1.StackSetCondition
+	for i := range updated.Status.Conditions {
+		if updated.Status.Conditions[i].Type == zv1.StackSetConditionReconcileTimeout {
+			timeout = &updated.Status.Conditions[i]
+		}
+	}
+	require.NotNil(t, timeout)
+	require.Equal(t, v1.ConditionTrue, timeout.Status)
+
+	// the stackset is requeued
+	require.Equal(t, types.NamespacedName{Namespace: "default", Name: "foo"}, <-env.controller.reconcileRequests)
+}
+
+func TestReconcileStackSetsBackoff(t *testing.T) {
+	env := NewTestEnvironment()
+	now := time.Now()
+
+	// the first retry happens after the interval
+	require.Equal(t, time.Minute, env.controller.reconcileFailed("123", now))
+	require.True(t, env.controller.backoffActive("123", now.Add(30*time.Second)))
+	require.False(t, env.controller.backoffActive("123", now.Add(time.Minute)))
+
+	// the delay doubles with every consecutive failure
+	require.Equal(t, 2*time.Minute, env.controller.reconcileFailed("123", now))
+	require.Equal(t, 4*time.Minute, env.controller.reconcileFailed("123", now))
+	require.True(t, env.controller.backoffActive("123", now.Add(3*time.Minute)))
+	require.False(t, env.controller.backoffActive("456", now))
+
+	// the delay is limited
+	for i := 0; i < 10; i++ {
+		env.controller.reconcileFailed("123", now)
+	}
+	require.Equal(t, maxReconcileBackoff, env.controller.reconcileFailed("123", now))
+
+	// a successful reconciliation resets the backoff
+	env.controller.resetBackoff("123")
+	require.False(t, env.controller.backoffActive("123", now))
+	require.Equal(t, time.Minute, env.controller.reconcileFailed("123", now))
+}
+
+func TestStacksetLogger(t *testing.T) {
+	env := NewTestEnvironment()
+	stackset := testStackset("foo", "default", "123")
+	ssc := &core.StackSetContainer{StackSet: &stackset}
+	sc := &core.StackContainer{Stack: &zv1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "foo-v1"}}}
+
+	// outside of a reconciliation there's no reconcile ID
+	require.Equal(t, "default", env.controller.stacksetLogger(ssc).Data["namespace"])
+	require.Equal(t, "foo", env.controller.stacksetLogger(ssc).Data["stackset"])
+	require.NotContains(t, env.controller.stacksetLogger(ssc).Data, "reconcile_id")
+
+	// every reconciliation gets a new ID
+	env.controller.startReconcile(stackset.UID)
+	reconcileID := env.controller.stacksetLogger(ssc).Data["reconcile_id"]
+	require.NotEmpty(t, reconcileID)
+	require.Equal(t, reconcileID, env.controller.stackLogger(ssc, sc).Data["reconcile_id"])
+	require.Equal(t, "foo-v1", env.controller.stackLogger(ssc, sc).Data["stack"])
+	env.controller.finishReconcile(stackset.UID)
+	require.NotContains(t, env.controller.stacksetLogger(ssc).Data, "reconcile_id")
+
+	env.controller.startReconcile(stackset.UID)
+	require.NotEqual(t, reconcileID, env.controller.stacksetLogger(ssc).Data["reconcile_id"])
+}
+
+func TestHasOwnership(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		controllerID string
+		selector     string
+		labels       map[string]string
+		annotations  map[string]string
+		expected     bool
+	}{
+		{
+			name:     "stacksets without annotation are owned by default",
+			expected: true,
+		},
+		{
+			name:        "stacksets of other controllers are ignored",
+			annotations: map[string]string{StacksetControllerControllerAnnotationKey: "canary"},
+			expected:    false,
+		},
+		{
+			name:         "stacksets with the controller id are owned",
+			controllerID: "canary",
+			annotations:  map[string]string{StacksetControllerControllerAnnotationKey: "canary"},
+			expected:     true,
+		},
+		{
+			name:         "stacksets without annotation are ignored with a controller id",
+			controllerID: "canary",
+			expected:     false,
+		},
+		{
+			name:     "stacksets matching the selector are owned",
+			selector: "team=foo",
+			labels:   map[string]string{"team": "foo"},
+			expected: true,
+		},
+		{
+			name:     "stacksets not matching the selector are ignored",
+			selector: "team=foo",
+			labels:   map[string]string{"team": "bar"},
+			expected: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := labels.Parse(tc.selector)
+			require.NoError(t, err)
+
+			env := NewTestEnvironment()
+			env.controller.controllerID = tc.controllerID
+			env.controller.stacksetSelector = selector
+
+			stackset := testStackset("foo", "default", "123")
+			stackset.Labels = tc.labels
+			stackset.Annotations = tc.annotations
+			require.Equal(t, tc.expected, env.controller.hasOwnership(&stackset))
+		})
+	}
+}
+
+func TestManagesNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		namespaces         []string
+		excludedNamespaces []string
+		namespace          string
+		expected           bool
+	}{
+		{
+			name:      "all namespaces are managed by default",
+			namespace: "default",
+			expected:  true,
+		},
+		{
+			name:       "configured namespaces are managed",
+			namespaces: []string{"foo", "bar"},
+			namespace:  "bar",
+			expected:   true,
+		},
+		{
+			name:       "other namespaces are ignored",
+			namespaces: []string{"foo", "bar"},
+			namespace:  "default",
+			expected:   false,
+		},
+		{
+			name:               "excluded namespaces are ignored",
+			excludedNamespaces: []string{"kube-system"},
+			namespace:          "kube-system",
+			expected:           false,
+		},
+		{
+			name:               "exclusions take precedence",
+			namespaces:         []string{"foo"},
+			excludedNamespaces: []string{"foo"},
+			namespace:          "foo",
+			expected:           false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+			env.controller.namespaces = tc.namespaces
+			env.controller.excludedNamespaces = tc.excludedNamespaces
+			require.Equal(t, tc.expected, env.controller.managesNamespace(tc.namespace))
+		})
+	}
+}
+
+func TestCollectResourcesNamespaces(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.namespaces = []string{"foo"}
+
+	stacksetFoo := testStackset("app", "foo", "123")
+	stacksetBar := testStackset("app", "bar", "456")
+	err := env.CreateStacksets([]zv1.StackSet{stacksetFoo, stacksetBar})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{
+		testStack("app-v1", "foo", "abc", stacksetFoo),
+		testStack("app-v1", "bar", "def", stacksetBar),
+	})
+	require.NoError(t, err)
+
+	containers, err := env.controller.collectResources()
+	require.NoError(t, err)
+
+	// only the resources of the configured namespaces are listed
+	require.Len(t, containers["123"].StackContainers, 1)
+	require.Len(t, containers["456"].StackContainers, 0)
+}
+
 func TestCleanupOldStacks(t *testing.T) {
 	env := NewTestEnvironment()
 
@@ -351,6 +855,357 @@ func TestCleanupOldStacks(t *testing.T) {
 	require.Equal(t, []zv1.Stack{testStack3, testStack4}, result.Items)
 }
 
+func TestReconcileStackFinalizer(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	sc := &core.StackContainer{Stack: &stack}
+	err = env.controller.ReconcileStackFinalizer(sc)
+	require.NoError(t, err)
+	require.Equal(t, []string{core.StackTrafficFinalizer}, sc.Stack.Finalizers)
+
+	// the finalizer is removed once the stack is deleted without traffic
+	deleted := sc.Stack.DeepCopy()
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	sc.Stack = deleted
+
+	err = env.controller.ReconcileStackFinalizer(sc)
+	require.NoError(t, err)
+	require.Empty(t, sc.Stack.Finalizers)
+}
+
+func TestReconcileStackFinalizerBlocked(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.Ingress = &zv1.StackSetIngressSpec{}
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.Finalizers = []string{core.StackTrafficFinalizer}
+	now := metav1.Now()
+	stack.DeletionTimestamp = &now
+
+	err := env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	ingress := extensions.Ingress{ObjectMeta: stacksetOwned(stackset)}
+	ingress.Annotations = map[string]string{"zalando.org/backend-weights": `{"foo-v1": 100}`}
+	container := &core.StackSetContainer{
+		StackSet:        &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{stack.UID: {Stack: &stack}},
+		Ingress:         &ingress,
+	}
+	err = container.UpdateFromResources()
+	require.NoError(t, err)
+	sc := container.StackContainers[stack.UID]
+
+	// the finalizer is kept while the stack gets traffic
+	err = env.controller.ReconcileStackFinalizer(sc)
+	require.NoError(t, err)
+	require.Equal(t, []string{core.StackTrafficFinalizer}, sc.Stack.Finalizers)
+
+	status := sc.GenerateStackStatus()
+	require.Len(t, status.Conditions, 1)
+	require.Equal(t, zv1.StackConditionDeletionBlocked, status.Conditions[0].Type)
+	require.Equal(t, v1.ConditionTrue, status.Conditions[0].Status)
+}
+
+func TestReconcileOrphanedStacks(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	now := metav1.Now()
+
+	orphaned := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	orphaned.Finalizers = []string{core.StackTrafficFinalizer, core.StackTeardownFinalizer}
+	orphaned.DeletionTimestamp = &now
+
+	running := testStack("foo-v2", stackset.Namespace, "abc2", stackset)
+	running.Finalizers = []string{core.StackTrafficFinalizer, core.StackTeardownFinalizer}
+
+	otherStackset := testStackset("bar", "default", "456")
+	managed := testStack("bar-v1", otherStackset.Namespace, "def1", otherStackset)
+	managed.Finalizers = []string{core.StackTrafficFinalizer}
+	managed.DeletionTimestamp = &now
+
+	// the StackSet foo was deleted, bar still exists
+	err := env.CreateStacksets([]zv1.StackSet{otherStackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{orphaned, running, managed})
+	require.NoError(t, err)
+
+	ingress := extensions.Ingress{ObjectMeta: stackOwned(orphaned)}
+	err = env.CreateIngresses([]extensions.Ingress{ingress})
+	require.NoError(t, err)
+
+	err = env.controller.ReconcileOrphanedStacks(map[types.UID]*core.StackSetContainer{})
+	require.NoError(t, err)
+
+	// the traffic finalizer of the deleted stack of the deleted StackSet is
+	// released and its teardown started
+	result, err := env.client.ZalandoV1().Stacks(stackset.Namespace).Get(orphaned.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{core.StackTeardownFinalizer}, result.Finalizers)
+	_, err = env.client.ExtensionsV1beta1().Ingresses(stackset.Namespace).Get(ingress.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+
+	// the teardown finalizer is removed once all the resources are gone
+	err = env.controller.ReconcileOrphanedStacks(map[types.UID]*core.StackSetContainer{})
+	require.NoError(t, err)
+	result, err = env.client.ZalandoV1().Stacks(stackset.Namespace).Get(orphaned.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.Finalizers)
+
+	// stacks which aren't deleted or whose StackSet exists are left alone
+	result, err = env.client.ZalandoV1().Stacks(stackset.Namespace).Get(running.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, running.Finalizers, result.Finalizers)
+
+	result, err = env.client.ZalandoV1().Stacks(otherStackset.Namespace).Get(managed.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, managed.Finalizers, result.Finalizers)
+}
+
+func TestReconcileStackTeardown(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.Finalizers = []string{core.StackTeardownFinalizer}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	ingress := extensions.Ingress{ObjectMeta: stackOwned(stack)}
+	err = env.CreateIngresses([]extensions.Ingress{ingress})
+	require.NoError(t, err)
+
+	service := v1.Service{ObjectMeta: stackOwned(stack)}
+	err = env.CreateServices([]v1.Service{service})
+	require.NoError(t, err)
+
+	deployment := apps.Deployment{ObjectMeta: stackOwned(stack)}
+	err = env.CreateDeployments([]apps.Deployment{deployment})
+	require.NoError(t, err)
+
+	deleted := stack.DeepCopy()
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+
+	sc := &core.StackContainer{
+		Stack: deleted,
+		Resources: core.StackResources{
+			Ingress:    &ingress,
+			Service:    &service,
+			Deployment: &deployment,
+		},
+	}
+
+	// the ingress is deleted first
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	_, err = env.client.ExtensionsV1beta1().Ingresses(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+	_, err = env.client.CoreV1().Services(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	sc.Resources.Ingress = nil
+
+	// then the service
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	_, err = env.client.CoreV1().Services(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+	_, err = env.client.AppsV1().Deployments(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	sc.Resources.Service = nil
+
+	// and the deployment last
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	_, err = env.client.AppsV1().Deployments(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+	require.Equal(t, []string{core.StackTeardownFinalizer}, sc.Stack.Finalizers)
+	sc.Resources.Deployment = nil
+
+	// the finalizer is removed once all the resources are gone
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	require.Empty(t, sc.Stack.Finalizers)
+}
+
+func TestReconcileStackTeardownOutdatedResources(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.Finalizers = []string{core.StackTeardownFinalizer}
+	now := metav1.Now()
+	stack.DeletionTimestamp = &now
+
+	err := env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	// the ingress is already gone, but still listed
+	ingress := extensions.Ingress{ObjectMeta: stackOwned(stack)}
+	service := v1.Service{ObjectMeta: stackOwned(stack)}
+	err = env.CreateServices([]v1.Service{service})
+	require.NoError(t, err)
+
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+
+	sc := &core.StackContainer{
+		Stack:     &stack,
+		Resources: core.StackResources{Ingress: &ingress, Service: &service},
+	}
+
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	_, err = env.client.CoreV1().Services(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.True(t, errors.IsNotFound(err))
+	require.Equal(t, []string{core.StackTeardownFinalizer}, sc.Stack.Finalizers)
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, "DeletedService")
+
+	// nothing is left, so the finalizer is removed without further events
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	require.Empty(t, sc.Stack.Finalizers)
+	require.Empty(t, recorder.Events)
+}
+
+func TestReconcileStackTeardownWaitsForTraffic(t *testing.T) {
+	env := NewTestEnvironment()
+
+	stackset := testStackset("foo", "default", "123")
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+	stack.Finalizers = []string{core.StackTrafficFinalizer, core.StackTeardownFinalizer}
+	now := metav1.Now()
+	stack.DeletionTimestamp = &now
+
+	ingress := extensions.Ingress{ObjectMeta: stackOwned(stack)}
+	err := env.CreateIngresses([]extensions.Ingress{ingress})
+	require.NoError(t, err)
+
+	sc := &core.StackContainer{
+		Stack:     &stack,
+		Resources: core.StackResources{Ingress: &ingress},
+	}
+
+	err = env.controller.ReconcileStackTeardown(sc)
+	require.NoError(t, err)
+	_, err = env.client.ExtensionsV1beta1().Ingresses(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestReconcileInstantTrafficSwitch(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Annotations = map[string]string{InstantTrafficSwitchAnnotationKey: "true"}
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet:        &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{},
+	}
+
+	// the annotation is removed once the traffic was switched
+	err = env.controller.ReconcileInstantTrafficSwitch(container)
+	require.NoError(t, err)
+
+	result, err := env.client.ZalandoV1().StackSets(stackset.Namespace).Get(stackset.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, result.Annotations, InstantTrafficSwitchAnnotationKey)
+	require.NotContains(t, container.StackSet.Annotations, InstantTrafficSwitchAnnotationKey)
+
+	// nothing is updated without the annotation
+	err = env.controller.ReconcileInstantTrafficSwitch(container)
+	require.NoError(t, err)
+}
+
+func TestReconcileTrafficSnapshotRestore(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.TrafficSnapshotRestore = "20190806-030000"
+	stackset.Status.TrafficSnapshots = []zv1.TrafficSnapshot{{Name: "20190806-030000"}}
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet:        &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{},
+	}
+
+	// the requested snapshot is cleared once it was restored
+	err = env.controller.ReconcileTrafficSnapshotRestore(container)
+	require.NoError(t, err)
+
+	result, err := env.client.ZalandoV1().StackSets(stackset.Namespace).Get(stackset.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.Spec.TrafficSnapshotRestore)
+	require.Empty(t, container.StackSet.Spec.TrafficSnapshotRestore)
+}
+
+func TestReconcileStackSetDeletion(t *testing.T) {
+	env := NewTestEnvironment()
+	env.controller.recorder = record.NewFakeRecorder(10)
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.StackLifecycle.OnDelete = zv1.StackSetDeletionPolicyOrphan
+	stack := testStack("foo-v1", stackset.Namespace, "abc1", stackset)
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	err = env.CreateStacks([]zv1.Stack{stack})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet: &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{
+			stack.UID: {Stack: &stack},
+		},
+	}
+
+	// the orphan finalizer is added to the stackset
+	deleted, err := env.controller.ReconcileStackSetDeletion(container)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	require.Equal(t, []string{core.StackSetOrphanFinalizer}, container.StackSet.Finalizers)
+
+	// the stacks are orphaned once the stackset is deleted
+	now := metav1.Now()
+	container.StackSet.DeletionTimestamp = &now
+
+	deleted, err = env.controller.ReconcileStackSetDeletion(container)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Empty(t, container.StackSet.Finalizers)
+
+	result, err := env.client.ZalandoV1().Stacks(stack.Namespace).Get(stack.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.OwnerReferences)
+}
+
 func TestReconcileStackSetIngress(t *testing.T) {
 	exampleRules := []extensions.IngressRule{
 		{
@@ -521,6 +1376,7 @@ func TestReconcileStackSetIngress(t *testing.T) {
 			updated, err := env.client.ExtensionsV1beta1().Ingresses(testStackSet.Namespace).Get(testStackSet.Name, metav1.GetOptions{})
 			if tc.expected != nil {
 				require.NoError(t, err)
+				updated.Annotations = withoutProvenance(updated.Annotations)
 				require.Equal(t, tc.expected, updated)
 			} else {
 				require.True(t, errors.IsNotFound(err))
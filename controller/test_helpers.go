@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"encoding/json"
+	"reflect"
 	"time"
 
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
@@ -13,9 +15,12 @@ import (
 	v1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 type testClient struct {
@@ -33,14 +38,47 @@ type testEnvironment struct {
 }
 
 func NewTestEnvironment() *testEnvironment {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("patch", "*", patchReactor(kubeClient.ReactionChain[0]))
+
 	client := &testClient{
-		Interface: fake.NewSimpleClientset(),
+		Interface: kubeClient,
 		ssClient:  ssfake.NewSimpleClientset(),
 	}
 
 	return &testEnvironment{
 		client:     client,
-		controller: NewStackSetController(client, "", time.Minute),
+		controller: NewStackSetController(client, nil, nil, "", nil, Shard{}, time.Minute, 0, 0),
+	}
+}
+
+// patchReactor applies strategic merge patches like the API server, using
+// the object reaction of the fake clientset to get and update the object.
+// The default reaction decodes the patched object into the existing one, so
+// keys removed from maps, e.g. annotations, are kept.
+func patchReactor(objectReaction clienttesting.Reactor) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(clienttesting.PatchAction)
+		_, existing, err := objectReaction.React(clienttesting.NewGetAction(action.GetResource(), action.GetNamespace(), patchAction.GetName()))
+		if err != nil {
+			return true, nil, err
+		}
+
+		original, err := json.Marshal(existing)
+		if err != nil {
+			return true, nil, err
+		}
+		patched, err := strategicpatch.StrategicMergePatch(original, patchAction.GetPatch(), existing)
+		if err != nil {
+			return true, nil, err
+		}
+
+		result := reflect.New(reflect.TypeOf(existing).Elem()).Interface().(runtime.Object)
+		err = json.Unmarshal(patched, result)
+		if err != nil {
+			return true, nil, err
+		}
+		return objectReaction.React(clienttesting.NewUpdateAction(action.GetResource(), action.GetNamespace(), result))
 	}
 }
 
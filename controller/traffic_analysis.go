@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+)
+
+const (
+	prometheusQueryPath = "/api/v1/query"
+	kairosDBQueryPath   = "/api/v1/datapoints/query"
+
+	zmonCheckMetricPrefix = "zmon.check."
+
+	defaultTrafficAnalysisTimeout = 10 * time.Second
+	defaultZMONAnalysisDuration   = 10 * time.Minute
+)
+
+// httpTrafficAnalysis queries the values of the analysis checks of traffic
+// ramps from Prometheus or the ZMON KairosDB.
+type httpTrafficAnalysis struct {
+	client *http.Client
+}
+
+func (a *httpTrafficAnalysis) CheckValue(check zv1.TrafficAnalysisCheck, namespace, stacksetName, stackName string) (float64, error) {
+	placeholders := strings.NewReplacer(
+		"{namespace}", namespace,
+		"{stackset}", stacksetName,
+		"{stack}", stackName,
+	)
+
+	switch {
+	case check.Prometheus != nil && check.ZMON == nil:
+		return a.prometheusValue(check.Prometheus, placeholders)
+	case check.ZMON != nil && check.Prometheus == nil:
+		return a.zmonValue(check.ZMON, placeholders)
+	default:
+		return 0, errors.New("exactly one of prometheus and zmon must be set")
+	}
+}
+
+// prometheusQueryResponse is the subset of the response of a Prometheus
+// instant query needed to read a scalar or a single sample.
+type prometheusQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// prometheusValue runs an instant query and returns its single value.
+func (a *httpTrafficAnalysis) prometheusValue(analysis *zv1.PrometheusAnalysis, placeholders *strings.Replacer) (float64, error) {
+	query := url.Values{"query": []string{placeholders.Replace(analysis.Query)}}
+	resp, err := a.client.Get(strings.TrimSuffix(analysis.URL, "/") + prometheusQueryPath + "?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result prometheusQueryResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse Prometheus response: %v", err)
+	}
+	if result.Status != "success" {
+		return 0, fmt.Errorf("Prometheus query failed with status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	var sample []interface{}
+	switch result.Data.ResultType {
+	case "scalar":
+		err = json.Unmarshal(result.Data.Result, &sample)
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		err = json.Unmarshal(result.Data.Result, &vector)
+		if err == nil && len(vector) != 1 {
+			return 0, fmt.Errorf("Prometheus query returned %d samples instead of one", len(vector))
+		}
+		if err == nil {
+			sample = vector[0].Value
+		}
+	default:
+		return 0, fmt.Errorf("Prometheus result type %s not supported", result.Data.ResultType)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse Prometheus result: %v", err)
+	}
+
+	// samples are [<timestamp>, "<value>"] pairs
+	if len(sample) != 2 {
+		return 0, errors.New("invalid Prometheus sample")
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, errors.New("invalid Prometheus sample value")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// kairosDBQuery is a query of the KairosDB datapoints.
+type kairosDBQuery struct {
+	StartRelative kairosDBDuration `json:"start_relative"`
+	Metrics       []kairosDBMetric `json:"metrics"`
+}
+
+type kairosDBDuration struct {
+	Value int64  `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+type kairosDBMetric struct {
+	Name string              `json:"name"`
+	Tags map[string][]string `json:"tags"`
+}
+
+// kairosDBQueryResponse is the subset of the KairosDB query response needed
+// to read the datapoints.
+type kairosDBQueryResponse struct {
+	Queries []struct {
+		Results []struct {
+			Values [][]float64 `json:"values"`
+		} `json:"results"`
+	} `json:"queries"`
+}
+
+// zmonValue returns the average of the check values within the duration.
+func (a *httpTrafficAnalysis) zmonValue(analysis *zv1.ZMONAnalysis, placeholders *strings.Replacer) (float64, error) {
+	duration := defaultZMONAnalysisDuration
+	if analysis.Duration != nil {
+		duration = analysis.Duration.Duration
+	}
+
+	tags := map[string][]string{"key": {analysis.Key}}
+	for key, value := range analysis.Tags {
+		tags[key] = []string{placeholders.Replace(value)}
+	}
+
+	query, err := json.Marshal(&kairosDBQuery{
+		StartRelative: kairosDBDuration{Value: int64(duration / time.Millisecond), Unit: "milliseconds"},
+		Metrics: []kairosDBMetric{
+			{Name: zmonCheckMetricPrefix + analysis.CheckID, Tags: tags},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Post(strings.TrimSuffix(analysis.URL, "/")+kairosDBQueryPath, "application/json", bytes.NewReader(query))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("KairosDB query failed with status %d", resp.StatusCode)
+	}
+
+	var result kairosDBQueryResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse KairosDB response: %v", err)
+	}
+
+	// datapoints are [<timestamp>, <value>] pairs
+	total, count := 0.0, 0
+	for _, query := range result.Queries {
+		for _, result := range query.Results {
+			for _, datapoint := range result.Values {
+				if len(datapoint) == 2 {
+					total += datapoint[1]
+					count++
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("no values of check %s within %s", analysis.CheckID, duration)
+	}
+	return total / float64(count), nil
+}
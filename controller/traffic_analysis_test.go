@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPrometheusAnalysis(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		response      string
+		expectedValue float64
+		expectedError bool
+	}{
+		{
+			name:          "vector with a single sample",
+			response:      `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1565000000.123,"0.25"]}]}}`,
+			expectedValue: 0.25,
+		},
+		{
+			name:          "scalar",
+			response:      `{"status":"success","data":{"resultType":"scalar","result":[1565000000.123,"3"]}}`,
+			expectedValue: 3,
+		},
+		{
+			name:          "vector with multiple samples",
+			response:      `{"status":"success","data":{"resultType":"vector","result":[{"value":[1565000000,"1"]},{"value":[1565000000,"2"]}]}}`,
+			expectedError: true,
+		},
+		{
+			name:          "failed query",
+			response:      `{"status":"error","error":"invalid query"}`,
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, prometheusQueryPath, r.URL.Path)
+				require.Equal(t, `sum(rate(errors{stack="foo-v2"}[5m]))`, r.URL.Query().Get("query"))
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			analysis := &httpTrafficAnalysis{client: server.Client()}
+			check := zv1.TrafficAnalysisCheck{
+				Name: "errors",
+				Prometheus: &zv1.PrometheusAnalysis{
+					URL:   server.URL,
+					Query: `sum(rate(errors{stack="{stack}"}[5m]))`,
+				},
+			}
+
+			value, err := analysis.CheckValue(check, "default", "foo", "foo-v2")
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedValue, value)
+		})
+	}
+}
+
+func TestZMONAnalysis(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, kairosDBQueryPath, r.URL.Path)
+
+		var query kairosDBQuery
+		err := json.NewDecoder(r.Body).Decode(&query)
+		require.NoError(t, err)
+		require.Equal(t, kairosDBDuration{Value: 300000, Unit: "milliseconds"}, query.StartRelative)
+		require.Equal(t, []kairosDBMetric{
+			{
+				Name: "zmon.check.1234",
+				Tags: map[string][]string{
+					"key":   {"error-rate"},
+					"stack": {"foo-v2"},
+				},
+			},
+		}, query.Metrics)
+
+		w.Write([]byte(`{"queries":[{"results":[{"values":[[1565000000000,1],[1565000060000,3]]}]}]}`))
+	}))
+	defer server.Close()
+
+	analysis := &httpTrafficAnalysis{client: server.Client()}
+	check := zv1.TrafficAnalysisCheck{
+		Name: "errors",
+		ZMON: &zv1.ZMONAnalysis{
+			URL:      server.URL,
+			CheckID:  "1234",
+			Key:      "error-rate",
+			Tags:     map[string]string{"stack": "{stack}"},
+			Duration: &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	}
+
+	value, err := analysis.CheckValue(check, "default", "foo", "foo-v2")
+	require.NoError(t, err)
+	require.Equal(t, 2.0, value)
+
+	// exactly one query must be set
+	check.Prometheus = &zv1.PrometheusAnalysis{URL: server.URL}
+	_, err = analysis.CheckValue(check, "default", "foo", "foo-v2")
+	require.Error(t, err)
+}
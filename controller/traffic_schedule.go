@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ReconcileScheduledTraffic applies the latest scheduled traffic switch which
+// is due by making its weights the desired traffic weights in the stackset
+// spec. The applied switch and the older ones are removed from the schedule.
+func (c *StackSetController) ReconcileScheduledTraffic(ssc *core.StackSetContainer, currentTimestamp time.Time) error {
+	policy := ssc.StackSet.Spec.TrafficPolicy
+	if policy == nil || len(policy.Scheduled) == 0 {
+		return nil
+	}
+
+	updated := ssc.StackSet.DeepCopy()
+	scheduled := updated.Spec.TrafficPolicy.Scheduled
+	updated.Spec.TrafficPolicy.Scheduled = nil
+
+	due := -1
+	for i, trafficSwitch := range scheduled {
+		if trafficSwitch.ApplyAt.Time.After(currentTimestamp) {
+			updated.Spec.TrafficPolicy.Scheduled = append(updated.Spec.TrafficPolicy.Scheduled, trafficSwitch)
+			continue
+		}
+		if due == -1 || !trafficSwitch.ApplyAt.Before(&scheduled[due].ApplyAt) {
+			due = i
+		}
+	}
+	if due == -1 {
+		return nil
+	}
+	updated.Spec.Traffic = scheduled[due].Weights
+
+	// the switch is recorded as a change by the schedule in the traffic history
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	updated.Annotations[core.TrafficChangedByAnnotationKey] = "stackset-controller (scheduled)"
+
+	result, err := c.client.ZalandoV1().StackSets(updated.Namespace).Update(updated)
+	if err != nil {
+		return err
+	}
+	fixupStackSetTypeMeta(result)
+	ssc.StackSet = result
+
+	c.recorder.Eventf(
+		ssc.StackSet,
+		v1.EventTypeNormal,
+		"AppliedScheduledTraffic",
+		"Applied the traffic switch scheduled at %s",
+		scheduled[due].ApplyAt.Time.Format(time.RFC3339))
+	return nil
+}
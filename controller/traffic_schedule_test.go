@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReconcileScheduledTraffic(t *testing.T) {
+	env := NewTestEnvironment()
+	recorder := record.NewFakeRecorder(10)
+	env.controller.recorder = recorder
+	now := time.Now()
+
+	weights := func(v1, v2 float64) []zv1.StackTrafficWeight {
+		return []zv1.StackTrafficWeight{
+			{StackName: "foo-v1", Weight: v1},
+			{StackName: "foo-v2", Weight: v2},
+		}
+	}
+
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.Traffic = weights(100, 0)
+	stackset.Spec.TrafficPolicy = &zv1.StackSetTrafficSpec{
+		Scheduled: []zv1.ScheduledTrafficSwitch{
+			{ApplyAt: metav1.NewTime(now.Add(-time.Hour)), Weights: weights(50, 50)},
+			{ApplyAt: metav1.NewTime(now.Add(-time.Minute)), Weights: weights(20, 80)},
+			{ApplyAt: metav1.NewTime(now.Add(time.Hour)), Weights: weights(0, 100)},
+		},
+	}
+
+	err := env.CreateStacksets([]zv1.StackSet{stackset})
+	require.NoError(t, err)
+
+	container := &core.StackSetContainer{
+		StackSet:        &stackset,
+		StackContainers: map[types.UID]*core.StackContainer{},
+	}
+
+	// nothing happens before the first switch is due
+	err = env.controller.ReconcileScheduledTraffic(container, now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, weights(100, 0), container.StackSet.Spec.Traffic)
+	require.Len(t, container.StackSet.Spec.TrafficPolicy.Scheduled, 3)
+
+	// the latest due switch is applied and the due ones are removed
+	err = env.controller.ReconcileScheduledTraffic(container, now)
+	require.NoError(t, err)
+
+	result, err := env.client.ZalandoV1().StackSets(stackset.Namespace).Get(stackset.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, weights(20, 80), result.Spec.Traffic)
+	require.Len(t, result.Spec.TrafficPolicy.Scheduled, 1)
+	require.Equal(t, weights(0, 100), result.Spec.TrafficPolicy.Scheduled[0].Weights)
+	require.Equal(t, "stackset-controller (scheduled)", result.Annotations[core.TrafficChangedByAnnotationKey])
+	require.Equal(t, weights(20, 80), container.StackSet.Spec.Traffic)
+	require.Len(t, recorder.Events, 1)
+}
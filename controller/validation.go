@@ -0,0 +1,239 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"github.com/zalando-incubator/stackset-controller/pkg/core"
+	admission "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ForceDeleteAnnotationKey can be set to "true" on a Stack to delete it
+// even though it still gets traffic.
+const ForceDeleteAnnotationKey = "stackset-controller.zalando.org/force-delete"
+
+// ValidatingWebhookHandler returns an HTTP handler for the validating
+// admission webhook of StackSets and Stacks. It rejects the changes which
+// would break the traffic of a StackSet and the invalid specs, which the
+// controller would otherwise only report once it fails to apply them.
+func (c *StackSetController) ValidatingWebhookHandler() http.Handler {
+	return admissionHandler(c.admitValidation)
+}
+
+// admitValidation rejects the admission request if it's invalid.
+func (c *StackSetController) admitValidation(request *admission.AdmissionRequest) (*admission.AdmissionResponse, error) {
+	switch request.Operation {
+	case admission.Create, admission.Update:
+		switch request.Kind.Kind {
+		case "StackSet":
+			var stackset, old zv1.StackSet
+			err := decodeAdmissionObjects(request, &stackset, &old)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the StackSet: %v", err)
+			}
+			err = validateStackSet(&stackset, &old)
+			if err != nil {
+				return nil, err
+			}
+			return nil, c.validateIngressRoutes(&stackset, &old)
+		case "Stack":
+			var stack, old zv1.Stack
+			err := decodeAdmissionObjects(request, &stack, &old)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the Stack: %v", err)
+			}
+			return nil, validateStack(&stack, &old)
+		}
+	case admission.Delete:
+		if request.Kind.Kind == "Stack" {
+			return nil, c.validateStackDeletion(request)
+		}
+	}
+	return nil, nil
+}
+
+// decodeAdmissionObjects decodes the object of an admission request and the
+// object it replaces. The old object is left empty for created objects.
+func decodeAdmissionObjects(request *admission.AdmissionRequest, object, old interface{}) error {
+	err := json.Unmarshal(request.Object.Raw, object)
+	if err != nil {
+		return err
+	}
+	if len(request.OldObject.Raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(request.OldObject.Raw, old)
+}
+
+// validateStackSet checks the spec of a StackSet which is created or
+// updated. The fields which weren't changed by an update aren't validated
+// again, so that the controller can still update StackSets stored before
+// the validation.
+func validateStackSet(stackset, old *zv1.StackSet) error {
+	autoscaler := stackset.Spec.StackTemplate.Spec.Autoscaler
+	if !equality.Semantic.DeepEqual(autoscaler, old.Spec.StackTemplate.Spec.Autoscaler) {
+		err := core.ValidateAutoscaler(autoscaler)
+		if err != nil {
+			return fmt.Errorf("spec.stackTemplate.spec.autoscaler: %v", err)
+		}
+	}
+
+	err := validateLifecycleLimit(stackset, old)
+	if err != nil {
+		return fmt.Errorf("spec.stackLifecycle.limit: %v", err)
+	}
+
+	conflicts := stacksetLabelConflicts(stackset)
+	if len(conflicts) > 0 && !equality.Semantic.DeepEqual(conflicts, stacksetLabelConflicts(old)) {
+		return fmt.Errorf("%s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// validateLifecycleLimit rejects reducing the limit of the stacks kept by a
+// StackSet below the number of stacks which get traffic, which would queue
+// stacks serving traffic for removal.
+func validateLifecycleLimit(stackset, old *zv1.StackSet) error {
+	if old.UID == "" {
+		return nil
+	}
+
+	limit := lifecycleLimit(stackset)
+	if limit >= lifecycleLimit(old) {
+		return nil
+	}
+
+	stacksWithTraffic := old.Status.StacksWithTraffic
+	if limit < stacksWithTraffic {
+		return fmt.Errorf("the limit can't be reduced to %d while %d stacks get traffic, switch the traffic to fewer stacks first", limit, stacksWithTraffic)
+	}
+	return nil
+}
+
+// lifecycleLimit returns the number of stacks kept by a StackSet.
+func lifecycleLimit(stackset *zv1.StackSet) int32 {
+	if stackset.Spec.StackLifecycle.Limit == nil {
+		return core.DefaultStackLifecycleLimit
+	}
+	return *stackset.Spec.StackLifecycle.Limit
+}
+
+// validateStack checks the spec of a Stack which is created or updated,
+// like validateStackSet.
+func validateStack(stack, old *zv1.Stack) error {
+	if !equality.Semantic.DeepEqual(stack.Spec.Autoscaler, old.Spec.Autoscaler) {
+		err := core.ValidateAutoscaler(stack.Spec.Autoscaler)
+		if err != nil {
+			return fmt.Errorf("spec.autoscaler: %v", err)
+		}
+	}
+
+	conflicts := stackLabelConflicts(stack)
+	if len(conflicts) > 0 && !equality.Semantic.DeepEqual(conflicts, stackLabelConflicts(old)) {
+		return fmt.Errorf("%s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// reservedLabelConflicts describes the labels which are set to another value
+// than the one the controller sets them to. The controller selects the pods
+// of the stacks and maps the traffic to them by these labels.
+func reservedLabelConflicts(field string, labels, reserved map[string]string) []string {
+	var result []string
+	for key, value := range reserved {
+		if value == "" {
+			continue
+		}
+		if actual, ok := labels[key]; ok && actual != value {
+			result = append(result, fmt.Sprintf("%s: the label %s is reserved for the value %s set by the controller, not %s", field, key, value, actual))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// stacksetLabelConflicts returns the reserved labels of a StackSet and its
+// stack template which conflict with the name of the StackSet and the
+// version of the stack template.
+func stacksetLabelConflicts(stackset *zv1.StackSet) []string {
+	reserved := map[string]string{
+		core.StacksetHeritageLabelKey: stackset.Name,
+		core.StackVersionLabelKey:     stackset.Spec.StackTemplate.Spec.Version,
+	}
+	return append(
+		reservedLabelConflicts("metadata.labels", stackset.Labels, reserved),
+		reservedLabelConflicts("spec.stackTemplate.spec.podTemplate.metadata.labels", stackset.Spec.StackTemplate.Spec.PodTemplate.Labels, reserved)...)
+}
+
+// stackLabelConflicts returns the reserved labels of a Stack and its pod
+// template which conflict with the name of its StackSet and its version.
+func stackLabelConflicts(stack *zv1.Stack) []string {
+	reserved := map[string]string{
+		core.StacksetHeritageLabelKey: stack.Labels[core.StacksetHeritageLabelKey],
+		core.StackVersionLabelKey:     stack.Labels[core.StackVersionLabelKey],
+	}
+
+	var result []string
+	for _, owner := range stack.OwnerReferences {
+		if owner.Kind == "StackSet" {
+			result = reservedLabelConflicts("metadata.labels", stack.Labels, map[string]string{
+				core.StacksetHeritageLabelKey: owner.Name,
+			})
+			reserved[core.StacksetHeritageLabelKey] = owner.Name
+		}
+	}
+	return append(result, reservedLabelConflicts("spec.podTemplate.metadata.labels", stack.Spec.PodTemplate.Labels, reserved)...)
+}
+
+// validateStackDeletion rejects the deletion of a stack which still gets
+// traffic, unless it's forced with the annotation or the stack is deleted
+// together with its StackSet.
+func (c *StackSetController) validateStackDeletion(request *admission.AdmissionRequest) error {
+	var stack zv1.Stack
+	if len(request.OldObject.Raw) > 0 {
+		err := json.Unmarshal(request.OldObject.Raw, &stack)
+		if err != nil {
+			return fmt.Errorf("failed to decode the Stack: %v", err)
+		}
+	} else {
+		// The API server only sends the deleted object since Kubernetes 1.15
+		existing, err := c.client.ZalandoV1().Stacks(request.Namespace).Get(request.Name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to get the Stack: %v", err)
+		}
+		stack = *existing
+	}
+
+	if stack.Status.ActualTrafficWeight <= 0 || stack.Annotations[ForceDeleteAnnotationKey] == "true" {
+		return nil
+	}
+
+	for _, owner := range stack.OwnerReferences {
+		if owner.Kind != "StackSet" {
+			continue
+		}
+		stackset, err := c.client.ZalandoV1().StackSets(stack.Namespace).Get(owner.Name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to get the StackSet of the Stack: %v", err)
+		}
+		if stackset.UID != owner.UID || stackset.DeletionTimestamp != nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("stack %s gets %.1f%% of the traffic, switch the traffic to other stacks before deleting it or set the annotation %s=true to force the deletion",
+		stack.Name, stack.Status.ActualTrafficWeight, ForceDeleteAnnotationKey)
+}
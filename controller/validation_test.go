@@ -0,0 +1,331 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	admission "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateStackDeletion(t *testing.T) {
+	deleting := metav1.Now()
+
+	for _, tc := range []struct {
+		name        string
+		traffic     float64
+		annotations map[string]string
+		stackset    *zv1.StackSet
+		oldObject   bool
+		allowed     bool
+	}{
+		{
+			name:     "stack without traffic",
+			stackset: &zv1.StackSet{},
+			allowed:  true,
+		},
+		{
+			name:     "stack with traffic",
+			traffic:  30,
+			stackset: &zv1.StackSet{},
+			allowed:  false,
+		},
+		{
+			name:      "stack with traffic sent with the request",
+			traffic:   30,
+			stackset:  &zv1.StackSet{},
+			oldObject: true,
+			allowed:   false,
+		},
+		{
+			name:        "forced deletion of a stack with traffic",
+			traffic:     30,
+			annotations: map[string]string{ForceDeleteAnnotationKey: "true"},
+			stackset:    &zv1.StackSet{},
+			allowed:     true,
+		},
+		{
+			name:     "stack with traffic of a deleted stackset",
+			traffic:  30,
+			stackset: nil,
+			allowed:  true,
+		},
+		{
+			name:     "stack with traffic of a stackset being deleted",
+			traffic:  30,
+			stackset: &zv1.StackSet{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleting}},
+			allowed:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+
+			stackset := testStackset("foo", "default", "123")
+			if tc.stackset != nil {
+				stackset.DeletionTimestamp = tc.stackset.DeletionTimestamp
+				require.NoError(t, env.CreateStacksets([]zv1.StackSet{stackset}))
+			}
+
+			stack := testStack("foo-v1", "default", "abc", stackset)
+			stack.Annotations = tc.annotations
+			stack.Status.ActualTrafficWeight = tc.traffic
+
+			request := &admission.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: "Stack"},
+				Namespace: stack.Namespace,
+				Name:      stack.Name,
+				Operation: admission.Delete,
+			}
+			if tc.oldObject {
+				raw, err := json.Marshal(stack)
+				require.NoError(t, err)
+				request.OldObject = runtime.RawExtension{Raw: raw}
+			} else {
+				require.NoError(t, env.CreateStacks([]zv1.Stack{stack}))
+			}
+
+			response := sendAdmissionReview(t, env.controller.ValidatingWebhookHandler(), request)
+			require.Equal(t, tc.allowed, response.Allowed)
+			if !tc.allowed {
+				require.Contains(t, response.Result.Message, ForceDeleteAnnotationKey)
+			}
+		})
+	}
+}
+
+func TestValidateStackDeletionNotFound(t *testing.T) {
+	env := NewTestEnvironment()
+
+	response := sendAdmissionReview(t, env.controller.ValidatingWebhookHandler(), &admission.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: "Stack"},
+		Namespace: "default",
+		Name:      "foo-v1",
+		Operation: admission.Delete,
+	})
+	require.True(t, response.Allowed)
+}
+
+func TestValidateAutoscalerMetrics(t *testing.T) {
+	utilization := func(value int32) *int32 { return &value }
+	average := func(value string) *resource.Quantity {
+		quantity := resource.MustParse(value)
+		return &quantity
+	}
+
+	for _, tc := range []struct {
+		name    string
+		metrics []zv1.AutoscalerMetrics
+		message string
+	}{
+		{
+			name: "valid metrics",
+			metrics: []zv1.AutoscalerMetrics{
+				{Type: "CPU", AverageUtilization: utilization(50)},
+				{Type: "AmazonSQS", Average: average("10"), Queue: &zv1.MetricsQueue{Name: "jobs", Region: "eu-central-1"}},
+			},
+		},
+		{
+			name:    "utilization below the minimum",
+			metrics: []zv1.AutoscalerMetrics{{Type: "CPU", AverageUtilization: utilization(0)}},
+			message: "spec.stackTemplate.spec.autoscaler: invalid metric CPU (metrics[0].averageUtilization)",
+		},
+		{
+			name: "negative average",
+			metrics: []zv1.AutoscalerMetrics{
+				{Type: "CPU", AverageUtilization: utilization(50)},
+				{Type: "Ingress", Average: average("-1")},
+			},
+			message: "spec.stackTemplate.spec.autoscaler: invalid metric Ingress (metrics[1].average)",
+		},
+		{
+			name:    "queue without region",
+			metrics: []zv1.AutoscalerMetrics{{Type: "AmazonSQS", Average: average("10"), Queue: &zv1.MetricsQueue{Name: "jobs"}}},
+			message: "spec.stackTemplate.spec.autoscaler: invalid metric AmazonSQS (metrics[0].queue)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+
+			stackset := testStackset("foo", "default", "123")
+			stackset.Spec.StackTemplate.Spec.Autoscaler = &zv1.Autoscaler{MaxReplicas: 3, Metrics: tc.metrics}
+
+			response := admissionReview(t, env.controller.ValidatingWebhookHandler(), admission.Create, "StackSet", stackset)
+			if tc.message == "" {
+				require.True(t, response.Allowed)
+				return
+			}
+			require.False(t, response.Allowed)
+			require.Contains(t, response.Result.Message, tc.message)
+		})
+	}
+}
+
+func TestValidateUnchangedAutoscaler(t *testing.T) {
+	env := NewTestEnvironment()
+	handler := env.controller.ValidatingWebhookHandler()
+
+	stack := testStack("foo-v1", "default", "abc", testStackset("foo", "default", "123"))
+	stack.Spec.Autoscaler = &zv1.Autoscaler{MaxReplicas: 3, Metrics: []zv1.AutoscalerMetrics{{Type: "Memory"}}}
+	response := admissionReview(t, handler, admission.Create, "Stack", stack)
+	require.False(t, response.Allowed)
+	require.Contains(t, response.Result.Message, "spec.autoscaler: invalid metric Memory")
+
+	// Stacks stored before the validation can still be updated
+	old, err := json.Marshal(stack)
+	require.NoError(t, err)
+	stack.Labels = map[string]string{"team": "foo"}
+	updated, err := json.Marshal(stack)
+	require.NoError(t, err)
+	response = sendAdmissionReview(t, handler, &admission.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: "Stack"},
+		Operation: admission.Update,
+		Object:    runtime.RawExtension{Raw: updated},
+		OldObject: runtime.RawExtension{Raw: old},
+	})
+	require.True(t, response.Allowed)
+}
+
+func TestValidateLifecycleLimit(t *testing.T) {
+	limit := func(value int32) *int32 { return &value }
+
+	for _, tc := range []struct {
+		name     string
+		oldLimit *int32
+		newLimit *int32
+		allowed  bool
+	}{
+		{
+			name:     "limit increased",
+			oldLimit: limit(2),
+			newLimit: limit(5),
+			allowed:  true,
+		},
+		{
+			name:     "limit reduced to the stacks with traffic",
+			oldLimit: limit(5),
+			newLimit: limit(3),
+			allowed:  true,
+		},
+		{
+			name:     "limit reduced below the stacks with traffic",
+			oldLimit: limit(5),
+			newLimit: limit(2),
+			allowed:  false,
+		},
+		{
+			name:     "default limit reduced below the stacks with traffic",
+			newLimit: limit(1),
+			allowed:  false,
+		},
+		{
+			name:     "limit below the stacks with traffic kept",
+			oldLimit: limit(2),
+			newLimit: limit(2),
+			allowed:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+
+			old := testStackset("foo", "default", "123")
+			old.Spec.StackLifecycle.Limit = tc.oldLimit
+			old.Status.StacksWithTraffic = 3
+			updated := *old.DeepCopy()
+			updated.Spec.StackLifecycle.Limit = tc.newLimit
+
+			oldRaw, err := json.Marshal(old)
+			require.NoError(t, err)
+			updatedRaw, err := json.Marshal(updated)
+			require.NoError(t, err)
+			response := sendAdmissionReview(t, env.controller.ValidatingWebhookHandler(), &admission.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: "zalando.org", Version: "v1", Kind: "StackSet"},
+				Operation: admission.Update,
+				Object:    runtime.RawExtension{Raw: updatedRaw},
+				OldObject: runtime.RawExtension{Raw: oldRaw},
+			})
+			require.Equal(t, tc.allowed, response.Allowed)
+			if !tc.allowed {
+				require.Contains(t, response.Result.Message, "spec.stackLifecycle.limit: the limit can't be reduced")
+			}
+		})
+	}
+}
+
+func TestValidateReservedLabels(t *testing.T) {
+	stackset := testStackset("foo", "default", "123")
+	stackset.Spec.StackTemplate.Spec.Version = "v1"
+
+	for _, tc := range []struct {
+		name     string
+		kind     string
+		object   func() interface{}
+		messages []string
+	}{
+		{
+			name: "stackset with matching labels",
+			kind: "StackSet",
+			object: func() interface{} {
+				stackset := *stackset.DeepCopy()
+				stackset.Labels = map[string]string{"stackset": "foo", "team": "bar"}
+				stackset.Spec.StackTemplate.Spec.PodTemplate.Labels = map[string]string{"stackset": "foo", "stack-version": "v1"}
+				return stackset
+			},
+		},
+		{
+			name: "stackset with conflicting labels",
+			kind: "StackSet",
+			object: func() interface{} {
+				stackset := *stackset.DeepCopy()
+				stackset.Labels = map[string]string{"stackset": "bar"}
+				stackset.Spec.StackTemplate.Spec.PodTemplate.Labels = map[string]string{"stack-version": "v2"}
+				return stackset
+			},
+			messages: []string{
+				"metadata.labels: the label stackset is reserved for the value foo set by the controller, not bar",
+				"spec.stackTemplate.spec.podTemplate.metadata.labels: the label stack-version is reserved for the value v1 set by the controller, not v2",
+			},
+		},
+		{
+			name: "stack with matching labels",
+			kind: "Stack",
+			object: func() interface{} {
+				stack := testStack("foo-v1", "default", "abc", stackset)
+				stack.Labels = map[string]string{"stackset": "foo", "stack-version": "v1"}
+				stack.Spec.PodTemplate.Labels = map[string]string{"stackset": "foo", "stack-version": "v1", "team": "bar"}
+				return stack
+			},
+		},
+		{
+			name: "stack with conflicting labels",
+			kind: "Stack",
+			object: func() interface{} {
+				stack := testStack("foo-v1", "default", "abc", stackset)
+				stack.Labels = map[string]string{"stackset": "bar", "stack-version": "v1"}
+				stack.Spec.PodTemplate.Labels = map[string]string{"stack-version": "v2"}
+				return stack
+			},
+			messages: []string{
+				"metadata.labels: the label stackset is reserved for the value foo set by the controller, not bar",
+				"spec.podTemplate.metadata.labels: the label stack-version is reserved for the value v1 set by the controller, not v2",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := NewTestEnvironment()
+
+			response := admissionReview(t, env.controller.ValidatingWebhookHandler(), admission.Create, tc.kind, tc.object())
+			if len(tc.messages) == 0 {
+				require.True(t, response.Allowed)
+				return
+			}
+			require.False(t, response.Allowed)
+			for _, message := range tc.messages {
+				require.Contains(t, response.Result.Message, message)
+			}
+		})
+	}
+}
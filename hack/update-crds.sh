@@ -0,0 +1,27 @@
+#!/bin/bash
+
+# Embeds the CustomResourceDefinitions from docs/ in the controller binary,
+# so that they can be installed with --install-crds.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT="$(dirname ${BASH_SOURCE})/.."
+OUTPUT="${SCRIPT_ROOT}/pkg/crds/zz_generated.manifests.go"
+
+{
+    echo "// Code generated by hack/update-crds.sh. DO NOT EDIT."
+    echo
+    echo "package crds"
+    echo
+    echo "// stackSetCRD is the content of docs/stackset_crd.yaml."
+    echo "const stackSetCRD = \`$(cat "${SCRIPT_ROOT}/docs/stackset_crd.yaml")"
+    echo "\`"
+    echo
+    echo "// stackCRD is the content of docs/stack_crd.yaml."
+    echo "const stackCRD = \`$(cat "${SCRIPT_ROOT}/docs/stack_crd.yaml")"
+    echo "\`"
+} > "${OUTPUT}"
+
+gofmt -w "${OUTPUT}"
@@ -27,6 +27,53 @@ type StackSetSpec struct {
 	Ingress        *StackSetIngressSpec `json:"ingress"`
 	StackLifecycle StackLifecycle       `json:"stackLifecycle"`
 	StackTemplate  StackTemplate        `json:"stackTemplate"`
+	// Traffic are the desired traffic weights of the Stacks. If set, they
+	// take precedence over the stack traffic weights annotation of the
+	// StackSet ingress.
+	// +optional
+	Traffic []StackTrafficWeight `json:"traffic,omitempty"`
+	// TrafficPolicy configures how traffic is switched between the Stacks
+	// of the StackSet.
+	// +optional
+	TrafficPolicy *StackSetTrafficSpec `json:"trafficPolicy,omitempty"`
+	// AutoscalerProfiles is a list of named sets of autoscaler metrics
+	// which can be used instead of the metrics defined in the autoscaler
+	// of the Stacks.
+	// +optional
+	AutoscalerProfiles []AutoscalerProfile `json:"autoscalerProfiles,omitempty"`
+	// AutoscalerProfile is the name of the active autoscaler profile. If
+	// empty, the metrics defined in the autoscaler of the Stacks are used.
+	// +optional
+	AutoscalerProfile string `json:"autoscalerProfile,omitempty"`
+	// TrafficForecast is a schedule of known traffic spikes ahead of
+	// which the minReplicas of the HPAs of the Stacks getting traffic are
+	// raised.
+	// +optional
+	TrafficForecast []TrafficForecastSchedule `json:"trafficForecast,omitempty"`
+	// Paused stops the reconciliation of the resources and the traffic of
+	// the StackSet and its Stacks. Only their status is updated.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+	// PinnedVersions lists stack versions which are never scaled down
+	// because of missing traffic, e.g. long-lived versions of an API. In
+	// addition to the ProtectedVersions of the StackLifecycle, which are
+	// only never deleted, pinned stacks keep running without traffic.
+	// +optional
+	PinnedVersions []string `json:"pinnedVersions,omitempty"`
+	// RecreateDeletedStacks recreates the Stack of the current version if
+	// it was deleted. By default a deleted Stack isn't created again.
+	// +optional
+	RecreateDeletedStacks bool `json:"recreateDeletedStacks,omitempty"`
+	// MaintenanceWindows are recurring periods during which the traffic
+	// weights aren't changed and no Stacks are deleted. The resources are
+	// still reconciled.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// TrafficSnapshotRestore is the name of a traffic snapshot from the
+	// status whose weights become the desired traffic weights. The field
+	// is cleared once the snapshot was restored.
+	// +optional
+	TrafficSnapshotRestore string `json:"trafficSnapshotRestore,omitempty"`
 }
 
 // StackSetIngressSpec is the ingress defintion of an StackSet. This
@@ -52,6 +99,376 @@ type StackLifecycle struct {
 	// number of Stacks exceeds the limit then the oldest stacks which are
 	// not getting traffic are deleted.
 	Limit *int32 `json:"limit,omitempty"`
+	// MaxAge defines the maximum age of Stacks. Stacks which are older
+	// and not getting traffic are deleted even if the number of Stacks
+	// is below the limit.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// MinStacks defines the minimum number of Stacks not getting traffic
+	// to keep around, even if they're older than MaxAge or exceed the
+	// Limit.
+	// +optional
+	MinStacks *int32 `json:"minStacks,omitempty"`
+	// Ordering defines which Stacks are deleted first when the number of
+	// Stacks exceeds the limit. Defaults to CreationTimestamp.
+	// +optional
+	Ordering StackLifecycleOrdering `json:"ordering,omitempty"`
+	// ProtectedVersions are the versions of Stacks which are never
+	// deleted, e.g. known-good versions to roll back to.
+	// +optional
+	ProtectedVersions []string `json:"protectedVersions,omitempty"`
+	// ReadinessDeadline is the duration within which a new Stack must
+	// become ready. Stacks exceeding the deadline are marked as failed
+	// and don't get any traffic.
+	// +optional
+	ReadinessDeadline *metav1.Duration `json:"readinessDeadline,omitempty"`
+	// DeleteFailedStacks enables deleting Stacks which exceeded the
+	// readiness deadline.
+	// +optional
+	DeleteFailedStacks bool `json:"deleteFailedStacks,omitempty"`
+	// DrainDuration defines the duration over which the traffic of a
+	// Stack is gradually reduced to zero before the Stack is deleted. If
+	// not set, Stacks getting traffic aren't deleted.
+	// +optional
+	DrainDuration *metav1.Duration `json:"drainDuration,omitempty"`
+	// RemovalGracePeriod defines how long Stacks which should be deleted
+	// are only reported in the status of the StackSet before they're
+	// actually deleted.
+	// +optional
+	RemovalGracePeriod *metav1.Duration `json:"removalGracePeriod,omitempty"`
+	// PreDeleteHook is called before a Stack is deleted, e.g. to
+	// deregister its version from external systems. The Stack is only
+	// deleted once the hook succeeded.
+	// +optional
+	PreDeleteHook *LifecycleHook `json:"preDeleteHook,omitempty"`
+	// ScaleDownBehavior defines how Stacks which don't get traffic are
+	// scaled down. Defaults to ScaleToZero.
+	// +optional
+	ScaleDownBehavior ScaleDownBehavior `json:"scaleDownBehavior,omitempty"`
+	// WarmReplicas is the number of replicas kept by Stacks scaled down
+	// with the KeepWarm behavior. Defaults to 1.
+	// +optional
+	WarmReplicas *int32 `json:"warmReplicas,omitempty"`
+	// OnDelete defines what happens to the Stacks when the StackSet is
+	// deleted. Defaults to Delete.
+	// +optional
+	OnDelete StackSetDeletionPolicy `json:"onDelete,omitempty"`
+}
+
+// StackLifecycleOrdering is the order in which Stacks are deleted.
+type StackLifecycleOrdering string
+
+const (
+	// StackLifecycleOrderingCreationTimestamp deletes the oldest Stacks
+	// first.
+	StackLifecycleOrderingCreationTimestamp StackLifecycleOrdering = "CreationTimestamp"
+	// StackLifecycleOrderingNoTrafficSince deletes the Stacks first which
+	// haven't been getting traffic for the longest time. Stacks which
+	// never got traffic are ordered by their creation timestamp.
+	StackLifecycleOrderingNoTrafficSince StackLifecycleOrdering = "NoTrafficSince"
+)
+
+// ScaleDownBehavior defines how Stacks which don't get traffic are scaled
+// down.
+type ScaleDownBehavior string
+
+const (
+	// ScaleDownBehaviorScaleToZero scales the Deployment of the Stack to
+	// zero replicas.
+	ScaleDownBehaviorScaleToZero ScaleDownBehavior = "ScaleToZero"
+	// ScaleDownBehaviorKeepWarm deletes the HPA and keeps a minimum of
+	// warm replicas.
+	ScaleDownBehaviorKeepWarm ScaleDownBehavior = "KeepWarm"
+	// ScaleDownBehaviorDeleteDeployment deletes the Deployment and the
+	// HPA of the Stack. They're recreated once the Stack gets traffic
+	// again.
+	ScaleDownBehaviorDeleteDeployment ScaleDownBehavior = "DeleteDeployment"
+)
+
+// LifecycleHook is an HTTP endpoint notified by the controller about the
+// lifecycle of the Stacks.
+// +k8s:deepcopy-gen=true
+type LifecycleHook struct {
+	// URL is the HTTP(S) endpoint the notification is POSTed to.
+	URL string `json:"url"`
+	// Timeout is the timeout of the request. Defaults to 10s, at most 30s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// LifecycleHookStatus is the result of the calls of a lifecycle hook for a
+// Stack.
+// +k8s:deepcopy-gen=true
+type LifecycleHookStatus struct {
+	// Succeeded is true once the hook succeeded, it isn't called again
+	// afterwards.
+	// +optional
+	Succeeded bool `json:"succeeded,omitempty"`
+	// FailedAttempts is the number of failed calls of the hook.
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// LastCallTime is the last time the hook was called.
+	// +optional
+	LastCallTime *metav1.Time `json:"lastCallTime,omitempty"`
+	// Message is the error of the last failed call.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// StackSetDeletionPolicy defines what happens to the Stacks of a deleted
+// StackSet.
+type StackSetDeletionPolicy string
+
+const (
+	// StackSetDeletionPolicyDelete deletes the Stacks together with the
+	// StackSet.
+	StackSetDeletionPolicyDelete StackSetDeletionPolicy = "Delete"
+	// StackSetDeletionPolicyOrphan keeps the Stacks and the StackSet
+	// ingress when the StackSet is deleted.
+	StackSetDeletionPolicyOrphan StackSetDeletionPolicy = "Orphan"
+)
+
+// StackSetTrafficSpec defines how traffic is switched between the Stacks of
+// a StackSet.
+// +k8s:deepcopy-gen=true
+type StackSetTrafficSpec struct {
+	// Prescaling enables scaling up Stacks before traffic is switched to
+	// them.
+	// +optional
+	Prescaling *PrescalingSpec `json:"prescaling,omitempty"`
+	// Ramp gradually moves the traffic from the other Stacks to a target
+	// Stack.
+	// +optional
+	Ramp *TrafficRampSpec `json:"ramp,omitempty"`
+	// Rollback reverts the traffic to the last known good distribution if
+	// the error rate of the Stack gaining traffic exceeds a threshold.
+	// +optional
+	Rollback *TrafficRollbackSpec `json:"rollback,omitempty"`
+	// Scheduled are traffic switches applied at a later time, e.g. at a
+	// low-traffic hour. Applied switches are removed.
+	// +optional
+	Scheduled []ScheduledTrafficSwitch `json:"scheduled,omitempty"`
+	// HistoryLimit is the number of changes of the desired traffic weights
+	// and of traffic snapshots kept in the status.
+	// Defaults to 10.
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+	// MinWeightChange is the minimum change of the traffic weight of a
+	// Stack. Changes of the desired traffic weights where no Stack changes
+	// by at least this amount are ignored, to prevent micro-adjustments
+	// from switching traffic all the time.
+	// Defaults to 0, i.e. all changes are applied.
+	// +optional
+	MinWeightChange float64 `json:"minWeightChange,omitempty"`
+}
+
+// ScheduledTrafficSwitch is a set of desired traffic weights applied at a
+// given time.
+// +k8s:deepcopy-gen=true
+type ScheduledTrafficSwitch struct {
+	// ApplyAt is the time when the weights become the desired traffic
+	// weights of the StackSet.
+	ApplyAt metav1.Time `json:"applyAt"`
+	// Weights are the desired traffic weights of the Stacks.
+	Weights []StackTrafficWeight `json:"weights"`
+}
+
+// StackTrafficWeight is the traffic weight of a Stack.
+// +k8s:deepcopy-gen=true
+type StackTrafficWeight struct {
+	// StackName is the name of the Stack.
+	StackName string `json:"stackName"`
+	// Weight is the share of the traffic routed to the Stack.
+	Weight float64 `json:"weight"`
+}
+
+// TrafficRollbackSpec configures the automatic rollback of traffic changes.
+// +k8s:deepcopy-gen=true
+type TrafficRollbackSpec struct {
+	// ErrorRate is the error rate metric of the Stack gaining traffic and
+	// its accepted bounds.
+	ErrorRate TrafficAnalysisCheck `json:"errorRate"`
+	// Window is the duration after a traffic change during which the
+	// error rate is watched before the new distribution is considered
+	// good.
+	// Defaults to 10 minutes.
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
+// TrafficRampSpec configures the gradual switch of traffic to a Stack.
+// +k8s:deepcopy-gen=true
+type TrafficRampSpec struct {
+	// Target is the name of the Stack the traffic is moved to.
+	// Defaults to the Stack of the current version.
+	// +optional
+	Target string `json:"target,omitempty"`
+	// Targets are the final traffic weights of the Stacks if the traffic
+	// is moved between several Stacks at once. If set, they take
+	// precedence over Target. Stacks which aren't listed lose all their
+	// traffic.
+	// +optional
+	Targets []StackTrafficWeight `json:"targets,omitempty"`
+	// StepPercent is the percentage of the traffic moved to the target
+	// Stacks on every step.
+	// Defaults to 10.
+	// +optional
+	StepPercent int32 `json:"stepPercent,omitempty"`
+	// Interval is the duration between two steps.
+	// Defaults to 5 minutes.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Analysis are the checks evaluated before every further step of the
+	// ramp.
+	// +optional
+	Analysis []TrafficAnalysisCheck `json:"analysis,omitempty"`
+	// OnFailure defines what happens to the traffic if an analysis check
+	// fails.
+	// Defaults to Halt.
+	// +optional
+	OnFailure TrafficRampFailurePolicy `json:"onFailure,omitempty"`
+}
+
+// TrafficRampFailurePolicy defines what happens to the traffic if an
+// analysis check of a traffic ramp fails.
+type TrafficRampFailurePolicy string
+
+const (
+	// TrafficRampFailurePolicyHalt stops the ramp and keeps the current
+	// traffic weights.
+	TrafficRampFailurePolicyHalt TrafficRampFailurePolicy = "Halt"
+	// TrafficRampFailurePolicyRollback stops the ramp and restores the
+	// traffic weights from before the ramp started.
+	TrafficRampFailurePolicyRollback TrafficRampFailurePolicy = "Rollback"
+)
+
+// TrafficAnalysisCheck is a metric of the target Stack of a traffic ramp
+// which has to stay within the bounds. Exactly one of the queries must be
+// set. The placeholders {namespace}, {stackset} and {stack} in the queries
+// are replaced with the values of the target Stack.
+// +k8s:deepcopy-gen=true
+type TrafficAnalysisCheck struct {
+	// Name is the name of the check.
+	Name string `json:"name"`
+	// Prometheus queries the value from Prometheus.
+	// +optional
+	Prometheus *PrometheusAnalysis `json:"prometheus,omitempty"`
+	// ZMON queries the value from the ZMON KairosDB.
+	// +optional
+	ZMON *ZMONAnalysis `json:"zmon,omitempty"`
+	// Min is the lowest accepted value.
+	// +optional
+	Min *resource.Quantity `json:"min,omitempty"`
+	// Max is the highest accepted value.
+	// +optional
+	Max *resource.Quantity `json:"max,omitempty"`
+}
+
+// PrometheusAnalysis is an instant query of the Prometheus HTTP API. The
+// query must return a single scalar or sample.
+// +k8s:deepcopy-gen=true
+type PrometheusAnalysis struct {
+	// URL is the base URL of the Prometheus server.
+	URL string `json:"url"`
+	// Query is the PromQL query.
+	Query string `json:"query"`
+}
+
+// ZMONAnalysis is the average of a ZMON check value over a duration.
+// +k8s:deepcopy-gen=true
+type ZMONAnalysis struct {
+	// URL is the base URL of the KairosDB storing the check values.
+	URL string `json:"url"`
+	// CheckID is the ID of the ZMON check.
+	CheckID string `json:"checkID"`
+	// Key is the key of the check value.
+	Key string `json:"key"`
+	// Tags filter the check values, e.g. by the stack.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// Duration is the period the values are averaged over.
+	// Defaults to 10 minutes.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// PrescalingSpec configures the prescaling of Stacks before traffic is
+// switched to them.
+// +k8s:deepcopy-gen=true
+type PrescalingSpec struct {
+	// Timeout is the duration prescaling is kept active after the traffic
+	// of a Stack was last increased.
+	// Defaults to 10 minutes.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Cooldown is the duration a prescaled Stack has to be ready before
+	// traffic is switched to it, giving slow starting applications time
+	// to warm up.
+	// Defaults to 0.
+	// +optional
+	Cooldown *metav1.Duration `json:"cooldown,omitempty"`
+	// BufferPercent is the head-room in percent added on top of the
+	// calculated number of prescaled replicas. Must be between 0 and 100.
+	// Defaults to 0.
+	// +optional
+	BufferPercent int32 `json:"bufferPercent,omitempty"`
+	// ReadinessThresholdPercent is the share in percent of the replicas
+	// needed for the desired traffic weight of a Stack which have to be
+	// ready before its traffic is increased. The replicas needed are
+	// based on the replicas of the Stacks getting traffic.
+	// Defaults to 0, i.e. only the prescaled replicas have to be ready.
+	// +optional
+	ReadinessThresholdPercent int32 `json:"readinessThresholdPercent,omitempty"`
+	// CapacityCheck enables checking whether the pods of a Stack gaining
+	// traffic can be scheduled. If not, the traffic switch is held and
+	// the InsufficientCapacity condition is set on the Stack.
+	// +optional
+	CapacityCheck bool `json:"capacityCheck,omitempty"`
+}
+
+// TrafficForecastSchedule defines a recurring traffic spike, e.g. every
+// weekday at 9am.
+// +k8s:deepcopy-gen=true
+type TrafficForecastSchedule struct {
+	// Name is the name of the forecasted event.
+	Name string `json:"name"`
+	// Days are the days of the week (e.g. Mon, Tue) on which the spike
+	// occurs. Defaults to every day.
+	// +optional
+	Days []string `json:"days,omitempty"`
+	// Time is the time of the day (HH:MM) when the spike starts.
+	Time string `json:"time"`
+	// TimeZone is the IANA time zone of Time.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Duration is how long the spike lasts.
+	Duration metav1.Duration `json:"duration"`
+	// LeadTime is how long before the spike the minReplicas are raised.
+	// Defaults to 0.
+	// +optional
+	LeadTime *metav1.Duration `json:"leadTime,omitempty"`
+	// MinReplicas is the total number of replicas required during the
+	// spike. It is distributed between the Stacks getting traffic
+	// according to their traffic weight.
+	MinReplicas int32 `json:"minReplicas"`
+}
+
+// MaintenanceWindow defines a recurring maintenance window, e.g. every
+// Friday from 6pm for the weekend.
+// +k8s:deepcopy-gen=true
+type MaintenanceWindow struct {
+	// Name is the name of the maintenance window.
+	Name string `json:"name"`
+	// Schedule is the cron expression (minute, hour, day of month, month
+	// and day of week) defining when the window starts, e.g. "0 18 * * Fri".
+	Schedule string `json:"schedule"`
+	// TimeZone is the IANA time zone of Schedule.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// Duration is how long the window lasts.
+	Duration metav1.Duration `json:"duration"`
 }
 
 // StackTemplate defines the template used for the Stack created from a
@@ -72,11 +489,32 @@ type MetricsEndpoint struct {
 	Name string `json:"name"`
 }
 
-// MetricsQueue specifies the SQS queue whose length should be used for scaling
+// MetricsQueue specifies the queue whose length should be used for scaling.
+// This is either an SQS queue, a RabbitMQ queue or a Kafka topic.
 // +k8s:deepcopy-gen=true
 type MetricsQueue struct {
-	Name   string `json:"name"`
-	Region string `json:"region"`
+	// Name is the name of the queue or topic.
+	Name string `json:"name"`
+	// Region is the AWS region of an SQS queue.
+	Region string `json:"region,omitempty"`
+	// Broker is the address of the RabbitMQ or Kafka broker.
+	Broker string `json:"broker,omitempty"`
+	// ConsumerGroup is the Kafka consumer group whose lag should be used
+	// for scaling.
+	ConsumerGroup string `json:"consumerGroup,omitempty"`
+}
+
+// MetricsObject specifies a metric of the central Ingress or RouteGroup of a
+// StackSet. The name of the object is filled in by the controller.
+// +k8s:deepcopy-gen=true
+type MetricsObject struct {
+	// MetricName is the name of the metric.
+	MetricName string `json:"metricName"`
+	// Kind is the kind of the described object, either Ingress or
+	// RouteGroup.
+	// Defaults to Ingress.
+	// +optional
+	Kind string `json:"kind,omitempty"`
 }
 
 // AutoscalerMetrics is the type of metric to be be used for autoscaling
@@ -87,8 +525,26 @@ type AutoscalerMetrics struct {
 	Endpoint           *MetricsEndpoint   `json:"endpoint,omitEmpty"`
 	AverageUtilization *int32             `json:"averageUtilization,omitempty"`
 	Queue              *MetricsQueue      `json:"queue,omitEmpty"`
+	Object             *MetricsObject     `json:"object,omitempty"`
+	// Role defines whether the metric is a primary or an advisory metric.
+	// Advisory metrics are left out of the HPA while traffic is switched
+	// between stacks. Defaults to primary.
+	// +optional
+	Role AutoscalerMetricRole `json:"role,omitempty"`
 }
 
+// AutoscalerMetricRole is the role of a metric in an autoscaler.
+type AutoscalerMetricRole string
+
+const (
+	// AutoscalerMetricRolePrimary marks a metric that is always used for
+	// scaling.
+	AutoscalerMetricRolePrimary AutoscalerMetricRole = "primary"
+	// AutoscalerMetricRoleAdvisory marks a metric that is not used for
+	// scaling while traffic is switched between stacks.
+	AutoscalerMetricRoleAdvisory AutoscalerMetricRole = "advisory"
+)
+
 // Autoscaler is the autoscaling definition for a stack
 // +k8s:deepcopy-gen=true
 type Autoscaler struct {
@@ -103,6 +559,17 @@ type Autoscaler struct {
 	Metrics []AutoscalerMetrics `json:"metrics"`
 }
 
+// AutoscalerProfile is a named set of autoscaler metrics, e.g. for different
+// load patterns during the week.
+// +k8s:deepcopy-gen=true
+type AutoscalerProfile struct {
+	// Name is the name of the profile.
+	Name string `json:"name"`
+	// Metrics replace the metrics of the autoscaler of the Stacks while
+	// the profile is active.
+	Metrics []AutoscalerMetrics `json:"metrics"`
+}
+
 // HorizontalPodAutoscaler is the Autoscaling configuration of a Stack. If
 // defined an HPA will be created for the Stack.
 // +k8s:deepcopy-gen=true
@@ -145,6 +612,240 @@ type StackSetStatus struct {
 	// TODO: add a more detailed comment
 	// +optional
 	ObservedStackVersion string `json:"observedStackVersion,omitempty"`
+	// PendingRemoval lists the Stacks which are going to be deleted once
+	// the removal grace period is over.
+	// +optional
+	PendingRemoval []PendingStackRemoval `json:"pendingRemoval,omitempty"`
+	// TrafficRamp is the progress of the traffic ramp.
+	// +optional
+	TrafficRamp *TrafficRampStatus `json:"trafficRamp,omitempty"`
+	// TrafficRollback is the state of the automatic traffic rollback.
+	// +optional
+	TrafficRollback *TrafficRollbackStatus `json:"trafficRollback,omitempty"`
+	// Traffic are the actual traffic weights of the Stacks.
+	// +optional
+	Traffic []StackTrafficWeight `json:"traffic,omitempty"`
+	// DesiredTraffic are the desired traffic weights of the Stacks if they
+	// are defined in the spec, including the changes made by the
+	// controller, e.g. by a traffic ramp or a rollback. They start over
+	// from the spec whenever its traffic weights are changed.
+	// +optional
+	DesiredTraffic []StackTrafficWeight `json:"desiredTraffic,omitempty"`
+	// ObservedTraffic are the traffic weights of the spec the
+	// DesiredTraffic is based on.
+	// +optional
+	ObservedTraffic []StackTrafficWeight `json:"observedTraffic,omitempty"`
+	// TrafficHistory are the last changes of the desired traffic weights,
+	// the most recent one last.
+	// +optional
+	TrafficHistory []TrafficChangeRecord `json:"trafficHistory,omitempty"`
+	// TrafficSnapshots are the last desired traffic weights taken before
+	// they were changed, the most recent one last.
+	// +optional
+	TrafficSnapshots []TrafficSnapshot `json:"trafficSnapshots,omitempty"`
+	// LastErrors are the last errors which occurred while reconciling the
+	// StackSet, the most recent one last.
+	// +optional
+	LastErrors []ReconcileErrorRecord `json:"lastErrors,omitempty"`
+	// LastChanges are the changes made by the last reconciliation which
+	// changed any of the resources of the StackSet or the traffic.
+	// +optional
+	LastChanges *ChangeSummary `json:"lastChanges,omitempty"`
+	// Conditions describe the current state of the StackSet.
+	// +optional
+	Conditions []StackSetCondition `json:"conditions,omitempty"`
+}
+
+// StackSetConditionType is the type of a StackSet condition.
+type StackSetConditionType string
+
+const (
+	// StackSetConditionTrafficSwitchProgressing indicates whether traffic
+	// is being switched to prescaled Stacks.
+	StackSetConditionTrafficSwitchProgressing StackSetConditionType = "TrafficSwitchProgressing"
+	// StackSetConditionReconcileTimeout indicates whether the last
+	// reconciliation of the StackSet was aborted because it exceeded its
+	// deadline.
+	StackSetConditionReconcileTimeout StackSetConditionType = "ReconcileTimeout"
+	// StackSetConditionReady indicates whether all the Stacks which
+	// should get traffic are ready.
+	StackSetConditionReady StackSetConditionType = "Ready"
+	// StackSetConditionTrafficInSync indicates whether the actual traffic
+	// weights of the Stacks match the desired ones.
+	StackSetConditionTrafficInSync StackSetConditionType = "TrafficInSync"
+	// StackSetConditionStacksGarbageCollected indicates whether all the
+	// Stacks selected for removal were deleted.
+	StackSetConditionStacksGarbageCollected StackSetConditionType = "StacksGarbageCollected"
+	// StackSetConditionIngressUpToDate indicates whether the Ingress of the
+	// StackSet was updated successfully.
+	StackSetConditionIngressUpToDate StackSetConditionType = "IngressUpToDate"
+	// StackSetConditionUsesDeprecatedFeatures indicates that the StackSet
+	// uses features which are going to be removed, e.g. with the next API
+	// version. It's only reported while such features are used.
+	StackSetConditionUsesDeprecatedFeatures StackSetConditionType = "UsesDeprecatedFeatures"
+)
+
+// StackSetCondition describes the state of a StackSet at a certain point.
+// +k8s:deepcopy-gen=true
+type StackSetCondition struct {
+	// Type of the condition.
+	Type StackSetConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status v1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition transitioned from
+	// one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a unique, one-word, CamelCase reason for the condition's
+	// last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable message indicating details about the
+	// transition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ChangeSummary lists the changes a reconciliation of a StackSet made to its
+// resources and the traffic. Resources are listed as <kind>/<name>.
+// +k8s:deepcopy-gen=true
+type ChangeSummary struct {
+	// Time is the timestamp of the reconciliation.
+	Time metav1.Time `json:"time"`
+	// Created are the resources which were created.
+	// +optional
+	Created []string `json:"created,omitempty"`
+	// Updated are the resources which were updated.
+	// +optional
+	Updated []string `json:"updated,omitempty"`
+	// Deleted are the resources which were deleted.
+	// +optional
+	Deleted []string `json:"deleted,omitempty"`
+	// Traffic are the changes of the actual traffic weights of the
+	// Stacks.
+	// +optional
+	Traffic []string `json:"traffic,omitempty"`
+}
+
+// ReconcileErrorRecord is an error which occurred while reconciling a
+// StackSet. Repeated occurrences of the same error are counted.
+// +k8s:deepcopy-gen=true
+type ReconcileErrorRecord struct {
+	// Message is the error message.
+	Message string `json:"message"`
+	// FirstTimestamp is the time the error first occurred.
+	FirstTimestamp metav1.Time `json:"firstTimestamp"`
+	// LastTimestamp is the time the error most recently occurred.
+	LastTimestamp metav1.Time `json:"lastTimestamp"`
+	// Count is the number of times the error occurred.
+	Count int32 `json:"count"`
+}
+
+// TrafficSnapshot is a traffic distribution recorded before it was changed,
+// which can be restored with the name of the snapshot.
+// +k8s:deepcopy-gen=true
+type TrafficSnapshot struct {
+	// Name is the name of the snapshot, derived from the time it was
+	// taken.
+	Name string `json:"name"`
+	// Time is the timestamp when the snapshot was taken.
+	Time metav1.Time `json:"time"`
+	// Weights are the desired traffic weights of the Stacks.
+	Weights []StackTrafficWeight `json:"weights"`
+}
+
+// TrafficChangeSource is where a change of the desired traffic weights
+// came from.
+type TrafficChangeSource string
+
+const (
+	// TrafficChangeSourceAnnotation is a change of the stack traffic
+	// weights annotation of the StackSet ingress.
+	TrafficChangeSourceAnnotation TrafficChangeSource = "Annotation"
+	// TrafficChangeSourceSpec is a change of the desired traffic weights
+	// in the StackSet spec.
+	TrafficChangeSourceSpec TrafficChangeSource = "Spec"
+	// TrafficChangeSourceController is a change made by the controller,
+	// e.g. by a traffic ramp or rollback.
+	TrafficChangeSourceController TrafficChangeSource = "Controller"
+)
+
+// TrafficChangeRecord is a change of the desired traffic weights.
+// +k8s:deepcopy-gen=true
+type TrafficChangeRecord struct {
+	// Time is the timestamp when the change was observed.
+	Time metav1.Time `json:"time"`
+	// Source is where the change came from.
+	Source TrafficChangeSource `json:"source"`
+	// ChangedBy is who made the change, as reported by the tool making
+	// it.
+	// +optional
+	ChangedBy string `json:"changedBy,omitempty"`
+	// From are the desired traffic weights before the change.
+	// +optional
+	From []StackTrafficWeight `json:"from,omitempty"`
+	// To are the desired traffic weights after the change.
+	// +optional
+	To []StackTrafficWeight `json:"to,omitempty"`
+}
+
+// TrafficRampStatus is the progress of the gradual switch of traffic to a
+// Stack.
+// +k8s:deepcopy-gen=true
+type TrafficRampStatus struct {
+	// Target is the name of the Stack the traffic is moved to. Empty if
+	// the traffic is moved to several Stacks.
+	Target string `json:"target"`
+	// TargetWeights are the final traffic weights of the Stacks if the
+	// traffic is moved to several Stacks.
+	// +optional
+	TargetWeights map[string]float64 `json:"targetWeights,omitempty"`
+	// LastStepTime is the timestamp of the last step of the ramp.
+	LastStepTime metav1.Time `json:"lastStepTime"`
+	// InitialWeights are the desired traffic weights of the Stacks before
+	// the ramp started.
+	// +optional
+	InitialWeights map[string]float64 `json:"initialWeights,omitempty"`
+	// Failed is set if an analysis check failed and the ramp was stopped.
+	// +optional
+	Failed bool `json:"failed,omitempty"`
+	// Reason explains why the ramp was stopped.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// TrafficRollbackStatus is the state of the automatic traffic rollback.
+// +k8s:deepcopy-gen=true
+type TrafficRollbackStatus struct {
+	// KnownGoodWeights are the last desired traffic weights of the Stacks
+	// which passed the error rate check.
+	// +optional
+	KnownGoodWeights map[string]float64 `json:"knownGoodWeights,omitempty"`
+	// Stack is the name of the Stack gaining traffic whose error rate is
+	// watched.
+	// +optional
+	Stack string `json:"stack,omitempty"`
+	// Weight is the desired traffic weight of the watched Stack.
+	// +optional
+	Weight float64 `json:"weight,omitempty"`
+	// Since is the timestamp since when the Stack is watched.
+	// +optional
+	Since *metav1.Time `json:"since,omitempty"`
+	// LastRollbackTime is the timestamp of the last rollback.
+	// +optional
+	LastRollbackTime *metav1.Time `json:"lastRollbackTime,omitempty"`
+	// LastRollbackReason explains why the traffic was last rolled back.
+	// +optional
+	LastRollbackReason string `json:"lastRollbackReason,omitempty"`
+}
+
+// PendingStackRemoval is a Stack which is going to be deleted.
+// +k8s:deepcopy-gen=true
+type PendingStackRemoval struct {
+	// Name is the name of the Stack.
+	Name string `json:"name"`
+	// Since is the timestamp since when the Stack should be deleted.
+	Since metav1.Time `json:"since"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -186,6 +887,12 @@ type StackSpec struct {
 	PodTemplate v1.PodTemplateSpec `json:"podTemplate"`
 
 	Autoscaler *Autoscaler `json:"autoscaler,omitempty"`
+
+	// MaxTrafficWeight is the maximum traffic weight of the stack, e.g.
+	// to keep a canary at a small share of the traffic. Desired traffic
+	// exceeding it is redistributed to the other stacks.
+	// +optional
+	MaxTrafficWeight *float64 `json:"maxTrafficWeight,omitempty"`
 }
 
 // StackServiceSpec makes it possible to customize the service generated for
@@ -242,6 +949,126 @@ type StackStatus struct {
 	// NoTrafficSince is the timestamp defining the last time the stack was
 	// observed getting traffic.
 	NoTrafficSince *metav1.Time `json:"noTrafficSince,omitempty"`
+	// AutoscalerFrozenSince is the timestamp since when the HPA of the
+	// stack is pinned to the current number of replicas because of an
+	// ongoing traffic switch.
+	// +optional
+	AutoscalerFrozenSince *metav1.Time `json:"autoscalerFrozenSince,omitempty"`
+	// DrainingSince is the timestamp since when the traffic of the stack
+	// is being drained before it's deleted.
+	// +optional
+	DrainingSince *metav1.Time `json:"drainingSince,omitempty"`
+	// DrainingTrafficWeight is the traffic weight of the stack when the
+	// draining started.
+	// +optional
+	DrainingTrafficWeight float64 `json:"drainingTrafficWeight,omitempty"`
+	// Autoscaler is the status of the HorizontalPodAutoscaler managed by
+	// the stack.
+	// +optional
+	Autoscaler *AutoscalerStatus `json:"autoscaler,omitempty"`
+	// ResourceRecommendations are the resource requests recommended for
+	// the containers of the stack based on their observed usage. They
+	// are informational only and not applied by the controller.
+	// +optional
+	ResourceRecommendations []ContainerResourceRecommendation `json:"resourceRecommendations,omitempty"`
+	// ResourceRecommendationsUpdateTime is the last time the resource
+	// usage of the stack was observed for the recommendations.
+	// +optional
+	ResourceRecommendationsUpdateTime *metav1.Time `json:"resourceRecommendationsUpdateTime,omitempty"`
+	// PreDeleteHook is the result of the calls of the pre-deletion hook
+	// of the StackSet for the stack.
+	// +optional
+	PreDeleteHook *LifecycleHookStatus `json:"preDeleteHook,omitempty"`
+	// Conditions describe the current state of the stack.
+	// +optional
+	Conditions []StackCondition `json:"conditions,omitempty"`
+}
+
+// AutoscalerStatus is the status of the HorizontalPodAutoscaler of a stack
+// as last observed by the controller.
+// +k8s:deepcopy-gen=true
+type AutoscalerStatus struct {
+	// CurrentReplicas is the number of replicas last seen by the
+	// autoscaler.
+	CurrentReplicas int32 `json:"currentReplicas"`
+	// DesiredReplicas is the number of replicas last calculated by the
+	// autoscaler.
+	DesiredReplicas int32 `json:"desiredReplicas"`
+	// LastScaleTime is the last time the autoscaler scaled the stack.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	// CurrentMetrics are the last read values of the metrics used by the
+	// autoscaler.
+	// +optional
+	CurrentMetrics []autoscaling.MetricStatus `json:"currentMetrics,omitempty"`
+	// Conditions are the conditions reported by the autoscaler.
+	// +optional
+	Conditions []autoscaling.HorizontalPodAutoscalerCondition `json:"conditions,omitempty"`
+}
+
+// ContainerResourceRecommendation is the recommended resource requests of a
+// container of a stack.
+// +k8s:deepcopy-gen=true
+type ContainerResourceRecommendation struct {
+	// ContainerName is the name of the container.
+	ContainerName string `json:"containerName"`
+	// Requests are the recommended resource requests of the container.
+	Requests v1.ResourceList `json:"requests"`
+}
+
+// StackConditionType is the type of a Stack condition.
+type StackConditionType string
+
+const (
+	// StackConditionAutoscalerValid indicates whether the autoscaler
+	// definition of the stack could be converted into a valid HPA.
+	StackConditionAutoscalerValid StackConditionType = "AutoscalerValid"
+	// StackConditionReplicasConflict indicates whether the replicas of
+	// the stack conflict with the replica bounds of its autoscaler.
+	StackConditionReplicasConflict StackConditionType = "ReplicasConflict"
+	// StackConditionFailed indicates whether the stack failed to become
+	// ready within the readiness deadline.
+	StackConditionFailed StackConditionType = "Failed"
+	// StackConditionDeletionBlocked indicates that the deletion of the
+	// stack is blocked because it's still getting traffic.
+	StackConditionDeletionBlocked StackConditionType = "DeletionBlocked"
+	// StackConditionInsufficientCapacity indicates that the traffic of the
+	// stack isn't increased because some of its pods can't be scheduled.
+	StackConditionInsufficientCapacity StackConditionType = "InsufficientCapacity"
+	// StackConditionResourcesReady indicates whether the resources of the
+	// stack were reconciled successfully and its deployment is ready.
+	StackConditionResourcesReady StackConditionType = "ResourcesReady"
+	// StackConditionHasTraffic indicates whether the stack gets or should
+	// get traffic.
+	StackConditionHasTraffic StackConditionType = "HasTraffic"
+	// StackConditionScheduledForRemoval indicates whether the stack was
+	// selected to be deleted.
+	StackConditionScheduledForRemoval StackConditionType = "ScheduledForRemoval"
+	// StackConditionBackendPortValid indicates whether the backendPort of
+	// the ingress of the StackSet matches a port of the service of the
+	// stack. It's only reported for StackSets with an ingress.
+	StackConditionBackendPortValid StackConditionType = "BackendPortValid"
+)
+
+// StackCondition describes the state of a Stack at a certain point.
+// +k8s:deepcopy-gen=true
+type StackCondition struct {
+	// Type of the condition.
+	Type StackConditionType `json:"type"`
+	// Status of the condition, one of True, False, Unknown.
+	Status v1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition transitioned from
+	// one status to another.
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a unique, one-word, CamelCase reason for the condition's
+	// last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable message indicating details about the
+	// transition.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // Prescaling hold prescaling information
@@ -259,8 +1086,48 @@ type PrescalingStatus struct {
 	// LastTrafficIncrease is the timestamp when the traffic was last increased on the stack
 	// +optional
 	LastTrafficIncrease *metav1.Time `json:"lastTrafficIncrease,omitempty"`
+	// ReadySince is the timestamp since when the stack is ready with the
+	// prescaled replicas
+	// +optional
+	ReadySince *metav1.Time `json:"readySince,omitempty"`
+	// Phase is the progress of the traffic switch to the prescaled stack.
+	// +optional
+	Phase PrescalingPhase `json:"phase,omitempty"`
+	// ReadyReplicas is the number of ready replicas of the stack, to be
+	// compared with the prescaled Replicas.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// StartTime is the timestamp when the stack was prescaled for the
+	// desired traffic weight.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the timestamp when the traffic was switched to
+	// the prescaled stack.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
+// PrescalingPhase is the progress of the traffic switch to a prescaled
+// Stack.
+type PrescalingPhase string
+
+const (
+	// PrescalingPhasePending means the Stack isn't scaled up to the
+	// prescaled replicas yet.
+	PrescalingPhasePending PrescalingPhase = "Pending"
+	// PrescalingPhaseScaling means the prescaled replicas aren't ready
+	// yet.
+	PrescalingPhaseScaling PrescalingPhase = "Scaling"
+	// PrescalingPhaseWaiting means the prescaled replicas are ready but
+	// the traffic isn't switched yet, e.g. because of the cooldown.
+	PrescalingPhaseWaiting PrescalingPhase = "Waiting"
+	// PrescalingPhaseCompleted means the traffic was switched.
+	PrescalingPhaseCompleted PrescalingPhase = "Completed"
+	// PrescalingPhaseTimedOut means the traffic wasn't switched within
+	// the prescaling timeout.
+	PrescalingPhaseTimedOut PrescalingPhase = "TimedOut"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // StackList is a list of Stacks.
@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -23,6 +24,7 @@ package v1
 import (
 	v2beta1 "k8s.io/api/autoscaling/v2beta1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -77,6 +79,11 @@ func (in *AutoscalerMetrics) DeepCopyInto(out *AutoscalerMetrics) {
 		*out = new(MetricsQueue)
 		**out = **in
 	}
+	if in.Object != nil {
+		in, out := &in.Object, &out.Object
+		*out = new(MetricsObject)
+		**out = **in
+	}
 	return
 }
 
@@ -90,6 +97,123 @@ func (in *AutoscalerMetrics) DeepCopy() *AutoscalerMetrics {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalerProfile) DeepCopyInto(out *AutoscalerProfile) {
+	*out = *in
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]AutoscalerMetrics, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalerProfile.
+func (in *AutoscalerProfile) DeepCopy() *AutoscalerProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalerProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalerStatus) DeepCopyInto(out *AutoscalerStatus) {
+	*out = *in
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CurrentMetrics != nil {
+		in, out := &in.CurrentMetrics, &out.CurrentMetrics
+		*out = make([]v2beta1.MetricStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v2beta1.HorizontalPodAutoscalerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalerStatus.
+func (in *AutoscalerStatus) DeepCopy() *AutoscalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChangeSummary) DeepCopyInto(out *ChangeSummary) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Created != nil {
+		in, out := &in.Created, &out.Created
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Updated != nil {
+		in, out := &in.Updated, &out.Updated
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deleted != nil {
+		in, out := &in.Deleted, &out.Deleted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Traffic != nil {
+		in, out := &in.Traffic, &out.Traffic
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChangeSummary.
+func (in *ChangeSummary) DeepCopy() *ChangeSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ChangeSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerResourceRecommendation) DeepCopyInto(out *ContainerResourceRecommendation) {
+	*out = *in
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerResourceRecommendation.
+func (in *ContainerResourceRecommendation) DeepCopy() *ContainerResourceRecommendation {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerResourceRecommendation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HorizontalPodAutoscaler) DeepCopyInto(out *HorizontalPodAutoscaler) {
 	*out = *in
@@ -119,6 +243,64 @@ func (in *HorizontalPodAutoscaler) DeepCopy() *HorizontalPodAutoscaler {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleHook) DeepCopyInto(out *LifecycleHook) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleHook.
+func (in *LifecycleHook) DeepCopy() *LifecycleHook {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleHookStatus) DeepCopyInto(out *LifecycleHookStatus) {
+	*out = *in
+	if in.LastCallTime != nil {
+		in, out := &in.LastCallTime, &out.LastCallTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleHookStatus.
+func (in *LifecycleHookStatus) DeepCopy() *LifecycleHookStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleHookStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsEndpoint) DeepCopyInto(out *MetricsEndpoint) {
 	*out = *in
@@ -135,6 +317,22 @@ func (in *MetricsEndpoint) DeepCopy() *MetricsEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsObject) DeepCopyInto(out *MetricsObject) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsObject.
+func (in *MetricsObject) DeepCopy() *MetricsObject {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsQueue) DeepCopyInto(out *MetricsQueue) {
 	*out = *in
@@ -151,6 +349,49 @@ func (in *MetricsQueue) DeepCopy() *MetricsQueue {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingStackRemoval) DeepCopyInto(out *PendingStackRemoval) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingStackRemoval.
+func (in *PendingStackRemoval) DeepCopy() *PendingStackRemoval {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingStackRemoval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrescalingSpec) DeepCopyInto(out *PrescalingSpec) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Cooldown != nil {
+		in, out := &in.Cooldown, &out.Cooldown
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrescalingSpec.
+func (in *PrescalingSpec) DeepCopy() *PrescalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrescalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PrescalingStatus) DeepCopyInto(out *PrescalingStatus) {
 	*out = *in
@@ -158,6 +399,18 @@ func (in *PrescalingStatus) DeepCopyInto(out *PrescalingStatus) {
 		in, out := &in.LastTrafficIncrease, &out.LastTrafficIncrease
 		*out = (*in).DeepCopy()
 	}
+	if in.ReadySince != nil {
+		in, out := &in.ReadySince, &out.ReadySince
+		*out = (*in).DeepCopy()
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -171,6 +424,62 @@ func (in *PrescalingStatus) DeepCopy() *PrescalingStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusAnalysis) DeepCopyInto(out *PrometheusAnalysis) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusAnalysis.
+func (in *PrometheusAnalysis) DeepCopy() *PrometheusAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReconcileErrorRecord) DeepCopyInto(out *ReconcileErrorRecord) {
+	*out = *in
+	in.FirstTimestamp.DeepCopyInto(&out.FirstTimestamp)
+	in.LastTimestamp.DeepCopyInto(&out.LastTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReconcileErrorRecord.
+func (in *ReconcileErrorRecord) DeepCopy() *ReconcileErrorRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ReconcileErrorRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledTrafficSwitch) DeepCopyInto(out *ScheduledTrafficSwitch) {
+	*out = *in
+	in.ApplyAt.DeepCopyInto(&out.ApplyAt)
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledTrafficSwitch.
+func (in *ScheduledTrafficSwitch) DeepCopy() *ScheduledTrafficSwitch {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledTrafficSwitch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Stack) DeepCopyInto(out *Stack) {
 	*out = *in
@@ -199,6 +508,23 @@ func (in *Stack) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackCondition) DeepCopyInto(out *StackCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackCondition.
+func (in *StackCondition) DeepCopy() *StackCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(StackCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackLifecycle) DeepCopyInto(out *StackLifecycle) {
 	*out = *in
@@ -212,6 +538,46 @@ func (in *StackLifecycle) DeepCopyInto(out *StackLifecycle) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MinStacks != nil {
+		in, out := &in.MinStacks, &out.MinStacks
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ProtectedVersions != nil {
+		in, out := &in.ProtectedVersions, &out.ProtectedVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReadinessDeadline != nil {
+		in, out := &in.ReadinessDeadline, &out.ReadinessDeadline
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.DrainDuration != nil {
+		in, out := &in.DrainDuration, &out.DrainDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RemovalGracePeriod != nil {
+		in, out := &in.RemovalGracePeriod, &out.RemovalGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PreDeleteHook != nil {
+		in, out := &in.PreDeleteHook, &out.PreDeleteHook
+		*out = new(LifecycleHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WarmReplicas != nil {
+		in, out := &in.WarmReplicas, &out.WarmReplicas
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -286,7 +652,7 @@ func (in *StackSet) DeepCopyInto(out *StackSet) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -308,6 +674,23 @@ func (in *StackSet) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSetCondition) DeepCopyInto(out *StackSetCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSetCondition.
+func (in *StackSetCondition) DeepCopy() *StackSetCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSetCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackSetIngressSpec) DeepCopyInto(out *StackSetIngressSpec) {
 	*out = *in
@@ -374,6 +757,40 @@ func (in *StackSetSpec) DeepCopyInto(out *StackSetSpec) {
 	}
 	in.StackLifecycle.DeepCopyInto(&out.StackLifecycle)
 	in.StackTemplate.DeepCopyInto(&out.StackTemplate)
+	if in.Traffic != nil {
+		in, out := &in.Traffic, &out.Traffic
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrafficPolicy != nil {
+		in, out := &in.TrafficPolicy, &out.TrafficPolicy
+		*out = new(StackSetTrafficSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AutoscalerProfiles != nil {
+		in, out := &in.AutoscalerProfiles, &out.AutoscalerProfiles
+		*out = make([]AutoscalerProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TrafficForecast != nil {
+		in, out := &in.TrafficForecast, &out.TrafficForecast
+		*out = make([]TrafficForecastSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PinnedVersions != nil {
+		in, out := &in.PinnedVersions, &out.PinnedVersions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -390,6 +807,71 @@ func (in *StackSetSpec) DeepCopy() *StackSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackSetStatus) DeepCopyInto(out *StackSetStatus) {
 	*out = *in
+	if in.PendingRemoval != nil {
+		in, out := &in.PendingRemoval, &out.PendingRemoval
+		*out = make([]PendingStackRemoval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TrafficRamp != nil {
+		in, out := &in.TrafficRamp, &out.TrafficRamp
+		*out = new(TrafficRampStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrafficRollback != nil {
+		in, out := &in.TrafficRollback, &out.TrafficRollback
+		*out = new(TrafficRollbackStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Traffic != nil {
+		in, out := &in.Traffic, &out.Traffic
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.DesiredTraffic != nil {
+		in, out := &in.DesiredTraffic, &out.DesiredTraffic
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.ObservedTraffic != nil {
+		in, out := &in.ObservedTraffic, &out.ObservedTraffic
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.TrafficHistory != nil {
+		in, out := &in.TrafficHistory, &out.TrafficHistory
+		*out = make([]TrafficChangeRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TrafficSnapshots != nil {
+		in, out := &in.TrafficSnapshots, &out.TrafficSnapshots
+		*out = make([]TrafficSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastErrors != nil {
+		in, out := &in.LastErrors, &out.LastErrors
+		*out = make([]ReconcileErrorRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastChanges != nil {
+		in, out := &in.LastChanges, &out.LastChanges
+		*out = new(ChangeSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]StackSetCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -403,6 +885,49 @@ func (in *StackSetStatus) DeepCopy() *StackSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackSetTrafficSpec) DeepCopyInto(out *StackSetTrafficSpec) {
+	*out = *in
+	if in.Prescaling != nil {
+		in, out := &in.Prescaling, &out.Prescaling
+		*out = new(PrescalingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ramp != nil {
+		in, out := &in.Ramp, &out.Ramp
+		*out = new(TrafficRampSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(TrafficRollbackSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Scheduled != nil {
+		in, out := &in.Scheduled, &out.Scheduled
+		*out = make([]ScheduledTrafficSwitch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HistoryLimit != nil {
+		in, out := &in.HistoryLimit, &out.HistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackSetTrafficSpec.
+func (in *StackSetTrafficSpec) DeepCopy() *StackSetTrafficSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(StackSetTrafficSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StackSpec) DeepCopyInto(out *StackSpec) {
 	*out = *in
@@ -427,6 +952,11 @@ func (in *StackSpec) DeepCopyInto(out *StackSpec) {
 		*out = new(Autoscaler)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MaxTrafficWeight != nil {
+		in, out := &in.MaxTrafficWeight, &out.MaxTrafficWeight
+		*out = new(float64)
+		**out = **in
+	}
 	return
 }
 
@@ -465,6 +995,42 @@ func (in *StackStatus) DeepCopyInto(out *StackStatus) {
 		in, out := &in.NoTrafficSince, &out.NoTrafficSince
 		*out = (*in).DeepCopy()
 	}
+	if in.AutoscalerFrozenSince != nil {
+		in, out := &in.AutoscalerFrozenSince, &out.AutoscalerFrozenSince
+		*out = (*in).DeepCopy()
+	}
+	if in.DrainingSince != nil {
+		in, out := &in.DrainingSince, &out.DrainingSince
+		*out = (*in).DeepCopy()
+	}
+	if in.Autoscaler != nil {
+		in, out := &in.Autoscaler, &out.Autoscaler
+		*out = new(AutoscalerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResourceRecommendations != nil {
+		in, out := &in.ResourceRecommendations, &out.ResourceRecommendations
+		*out = make([]ContainerResourceRecommendation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResourceRecommendationsUpdateTime != nil {
+		in, out := &in.ResourceRecommendationsUpdateTime, &out.ResourceRecommendationsUpdateTime
+		*out = (*in).DeepCopy()
+	}
+	if in.PreDeleteHook != nil {
+		in, out := &in.PreDeleteHook, &out.PreDeleteHook
+		*out = new(LifecycleHookStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]StackCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -495,3 +1061,276 @@ func (in *StackTemplate) DeepCopy() *StackTemplate {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StackTrafficWeight) DeepCopyInto(out *StackTrafficWeight) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StackTrafficWeight.
+func (in *StackTrafficWeight) DeepCopy() *StackTrafficWeight {
+	if in == nil {
+		return nil
+	}
+	out := new(StackTrafficWeight)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficAnalysisCheck) DeepCopyInto(out *TrafficAnalysisCheck) {
+	*out = *in
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusAnalysis)
+		**out = **in
+	}
+	if in.ZMON != nil {
+		in, out := &in.ZMON, &out.ZMON
+		*out = new(ZMONAnalysis)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficAnalysisCheck.
+func (in *TrafficAnalysisCheck) DeepCopy() *TrafficAnalysisCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficAnalysisCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficChangeRecord) DeepCopyInto(out *TrafficChangeRecord) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficChangeRecord.
+func (in *TrafficChangeRecord) DeepCopy() *TrafficChangeRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficChangeRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficForecastSchedule) DeepCopyInto(out *TrafficForecastSchedule) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+	if in.LeadTime != nil {
+		in, out := &in.LeadTime, &out.LeadTime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficForecastSchedule.
+func (in *TrafficForecastSchedule) DeepCopy() *TrafficForecastSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficForecastSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficRampSpec) DeepCopyInto(out *TrafficRampSpec) {
+	*out = *in
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Analysis != nil {
+		in, out := &in.Analysis, &out.Analysis
+		*out = make([]TrafficAnalysisCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRampSpec.
+func (in *TrafficRampSpec) DeepCopy() *TrafficRampSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficRampSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficRampStatus) DeepCopyInto(out *TrafficRampStatus) {
+	*out = *in
+	if in.TargetWeights != nil {
+		in, out := &in.TargetWeights, &out.TargetWeights
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.LastStepTime.DeepCopyInto(&out.LastStepTime)
+	if in.InitialWeights != nil {
+		in, out := &in.InitialWeights, &out.InitialWeights
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRampStatus.
+func (in *TrafficRampStatus) DeepCopy() *TrafficRampStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficRampStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficRollbackSpec) DeepCopyInto(out *TrafficRollbackSpec) {
+	*out = *in
+	in.ErrorRate.DeepCopyInto(&out.ErrorRate)
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRollbackSpec.
+func (in *TrafficRollbackSpec) DeepCopy() *TrafficRollbackSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficRollbackSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficRollbackStatus) DeepCopyInto(out *TrafficRollbackStatus) {
+	*out = *in
+	if in.KnownGoodWeights != nil {
+		in, out := &in.KnownGoodWeights, &out.KnownGoodWeights
+		*out = make(map[string]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Since != nil {
+		in, out := &in.Since, &out.Since
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRollbackTime != nil {
+		in, out := &in.LastRollbackTime, &out.LastRollbackTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficRollbackStatus.
+func (in *TrafficRollbackStatus) DeepCopy() *TrafficRollbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficRollbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficSnapshot) DeepCopyInto(out *TrafficSnapshot) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make([]StackTrafficWeight, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficSnapshot.
+func (in *TrafficSnapshot) DeepCopy() *TrafficSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZMONAnalysis) DeepCopyInto(out *ZMONAnalysis) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZMONAnalysis.
+func (in *ZMONAnalysis) DeepCopy() *ZMONAnalysis {
+	if in == nil {
+		return nil
+	}
+	out := new(ZMONAnalysis)
+	in.DeepCopyInto(out)
+	return out
+}
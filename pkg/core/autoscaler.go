@@ -2,22 +2,29 @@ package core
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
+	"time"
 
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
 	autoscaling "k8s.io/api/autoscaling/v2beta1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
 	amazonSQSMetricName   = "AmazonSQS"
+	rabbitMQMetricName    = "RabbitMQ"
+	kafkaMetricName       = "Kafka"
 	podJSONMetricName     = "PodJSON"
 	ingressMetricName     = "Ingress"
+	objectMetricName      = "Object"
 	cpuMetricName         = "CPU"
 	memoryMetricName      = "Memory"
 	zmonMetricName        = "ZMON"
+	routeGroupKind        = "RouteGroup"
 	requestsPerSecondName = "requests-per-second"
 	metricConfigJSONPath  = "metric-config.pods.%s.json-path/path"
 	metricConfigJSONKey   = "metric-config.pods.%s.json-path/json-key"
@@ -25,6 +32,19 @@ const (
 	sqsQueueLengthTag     = "sqs-queue-length"
 	sqsQueueNameTag       = "queue-name"
 	sqsQueueRegionTag     = "region"
+	rabbitMQQueueLength   = "rabbitmq-queue-length"
+	rabbitMQQueueNameTag  = "queue-name"
+	kafkaConsumerLag      = "kafka-consumer-lag"
+	kafkaTopicTag         = "topic"
+	kafkaConsumerGroupTag = "consumer-group"
+	metricConfigBroker    = "metric-config.external.%s.%s/broker"
+	minUtilization        = 1
+
+	// aggregatedAutoscalingTolerance is the relative difference between the
+	// required and the current number of replicas below which the aggregated
+	// autoscaler doesn't scale, like the default tolerance of the HPA
+	// controller. It can be overridden per StackSet.
+	aggregatedAutoscalingTolerance = 0.1
 )
 
 type MetricsList []autoscaling.MetricSpec
@@ -43,11 +63,152 @@ func (l MetricsList) Less(i, j int) bool {
 	return l[i].Type < l[j].Type
 }
 
+// validateAutoscalerReplicas checks that the replica bounds of an autoscaler
+// can be used for an HPA.
+func validateAutoscalerReplicas(autoscaler *zv1.Autoscaler) error {
+	if autoscaler.MaxReplicas < 1 {
+		return fmt.Errorf("maxReplicas must be at least 1")
+	}
+	if autoscaler.MinReplicas != nil {
+		if *autoscaler.MinReplicas < 1 {
+			return fmt.Errorf("minReplicas must be at least 1")
+		}
+		if *autoscaler.MinReplicas > autoscaler.MaxReplicas {
+			return fmt.Errorf("minReplicas (%d) must not be greater than maxReplicas (%d)", *autoscaler.MinReplicas, autoscaler.MaxReplicas)
+		}
+	}
+	return nil
+}
+
+// validateAutoscaler checks that the autoscaler definition of the stack can be
+// converted into a valid HPA.
+func (sc *StackContainer) validateAutoscaler() error {
+	autoscaler := sc.Stack.Spec.Autoscaler
+	if autoscaler == nil {
+		return nil
+	}
+
+	err := validateAutoscalerReplicas(autoscaler)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := sc.autoscalerMetrics()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = convertCustomMetrics(sc.stacksetName, sc.Name(), metrics)
+	if metricErr, ok := err.(*MetricError); ok {
+		metricErr.Profile = sc.autoscalerProfile
+	}
+	return err
+}
+
+// ValidateAutoscaler checks that an autoscaler can be converted into a valid
+// HPA with its own metrics. It's used to reject invalid autoscalers before
+// they're stored, the metrics of autoscaler profiles are only known to the
+// controller.
+func ValidateAutoscaler(autoscaler *zv1.Autoscaler) error {
+	if autoscaler == nil {
+		return nil
+	}
+
+	err := validateAutoscalerReplicas(autoscaler)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = convertCustomMetrics("", "", autoscaler.Metrics)
+	return err
+}
+
+// autoscalerMetrics returns the metrics of the active autoscaler profile, or
+// the metrics defined in the autoscaler of the stack if no profile is active.
+func (sc *StackContainer) autoscalerMetrics() ([]zv1.AutoscalerMetrics, error) {
+	if sc.autoscalerProfile == "" {
+		return sc.Stack.Spec.Autoscaler.Metrics, nil
+	}
+
+	for _, profile := range sc.autoscalerProfiles {
+		if profile.Name == sc.autoscalerProfile {
+			return profile.Metrics, nil
+		}
+	}
+	return nil, fmt.Errorf("autoscaler profile %s not found", sc.autoscalerProfile)
+}
+
+// MetricError is returned for an autoscaler metric which can't be converted
+// into a metric of the HPA. It names the offending entry of the metrics and
+// its field, so that users can map it to their spec.
+type MetricError struct {
+	// Profile is the autoscaler profile defining the metrics, if any.
+	Profile string
+	// Index is the position of the metric in the metrics.
+	Index int
+	// Type is the type of the metric.
+	Type string
+	// Field is the path of the invalid field of the metric, e.g.
+	// averageUtilization or object.metricName.
+	Field string
+	Err   error
+}
+
+func (e *MetricError) Error() string {
+	path := fmt.Sprintf("metrics[%d]", e.Index)
+	if e.Field != "" {
+		path += "." + e.Field
+	}
+	if e.Profile != "" {
+		path = fmt.Sprintf("autoscaler profile %s %s", e.Profile, path)
+	}
+	return fmt.Sprintf("invalid metric %s (%s): %v", e.Type, path, e.Err)
+}
+
+// metricFieldError is an error of a single field of an autoscaler metric.
+type metricFieldError struct {
+	field string
+	err   error
+}
+
+func (e *metricFieldError) Error() string {
+	return e.err.Error()
+}
+
+func fieldErrorf(field, format string, args ...interface{}) error {
+	return &metricFieldError{field: field, err: fmt.Errorf(format, args...)}
+}
+
+// validateAverage checks that an average target value is specified and
+// greater than zero.
+func validateAverage(average *resource.Quantity) error {
+	if average == nil {
+		return fieldErrorf("average", "average is not specified")
+	}
+	if average.Sign() <= 0 {
+		return fieldErrorf("average", "average must be greater than zero")
+	}
+	return nil
+}
+
+// validateUtilization checks that an average utilization is specified and
+// positive. The utilization is relative to the resource requests, so targets
+// above 100% are valid for containers using more than they request.
+func validateUtilization(utilization *int32) error {
+	if utilization == nil {
+		return fieldErrorf("averageUtilization", "utilization is not specified")
+	}
+	if *utilization < minUtilization {
+		return fieldErrorf("averageUtilization", "utilization must be at least %d", minUtilization)
+	}
+	return nil
+}
+
 func convertCustomMetrics(stacksetName, stackName string, metrics []zv1.AutoscalerMetrics) ([]autoscaling.MetricSpec, map[string]string, error) {
 	var resultMetrics MetricsList
 	resultAnnotations := make(map[string]string)
 
-	for _, m := range metrics {
+	for i, m := range metrics {
 		var (
 			generated   *autoscaling.MetricSpec
 			annotations map[string]string
@@ -56,22 +217,35 @@ func convertCustomMetrics(stacksetName, stackName string, metrics []zv1.Autoscal
 		switch m.Type {
 		case amazonSQSMetricName:
 			generated, err = sqsMetric(m)
+		case rabbitMQMetricName:
+			generated, annotations, err = rabbitMQMetric(m)
+		case kafkaMetricName:
+			generated, annotations, err = kafkaMetric(m)
 		case podJSONMetricName:
 			generated, annotations, err = podJsonMetric(m)
 		case ingressMetricName:
 			generated, err = ingressMetric(m, stacksetName, stackName)
+		case objectMetricName:
+			generated, err = objectMetric(m, stacksetName)
 		case zmonMetricName:
-			err = fmt.Errorf("not implemented metric type: %s", zmonMetricName)
+			err = fieldErrorf("type", "metric type not implemented")
 		case cpuMetricName:
 			generated, err = cpuMetric(m)
 		case memoryMetricName:
 			generated, err = memoryMetric(m)
 		default:
-			err = fmt.Errorf("metric type %s not supported", m.Type)
+			err = fieldErrorf("type", "metric type not supported")
 		}
 
+		if err == nil && m.Role != "" && m.Role != zv1.AutoscalerMetricRolePrimary && m.Role != zv1.AutoscalerMetricRoleAdvisory {
+			err = fieldErrorf("role", "role %s not supported", m.Role)
+		}
 		if err != nil {
-			return nil, nil, err
+			metricErr := &MetricError{Index: i, Type: m.Type, Err: err}
+			if fieldErr, ok := err.(*metricFieldError); ok {
+				metricErr.Field = fieldErr.field
+			}
+			return nil, nil, metricErr
 		}
 		resultMetrics = append(resultMetrics, *generated)
 		for k, v := range annotations {
@@ -79,13 +253,28 @@ func convertCustomMetrics(stacksetName, stackName string, metrics []zv1.Autoscal
 		}
 	}
 
+	if len(metrics) > 0 && len(primaryMetrics(metrics)) == 0 {
+		return nil, nil, fmt.Errorf("at least one metric must be primary")
+	}
+
 	sort.Sort(resultMetrics)
 	return resultMetrics, resultAnnotations, nil
 }
 
+// primaryMetrics returns the metrics that aren't marked as advisory.
+func primaryMetrics(metrics []zv1.AutoscalerMetrics) []zv1.AutoscalerMetrics {
+	var result []zv1.AutoscalerMetrics
+	for _, m := range metrics {
+		if m.Role != zv1.AutoscalerMetricRoleAdvisory {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
 func memoryMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error) {
-	if metrics.AverageUtilization == nil {
-		return nil, fmt.Errorf("utilization is not specified")
+	if err := validateUtilization(metrics.AverageUtilization); err != nil {
+		return nil, err
 	}
 	generated := &autoscaling.MetricSpec{
 		Type: autoscaling.ResourceMetricSourceType,
@@ -98,8 +287,8 @@ func memoryMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error
 }
 
 func cpuMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error) {
-	if metrics.AverageUtilization == nil {
-		return nil, fmt.Errorf("utilization is not specified")
+	if err := validateUtilization(metrics.AverageUtilization); err != nil {
+		return nil, err
 	}
 	generated := &autoscaling.MetricSpec{
 		Type: autoscaling.ResourceMetricSourceType,
@@ -112,11 +301,11 @@ func cpuMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error) {
 }
 
 func sqsMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error) {
-	if metrics.Average == nil {
-		return nil, fmt.Errorf("average not specified")
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, err
 	}
 	if metrics.Queue == nil || metrics.Queue.Name == "" || metrics.Queue.Region == "" {
-		return nil, fmt.Errorf("queue not specified correctly")
+		return nil, fieldErrorf("queue", "queue not specified correctly")
 	}
 	average := metrics.Average.DeepCopy()
 	generated := &autoscaling.MetricSpec{
@@ -132,9 +321,60 @@ func sqsMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, error) {
 	return generated, nil
 }
 
+func rabbitMQMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, map[string]string, error) {
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, nil, err
+	}
+	if metrics.Queue == nil || metrics.Queue.Name == "" || metrics.Queue.Broker == "" {
+		return nil, nil, fieldErrorf("queue", "queue not specified correctly")
+	}
+	average := metrics.Average.DeepCopy()
+	generated := &autoscaling.MetricSpec{
+		Type: autoscaling.ExternalMetricSourceType,
+		External: &autoscaling.ExternalMetricSource{
+			MetricName: rabbitMQQueueLength,
+			MetricSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{rabbitMQQueueNameTag: metrics.Queue.Name},
+			},
+			TargetAverageValue: &average,
+		},
+	}
+	annotations := map[string]string{
+		fmt.Sprintf(metricConfigBroker, rabbitMQQueueLength, "rabbitmq"): metrics.Queue.Broker,
+	}
+	return generated, annotations, nil
+}
+
+func kafkaMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, map[string]string, error) {
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, nil, err
+	}
+	if metrics.Queue == nil || metrics.Queue.Name == "" || metrics.Queue.Broker == "" || metrics.Queue.ConsumerGroup == "" {
+		return nil, nil, fieldErrorf("queue", "topic not specified correctly")
+	}
+	average := metrics.Average.DeepCopy()
+	generated := &autoscaling.MetricSpec{
+		Type: autoscaling.ExternalMetricSourceType,
+		External: &autoscaling.ExternalMetricSource{
+			MetricName: kafkaConsumerLag,
+			MetricSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{kafkaTopicTag: metrics.Queue.Name, kafkaConsumerGroupTag: metrics.Queue.ConsumerGroup},
+			},
+			TargetAverageValue: &average,
+		},
+	}
+	annotations := map[string]string{
+		fmt.Sprintf(metricConfigBroker, kafkaConsumerLag, "kafka"): metrics.Queue.Broker,
+	}
+	return generated, annotations, nil
+}
+
 func podJsonMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, map[string]string, error) {
-	if metrics.Average == nil {
-		return nil, nil, fmt.Errorf("average is not specified for metric")
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, nil, err
+	}
+	if metrics.Endpoint == nil || metrics.Endpoint.Port == 0 || metrics.Endpoint.Path == "" || metrics.Endpoint.Key == "" || metrics.Endpoint.Name == "" {
+		return nil, nil, fieldErrorf("endpoint", "the metrics endpoint is not specified correctly")
 	}
 	generated := &autoscaling.MetricSpec{
 		Type: autoscaling.PodsMetricSourceType,
@@ -143,9 +383,6 @@ func podJsonMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, map[
 			TargetAverageValue: metrics.Average.DeepCopy(),
 		},
 	}
-	if metrics.Endpoint == nil || metrics.Endpoint.Port == 0 || metrics.Endpoint.Path == "" || metrics.Endpoint.Key == "" || metrics.Endpoint.Name == "" {
-		return nil, nil, fmt.Errorf("the metrics endpoint is not specified correctly")
-	}
 	annotations := map[string]string{
 		fmt.Sprintf(metricConfigJSONKey, metrics.Endpoint.Name):  metrics.Endpoint.Key,
 		fmt.Sprintf(metricConfigJSONPath, metrics.Endpoint.Name): metrics.Endpoint.Path,
@@ -155,8 +392,8 @@ func podJsonMetric(metrics zv1.AutoscalerMetrics) (*autoscaling.MetricSpec, map[
 }
 
 func ingressMetric(metrics zv1.AutoscalerMetrics, ingressName, backendName string) (*autoscaling.MetricSpec, error) {
-	if metrics.Average == nil {
-		return nil, fmt.Errorf("average value not specified for metric")
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, err
 	}
 	generated := &autoscaling.MetricSpec{
 		Type: autoscaling.ObjectMetricSourceType,
@@ -172,3 +409,323 @@ func ingressMetric(metrics zv1.AutoscalerMetrics, ingressName, backendName strin
 	}
 	return generated, nil
 }
+
+// objectMetric generates a metric of the central Ingress or RouteGroup of the
+// StackSet, which exists independently of the per stack ingresses.
+func objectMetric(metrics zv1.AutoscalerMetrics, stacksetName string) (*autoscaling.MetricSpec, error) {
+	if err := validateAverage(metrics.Average); err != nil {
+		return nil, err
+	}
+	if metrics.Object == nil || metrics.Object.MetricName == "" {
+		return nil, fieldErrorf("object.metricName", "the metric name is not specified")
+	}
+
+	target := autoscaling.CrossVersionObjectReference{
+		APIVersion: "extensions/v1beta1",
+		Kind:       "Ingress",
+		Name:       stacksetName,
+	}
+	switch metrics.Object.Kind {
+	case "", target.Kind:
+	case routeGroupKind:
+		target.APIVersion = APIVersion
+		target.Kind = routeGroupKind
+	default:
+		return nil, fieldErrorf("object.kind", "object kind %s not supported", metrics.Object.Kind)
+	}
+
+	generated := &autoscaling.MetricSpec{
+		Type: autoscaling.ObjectMetricSourceType,
+		Object: &autoscaling.ObjectMetricSource{
+			MetricName:  metrics.Object.MetricName,
+			Target:      target,
+			TargetValue: metrics.Average.DeepCopy(),
+		},
+	}
+	return generated, nil
+}
+
+// DistributeAutoscaling treats the autoscalers of all stacks getting traffic
+// as a single logical autoscaler if aggregated autoscaling is enabled for the
+// StackSet, otherwise every stack is autoscaled on its own. The total number
+// of replicas is computed from the metrics reported by the HPAs of the stacks
+// and distributed between them proportionally to their actual traffic weight.
+func (ssc *StackSetContainer) DistributeAutoscaling() {
+	var (
+		stacks      []*StackContainer
+		totalWeight float64
+	)
+	for _, sc := range ssc.StackContainers {
+		sc.aggregatedReplicas = 0
+		if sc.actualTrafficWeight > 0 && sc.IsAutoscaled() {
+			stacks = append(stacks, sc)
+			totalWeight += sc.actualTrafficWeight
+		}
+	}
+
+	if !ssc.AggregateAutoscaling || totalWeight == 0 {
+		return
+	}
+
+	tolerance := aggregatedAutoscalingTolerance
+	if ssc.HPATolerance > 0 {
+		tolerance = ssc.HPATolerance
+	}
+
+	distributeReplicas(stacks, aggregatedReplicas(stacks, tolerance), totalWeight)
+}
+
+// distributeReplicas splits the total number of replicas between the stacks
+// proportionally to their actual traffic weight. Every stack gets one replica
+// first, the rest of the total is rounded down to the shares of the stacks
+// and the remaining replicas go to the stacks with the largest remainders,
+// so that the shares add up to the total.
+func distributeReplicas(stacks []*StackContainer, total int32, totalWeight float64) {
+	remaining := total - int32(len(stacks))
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	leftover := remaining
+	remainders := make(map[*StackContainer]float64, len(stacks))
+	for _, sc := range stacks {
+		share := float64(remaining) * sc.actualTrafficWeight / totalWeight
+		replicas := int32(math.Floor(share))
+		sc.aggregatedReplicas = 1 + replicas
+		remainders[sc] = share - float64(replicas)
+		leftover -= replicas
+	}
+
+	sort.Slice(stacks, func(i, j int) bool {
+		if remainders[stacks[i]] != remainders[stacks[j]] {
+			return remainders[stacks[i]] > remainders[stacks[j]]
+		}
+		return stacks[i].Name() < stacks[j].Name()
+	})
+	for i := 0; i < int(leftover) && i < len(stacks); i++ {
+		stacks[i].aggregatedReplicas++
+	}
+}
+
+// aggregatedReplicas returns the total number of replicas required by the
+// stacks. Like the HPA controller, the current number of replicas is kept if
+// the required number doesn't differ by more than the tolerance. The result
+// is limited to the largest autoscaler bounds of the stacks.
+func aggregatedReplicas(stacks []*StackContainer, tolerance float64) int32 {
+	var (
+		demand, current          float64
+		minReplicas, maxReplicas int32
+	)
+	for _, sc := range stacks {
+		demand += sc.autoscalerDemand()
+		current += float64(sc.deploymentReplicas)
+		if sc.minReplicas() > minReplicas {
+			minReplicas = sc.minReplicas()
+		}
+		if sc.MaxReplicas() > maxReplicas {
+			maxReplicas = sc.MaxReplicas()
+		}
+	}
+
+	total := int32(math.Ceil(demand))
+	if current > 0 && math.Abs(demand/current-1) <= tolerance {
+		total = int32(current)
+	}
+	if total < minReplicas {
+		total = minReplicas
+	}
+	if total > maxReplicas {
+		total = maxReplicas
+	}
+	return total
+}
+
+// autoscalerDemand returns the number of replicas required by the current
+// metrics of the HPA of the stack, before rounding and applying the bounds.
+// As the HPA controller, it uses the metric requiring the most replicas. The
+// metrics are applied to the replicas of the deployment, which the tolerance
+// of the aggregated autoscaler is compared with as well, rather than to the
+// replicas last seen by the HPA. The current number of replicas is returned
+// if the HPA didn't report any metrics yet.
+func (sc *StackContainer) autoscalerDemand() float64 {
+	hpa := sc.Resources.HPA
+	if hpa == nil || sc.deploymentReplicas == 0 || len(hpa.Status.CurrentMetrics) != len(hpa.Spec.Metrics) {
+		return float64(sc.deploymentReplicas)
+	}
+
+	demand := -1.0
+	for i, metric := range hpa.Spec.Metrics {
+		ratio, ok := metricUsageRatio(metric, hpa.Status.CurrentMetrics[i])
+		if ok && ratio*float64(sc.deploymentReplicas) > demand {
+			demand = ratio * float64(sc.deploymentReplicas)
+		}
+	}
+	if demand < 0 {
+		return float64(sc.deploymentReplicas)
+	}
+	return demand
+}
+
+// metricUsageRatio returns the ratio of the current value of an HPA metric to
+// its target value, which is the factor the HPA controller scales the current
+// number of replicas with.
+func metricUsageRatio(metric autoscaling.MetricSpec, status autoscaling.MetricStatus) (float64, bool) {
+	if metric.Type != status.Type {
+		return 0, false
+	}
+
+	switch metric.Type {
+	case autoscaling.ResourceMetricSourceType:
+		if metric.Resource == nil || status.Resource == nil {
+			return 0, false
+		}
+		if metric.Resource.TargetAverageUtilization != nil {
+			if status.Resource.CurrentAverageUtilization == nil || *metric.Resource.TargetAverageUtilization <= 0 {
+				return 0, false
+			}
+			return float64(*status.Resource.CurrentAverageUtilization) / float64(*metric.Resource.TargetAverageUtilization), true
+		}
+		if metric.Resource.TargetAverageValue != nil {
+			return quantityRatio(status.Resource.CurrentAverageValue, *metric.Resource.TargetAverageValue)
+		}
+	case autoscaling.PodsMetricSourceType:
+		if metric.Pods != nil && status.Pods != nil {
+			return quantityRatio(status.Pods.CurrentAverageValue, metric.Pods.TargetAverageValue)
+		}
+	case autoscaling.ObjectMetricSourceType:
+		if metric.Object != nil && status.Object != nil {
+			return quantityRatio(status.Object.CurrentValue, metric.Object.TargetValue)
+		}
+	case autoscaling.ExternalMetricSourceType:
+		if metric.External == nil || status.External == nil {
+			return 0, false
+		}
+		if metric.External.TargetAverageValue != nil && status.External.CurrentAverageValue != nil {
+			return quantityRatio(*status.External.CurrentAverageValue, *metric.External.TargetAverageValue)
+		}
+		if metric.External.TargetValue != nil {
+			return quantityRatio(status.External.CurrentValue, *metric.External.TargetValue)
+		}
+	}
+	return 0, false
+}
+
+// quantityRatio returns the ratio of the current to the target quantity.
+func quantityRatio(current, target resource.Quantity) (float64, bool) {
+	if target.MilliValue() <= 0 {
+		return 0, false
+	}
+	return float64(current.MilliValue()) / float64(target.MilliValue()), true
+}
+
+// ApplyTrafficForecast distributes the number of replicas required by the
+// traffic forecast between the stacks getting traffic proportionally to their
+// actual traffic weight.
+func (ssc *StackSetContainer) ApplyTrafficForecast(minReplicas int32) {
+	totalWeight := 0.0
+	for _, sc := range ssc.StackContainers {
+		sc.forecastMinReplicas = 0
+		totalWeight += sc.actualTrafficWeight
+	}
+
+	if minReplicas == 0 || totalWeight == 0 {
+		return
+	}
+
+	for _, sc := range ssc.StackContainers {
+		if sc.actualTrafficWeight > 0 && sc.IsAutoscaled() {
+			sc.forecastMinReplicas = int32(math.Ceil(float64(minReplicas) * sc.actualTrafficWeight / totalWeight))
+		}
+	}
+}
+
+// FreezeAutoscalers pins the HPAs of all stacks to their current number of
+// replicas while the desired and actual traffic weights diverge. The HPAs are
+// released once the traffic switch is done or after the freeze window
+// expired, whichever happens first. Independent of the freeze window, the
+// stacks are marked as switching traffic so that advisory metrics are left
+// out of their HPAs.
+func (ssc *StackSetContainer) FreezeAutoscalers(currentTimestamp time.Time) {
+	switching := false
+	for _, sc := range ssc.StackContainers {
+		if sc.desiredTrafficWeight != sc.actualTrafficWeight {
+			switching = true
+			break
+		}
+	}
+
+	for _, sc := range ssc.StackContainers {
+		sc.trafficSwitching = switching
+		sc.autoscalerFrozen = false
+
+		if ssc.AutoscalerFreezeWindow == 0 || !switching || !sc.IsAutoscaled() {
+			sc.autoscalerFrozenSince = time.Time{}
+			continue
+		}
+
+		if sc.autoscalerFrozenSince.IsZero() {
+			sc.autoscalerFrozenSince = currentTimestamp
+		}
+		sc.autoscalerFrozen = currentTimestamp.Sub(sc.autoscalerFrozenSince) < ssc.AutoscalerFreezeWindow
+	}
+}
+
+// HoldAutoscalers implements the tolerance and CPU initialization period
+// overrides of the StackSet, which the HPA controller only supports cluster
+// wide. The HPA of a stack is pinned to its current number of replicas while
+// the metrics are within the overridden tolerance, or while the CPU of the
+// pods started by the last scaling of the HPA is still initializing.
+func (ssc *StackSetContainer) HoldAutoscalers(currentTimestamp time.Time) {
+	for _, sc := range ssc.StackContainers {
+		sc.autoscalerHeld = sc.IsAutoscaled() && sc.autoscalerWithinOverrides(currentTimestamp)
+	}
+}
+
+// autoscalerWithinOverrides returns true if the HPA of the stack must not
+// scale because of the tolerance or CPU initialization period overrides.
+func (sc *StackContainer) autoscalerWithinOverrides(currentTimestamp time.Time) bool {
+	hpa := sc.Resources.HPA
+	if hpa == nil || sc.deploymentReplicas == 0 {
+		return false
+	}
+
+	if sc.hpaCPUInitializationPeriod > 0 && hpa.Status.LastScaleTime != nil && hasCPUMetric(hpa.Spec.Metrics) &&
+		currentTimestamp.Sub(hpa.Status.LastScaleTime.Time) < sc.hpaCPUInitializationPeriod {
+		return true
+	}
+
+	if sc.hpaTolerance > 0 {
+		demand := sc.autoscalerDemand()
+		return math.Abs(demand/float64(sc.deploymentReplicas)-1) <= sc.hpaTolerance
+	}
+	return false
+}
+
+// hasCPUMetric returns true if the HPA scales based on the CPU of the pods.
+func hasCPUMetric(metrics []autoscaling.MetricSpec) bool {
+	for _, metric := range metrics {
+		if metric.Type == autoscaling.ResourceMetricSourceType && metric.Resource != nil && metric.Resource.Name == v1.ResourceCPU {
+			return true
+		}
+	}
+	return false
+}
+
+// requestsPerSecondPerPod returns the number of requests per second a single
+// pod of the stack is able to handle as defined by the Ingress metric of the
+// autoscaler. Returns 0 if unknown.
+func (sc *StackContainer) requestsPerSecondPerPod() float64 {
+	if sc.Stack.Spec.Autoscaler == nil {
+		return 0
+	}
+	metrics, err := sc.autoscalerMetrics()
+	if err != nil {
+		return 0
+	}
+	for _, m := range metrics {
+		if m.Type == ingressMetricName && m.Average != nil {
+			return float64(m.Average.MilliValue()) / 1000
+		}
+	}
+	return 0
+}
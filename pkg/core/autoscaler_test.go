@@ -2,7 +2,9 @@ package core
 
 import (
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
@@ -10,6 +12,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func generateAutoscalerStub(minReplicas, maxReplicas int32) StackContainer {
@@ -135,6 +138,42 @@ func TestStackSetController_ReconcileAutoscalersSQS(t *testing.T) {
 	require.Equal(t, externalMetric.External.TargetAverageValue.Value(), int64(80))
 }
 
+func TestStackSetController_ReconcileAutoscalersRabbitMQ(t *testing.T) {
+	ssc := generateAutoscalerStub(1, 10)
+	ssc.Stack.Spec.Autoscaler.Metrics = []zv1.AutoscalerMetrics{{
+		Type:    rabbitMQMetricName,
+		Queue:   &zv1.MetricsQueue{Name: "test-queue", Broker: "amqp://rabbitmq:5672"},
+		Average: resource.NewQuantity(80, resource.DecimalSI),
+	}}
+	hpa, err := ssc.GenerateHPA()
+	require.NoError(t, err, "failed to create an HPA")
+	require.Len(t, hpa.Spec.Metrics, 1, "expected HPA to have 1 metric. instead got %d", len(hpa.Spec.Metrics))
+	externalMetric := hpa.Spec.Metrics[0]
+	require.Equal(t, v2beta1.ExternalMetricSourceType, externalMetric.Type)
+	require.Equal(t, "rabbitmq-queue-length", externalMetric.External.MetricName)
+	require.Equal(t, map[string]string{"queue-name": "test-queue"}, externalMetric.External.MetricSelector.MatchLabels)
+	require.Equal(t, int64(80), externalMetric.External.TargetAverageValue.Value())
+	require.Equal(t, "amqp://rabbitmq:5672", hpa.Annotations["metric-config.external.rabbitmq-queue-length.rabbitmq/broker"])
+}
+
+func TestStackSetController_ReconcileAutoscalersKafka(t *testing.T) {
+	ssc := generateAutoscalerStub(1, 10)
+	ssc.Stack.Spec.Autoscaler.Metrics = []zv1.AutoscalerMetrics{{
+		Type:    kafkaMetricName,
+		Queue:   &zv1.MetricsQueue{Name: "test-topic", Broker: "kafka:9092", ConsumerGroup: "test-group"},
+		Average: resource.NewQuantity(100, resource.DecimalSI),
+	}}
+	hpa, err := ssc.GenerateHPA()
+	require.NoError(t, err, "failed to create an HPA")
+	require.Len(t, hpa.Spec.Metrics, 1, "expected HPA to have 1 metric. instead got %d", len(hpa.Spec.Metrics))
+	externalMetric := hpa.Spec.Metrics[0]
+	require.Equal(t, v2beta1.ExternalMetricSourceType, externalMetric.Type)
+	require.Equal(t, "kafka-consumer-lag", externalMetric.External.MetricName)
+	require.Equal(t, map[string]string{"topic": "test-topic", "consumer-group": "test-group"}, externalMetric.External.MetricSelector.MatchLabels)
+	require.Equal(t, int64(100), externalMetric.External.TargetAverageValue.Value())
+	require.Equal(t, "kafka:9092", hpa.Annotations["metric-config.external.kafka-consumer-lag.kafka/broker"])
+}
+
 func TestStackSetController_ReconcileAutoscalersPodJson(t *testing.T) {
 	ssc := generateAutoscalerPodJson(1, 10, 80, 8080, "current-load", "/metrics", "$.current-load.counter")
 	hpa, err := ssc.GenerateHPA()
@@ -229,6 +268,73 @@ func TestIngressMetricInvalid(t *testing.T) {
 	require.Errorf(t, err, "created metric with invalid configuration")
 }
 
+func TestObjectMetric(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		object         *zv1.MetricsObject
+		average        *resource.Quantity
+		expectedTarget v2beta1.CrossVersionObjectReference
+		expectedErr    string
+	}{
+		{
+			name:    "defaults to the stackset ingress",
+			object:  &zv1.MetricsObject{MetricName: "requests-per-second"},
+			average: resource.NewQuantity(80, resource.DecimalSI),
+			expectedTarget: v2beta1.CrossVersionObjectReference{
+				APIVersion: "extensions/v1beta1",
+				Kind:       "Ingress",
+				Name:       "stackset",
+			},
+		},
+		{
+			name:    "stackset routegroup",
+			object:  &zv1.MetricsObject{MetricName: "requests-per-second", Kind: "RouteGroup"},
+			average: resource.NewQuantity(80, resource.DecimalSI),
+			expectedTarget: v2beta1.CrossVersionObjectReference{
+				APIVersion: "zalando.org/v1",
+				Kind:       "RouteGroup",
+				Name:       "stackset",
+			},
+		},
+		{
+			name:        "unsupported kind",
+			object:      &zv1.MetricsObject{MetricName: "requests-per-second", Kind: "Service"},
+			average:     resource.NewQuantity(80, resource.DecimalSI),
+			expectedErr: "object kind Service not supported",
+		},
+		{
+			name:        "missing metric name",
+			object:      &zv1.MetricsObject{},
+			average:     resource.NewQuantity(80, resource.DecimalSI),
+			expectedErr: "the metric name is not specified",
+		},
+		{
+			name:        "missing object",
+			average:     resource.NewQuantity(80, resource.DecimalSI),
+			expectedErr: "the metric name is not specified",
+		},
+		{
+			name:        "missing average",
+			object:      &zv1.MetricsObject{MetricName: "requests-per-second"},
+			expectedErr: "average is not specified",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			metrics := zv1.AutoscalerMetrics{Type: objectMetricName, Object: tc.object, Average: tc.average}
+			metric, err := objectMetric(metrics, "stackset")
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, v2beta1.ObjectMetricSourceType, metric.Type)
+			require.Equal(t, tc.object.MetricName, metric.Object.MetricName)
+			require.Equal(t, tc.expectedTarget, metric.Object.Target)
+			require.Equal(t, int64(80), metric.Object.TargetValue.Value())
+		})
+	}
+}
+
 func TestSortingMetrics(t *testing.T) {
 	container := generateAutoscalerStub(1, 10)
 	metrics := []zv1.AutoscalerMetrics{
@@ -248,6 +354,681 @@ func TestSortingMetrics(t *testing.T) {
 	require.EqualValues(t, v2beta1.ResourceMetricSourceType, hpa.Spec.Metrics[3].Type)
 }
 
+func cpuAutoscaledStack(name string, weight float64, replicas, utilization int32) *StackContainer {
+	sc := testStack(name).traffic(weight, weight).ready(replicas).maxReplicas(20).stack()
+	sc.Resources.HPA = &v2beta1.HorizontalPodAutoscaler{
+		Spec: v2beta1.HorizontalPodAutoscalerSpec{
+			Metrics: []v2beta1.MetricSpec{
+				{
+					Type: v2beta1.ResourceMetricSourceType,
+					Resource: &v2beta1.ResourceMetricSource{
+						Name:                     corev1.ResourceCPU,
+						TargetAverageUtilization: pint32(50),
+					},
+				},
+			},
+		},
+		Status: v2beta1.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: replicas,
+			CurrentMetrics: []v2beta1.MetricStatus{
+				{
+					Type: v2beta1.ResourceMetricSourceType,
+					Resource: &v2beta1.ResourceMetricStatus{
+						Name:                      corev1.ResourceCPU,
+						CurrentAverageUtilization: &utilization,
+					},
+				},
+			},
+		},
+	}
+	return sc
+}
+
+func TestDistributeAutoscaling(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		aggregate        bool
+		tolerance        float64
+		stacks           map[types.UID]*StackContainer
+		expectedReplicas map[string]int32
+	}{
+		{
+			name:      "stacks are autoscaled on their own by default",
+			aggregate: false,
+			stacks: map[types.UID]*StackContainer{
+				"v1": cpuAutoscaledStack("foo-v1", 50, 4, 100),
+				"v2": cpuAutoscaledStack("foo-v2", 50, 4, 100),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 0, "foo-v2": 0},
+		},
+		{
+			name:      "total replicas are distributed according to the actual traffic",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": cpuAutoscaledStack("foo-v1", 75, 4, 100),
+				"v2": cpuAutoscaledStack("foo-v2", 25, 4, 50),
+				"v3": cpuAutoscaledStack("foo-v3", 0, 1, 100),
+			},
+			// 4 * 100/50 + 4 * 50/50 = 12 replicas in total
+			expectedReplicas: map[string]int32{"foo-v1": 9, "foo-v2": 3, "foo-v3": 0},
+		},
+		{
+			name:      "total replicas are limited to the autoscaler bounds",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": cpuAutoscaledStack("foo-v1", 50, 10, 100),
+				"v2": cpuAutoscaledStack("foo-v2", 50, 10, 100),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 10, "foo-v2": 10},
+		},
+		{
+			name:      "current replicas are kept within the tolerance",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": cpuAutoscaledStack("foo-v1", 50, 5, 52),
+				"v2": cpuAutoscaledStack("foo-v2", 50, 5, 52),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 5, "foo-v2": 5},
+		},
+		{
+			name:      "current replicas are kept within the overridden tolerance",
+			aggregate: true,
+			tolerance: 0.3,
+			stacks: map[types.UID]*StackContainer{
+				"v1": cpuAutoscaledStack("foo-v1", 50, 5, 60),
+				"v2": cpuAutoscaledStack("foo-v2", 50, 5, 60),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 5, "foo-v2": 5},
+		},
+		{
+			name:      "current replicas are used without metrics",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 50).ready(3).maxReplicas(20).stack(),
+				"v2": testStack("foo-v2").traffic(50, 50).ready(5).maxReplicas(20).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 4, "foo-v2": 4},
+		},
+		{
+			name:      "shares add up to the total replicas",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 50).ready(2).maxReplicas(20).stack(),
+				"v2": testStack("foo-v2").traffic(50, 50).ready(3).maxReplicas(20).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 3, "foo-v2": 2},
+		},
+		{
+			name:      "every stack gets one replica before the total is distributed",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(95, 95).ready(2).maxReplicas(20).stack(),
+				"v2": testStack("foo-v2").traffic(5, 5).ready(2).maxReplicas(20).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 3, "foo-v2": 1},
+		},
+		{
+			name:      "metrics apply to the replicas of the deployment",
+			aggregate: true,
+			stacks: map[types.UID]*StackContainer{
+				"v1": func() *StackContainer {
+					sc := cpuAutoscaledStack("foo-v1", 50, 6, 100)
+					sc.Resources.HPA.Status.CurrentReplicas = 3
+					return sc
+				}(),
+				"v2": cpuAutoscaledStack("foo-v2", 50, 6, 50),
+			},
+			// 6 * 100/50 + 6 * 50/50 = 18 replicas in total
+			expectedReplicas: map[string]int32{"foo-v1": 9, "foo-v2": 9},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &StackSetContainer{
+				StackContainers:      tc.stacks,
+				AggregateAutoscaling: tc.aggregate,
+				HPATolerance:         tc.tolerance,
+			}
+			c.DistributeAutoscaling()
+			for _, sc := range c.StackContainers {
+				require.Equal(t, tc.expectedReplicas[sc.Name()], sc.aggregatedReplicas, "stack %s", sc.Name())
+			}
+		})
+	}
+}
+
+func TestGenerateHPAAggregated(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		aggregatedReplicas int32
+		minReplicas        int32
+		maxReplicas        int32
+		expectedMin        int32
+		expectedMax        int32
+	}{
+		{
+			name:               "full bounds if not aggregated",
+			aggregatedReplicas: 0,
+			minReplicas:        3,
+			maxReplicas:        10,
+			expectedMin:        3,
+			expectedMax:        10,
+		},
+		{
+			name:               "pinned to the aggregated replicas",
+			aggregatedReplicas: 2,
+			minReplicas:        4,
+			maxReplicas:        10,
+			expectedMin:        2,
+			expectedMax:        2,
+		},
+		{
+			name:               "aggregated replicas are limited to the max replicas",
+			aggregatedReplicas: 15,
+			minReplicas:        3,
+			maxReplicas:        10,
+			expectedMin:        10,
+			expectedMax:        10,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			container := generateAutoscalerCPU(tc.minReplicas, tc.maxReplicas, 80)
+			container.aggregatedReplicas = tc.aggregatedReplicas
+			hpa, err := container.GenerateHPA()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMin, *hpa.Spec.MinReplicas)
+			require.Equal(t, tc.expectedMax, hpa.Spec.MaxReplicas)
+		})
+	}
+}
+
 func pint32(val int) *int32 {
 	return &[]int32{int32(val)}[0]
 }
+
+func TestValidateAutoscaler(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		minReplicas *int32
+		maxReplicas int32
+		metrics     []zv1.AutoscalerMetrics
+		expectedErr string
+	}{
+		{
+			name:        "valid autoscaler",
+			minReplicas: pint32(1),
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: cpuMetricName, AverageUtilization: pint32(50)}},
+		},
+		{
+			name:        "maxReplicas must be set",
+			maxReplicas: 0,
+			expectedErr: "maxReplicas must be at least 1",
+		},
+		{
+			name:        "minReplicas must not exceed maxReplicas",
+			minReplicas: pint32(5),
+			maxReplicas: 3,
+			expectedErr: "minReplicas (5) must not be greater than maxReplicas (3)",
+		},
+		{
+			name:        "unknown metric type",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: "Foo"}},
+			expectedErr: "invalid metric Foo (metrics[0].type): metric type not supported",
+		},
+		{
+			name:        "utilization below the minimum",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: cpuMetricName, AverageUtilization: pint32(0)}},
+			expectedErr: "invalid metric CPU (metrics[0].averageUtilization): utilization must be at least 1",
+		},
+		{
+			name:        "utilization above the requests",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: cpuMetricName, AverageUtilization: pint32(150)}},
+		},
+		{
+			name:        "zero average target",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: ingressMetricName, Average: resource.NewQuantity(0, resource.DecimalSI)}},
+			expectedErr: "invalid metric Ingress (metrics[0].average): average must be greater than zero",
+		},
+		{
+			name:        "queue region missing",
+			maxReplicas: 10,
+			metrics: []zv1.AutoscalerMetrics{{
+				Type:    amazonSQSMetricName,
+				Average: resource.NewQuantity(10, resource.DecimalSI),
+				Queue:   &zv1.MetricsQueue{Name: "test"},
+			}},
+			expectedErr: "invalid metric AmazonSQS (metrics[0].queue): queue not specified correctly",
+		},
+		{
+			name:        "errors name the position of the metric",
+			maxReplicas: 10,
+			metrics: []zv1.AutoscalerMetrics{
+				{Type: cpuMetricName, AverageUtilization: pint32(80)},
+				{Type: amazonSQSMetricName, Average: resource.NewQuantity(10, resource.DecimalSI), Queue: &zv1.MetricsQueue{Name: "test"}},
+			},
+			expectedErr: "invalid metric AmazonSQS (metrics[1].queue): queue not specified correctly",
+		},
+		{
+			name:        "rabbitmq broker missing",
+			maxReplicas: 10,
+			metrics: []zv1.AutoscalerMetrics{{
+				Type:    rabbitMQMetricName,
+				Average: resource.NewQuantity(10, resource.DecimalSI),
+				Queue:   &zv1.MetricsQueue{Name: "test"},
+			}},
+			expectedErr: "invalid metric RabbitMQ (metrics[0].queue): queue not specified correctly",
+		},
+		{
+			name:        "kafka consumer group missing",
+			maxReplicas: 10,
+			metrics: []zv1.AutoscalerMetrics{{
+				Type:    kafkaMetricName,
+				Average: resource.NewQuantity(10, resource.DecimalSI),
+				Queue:   &zv1.MetricsQueue{Name: "test", Broker: "kafka:9092"},
+			}},
+			expectedErr: "invalid metric Kafka (metrics[0].queue): topic not specified correctly",
+		},
+		{
+			name:        "unknown metric role",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: cpuMetricName, AverageUtilization: pint32(80), Role: "optional"}},
+			expectedErr: "invalid metric CPU (metrics[0].role): role optional not supported",
+		},
+		{
+			name:        "only advisory metrics",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: cpuMetricName, AverageUtilization: pint32(80), Role: zv1.AutoscalerMetricRoleAdvisory}},
+			expectedErr: "at least one metric must be primary",
+		},
+		{
+			name:        "pod metric without an endpoint",
+			maxReplicas: 10,
+			metrics:     []zv1.AutoscalerMetrics{{Type: podJSONMetricName, Average: resource.NewQuantity(10, resource.DecimalSI)}},
+			expectedErr: "invalid metric PodJSON (metrics[0].endpoint): the metrics endpoint is not specified correctly",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			container := generateAutoscalerStub(1, tc.maxReplicas)
+			container.Stack.Spec.Autoscaler.MinReplicas = tc.minReplicas
+			container.Stack.Spec.Autoscaler.Metrics = tc.metrics
+
+			err := container.validateAutoscaler()
+			_, generateErr := container.GenerateHPA()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				require.NoError(t, generateErr)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+				require.EqualError(t, generateErr, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestMetricErrorOfProfile(t *testing.T) {
+	err := &MetricError{Profile: "night", Index: 2, Type: cpuMetricName, Field: "averageUtilization", Err: fmt.Errorf("utilization is not specified")}
+	require.EqualError(t, err, "invalid metric CPU (autoscaler profile night metrics[2].averageUtilization): utilization is not specified")
+}
+
+func TestFreezeAutoscalers(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name           string
+		window         time.Duration
+		stacks         map[types.UID]*StackContainer
+		expectedFrozen map[string]bool
+		expectedSince  map[string]time.Time
+		switching      bool
+	}{
+		{
+			name:   "autoscalers are not frozen if disabled",
+			window: 0,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 100).maxReplicas(10).stack(),
+				"v2": testStack("foo-v2").traffic(50, 0).maxReplicas(10).stack(),
+			},
+			expectedFrozen: map[string]bool{},
+			expectedSince:  map[string]time.Time{},
+			switching:      true,
+		},
+		{
+			name:   "autoscalers are frozen while traffic is switched",
+			window: time.Hour,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 100).maxReplicas(10).stack(),
+				"v2": testStack("foo-v2").traffic(50, 0).maxReplicas(10).autoscalerFrozenSince(fiveMinutesAgo).stack(),
+				"v3": testStack("foo-v3").traffic(0, 0).stack(),
+			},
+			expectedFrozen: map[string]bool{"foo-v1": true, "foo-v2": true},
+			expectedSince:  map[string]time.Time{"foo-v1": now, "foo-v2": fiveMinutesAgo},
+			switching:      true,
+		},
+		{
+			name:   "autoscalers are released after the window expired",
+			window: time.Minute,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 100).maxReplicas(10).autoscalerFrozenSince(fiveMinutesAgo).stack(),
+			},
+			expectedFrozen: map[string]bool{},
+			expectedSince:  map[string]time.Time{"foo-v1": fiveMinutesAgo},
+			switching:      true,
+		},
+		{
+			name:   "autoscalers are released once the traffic is switched",
+			window: time.Hour,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(100, 100).maxReplicas(10).autoscalerFrozenSince(fiveMinutesAgo).stack(),
+				"v2": testStack("foo-v2").traffic(0, 0).maxReplicas(10).autoscalerFrozenSince(fiveMinutesAgo).stack(),
+			},
+			expectedFrozen: map[string]bool{},
+			expectedSince:  map[string]time.Time{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &StackSetContainer{
+				StackContainers:        tc.stacks,
+				AutoscalerFreezeWindow: tc.window,
+			}
+			c.FreezeAutoscalers(now)
+			for _, sc := range c.StackContainers {
+				require.Equal(t, tc.expectedFrozen[sc.Name()], sc.autoscalerFrozen, "stack %s", sc.Name())
+				require.Equal(t, tc.expectedSince[sc.Name()], sc.autoscalerFrozenSince, "stack %s", sc.Name())
+				require.Equal(t, tc.switching, sc.trafficSwitching, "stack %s", sc.Name())
+			}
+		})
+	}
+}
+
+func TestGenerateHPAFrozen(t *testing.T) {
+	container := generateAutoscalerCPU(3, 10, 80)
+	container.deploymentReplicas = 6
+	container.autoscalerFrozen = true
+	hpa, err := container.GenerateHPA()
+	require.NoError(t, err)
+	require.Equal(t, int32(6), *hpa.Spec.MinReplicas)
+	require.Equal(t, int32(6), hpa.Spec.MaxReplicas)
+
+	// prescaling still takes precedence
+	container.prescalingActive = true
+	container.prescalingReplicas = 8
+	hpa, err = container.GenerateHPA()
+	require.NoError(t, err)
+	require.Equal(t, int32(8), *hpa.Spec.MinReplicas)
+	require.Equal(t, int32(8), hpa.Spec.MaxReplicas)
+}
+
+func TestGenerateHPAAdvisoryMetrics(t *testing.T) {
+	container := generateAutoscalerCPU(3, 10, 80)
+	container.Stack.Spec.Autoscaler.Metrics = append(container.Stack.Spec.Autoscaler.Metrics, zv1.AutoscalerMetrics{
+		Type:    ingressMetricName,
+		Average: resource.NewQuantity(30, resource.DecimalSI),
+		Role:    zv1.AutoscalerMetricRoleAdvisory,
+	})
+
+	hpa, err := container.GenerateHPA()
+	require.NoError(t, err)
+	require.Len(t, hpa.Spec.Metrics, 2)
+
+	// advisory metrics are left out during a traffic switch, only the
+	// metrics of the HPA change
+	container.trafficSwitching = true
+	switching, err := container.GenerateHPA()
+	require.NoError(t, err)
+	require.Len(t, switching.Spec.Metrics, 1)
+	require.Equal(t, v2beta1.ResourceMetricSourceType, switching.Spec.Metrics[0].Type)
+	require.Equal(t, hpa.Spec.MinReplicas, switching.Spec.MinReplicas)
+	require.Equal(t, hpa.Spec.MaxReplicas, switching.Spec.MaxReplicas)
+	require.Equal(t, hpa.Annotations, switching.Annotations)
+}
+
+func TestGenerateHPAAutoscalerProfile(t *testing.T) {
+	profiles := []zv1.AutoscalerProfile{
+		{
+			Name: "weekday",
+			Metrics: []zv1.AutoscalerMetrics{
+				{Type: cpuMetricName, AverageUtilization: pint32(50)},
+			},
+		},
+		{
+			Name: "weekend",
+			Metrics: []zv1.AutoscalerMetrics{
+				{Type: ingressMetricName, Average: resource.NewQuantity(30, resource.DecimalSI)},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name                string
+		profile             string
+		expectedMetricType  v2beta1.MetricSourceType
+		expectedAnnotations map[string]string
+		expectedErr         string
+	}{
+		{
+			name:               "metrics of the stack are used without a profile",
+			expectedMetricType: v2beta1.ResourceMetricSourceType,
+			expectedAnnotations: map[string]string{
+				stackGenerationAnnotationKey: "0",
+			},
+		},
+		{
+			name:               "metrics of the active profile are used",
+			profile:            "weekend",
+			expectedMetricType: v2beta1.ObjectMetricSourceType,
+			expectedAnnotations: map[string]string{
+				stackGenerationAnnotationKey:   "0",
+				AutoscalerProfileAnnotationKey: "weekend",
+			},
+		},
+		{
+			name:        "unknown profile",
+			profile:     "sale-event",
+			expectedErr: "autoscaler profile sale-event not found",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			container := generateAutoscalerCPU(1, 10, 80)
+			container.autoscalerProfile = tc.profile
+			container.autoscalerProfiles = profiles
+
+			require.Equal(t, tc.expectedErr == "", container.validateAutoscaler() == nil)
+
+			hpa, err := container.GenerateHPA()
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, hpa.Spec.Metrics, 1)
+			require.Equal(t, tc.expectedMetricType, hpa.Spec.Metrics[0].Type)
+			require.Equal(t, tc.expectedAnnotations, hpa.Annotations)
+		})
+	}
+}
+
+func TestHoldAutoscalers(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name                 string
+		tolerance            float64
+		initializationPeriod time.Duration
+		utilization          int32
+		lastScaleTime        time.Time
+		expectedHeld         bool
+	}{
+		{
+			name:         "not held without overrides",
+			utilization:  55,
+			expectedHeld: false,
+		},
+		{
+			name:         "held within the overridden tolerance",
+			tolerance:    0.2,
+			utilization:  55,
+			expectedHeld: true,
+		},
+		{
+			name:         "not held outside of the overridden tolerance",
+			tolerance:    0.2,
+			utilization:  70,
+			expectedHeld: false,
+		},
+		{
+			name:                 "held during the CPU initialization period",
+			initializationPeriod: 3 * time.Minute,
+			utilization:          100,
+			lastScaleTime:        now.Add(-time.Minute),
+			expectedHeld:         true,
+		},
+		{
+			name:                 "not held after the CPU initialization period",
+			initializationPeriod: 3 * time.Minute,
+			utilization:          100,
+			lastScaleTime:        now.Add(-5 * time.Minute),
+			expectedHeld:         false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := cpuAutoscaledStack("foo-v1", 100, 4, tc.utilization)
+			if !tc.lastScaleTime.IsZero() {
+				sc.Resources.HPA.Status.LastScaleTime = &metav1.Time{Time: tc.lastScaleTime}
+			}
+			c := &StackSetContainer{
+				StackContainers: map[types.UID]*StackContainer{"v1": sc},
+			}
+			sc.hpaTolerance = tc.tolerance
+			sc.hpaCPUInitializationPeriod = tc.initializationPeriod
+
+			c.HoldAutoscalers(now)
+			require.Equal(t, tc.expectedHeld, sc.autoscalerHeld)
+
+			hpa, err := sc.GenerateHPA()
+			require.NoError(t, err)
+			if tc.expectedHeld {
+				require.Equal(t, int32(4), *hpa.Spec.MinReplicas)
+				require.Equal(t, int32(4), hpa.Spec.MaxReplicas)
+			} else {
+				require.Equal(t, int32(20), hpa.Spec.MaxReplicas)
+			}
+		})
+	}
+}
+
+func TestGenerateHPADisabled(t *testing.T) {
+	container := generateAutoscalerCPU(1, 10, 80)
+	container.Stack.Annotations = map[string]string{DisableAutoscalerAnnotationKey: "yes"}
+
+	hpa, err := container.GenerateHPA()
+	require.NoError(t, err)
+	require.Nil(t, hpa)
+	require.False(t, container.IsAutoscaled())
+	require.Equal(t, int32(math.MaxInt32), container.MaxReplicas())
+}
+
+func TestGenerateHPAScaledDown(t *testing.T) {
+	for _, tc := range []struct {
+		behavior    zv1.ScaleDownBehavior
+		expectedHPA bool
+	}{
+		{behavior: "", expectedHPA: true},
+		{behavior: zv1.ScaleDownBehaviorScaleToZero, expectedHPA: true},
+		{behavior: zv1.ScaleDownBehaviorKeepWarm, expectedHPA: false},
+		{behavior: zv1.ScaleDownBehaviorDeleteDeployment, expectedHPA: false},
+	} {
+		t.Run(string(tc.behavior), func(t *testing.T) {
+			container := generateAutoscalerCPU(1, 10, 80)
+			container.noTrafficSince = time.Now().Add(-time.Hour)
+			container.scaledownTTL = time.Minute
+			container.scaleDownBehavior = tc.behavior
+
+			hpa, err := container.GenerateHPA()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedHPA, hpa != nil)
+		})
+	}
+}
+
+func TestApplyTrafficForecast(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		minReplicas      int32
+		stacks           map[types.UID]*StackContainer
+		expectedReplicas map[string]int32
+	}{
+		{
+			name:        "no forecasted spike",
+			minReplicas: 0,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(100, 100).maxReplicas(10).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 0},
+		},
+		{
+			name:        "replicas are distributed according to the actual traffic",
+			minReplicas: 10,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 75).maxReplicas(10).stack(),
+				"v2": testStack("foo-v2").traffic(50, 25).maxReplicas(10).stack(),
+				"v3": testStack("foo-v3").traffic(0, 0).maxReplicas(10).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 8, "foo-v2": 3, "foo-v3": 0},
+		},
+		{
+			name:        "stacks without autoscaler are ignored",
+			minReplicas: 10,
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(100, 100).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v1": 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &StackSetContainer{
+				StackContainers: tc.stacks,
+			}
+			c.ApplyTrafficForecast(tc.minReplicas)
+			for _, sc := range c.StackContainers {
+				require.Equal(t, tc.expectedReplicas[sc.Name()], sc.forecastMinReplicas, "stack %s", sc.Name())
+			}
+		})
+	}
+}
+
+func TestGenerateHPATrafficForecast(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		forecastReplicas int32
+		expectedMin      int32
+	}{
+		{
+			name:             "min replicas are raised",
+			forecastReplicas: 5,
+			expectedMin:      5,
+		},
+		{
+			name:             "min replicas are not lowered",
+			forecastReplicas: 2,
+			expectedMin:      3,
+		},
+		{
+			name:             "min replicas are limited to max replicas",
+			forecastReplicas: 15,
+			expectedMin:      10,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			container := generateAutoscalerCPU(3, 10, 80)
+			container.forecastMinReplicas = tc.forecastReplicas
+			hpa, err := container.GenerateHPA()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMin, *hpa.Spec.MinReplicas)
+			require.Equal(t, int32(10), hpa.Spec.MaxReplicas)
+		})
+	}
+}
@@ -0,0 +1,605 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	reasonValidAutoscaler         = "ValidAutoscaler"
+	reasonInvalidAutoscaler       = "InvalidAutoscaler"
+	reasonInvalidAutoscalerMetric = "InvalidAutoscalerMetric"
+
+	reasonReplicasOutOfBounds = "ReplicasOutOfAutoscalerBounds"
+	reasonNoReplicasConflict  = "NoReplicasConflict"
+
+	reasonReadinessDeadlineExceeded = "ReadinessDeadlineExceeded"
+	reasonStackReady                = "StackReady"
+
+	reasonStackGettingTraffic = "StackGettingTraffic"
+	reasonStackWithoutTraffic = "StackWithoutTraffic"
+
+	reasonUnschedulablePods = "UnschedulablePods"
+
+	reasonTrafficSwitchCompleted = "Completed"
+
+	reasonDeadlineExceeded   = "DeadlineExceeded"
+	reasonReconcileCompleted = "ReconcileCompleted"
+
+	reasonNoStacks       = "NoStacks"
+	reasonStacksReady    = "StacksReady"
+	reasonStacksNotReady = "StacksNotReady"
+
+	reasonTrafficInSync    = "TrafficInSync"
+	reasonTrafficSwitching = "TrafficSwitching"
+
+	reasonStacksRemoved        = "StacksRemoved"
+	reasonStacksPendingRemoval = "StacksPendingRemoval"
+
+	reasonIngressUpdated      = "IngressUpdated"
+	reasonIngressUpdateFailed = "IngressUpdateFailed"
+
+	reasonResourcesReady      = "ResourcesReady"
+	reasonResourcesFailed     = "ResourcesFailed"
+	reasonDeploymentNotReady  = "DeploymentNotReady"
+	reasonMarkedForRemoval    = "MarkedForRemoval"
+	reasonNotMarkedForRemoval = "NotMarkedForRemoval"
+
+	reasonBackendPortMatched    = "BackendPortMatched"
+	reasonNoMatchingServicePort = "NoMatchingServicePort"
+
+	reasonDeprecatedFeaturesUsed = "DeprecatedFeaturesUsed"
+)
+
+// setStackCondition returns a copy of the conditions with the condition of
+// the same type replaced. The transition time is only updated if the status
+// of the condition changed.
+func setStackCondition(conditions []zv1.StackCondition, condition zv1.StackCondition) []zv1.StackCondition {
+	result := make([]zv1.StackCondition, 0, len(conditions)+1)
+	found := false
+	for _, existing := range conditions {
+		if existing.Type != condition.Type {
+			result = append(result, existing)
+			continue
+		}
+
+		found = true
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		result = append(result, condition)
+	}
+
+	if !found {
+		condition.LastTransitionTime = metav1.Now()
+		result = append(result, condition)
+	}
+	return result
+}
+
+// getStackCondition returns the condition of the specified type or nil if
+// it's not set.
+func getStackCondition(conditions []zv1.StackCondition, conditionType zv1.StackConditionType) *zv1.StackCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// removeStackCondition returns a copy of the conditions without the
+// condition of the specified type.
+func removeStackCondition(conditions []zv1.StackCondition, conditionType zv1.StackConditionType) []zv1.StackCondition {
+	var result []zv1.StackCondition
+	for _, existing := range conditions {
+		if existing.Type != conditionType {
+			result = append(result, existing)
+		}
+	}
+	return result
+}
+
+// setStackSetCondition returns a copy of the conditions with the condition
+// of the same type replaced. The transition time is only updated if the
+// status of the condition changed.
+func setStackSetCondition(conditions []zv1.StackSetCondition, condition zv1.StackSetCondition) []zv1.StackSetCondition {
+	result := make([]zv1.StackSetCondition, 0, len(conditions)+1)
+	found := false
+	for _, existing := range conditions {
+		if existing.Type != condition.Type {
+			result = append(result, existing)
+			continue
+		}
+
+		found = true
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		result = append(result, condition)
+	}
+
+	if !found {
+		condition.LastTransitionTime = metav1.Now()
+		result = append(result, condition)
+	}
+	return result
+}
+
+// getStackSetCondition returns the condition of the specified type or nil if
+// it's not set.
+func getStackSetCondition(conditions []zv1.StackSetCondition, conditionType zv1.StackSetConditionType) *zv1.StackSetCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// removeStackSetCondition returns a copy of the conditions without the
+// condition of the specified type.
+func removeStackSetCondition(conditions []zv1.StackSetCondition, conditionType zv1.StackSetConditionType) []zv1.StackSetCondition {
+	var result []zv1.StackSetCondition
+	for _, existing := range conditions {
+		if existing.Type != conditionType {
+			result = append(result, existing)
+		}
+	}
+	return result
+}
+
+// autoscalerCondition returns the AutoscalerValid condition for the result
+// of the autoscaler validation.
+func autoscalerCondition(validationErr error) zv1.StackCondition {
+	if validationErr != nil {
+		reason := reasonInvalidAutoscaler
+		if _, ok := validationErr.(*MetricError); ok {
+			reason = reasonInvalidAutoscalerMetric
+		}
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionAutoscalerValid,
+			Status:  v1.ConditionFalse,
+			Reason:  reason,
+			Message: validationErr.Error(),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionAutoscalerValid,
+		Status: v1.ConditionTrue,
+		Reason: reasonValidAutoscaler,
+	}
+}
+
+// replicasConflictCondition returns the ReplicasConflict condition for the
+// replicas of a stack and the replica bounds of its autoscaler.
+func replicasConflictCondition(replicas, minReplicas, maxReplicas int32) zv1.StackCondition {
+	if replicas < minReplicas || replicas > maxReplicas {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionReplicasConflict,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonReplicasOutOfBounds,
+			Message: fmt.Sprintf("replicas (%d) are outside of the autoscaler bounds (%d-%d), the autoscaler takes precedence", replicas, minReplicas, maxReplicas),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionReplicasConflict,
+		Status: v1.ConditionFalse,
+		Reason: reasonNoReplicasConflict,
+	}
+}
+
+// failedCondition returns the Failed condition for a stack which did or
+// didn't become ready within the readiness deadline.
+func failedCondition(failed bool, deadline time.Duration) zv1.StackCondition {
+	if failed {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionFailed,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonReadinessDeadlineExceeded,
+			Message: fmt.Sprintf("stack didn't become ready within %s", deadline),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionFailed,
+		Status: v1.ConditionFalse,
+		Reason: reasonStackReady,
+	}
+}
+
+// SetDeletionBlocked records in the DeletionBlocked condition whether the
+// deletion of the stack is blocked because it's still getting traffic.
+func (sc *StackContainer) SetDeletionBlocked(blocked bool) {
+	sc.conditions = setStackCondition(sc.conditions, deletionBlockedCondition(blocked, sc.actualTrafficWeight))
+}
+
+// deletionBlockedCondition returns the DeletionBlocked condition for a
+// deleted stack which is or isn't getting traffic anymore.
+func deletionBlockedCondition(blocked bool, trafficWeight float64) zv1.StackCondition {
+	if blocked {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionDeletionBlocked,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonStackGettingTraffic,
+			Message: fmt.Sprintf("stack is not deleted while it's getting %.1f%% of the traffic", trafficWeight),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionDeletionBlocked,
+		Status: v1.ConditionFalse,
+		Reason: reasonStackWithoutTraffic,
+	}
+}
+
+// insufficientCapacityCondition returns the InsufficientCapacity condition
+// for a stack whose traffic isn't increased because some of its pods can't be
+// scheduled.
+func insufficientCapacityCondition(unschedulablePods int) zv1.StackCondition {
+	return zv1.StackCondition{
+		Type:    zv1.StackConditionInsufficientCapacity,
+		Status:  v1.ConditionTrue,
+		Reason:  reasonUnschedulablePods,
+		Message: fmt.Sprintf("%d pods can't be scheduled, traffic isn't increased", unschedulablePods),
+	}
+}
+
+// prescalingPhaseOrder orders the prescaling phases from the least to the
+// most advanced one.
+var prescalingPhaseOrder = map[zv1.PrescalingPhase]int{
+	zv1.PrescalingPhaseTimedOut:  0,
+	zv1.PrescalingPhasePending:   1,
+	zv1.PrescalingPhaseScaling:   2,
+	zv1.PrescalingPhaseWaiting:   3,
+	zv1.PrescalingPhaseCompleted: 4,
+}
+
+// updateTrafficSwitchCondition aggregates the prescaling phases of the stacks
+// into the TrafficSwitchProgressing condition of the StackSet. The condition
+// is removed if none of the stacks is prescaled.
+func (ssc *StackSetContainer) updateTrafficSwitchCondition() {
+	var (
+		progress []string
+		reason   zv1.PrescalingPhase
+		active   bool
+	)
+	for _, sc := range ssc.StackContainers {
+		if !sc.prescalingActive || sc.prescalingPhase == "" {
+			continue
+		}
+		active = true
+		if sc.prescalingPhase == zv1.PrescalingPhaseCompleted {
+			continue
+		}
+		if reason == "" || prescalingPhaseOrder[sc.prescalingPhase] < prescalingPhaseOrder[reason] {
+			reason = sc.prescalingPhase
+		}
+		progress = append(progress, fmt.Sprintf("%s: %s, %d/%d replicas ready", sc.Name(), sc.prescalingPhase, sc.readyReplicas, sc.prescalingReplicas))
+	}
+
+	if !active {
+		ssc.conditions = removeStackSetCondition(ssc.conditions, zv1.StackSetConditionTrafficSwitchProgressing)
+		return
+	}
+
+	if len(progress) == 0 {
+		ssc.conditions = setStackSetCondition(ssc.conditions, zv1.StackSetCondition{
+			Type:   zv1.StackSetConditionTrafficSwitchProgressing,
+			Status: v1.ConditionFalse,
+			Reason: reasonTrafficSwitchCompleted,
+		})
+		return
+	}
+
+	sort.Strings(progress)
+	ssc.conditions = setStackSetCondition(ssc.conditions, zv1.StackSetCondition{
+		Type:    zv1.StackSetConditionTrafficSwitchProgressing,
+		Status:  v1.ConditionTrue,
+		Reason:  string(reason),
+		Message: strings.Join(progress, "; "),
+	})
+}
+
+// SetReconcileTimedOut records in the ReconcileTimeout condition whether the
+// reconciliation of the StackSet was aborted after the timeout. The condition
+// is only added once a reconciliation timed out.
+func (ssc *StackSetContainer) SetReconcileTimedOut(timedOut bool, timeout time.Duration) {
+	if timedOut {
+		ssc.conditions = setStackSetCondition(ssc.conditions, zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionReconcileTimeout,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonDeadlineExceeded,
+			Message: fmt.Sprintf("reconciliation didn't finish within %s, the remaining changes are applied in the next one", timeout),
+		})
+		return
+	}
+
+	if getStackSetCondition(ssc.conditions, zv1.StackSetConditionReconcileTimeout) != nil {
+		ssc.conditions = setStackSetCondition(ssc.conditions, zv1.StackSetCondition{
+			Type:   zv1.StackSetConditionReconcileTimeout,
+			Status: v1.ConditionFalse,
+			Reason: reasonReconcileCompleted,
+		})
+	}
+}
+
+// SetIngressReconciled records the result of the reconciliation of the
+// ingress of the StackSet for the IngressUpToDate condition.
+func (ssc *StackSetContainer) SetIngressReconciled(err error) {
+	ssc.ingressReconciled = true
+	ssc.ingressErr = err
+}
+
+// SetDeprecations records the deprecated features used by the StackSet for
+// the UsesDeprecatedFeatures condition, each described with its replacement.
+func (ssc *StackSetContainer) SetDeprecations(deprecations []string) {
+	ssc.deprecations = deprecations
+}
+
+// stacksetConditions returns the conditions of the StackSet including the
+// ones derived from the current state of its stacks.
+func (ssc *StackSetContainer) stacksetConditions() []zv1.StackSetCondition {
+	conditions := setStackSetCondition(ssc.conditions, ssc.readyCondition())
+	conditions = setStackSetCondition(conditions, ssc.trafficInSyncCondition())
+	conditions = setStackSetCondition(conditions, ssc.stacksGarbageCollectedCondition())
+
+	if len(ssc.deprecations) > 0 {
+		conditions = setStackSetCondition(conditions, ssc.usesDeprecatedFeaturesCondition())
+	} else {
+		conditions = removeStackSetCondition(conditions, zv1.StackSetConditionUsesDeprecatedFeatures)
+	}
+
+	switch {
+	case ssc.StackSet.Spec.Ingress == nil:
+		conditions = removeStackSetCondition(conditions, zv1.StackSetConditionIngressUpToDate)
+	case ssc.ingressReconciled:
+		conditions = setStackSetCondition(conditions, ingressUpToDateCondition(ssc.ingressErr))
+	}
+	return conditions
+}
+
+// readyCondition returns the Ready condition, which is true if the StackSet
+// has stacks and all of the ones which should get traffic are ready.
+func (ssc *StackSetContainer) readyCondition() zv1.StackSetCondition {
+	var stacks, notReady []string
+	for _, sc := range ssc.StackContainers {
+		if sc.PendingRemoval {
+			continue
+		}
+		stacks = append(stacks, sc.Name())
+		if sc.desiredTrafficWeight > 0 && !sc.IsReady() {
+			notReady = append(notReady, sc.Name())
+		}
+	}
+
+	switch {
+	case len(stacks) == 0:
+		return zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionReady,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonNoStacks,
+			Message: "stackset has no stacks",
+		}
+	case len(notReady) > 0:
+		sort.Strings(notReady)
+		return zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionReady,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonStacksNotReady,
+			Message: fmt.Sprintf("stacks which should get traffic aren't ready: %s", strings.Join(notReady, ", ")),
+		}
+	}
+	return zv1.StackSetCondition{
+		Type:   zv1.StackSetConditionReady,
+		Status: v1.ConditionTrue,
+		Reason: reasonStacksReady,
+	}
+}
+
+// trafficInSyncCondition returns the TrafficInSync condition, which is true
+// if the actual traffic weights of all the stacks match the desired ones.
+func (ssc *StackSetContainer) trafficInSyncCondition() zv1.StackSetCondition {
+	var switching []string
+	for _, sc := range ssc.StackContainers {
+		if math.Abs(sc.actualTrafficWeight-sc.desiredTrafficWeight) > trafficWeightTolerance {
+			switching = append(switching, fmt.Sprintf("%s: %.1f%% of %.1f%%", sc.Name(), sc.actualTrafficWeight, sc.desiredTrafficWeight))
+		}
+	}
+
+	if len(switching) > 0 {
+		sort.Strings(switching)
+		return zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionTrafficInSync,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonTrafficSwitching,
+			Message: strings.Join(switching, "; "),
+		}
+	}
+	return zv1.StackSetCondition{
+		Type:   zv1.StackSetConditionTrafficInSync,
+		Status: v1.ConditionTrue,
+		Reason: reasonTrafficInSync,
+	}
+}
+
+// stacksGarbageCollectedCondition returns the StacksGarbageCollected
+// condition, which is true if none of the stacks is waiting to be removed.
+func (ssc *StackSetContainer) stacksGarbageCollectedCondition() zv1.StackSetCondition {
+	var pending []string
+	for _, sc := range ssc.StackContainers {
+		if sc.PendingRemoval {
+			pending = append(pending, sc.Name())
+		}
+	}
+
+	if len(pending) > 0 {
+		sort.Strings(pending)
+		return zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionStacksGarbageCollected,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonStacksPendingRemoval,
+			Message: fmt.Sprintf("stacks are going to be removed: %s", strings.Join(pending, ", ")),
+		}
+	}
+	return zv1.StackSetCondition{
+		Type:   zv1.StackSetConditionStacksGarbageCollected,
+		Status: v1.ConditionTrue,
+		Reason: reasonStacksRemoved,
+	}
+}
+
+// usesDeprecatedFeaturesCondition returns the UsesDeprecatedFeatures
+// condition listing the deprecated features used by the StackSet.
+func (ssc *StackSetContainer) usesDeprecatedFeaturesCondition() zv1.StackSetCondition {
+	deprecations := append([]string(nil), ssc.deprecations...)
+	sort.Strings(deprecations)
+	return zv1.StackSetCondition{
+		Type:    zv1.StackSetConditionUsesDeprecatedFeatures,
+		Status:  v1.ConditionTrue,
+		Reason:  reasonDeprecatedFeaturesUsed,
+		Message: strings.Join(deprecations, "; "),
+	}
+}
+
+// ingressUpToDateCondition returns the IngressUpToDate condition for the
+// result of the reconciliation of the ingress.
+func ingressUpToDateCondition(err error) zv1.StackSetCondition {
+	if err != nil {
+		return zv1.StackSetCondition{
+			Type:    zv1.StackSetConditionIngressUpToDate,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonIngressUpdateFailed,
+			Message: err.Error(),
+		}
+	}
+	return zv1.StackSetCondition{
+		Type:   zv1.StackSetConditionIngressUpToDate,
+		Status: v1.ConditionTrue,
+		Reason: reasonIngressUpdated,
+	}
+}
+
+// SetResourcesReconciled records the result of the reconciliation of the
+// resources of the stack for the ResourcesReady condition.
+func (sc *StackContainer) SetResourcesReconciled(err error) {
+	sc.resourcesErr = err
+}
+
+// ResourcesError returns the error of the last reconciliation of the
+// resources of the stack, if any.
+func (sc *StackContainer) ResourcesError() error {
+	return sc.resourcesErr
+}
+
+// UpdateStackConditions updates the ResourcesReady, HasTraffic and
+// ScheduledForRemoval conditions of the stacks from their current state.
+func (ssc *StackSetContainer) UpdateStackConditions() {
+	for _, sc := range ssc.StackContainers {
+		sc.conditions = setStackCondition(sc.conditions, sc.resourcesReadyCondition())
+		sc.conditions = setStackCondition(sc.conditions, sc.hasTrafficCondition())
+		sc.conditions = setStackCondition(sc.conditions, sc.scheduledForRemovalCondition())
+		if sc.ingressSpec != nil {
+			sc.conditions = setStackCondition(sc.conditions, sc.backendPortCondition())
+		} else {
+			sc.conditions = removeStackCondition(sc.conditions, zv1.StackConditionBackendPortValid)
+		}
+	}
+}
+
+// resourcesReadyCondition returns the ResourcesReady condition, which
+// reports why the resources of the stack couldn't be reconciled or why its
+// deployment isn't ready.
+func (sc *StackContainer) resourcesReadyCondition() zv1.StackCondition {
+	if sc.resourcesErr != nil {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionResourcesReady,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonResourcesFailed,
+			Message: sc.resourcesErr.Error(),
+		}
+	}
+	if !sc.IsReady() {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionResourcesReady,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonDeploymentNotReady,
+			Message: fmt.Sprintf("%d/%d replicas ready, %d updated", sc.readyReplicas, sc.deploymentReplicas, sc.updatedReplicas),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionResourcesReady,
+		Status: v1.ConditionTrue,
+		Reason: reasonResourcesReady,
+	}
+}
+
+// hasTrafficCondition returns the HasTraffic condition with the actual and
+// desired traffic weights of the stack.
+func (sc *StackContainer) hasTrafficCondition() zv1.StackCondition {
+	if sc.HasTraffic() {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionHasTraffic,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonStackGettingTraffic,
+			Message: fmt.Sprintf("stack gets %.1f%% of the traffic, %.1f%% desired", sc.actualTrafficWeight, sc.desiredTrafficWeight),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionHasTraffic,
+		Status: v1.ConditionFalse,
+		Reason: reasonStackWithoutTraffic,
+	}
+}
+
+// scheduledForRemovalCondition returns the ScheduledForRemoval condition,
+// which is true for stacks selected to be deleted, including the ones kept
+// during the removal grace period or while their traffic is drained.
+func (sc *StackContainer) scheduledForRemovalCondition() zv1.StackCondition {
+	if sc.PendingRemoval || sc.removalReason != "" {
+		message := sc.removalReason
+		if message == "" {
+			message = "stack was selected to be deleted"
+		}
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionScheduledForRemoval,
+			Status:  v1.ConditionTrue,
+			Reason:  reasonMarkedForRemoval,
+			Message: message,
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionScheduledForRemoval,
+		Status: v1.ConditionFalse,
+		Reason: reasonNotMarkedForRemoval,
+	}
+}
+
+// backendPortCondition returns the BackendPortValid condition, which reports
+// that neither the service nor the ingress of the stack can be generated
+// because the backendPort of the ingress matches none of its service ports.
+func (sc *StackContainer) backendPortCondition() zv1.StackCondition {
+	_, err := getServicePorts(sc.Stack.Spec, &sc.ingressSpec.BackendPort)
+	if err != nil {
+		return zv1.StackCondition{
+			Type:    zv1.StackConditionBackendPortValid,
+			Status:  v1.ConditionFalse,
+			Reason:  reasonNoMatchingServicePort,
+			Message: fmt.Sprintf("%v, the service and the ingress of the stack aren't created", err),
+		}
+	}
+	return zv1.StackCondition{
+		Type:   zv1.StackConditionBackendPortValid,
+		Status: v1.ConditionTrue,
+		Reason: reasonBackendPortMatched,
+	}
+}
@@ -0,0 +1,222 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSetStackCondition(t *testing.T) {
+	hourAgo := metav1.NewTime(hourAgo)
+
+	existing := []zv1.StackCondition{
+		{
+			Type:               zv1.StackConditionAutoscalerValid,
+			Status:             v1.ConditionTrue,
+			Reason:             reasonValidAutoscaler,
+			LastTransitionTime: hourAgo,
+		},
+	}
+
+	// unchanged status keeps the transition time
+	result := setStackCondition(existing, autoscalerCondition(nil))
+	require.Len(t, result, 1)
+	require.Equal(t, hourAgo, result[0].LastTransitionTime)
+
+	// changed status updates the transition time and doesn't modify the input
+	result = setStackCondition(existing, autoscalerCondition(errors.New("broken")))
+	require.Len(t, result, 1)
+	require.Equal(t, v1.ConditionFalse, result[0].Status)
+	require.Equal(t, reasonInvalidAutoscaler, result[0].Reason)
+	require.Equal(t, "broken", result[0].Message)
+	require.True(t, hourAgo.Before(&result[0].LastTransitionTime))
+	require.Equal(t, v1.ConditionTrue, existing[0].Status)
+
+	// new conditions are added
+	result = setStackCondition(nil, autoscalerCondition(nil))
+	require.Len(t, result, 1)
+	require.False(t, result[0].LastTransitionTime.IsZero())
+
+	// conditions can be removed
+	require.Empty(t, removeStackCondition(existing, zv1.StackConditionAutoscalerValid))
+}
+
+func TestUpdateAutoscalerCondition(t *testing.T) {
+	valid := generateAutoscalerCPU(1, 10, 80)
+	valid.updateFromResources()
+	require.Len(t, valid.conditions, 1)
+	require.Equal(t, v1.ConditionTrue, valid.conditions[0].Status)
+
+	invalid := generateAutoscalerCPU(1, 10, 0)
+	invalid.updateFromResources()
+	require.Len(t, invalid.conditions, 1)
+	require.Equal(t, v1.ConditionFalse, invalid.conditions[0].Status)
+	require.Equal(t, "invalid metric CPU (metrics[0].averageUtilization): utilization must be at least 1", invalid.conditions[0].Message)
+	require.Equal(t, reasonInvalidAutoscalerMetric, invalid.conditions[0].Reason)
+
+	// the condition is removed if the stack is no longer autoscaled
+	invalid.Stack.Status.Conditions = invalid.conditions
+	invalid.Stack.Spec.Autoscaler = nil
+	invalid.updateFromResources()
+	require.Empty(t, invalid.conditions)
+}
+
+func TestUpdateReplicasConflictCondition(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		replicas       *int32
+		autoscaled     bool
+		expectedStatus v1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:       "no condition without replicas",
+			autoscaled: true,
+		},
+		{
+			name:     "no condition without autoscaler",
+			replicas: wrapReplicas(20),
+		},
+		{
+			name:           "replicas within the autoscaler bounds",
+			replicas:       wrapReplicas(5),
+			autoscaled:     true,
+			expectedStatus: v1.ConditionFalse,
+			expectedReason: reasonNoReplicasConflict,
+		},
+		{
+			name:           "replicas above the autoscaler bounds",
+			replicas:       wrapReplicas(20),
+			autoscaled:     true,
+			expectedStatus: v1.ConditionTrue,
+			expectedReason: reasonReplicasOutOfBounds,
+		},
+		{
+			name:           "replicas below the autoscaler bounds",
+			replicas:       wrapReplicas(1),
+			autoscaled:     true,
+			expectedStatus: v1.ConditionTrue,
+			expectedReason: reasonReplicasOutOfBounds,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			container := generateAutoscalerCPU(2, 10, 80)
+			container.Stack.Spec.Replicas = tc.replicas
+			if !tc.autoscaled {
+				container.Stack.Spec.Autoscaler = nil
+			}
+			container.updateFromResources()
+
+			var conflict *zv1.StackCondition
+			for i, condition := range container.conditions {
+				if condition.Type == zv1.StackConditionReplicasConflict {
+					conflict = &container.conditions[i]
+				}
+			}
+			if tc.expectedStatus == "" {
+				require.Nil(t, conflict)
+				return
+			}
+			require.NotNil(t, conflict)
+			require.Equal(t, tc.expectedStatus, conflict.Status)
+			require.Equal(t, tc.expectedReason, conflict.Reason)
+		})
+	}
+}
+
+func TestSetReconcileTimedOut(t *testing.T) {
+	ssc := &StackSetContainer{}
+
+	// the condition isn't added as long as no reconciliation timed out
+	ssc.SetReconcileTimedOut(false, time.Minute)
+	require.Empty(t, ssc.conditions)
+
+	ssc.SetReconcileTimedOut(true, time.Minute)
+	require.Len(t, ssc.conditions, 1)
+	require.Equal(t, zv1.StackSetConditionReconcileTimeout, ssc.conditions[0].Type)
+	require.Equal(t, v1.ConditionTrue, ssc.conditions[0].Status)
+	require.Equal(t, reasonDeadlineExceeded, ssc.conditions[0].Reason)
+
+	// the condition is cleared once a reconciliation finishes in time
+	ssc.SetReconcileTimedOut(false, time.Minute)
+	require.Len(t, ssc.conditions, 1)
+	require.Equal(t, v1.ConditionFalse, ssc.conditions[0].Status)
+	require.Equal(t, reasonReconcileCompleted, ssc.conditions[0].Reason)
+}
+
+func TestUpdateStackConditions(t *testing.T) {
+	ready := testStack("foo-v1").ready(3).traffic(100, 100).stack()
+	failed := testStack("foo-v2").traffic(0, 0).stack()
+	failed.SetResourcesReconciled(errors.New("backendPort 8080 isn't exposed by the pod template"))
+	removed := testStack("foo-v3").deployment(true, 3, 3, 1).pendingRemoval().stack()
+
+	ssc := &StackSetContainer{
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": ready,
+			"v2": failed,
+			"v3": removed,
+		},
+	}
+	ssc.UpdateStackConditions()
+
+	for _, tc := range []struct {
+		stack         *StackContainer
+		conditionType zv1.StackConditionType
+		status        v1.ConditionStatus
+		message       string
+	}{
+		{ready, zv1.StackConditionResourcesReady, v1.ConditionTrue, ""},
+		{ready, zv1.StackConditionHasTraffic, v1.ConditionTrue, "stack gets 100.0% of the traffic, 100.0% desired"},
+		{ready, zv1.StackConditionScheduledForRemoval, v1.ConditionFalse, ""},
+		{failed, zv1.StackConditionResourcesReady, v1.ConditionFalse, "backendPort 8080 isn't exposed by the pod template"},
+		{failed, zv1.StackConditionHasTraffic, v1.ConditionFalse, ""},
+		{removed, zv1.StackConditionResourcesReady, v1.ConditionFalse, "1/3 replicas ready, 3 updated"},
+		{removed, zv1.StackConditionScheduledForRemoval, v1.ConditionTrue, "stack was selected to be deleted"},
+	} {
+		condition := getStackCondition(tc.stack.conditions, tc.conditionType)
+		require.NotNil(t, condition, "%s %s", tc.stack.Name(), tc.conditionType)
+		require.Equal(t, tc.status, condition.Status, "%s %s", tc.stack.Name(), tc.conditionType)
+		require.Equal(t, tc.message, condition.Message, "%s %s", tc.stack.Name(), tc.conditionType)
+	}
+}
+
+func TestBackendPortCondition(t *testing.T) {
+	matching := testStack("foo-v1").stack()
+	matching.Stack.Spec.PodTemplate.Spec.Containers = []v1.Container{{Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}}}}
+	mismatching := testStack("foo-v2").stack()
+	mismatching.Stack.Spec.PodTemplate.Spec.Containers = []v1.Container{{Ports: []v1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}}}
+	withoutIngress := testStack("foo-v3").stack()
+	withoutIngress.conditions = []zv1.StackCondition{{Type: zv1.StackConditionBackendPortValid, Status: v1.ConditionFalse}}
+
+	ingress := &zv1.StackSetIngressSpec{BackendPort: intstr.FromString("http")}
+	matching.ingressSpec = ingress
+	mismatching.ingressSpec = ingress
+
+	ssc := &StackSetContainer{
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": matching,
+			"v2": mismatching,
+			"v3": withoutIngress,
+		},
+	}
+	ssc.UpdateStackConditions()
+
+	condition := getStackCondition(matching.conditions, zv1.StackConditionBackendPortValid)
+	require.NotNil(t, condition)
+	require.Equal(t, v1.ConditionTrue, condition.Status)
+
+	condition = getStackCondition(mismatching.conditions, zv1.StackConditionBackendPortValid)
+	require.NotNil(t, condition)
+	require.Equal(t, v1.ConditionFalse, condition.Status)
+	require.Equal(t, reasonNoMatchingServicePort, condition.Reason)
+	require.Equal(t, "no service ports matching backendPort 'http', the service and the ingress of the stack aren't created", condition.Message)
+
+	require.Nil(t, getStackCondition(withoutIngress.conditions, zv1.StackConditionBackendPortValid))
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"sort"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StackSetDebugState is the internal model of a StackSet computed by the
+// controller, exposed for support investigations.
+type StackSetDebugState struct {
+	Namespace      string                    `json:"namespace"`
+	Name           string                    `json:"name"`
+	PendingRemoval []zv1.PendingStackRemoval `json:"pendingRemoval,omitempty"`
+	Conditions     []zv1.StackSetCondition   `json:"conditions,omitempty"`
+	Stacks         []*StackDebugState        `json:"stacks"`
+}
+
+// StackDebugState is the internal model of a stack computed by the
+// controller.
+type StackDebugState struct {
+	Name                       string               `json:"name"`
+	ActualTrafficWeight        float64              `json:"actualTrafficWeight"`
+	DesiredTrafficWeight       float64              `json:"desiredTrafficWeight"`
+	CurrentActualTrafficWeight float64              `json:"currentActualTrafficWeight"`
+	NoTrafficSince             *metav1.Time         `json:"noTrafficSince,omitempty"`
+	Ready                      bool                 `json:"ready"`
+	Failed                     bool                 `json:"failed"`
+	PendingRemoval             bool                 `json:"pendingRemoval"`
+	RemovalReason              string               `json:"removalReason,omitempty"`
+	DrainingSince              *metav1.Time         `json:"drainingSince,omitempty"`
+	DrainingTrafficWeight      float64              `json:"drainingTrafficWeight,omitempty"`
+	Prescaling                 zv1.PrescalingStatus `json:"prescaling"`
+	AutoscalerFrozen           bool                 `json:"autoscalerFrozen"`
+	AutoscalerHeld             bool                 `json:"autoscalerHeld"`
+	AggregatedReplicas         int32                `json:"aggregatedReplicas,omitempty"`
+	ForecastMinReplicas        int32                `json:"forecastMinReplicas,omitempty"`
+	Conditions                 []zv1.StackCondition `json:"conditions,omitempty"`
+	// ResourceDiffs are the differences between the existing and the
+	// generated resources of the stack by kind, filled in by the
+	// controller.
+	ResourceDiffs map[string]string `json:"resourceDiffs,omitempty"`
+}
+
+// DebugState returns the internal model of the StackSet and its stacks,
+// sorted by name.
+func (ssc *StackSetContainer) DebugState() *StackSetDebugState {
+	result := &StackSetDebugState{
+		Namespace:      ssc.StackSet.Namespace,
+		Name:           ssc.StackSet.Name,
+		PendingRemoval: ssc.pendingRemoval,
+		Conditions:     ssc.conditions,
+	}
+	for _, sc := range ssc.StackContainers {
+		result.Stacks = append(result.Stacks, &StackDebugState{
+			Name:                       sc.Name(),
+			ActualTrafficWeight:        sc.actualTrafficWeight,
+			DesiredTrafficWeight:       sc.desiredTrafficWeight,
+			CurrentActualTrafficWeight: sc.currentActualTrafficWeight,
+			NoTrafficSince:             wrapTime(sc.noTrafficSince),
+			Ready:                      sc.IsReady(),
+			Failed:                     sc.failed,
+			PendingRemoval:             sc.PendingRemoval,
+			RemovalReason:              sc.removalReason,
+			DrainingSince:              wrapTime(sc.drainingSince),
+			DrainingTrafficWeight:      sc.drainingTrafficWeight,
+			Prescaling:                 sc.GenerateStackStatus().Prescaling,
+			AutoscalerFrozen:           sc.autoscalerFrozen,
+			AutoscalerHeld:             sc.autoscalerHeld,
+			AggregatedReplicas:         sc.aggregatedReplicas,
+			ForecastMinReplicas:        sc.forecastMinReplicas,
+			Conditions:                 sc.conditions,
+		})
+	}
+	sort.Slice(result.Stacks, func(i, j int) bool {
+		return result.Stacks[i].Name < result.Stacks[j].Name
+	})
+	return result
+}
@@ -16,6 +16,42 @@ const (
 	KindStack    = "Stack"
 
 	stackGenerationAnnotationKey = "stackset-controller.zalando.org/stack-generation"
+
+	// AutoscalerProfileAnnotationKey selects the active autoscaler profile
+	// of a StackSet and is set on the generated HPAs to the name of the
+	// autoscaler profile used for the metrics.
+	AutoscalerProfileAnnotationKey = "alpha.stackset-controller.zalando.org/autoscaler-profile"
+
+	// DisableAutoscalerAnnotationKey can be set on a Stack to run it with
+	// a fixed number of replicas instead of generating an HPA.
+	DisableAutoscalerAnnotationKey = "alpha.stackset-controller.zalando.org/disable-autoscaler"
+
+	// GCProtectAnnotationKey can be set to "true" on a Stack to never
+	// delete it when cleaning up old stacks.
+	GCProtectAnnotationKey = "stackset-controller.zalando.org/gc-protect"
+
+	// ExpiresAtAnnotationKey and TTLAnnotationKey can be set on a Stack to
+	// delete it at the given RFC 3339 timestamp or after the given duration
+	// since its creation, regardless of the history limit.
+	ExpiresAtAnnotationKey = "stackset-controller.zalando.org/expires-at"
+	TTLAnnotationKey       = "stackset-controller.zalando.org/ttl"
+
+	// TrafficChangedByAnnotationKey can be set on the StackSet or its
+	// ingress by tools changing the desired traffic, to record who made
+	// the change in the traffic history.
+	TrafficChangedByAnnotationKey = "stackset-controller.zalando.org/traffic-changed-by"
+
+	// StackTrafficFinalizer is set on Stacks to prevent their deletion
+	// while they're getting traffic.
+	StackTrafficFinalizer = "stackset-controller.zalando.org/traffic-guard"
+
+	// StackTeardownFinalizer is set on Stacks to delete their resources in
+	// order when they're deleted: ingress, service, HPA and deployment.
+	StackTeardownFinalizer = "stackset-controller.zalando.org/ordered-teardown"
+
+	// StackSetOrphanFinalizer is set on StackSets with the Orphan deletion
+	// policy to orphan their stacks before they're deleted.
+	StackSetOrphanFinalizer = "stackset-controller.zalando.org/orphan-stacks"
 )
 
 func mergeLabels(labelMaps ...map[string]string) map[string]string {
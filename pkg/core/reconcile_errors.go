@@ -0,0 +1,60 @@
+package core
+
+import (
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lastErrorsLimit is the number of distinct reconcile errors kept in the
+// status of a StackSet.
+const lastErrorsLimit = 5
+
+// RecordError records an error which occurred while reconciling the
+// StackSet. It's merged into the last errors in the status of the StackSet:
+// a repeated error increments the count of the existing entry and moves it
+// to the end, a new one is appended and only the most recent errors are
+// kept.
+func (ssc *StackSetContainer) RecordError(err error, timestamp time.Time) {
+	if err == nil {
+		return
+	}
+
+	if ssc.lastErrors == nil {
+		ssc.lastErrors = ssc.StackSet.Status.LastErrors
+	}
+
+	record := zv1.ReconcileErrorRecord{
+		Message:        err.Error(),
+		FirstTimestamp: metav1.NewTime(timestamp),
+		LastTimestamp:  metav1.NewTime(timestamp),
+		Count:          1,
+	}
+
+	lastErrors := make([]zv1.ReconcileErrorRecord, 0, len(ssc.lastErrors)+1)
+	for _, existing := range ssc.lastErrors {
+		if existing.Message == record.Message {
+			record.FirstTimestamp = existing.FirstTimestamp
+			record.Count += existing.Count
+			continue
+		}
+		lastErrors = append(lastErrors, existing)
+	}
+	lastErrors = append(lastErrors, record)
+
+	if len(lastErrors) > lastErrorsLimit {
+		lastErrors = lastErrors[len(lastErrors)-lastErrorsLimit:]
+	}
+	ssc.lastErrors = lastErrors
+}
+
+// LastErrors returns the last errors which occurred while reconciling the
+// StackSet, including the ones recorded in the current reconciliation, the
+// most recent one last.
+func (ssc *StackSetContainer) LastErrors() []zv1.ReconcileErrorRecord {
+	if ssc.lastErrors == nil {
+		return ssc.StackSet.Status.LastErrors
+	}
+	return ssc.lastErrors
+}
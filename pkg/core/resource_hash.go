@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// stackResourceInputs are all the inputs the resources of a stack are
+// generated from, together with the versions of the existing resources they
+// are compared with.
+type stackResourceInputs struct {
+	Meta                       metav1.ObjectMeta
+	Spec                       zv1.StackSpec
+	StacksetName               string
+	IngressSpec                *zv1.StackSetIngressSpec
+	ScaledDown                 bool
+	ScaleDownBehavior          zv1.ScaleDownBehavior
+	WarmReplicas               int32
+	AutoscalerProfile          string
+	AutoscalerProfiles         []zv1.AutoscalerProfile
+	HPATolerance               float64
+	HPACPUInitializationPeriod time.Duration
+	HPAFieldOwnership          bool
+	StackReplicas              int32
+	DeploymentReplicas         int32
+	PrescalingActive           bool
+	PrescalingReplicas         int32
+	TrafficSwitching           bool
+	AutoscalerFrozen           bool
+	AutoscalerHeld             bool
+	AggregatedReplicas         int32
+	ForecastMinReplicas        int32
+	DeploymentVersion          string
+	HPAVersion                 string
+	ServiceVersion             string
+	IngressVersion             string
+}
+
+// ResourcesHash returns a hash of everything the Deployment, HPA, Service and
+// Ingress of the stack depend on, including the versions of the existing
+// resources. If the hash didn't change since the resources were last
+// reconciled, they don't need to be generated and compared again. The hash
+// is empty if the version of an existing resource is unknown.
+func (sc *StackContainer) ResourcesHash(hpaFieldOwnership bool) (string, error) {
+	inputs := stackResourceInputs{
+		Meta: metav1.ObjectMeta{
+			Name:        sc.Stack.Name,
+			Namespace:   sc.Stack.Namespace,
+			UID:         sc.Stack.UID,
+			Generation:  sc.Stack.Generation,
+			Labels:      sc.Stack.Labels,
+			Annotations: sc.Stack.Annotations,
+		},
+		Spec:                       sc.Stack.Spec,
+		StacksetName:               sc.stacksetName,
+		IngressSpec:                sc.ingressSpec,
+		ScaledDown:                 sc.ScaledDown(),
+		ScaleDownBehavior:          sc.scaleDownBehavior,
+		WarmReplicas:               sc.warmReplicas,
+		AutoscalerProfile:          sc.autoscalerProfile,
+		AutoscalerProfiles:         sc.autoscalerProfiles,
+		HPATolerance:               sc.hpaTolerance,
+		HPACPUInitializationPeriod: sc.hpaCPUInitializationPeriod,
+		HPAFieldOwnership:          hpaFieldOwnership,
+		StackReplicas:              sc.stackReplicas,
+		DeploymentReplicas:         sc.deploymentReplicas,
+		PrescalingActive:           sc.prescalingActive,
+		PrescalingReplicas:         sc.prescalingReplicas,
+		TrafficSwitching:           sc.trafficSwitching,
+		AutoscalerFrozen:           sc.autoscalerFrozen,
+		AutoscalerHeld:             sc.autoscalerHeld,
+		AggregatedReplicas:         sc.aggregatedReplicas,
+		ForecastMinReplicas:        sc.forecastMinReplicas,
+	}
+	if sc.Resources.Deployment != nil {
+		inputs.DeploymentVersion = sc.Resources.Deployment.ResourceVersion
+	}
+	if sc.Resources.HPA != nil {
+		inputs.HPAVersion = sc.Resources.HPA.ResourceVersion
+	}
+	if sc.Resources.Service != nil {
+		inputs.ServiceVersion = sc.Resources.Service.ResourceVersion
+	}
+	if sc.Resources.Ingress != nil {
+		inputs.IngressVersion = sc.Resources.Ingress.ResourceVersion
+	}
+
+	// Changes of existing resources can't be detected without their version
+	if sc.Resources.Deployment != nil && inputs.DeploymentVersion == "" ||
+		sc.Resources.HPA != nil && inputs.HPAVersion == "" ||
+		sc.Resources.Service != nil && inputs.ServiceVersion == "" ||
+		sc.Resources.Ingress != nil && inputs.IngressVersion == "" {
+		return "", nil
+	}
+
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write(data)
+	return fmt.Sprintf("%x", hash.Sum64()), nil
+}
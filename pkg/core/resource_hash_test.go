@@ -0,0 +1,75 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourcesHash(t *testing.T) {
+	c := testStack("foo-v1").stack()
+	c.stackReplicas = 3
+	c.Resources.Deployment = &apps.Deployment{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}
+
+	hash, err := c.ResourcesHash(false)
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+
+	// the hash is stable
+	unchanged, err := c.ResourcesHash(false)
+	require.NoError(t, err)
+	require.Equal(t, hash, unchanged)
+
+	for _, tc := range []struct {
+		name   string
+		change func(c *StackContainer)
+	}{
+		{
+			name:   "stack spec",
+			change: func(c *StackContainer) { c.Stack.Spec.Replicas = wrapReplicas(5) },
+		},
+		{
+			name:   "stack labels",
+			change: func(c *StackContainer) { c.Stack.Labels = map[string]string{"foo": "bar"} },
+		},
+		{
+			name:   "prescaling",
+			change: func(c *StackContainer) { c.prescalingActive = true },
+		},
+		{
+			name: "scaled down",
+			change: func(c *StackContainer) {
+				c.noTrafficSince = time.Now().Add(-time.Hour)
+				c.scaledownTTL = time.Minute
+			},
+		},
+		{
+			name:   "deployment version",
+			change: func(c *StackContainer) { c.Resources.Deployment.ResourceVersion = "2" },
+		},
+		{
+			name:   "deployment deleted",
+			change: func(c *StackContainer) { c.Resources.Deployment = nil },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := testStack("foo-v1").stack()
+			changed.stackReplicas = 3
+			changed.Resources.Deployment = &apps.Deployment{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}
+			tc.change(changed)
+
+			changedHash, err := changed.ResourcesHash(false)
+			require.NoError(t, err)
+			require.NotEqual(t, hash, changedHash)
+		})
+	}
+
+	// changes of existing resources without a version can't be detected
+	c.Resources.Deployment.ResourceVersion = ""
+	hash, err = c.ResourcesHash(false)
+	require.NoError(t, err)
+	require.Empty(t, hash)
+}
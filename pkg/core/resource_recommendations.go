@@ -0,0 +1,135 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// resourceRecommendationHeadroom is the head-room in percent added to
+	// the observed usage of a container to get the recommended requests.
+	resourceRecommendationHeadroom = 20
+
+	// resourceRecommendationInterval is the minimum time between two
+	// queries of the resource usage of a stack.
+	resourceRecommendationInterval = 5 * time.Minute
+
+	// resourceRecommendationHalfLife is the time after which the peak usage
+	// recorded in a recommendation is only taken into account with half of
+	// its value, so that past peaks fade out if the usage goes down.
+	resourceRecommendationHalfLife = 24 * time.Hour
+)
+
+// ResourceUsageProvider provides the measured resource usage of the
+// containers of the pods matching a label selector.
+type ResourceUsageProvider interface {
+	// ContainerResourceUsage returns the highest usage of each container
+	// across all the pods matching the selector, by container name.
+	ContainerResourceUsage(namespace, selector string) (map[string]v1.ResourceList, error)
+}
+
+// UpdateResourceRecommendations records recommended resource requests for
+// the containers of every stack with running pods. The recommendation is
+// the decaying peak of the observed usage plus some head-room. The usage of
+// a stack is queried at most once per resourceRecommendationInterval.
+// Nothing is done if no ResourceUsage provider is configured.
+func (ssc *StackSetContainer) UpdateResourceRecommendations(currentTimestamp time.Time) error {
+	if ssc.ResourceUsage == nil {
+		return nil
+	}
+
+	var failed []string
+	for _, sc := range ssc.StackContainers {
+		if sc.Resources.Deployment == nil || sc.Resources.Deployment.Spec.Selector == nil || sc.readyReplicas == 0 {
+			continue
+		}
+
+		elapsed := currentTimestamp.Sub(sc.resourceRecommendationsUpdateTime)
+		if !sc.resourceRecommendationsUpdateTime.IsZero() && elapsed < resourceRecommendationInterval {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(sc.Resources.Deployment.Spec.Selector)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", sc.Name(), err))
+			continue
+		}
+
+		usage, err := ssc.ResourceUsage.ContainerResourceUsage(sc.Namespace(), selector.String())
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", sc.Name(), err))
+			continue
+		}
+		decay := 1.0
+		if !sc.resourceRecommendationsUpdateTime.IsZero() {
+			decay = math.Pow(0.5, float64(elapsed)/float64(resourceRecommendationHalfLife))
+		}
+		sc.resourceRecommendations = recommendResources(sc.resourceRecommendations, usage, decay)
+		sc.resourceRecommendationsUpdateTime = currentTimestamp
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to get resource usage of stacks: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// recommendResources merges the observed usage into the previous
+// recommendations, which are scaled down by the decay factor first, keeping
+// the higher value of each resource.
+func recommendResources(previous []zv1.ContainerResourceRecommendation, usage map[string]v1.ResourceList, decay float64) []zv1.ContainerResourceRecommendation {
+	requests := make(map[string]v1.ResourceList, len(previous))
+	for _, recommendation := range previous {
+		requests[recommendation.ContainerName] = make(v1.ResourceList, len(recommendation.Requests))
+		for name, quantity := range recommendation.Requests {
+			requests[recommendation.ContainerName][name] = scaleQuantity(name, quantity, decay)
+		}
+	}
+
+	for container, resources := range usage {
+		if _, ok := requests[container]; !ok {
+			requests[container] = make(v1.ResourceList)
+		}
+		for name, quantity := range resources {
+			recommended := withHeadroom(name, quantity)
+			if current, ok := requests[container][name]; !ok || recommended.Cmp(current) > 0 {
+				requests[container][name] = recommended
+			}
+		}
+	}
+
+	result := make([]zv1.ContainerResourceRecommendation, 0, len(requests))
+	for container, resources := range requests {
+		result = append(result, zv1.ContainerResourceRecommendation{
+			ContainerName: container,
+			Requests:      resources,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ContainerName < result[j].ContainerName
+	})
+	return result
+}
+
+// withHeadroom adds the recommendation head-room to the observed usage of a
+// resource.
+func withHeadroom(name v1.ResourceName, usage resource.Quantity) resource.Quantity {
+	return scaleQuantity(name, usage, float64(100+resourceRecommendationHeadroom)/100)
+}
+
+// scaleQuantity multiplies the quantity of a resource by a factor. CPU is
+// rounded up to full millicores, everything else to full units.
+func scaleQuantity(name v1.ResourceName, quantity resource.Quantity, factor float64) resource.Quantity {
+	if name == v1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Ceil(float64(quantity.MilliValue())*factor)), resource.DecimalSI)
+	}
+	return *resource.NewQuantity(int64(math.Ceil(float64(quantity.Value())*factor)), quantity.Format)
+}
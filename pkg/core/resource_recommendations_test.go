@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type fakeResourceUsage map[string]map[string]v1.ResourceList
+
+func (f fakeResourceUsage) ContainerResourceUsage(namespace, selector string) (map[string]v1.ResourceList, error) {
+	usage, ok := f[selector]
+	if !ok {
+		return nil, fmt.Errorf("no metrics for %s", selector)
+	}
+	return usage, nil
+}
+
+func requireRecommendations(t *testing.T, expected, actual []zv1.ContainerResourceRecommendation) {
+	require.Len(t, actual, len(expected))
+	for i, recommendation := range expected {
+		require.Equal(t, recommendation.ContainerName, actual[i].ContainerName)
+		require.Len(t, actual[i].Requests, len(recommendation.Requests))
+		for name, quantity := range recommendation.Requests {
+			actualQuantity := actual[i].Requests[name]
+			require.Zero(t, quantity.Cmp(actualQuantity), "container %s, resource %s: %s", recommendation.ContainerName, name, actualQuantity.String())
+		}
+	}
+}
+
+func TestRecommendResources(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		previous []zv1.ContainerResourceRecommendation
+		usage    map[string]v1.ResourceList
+		decay    float64
+		expected []zv1.ContainerResourceRecommendation
+	}{
+		{
+			name:  "head-room is added to the usage",
+			decay: 1,
+			usage: map[string]v1.ResourceList{
+				"sidecar": {v1.ResourceCPU: resource.MustParse("10m")},
+				"app": {
+					v1.ResourceCPU:    resource.MustParse("500m"),
+					v1.ResourceMemory: resource.MustParse("100M"),
+				},
+			},
+			expected: []zv1.ContainerResourceRecommendation{
+				{ContainerName: "app", Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("600m"),
+					v1.ResourceMemory: resource.MustParse("120M"),
+				}},
+				{ContainerName: "sidecar", Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("12m"),
+				}},
+			},
+		},
+		{
+			name:  "the peak usage is kept",
+			decay: 1,
+			previous: []zv1.ContainerResourceRecommendation{
+				{ContainerName: "app", Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1200m"),
+					v1.ResourceMemory: resource.MustParse("60M"),
+				}},
+			},
+			usage: map[string]v1.ResourceList{
+				"app": {
+					v1.ResourceCPU:    resource.MustParse("500m"),
+					v1.ResourceMemory: resource.MustParse("100M"),
+				},
+			},
+			expected: []zv1.ContainerResourceRecommendation{
+				{ContainerName: "app", Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1200m"),
+					v1.ResourceMemory: resource.MustParse("120M"),
+				}},
+			},
+		},
+		{
+			name:  "the peak usage decays",
+			decay: 0.5,
+			previous: []zv1.ContainerResourceRecommendation{
+				{ContainerName: "app", Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("1200m"),
+					v1.ResourceMemory: resource.MustParse("400M"),
+				}},
+			},
+			usage: map[string]v1.ResourceList{
+				"app": {
+					v1.ResourceCPU:    resource.MustParse("500m"),
+					v1.ResourceMemory: resource.MustParse("100M"),
+				},
+			},
+			expected: []zv1.ContainerResourceRecommendation{
+				{ContainerName: "app", Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("600m"),
+					v1.ResourceMemory: resource.MustParse("200M"),
+				}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requireRecommendations(t, tc.expected, recommendResources(tc.previous, tc.usage, tc.decay))
+		})
+	}
+}
+
+func TestUpdateResourceRecommendations(t *testing.T) {
+	withDeployment := func(f *testStackFactory, app string) *StackContainer {
+		sc := f.stack()
+		sc.Resources.Deployment = &apps.Deployment{
+			Spec: apps.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"application": app}},
+			},
+		}
+		return sc
+	}
+
+	usage := fakeResourceUsage{
+		"application=foo": {"app": {v1.ResourceCPU: resource.MustParse("100m")}},
+	}
+	now := time.Now()
+
+	c := &StackSetContainer{
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": withDeployment(testStack("foo-v1").ready(3), "foo"),
+			"v2": withDeployment(testStack("foo-v2").ready(0), "bar"),
+			"v3": testStack("foo-v3").ready(3).stack(),
+		},
+		ResourceUsage: usage,
+	}
+	require.NoError(t, c.UpdateResourceRecommendations(now))
+	requireRecommendations(t, []zv1.ContainerResourceRecommendation{
+		{ContainerName: "app", Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("120m")}},
+	}, c.StackContainers["v1"].resourceRecommendations)
+	require.Equal(t, now, c.StackContainers["v1"].resourceRecommendationsUpdateTime)
+	require.Empty(t, c.StackContainers["v2"].resourceRecommendations)
+	require.Empty(t, c.StackContainers["v3"].resourceRecommendations)
+
+	// the usage isn't queried again within the interval
+	usage["application=foo"] = map[string]v1.ResourceList{"app": {v1.ResourceCPU: resource.MustParse("500m")}}
+	require.NoError(t, c.UpdateResourceRecommendations(now.Add(time.Minute)))
+	requireRecommendations(t, []zv1.ContainerResourceRecommendation{
+		{ContainerName: "app", Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("120m")}},
+	}, c.StackContainers["v1"].resourceRecommendations)
+
+	// the usage is queried again after the interval
+	later := now.Add(resourceRecommendationInterval)
+	require.NoError(t, c.UpdateResourceRecommendations(later))
+	requireRecommendations(t, []zv1.ContainerResourceRecommendation{
+		{ContainerName: "app", Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("600m")}},
+	}, c.StackContainers["v1"].resourceRecommendations)
+
+	// failures are reported, the previous recommendations are kept
+	c.StackContainers["v2"].readyReplicas = 1
+	require.Error(t, c.UpdateResourceRecommendations(later.Add(resourceRecommendationInterval)))
+	require.Len(t, c.StackContainers["v1"].resourceRecommendations, 1)
+
+	// nothing is done without a provider
+	c.ResourceUsage = nil
+	require.NoError(t, c.UpdateResourceRecommendations(later))
+}
@@ -80,6 +80,18 @@ func (sc *StackContainer) resourceMeta() metav1.ObjectMeta {
 	}
 }
 
+// AdoptResource takes over a resource created outside of the controller by
+// setting the owner reference and the generation annotation of the stack.
+// The resource is considered up to date until the stack is changed.
+func (sc *StackContainer) AdoptResource(resourceMeta *metav1.ObjectMeta) {
+	generated := sc.resourceMeta()
+	resourceMeta.OwnerReferences = generated.OwnerReferences
+	if resourceMeta.Annotations == nil {
+		resourceMeta.Annotations = map[string]string{}
+	}
+	resourceMeta.Annotations[stackGenerationAnnotationKey] = generated.Annotations[stackGenerationAnnotationKey]
+}
+
 // getServicePorts gets the service ports to be used for the stack service.
 func getServicePorts(stackSpec zv1.StackSpec, backendPort *intstr.IntOrString) ([]v1.ServicePort, error) {
 	var servicePorts []v1.ServicePort
@@ -150,10 +162,20 @@ func (sc *StackContainer) GenerateDeployment() *appsv1.Deployment {
 		if sc.deploymentReplicas == 0 || (!sc.IsAutoscaled() && desiredReplicas != sc.deploymentReplicas) {
 			updatedReplicas = wrapReplicas(desiredReplicas)
 		}
+	} else if desiredReplicas != 0 && sc.scaleDownBehavior == zv1.ScaleDownBehaviorDeleteDeployment {
+		// Stack scaled down because it doesn't receive traffic, the deployment is recreated when needed
+		return nil
 	} else {
 		// Stack scaled down (manually or because it doesn't receive traffic), check if we need to scale down the deployment
-		if sc.deploymentReplicas != 0 {
-			updatedReplicas = wrapReplicas(0)
+		scaledDownReplicas := int32(0)
+		if sc.scaleDownBehavior == zv1.ScaleDownBehaviorKeepWarm {
+			scaledDownReplicas = sc.warmReplicas
+			if desiredReplicas < scaledDownReplicas {
+				scaledDownReplicas = desiredReplicas
+			}
+		}
+		if sc.deploymentReplicas != scaledDownReplicas {
+			updatedReplicas = wrapReplicas(scaledDownReplicas)
 		}
 	}
 
@@ -173,7 +195,13 @@ func (sc *StackContainer) GenerateHPA() (*autoscaling.HorizontalPodAutoscaler, e
 	autoscalerSpec := sc.Stack.Spec.Autoscaler
 	hpaSpec := sc.Stack.Spec.HorizontalPodAutoscaler
 
-	if autoscalerSpec == nil && hpaSpec == nil {
+	if autoscalerSpec == nil && hpaSpec == nil || sc.autoscalerDisabled() {
+		return nil, nil
+	}
+
+	// The HPA is removed from stacks which are kept warm or whose
+	// deployment is deleted while they're scaled down
+	if sc.ScaledDown() && sc.scaleDownBehavior != "" && sc.scaleDownBehavior != zv1.ScaleDownBehaviorScaleToZero {
 		return nil, nil
 	}
 
@@ -193,25 +221,80 @@ func (sc *StackContainer) GenerateHPA() (*autoscaling.HorizontalPodAutoscaler, e
 	}
 
 	if autoscalerSpec != nil {
+		// Validate all the metrics first, so that errors name the
+		// metrics by their position in the spec
+		err := sc.validateAutoscaler()
+		if err != nil {
+			return nil, err
+		}
+
 		result.Spec.MinReplicas = autoscalerSpec.MinReplicas
 		result.Spec.MaxReplicas = autoscalerSpec.MaxReplicas
 
-		metrics, annotations, err := convertCustomMetrics(sc.stacksetName, sc.Name(), autoscalerSpec.Metrics)
+		autoscalerMetrics, err := sc.autoscalerMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		// Advisory metrics must not block scaling during a traffic switch
+		if sc.trafficSwitching && len(primaryMetrics(autoscalerMetrics)) > 0 {
+			autoscalerMetrics = primaryMetrics(autoscalerMetrics)
+		}
+
+		metrics, annotations, err := convertCustomMetrics(sc.stacksetName, sc.Name(), autoscalerMetrics)
 		if err != nil {
 			return nil, err
 		}
 		result.Spec.Metrics = metrics
 		result.Annotations = mergeLabels(result.Annotations, annotations)
+		if sc.autoscalerProfile != "" {
+			result.Annotations[AutoscalerProfileAnnotationKey] = sc.autoscalerProfile
+		}
 	} else {
 		result.Spec.MinReplicas = hpaSpec.MinReplicas
 		result.Spec.MaxReplicas = hpaSpec.MaxReplicas
 		result.Spec.Metrics = hpaSpec.Metrics
 	}
 
+	// If autoscaling is aggregated, the stack gets the replicas assigned to it
+	if sc.aggregatedReplicas > 0 {
+		replicas := sc.aggregatedReplicas
+		if replicas > result.Spec.MaxReplicas {
+			replicas = result.Spec.MaxReplicas
+		}
+		result.Spec.MinReplicas = &replicas
+	}
+
+	// If a traffic spike is forecasted, raise the lower bound ahead of it
+	if sc.forecastMinReplicas > 0 && (result.Spec.MinReplicas == nil || *result.Spec.MinReplicas < sc.forecastMinReplicas) {
+		forecastReplicas := sc.forecastMinReplicas
+		if forecastReplicas > result.Spec.MaxReplicas {
+			forecastReplicas = result.Spec.MaxReplicas
+		}
+		result.Spec.MinReplicas = &forecastReplicas
+	}
+
+	// An aggregated autoscaler is pinned, it only follows the assigned replicas
+	if sc.aggregatedReplicas > 0 {
+		result.Spec.MaxReplicas = *result.Spec.MinReplicas
+	}
+
+	// If the autoscaler is frozen or held by the tolerance and CPU
+	// initialization period overrides, pin it to the current number of
+	// replicas
+	if (sc.autoscalerFrozen || sc.autoscalerHeld) && sc.deploymentReplicas > 0 {
+		replicas := sc.deploymentReplicas
+		result.Spec.MinReplicas = &replicas
+		result.Spec.MaxReplicas = replicas
+	}
+
 	// If prescaling is enabled, ensure we have at least `precalingReplicas` pods
 	if sc.prescalingActive && (result.Spec.MinReplicas == nil || *result.Spec.MinReplicas < sc.prescalingReplicas) {
 		pr := sc.prescalingReplicas
 		result.Spec.MinReplicas = &pr
+		if result.Spec.MaxReplicas < pr {
+			result.Spec.MaxReplicas = pr
+		}
 	}
 
 	return result, nil
@@ -294,16 +377,29 @@ func (sc *StackContainer) GenerateStackStatus() *zv1.StackStatus {
 			Replicas:             sc.prescalingReplicas,
 			DesiredTrafficWeight: sc.prescalingDesiredTrafficWeight,
 			LastTrafficIncrease:  wrapTime(sc.prescalingLastTrafficIncrease),
+			ReadySince:           wrapTime(sc.prescalingReadySince),
+			Phase:                sc.prescalingPhase,
+			ReadyReplicas:        sc.readyReplicas,
+			StartTime:            wrapTime(sc.prescalingStartTime),
+			CompletionTime:       wrapTime(sc.prescalingCompletionTime),
 		}
 	}
 	return &zv1.StackStatus{
-		ActualTrafficWeight:  sc.actualTrafficWeight,
-		DesiredTrafficWeight: sc.desiredTrafficWeight,
-		Replicas:             sc.createdReplicas,
-		ReadyReplicas:        sc.readyReplicas,
-		UpdatedReplicas:      sc.updatedReplicas,
-		DesiredReplicas:      sc.desiredReplicas,
-		Prescaling:           prescaling,
-		NoTrafficSince:       wrapTime(sc.noTrafficSince),
+		ActualTrafficWeight:               sc.actualTrafficWeight,
+		DesiredTrafficWeight:              sc.desiredTrafficWeight,
+		Replicas:                          sc.createdReplicas,
+		ReadyReplicas:                     sc.readyReplicas,
+		UpdatedReplicas:                   sc.updatedReplicas,
+		DesiredReplicas:                   sc.desiredReplicas,
+		Prescaling:                        prescaling,
+		NoTrafficSince:                    wrapTime(sc.noTrafficSince),
+		AutoscalerFrozenSince:             wrapTime(sc.autoscalerFrozenSince),
+		DrainingSince:                     wrapTime(sc.drainingSince),
+		DrainingTrafficWeight:             sc.drainingTrafficWeight,
+		Autoscaler:                        sc.autoscalerStatus,
+		ResourceRecommendations:           sc.resourceRecommendations,
+		ResourceRecommendationsUpdateTime: wrapTime(sc.resourceRecommendationsUpdateTime),
+		PreDeleteHook:                     sc.preDeleteHookStatus,
+		Conditions:                        sc.conditions,
 	}
 }
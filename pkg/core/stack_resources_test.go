@@ -1,6 +1,7 @@
 package core
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -379,15 +380,33 @@ func TestStackGenerateService(t *testing.T) {
 	require.Equal(t, expected, service)
 }
 
+func TestStackGenerateDeploymentDeleted(t *testing.T) {
+	c := testStack("foo-v1").stack()
+	c.stackReplicas = 3
+	c.deploymentReplicas = 3
+	c.noTrafficSince = time.Now().Add(-time.Hour)
+	c.scaledownTTL = time.Minute
+	c.scaleDownBehavior = zv1.ScaleDownBehaviorDeleteDeployment
+	require.Nil(t, c.GenerateDeployment())
+
+	// the deployment is recreated once the stack gets traffic again
+	c.desiredTrafficWeight = 50
+	require.NotNil(t, c.GenerateDeployment())
+}
+
 func TestStackGenerateDeployment(t *testing.T) {
 	for _, tc := range []struct {
 		name               string
 		hpaEnabled         bool
+		hpaDisabled        bool
 		stackReplicas      int32
 		prescalingActive   bool
 		prescalingReplicas int32
 		deploymentReplicas int32
 		noTrafficSince     time.Time
+		pinned             bool
+		scaleDownBehavior  zv1.ScaleDownBehavior
+		warmReplicas       int32
 		expectedReplicas   *int32
 	}{
 		{
@@ -416,6 +435,40 @@ func TestStackGenerateDeployment(t *testing.T) {
 			noTrafficSince:     time.Now().Add(-time.Hour),
 			expectedReplicas:   nil,
 		},
+		{
+			name:               "pinned stack isn't scaled down without traffic",
+			stackReplicas:      3,
+			deploymentReplicas: 3,
+			noTrafficSince:     time.Now().Add(-time.Hour),
+			pinned:             true,
+			expectedReplicas:   nil,
+		},
+		{
+			name:               "stack kept warm because it doesn't have traffic, deployment still running",
+			stackReplicas:      3,
+			deploymentReplicas: 3,
+			noTrafficSince:     time.Now().Add(-time.Hour),
+			scaleDownBehavior:  zv1.ScaleDownBehaviorKeepWarm,
+			warmReplicas:       1,
+			expectedReplicas:   wrapReplicas(1),
+		},
+		{
+			name:               "stack kept warm because it doesn't have traffic, deployment already scaled down",
+			stackReplicas:      3,
+			deploymentReplicas: 1,
+			noTrafficSince:     time.Now().Add(-time.Hour),
+			scaleDownBehavior:  zv1.ScaleDownBehaviorKeepWarm,
+			warmReplicas:       1,
+			expectedReplicas:   nil,
+		},
+		{
+			name:               "stack scaled down to zero and kept warm, deployment still running",
+			stackReplicas:      0,
+			deploymentReplicas: 1,
+			scaleDownBehavior:  zv1.ScaleDownBehaviorKeepWarm,
+			warmReplicas:       1,
+			expectedReplicas:   wrapReplicas(0),
+		},
 		{
 			name:               "stack scaled down to zero, deployment already scaled down",
 			stackReplicas:      0,
@@ -454,6 +507,14 @@ func TestStackGenerateDeployment(t *testing.T) {
 			deploymentReplicas: 5,
 			expectedReplicas:   nil,
 		},
+		{
+			name:               "stack running, deployment has a different amount of replicas, hpa disabled with an annotation",
+			hpaEnabled:         true,
+			hpaDisabled:        true,
+			stackReplicas:      3,
+			deploymentReplicas: 5,
+			expectedReplicas:   wrapReplicas(3),
+		},
 		{
 			name:               "stack running, deployment has zero replicas, prescaling enabled",
 			stackReplicas:      3,
@@ -525,10 +586,16 @@ func TestStackGenerateDeployment(t *testing.T) {
 				deploymentReplicas: tc.deploymentReplicas,
 				noTrafficSince:     tc.noTrafficSince,
 				scaledownTTL:       time.Minute,
+				pinned:             tc.pinned,
+				scaleDownBehavior:  tc.scaleDownBehavior,
+				warmReplicas:       tc.warmReplicas,
 			}
 			if tc.hpaEnabled {
 				c.Stack.Spec.HorizontalPodAutoscaler = &zv1.HorizontalPodAutoscaler{}
 			}
+			if tc.hpaDisabled {
+				c.Stack.Annotations = map[string]string{DisableAutoscalerAnnotationKey: "yes"}
+			}
 			deployment := c.GenerateDeployment()
 			expected := &apps.Deployment{
 				ObjectMeta: testResourceMeta,
@@ -577,6 +644,8 @@ func TestGenerateStackStatus(t *testing.T) {
 		prescalingReplicas             int32
 		prescalingDesiredTrafficWeight float64
 		prescalingLastTrafficIncrease  time.Time
+		autoscalerFrozenSince          time.Time
+		autoscalerStatus               *zv1.AutoscalerStatus
 	}{
 		{
 			name:                 "with traffic",
@@ -596,6 +665,22 @@ func TestGenerateStackStatus(t *testing.T) {
 			prescalingDesiredTrafficWeight: 22.75,
 			prescalingLastTrafficIncrease:  hourAgo,
 		},
+		{
+			name:                  "autoscaler frozen",
+			actualTrafficWeight:   0.25,
+			desiredTrafficWeight:  0.75,
+			autoscalerFrozenSince: hourAgo,
+		},
+		{
+			name:                 "autoscaled",
+			actualTrafficWeight:  0.25,
+			desiredTrafficWeight: 0.75,
+			autoscalerStatus: &zv1.AutoscalerStatus{
+				CurrentReplicas: 3,
+				DesiredReplicas: 4,
+				LastScaleTime:   &metav1.Time{Time: hourAgo},
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			c := &StackContainer{
@@ -610,16 +695,20 @@ func TestGenerateStackStatus(t *testing.T) {
 				prescalingReplicas:             tc.prescalingReplicas,
 				prescalingDesiredTrafficWeight: tc.prescalingDesiredTrafficWeight,
 				prescalingLastTrafficIncrease:  tc.prescalingLastTrafficIncrease,
+				autoscalerFrozenSince:          tc.autoscalerFrozenSince,
+				autoscalerStatus:               tc.autoscalerStatus,
 			}
 			status := c.GenerateStackStatus()
 			expected := &zv1.StackStatus{
-				ActualTrafficWeight:  tc.actualTrafficWeight,
-				DesiredTrafficWeight: tc.desiredTrafficWeight,
-				Replicas:             3,
-				ReadyReplicas:        2,
-				UpdatedReplicas:      1,
-				DesiredReplicas:      4,
-				NoTrafficSince:       wrapTime(tc.noTrafficSince),
+				ActualTrafficWeight:   tc.actualTrafficWeight,
+				DesiredTrafficWeight:  tc.desiredTrafficWeight,
+				Replicas:              3,
+				ReadyReplicas:         2,
+				UpdatedReplicas:       1,
+				DesiredReplicas:       4,
+				NoTrafficSince:        wrapTime(tc.noTrafficSince),
+				AutoscalerFrozenSince: wrapTime(tc.autoscalerFrozenSince),
+				Autoscaler:            tc.autoscalerStatus,
 				Prescaling: zv1.PrescalingStatus{
 					Active:               tc.prescalingActive,
 					Replicas:             tc.prescalingReplicas,
@@ -627,7 +716,48 @@ func TestGenerateStackStatus(t *testing.T) {
 					LastTrafficIncrease:  wrapTime(tc.prescalingLastTrafficIncrease),
 				},
 			}
+			if tc.prescalingActive {
+				expected.Prescaling.ReadyReplicas = 2
+			}
 			require.Equal(t, expected, status)
 		})
 	}
 }
+
+func TestStackStateRestoredFromStatus(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	running := testStack("foo-v2").traffic(50, 0).
+		prescaling(3, 50, now.Add(-time.Minute)).
+		prescalingReadySince(now.Add(-30*time.Second)).
+		prescalingStartTime(now.Add(-2*time.Minute)).
+		noTrafficSince(now.Add(-time.Hour)).
+		autoscalerFrozenSince(now.Add(-time.Minute)).
+		draining(now.Add(-time.Minute), 50).
+		stack()
+	running.prescalingPhase = zv1.PrescalingPhaseWaiting
+
+	// a restarted controller only knows the status persisted in the API
+	data, err := json.Marshal(running.GenerateStackStatus())
+	require.NoError(t, err)
+	restored := &StackContainer{Stack: &zv1.Stack{ObjectMeta: running.Stack.ObjectMeta}}
+	err = json.Unmarshal(data, &restored.Stack.Status)
+	require.NoError(t, err)
+	restored.updateFromResources()
+
+	require.True(t, restored.prescalingActive)
+	require.EqualValues(t, 3, restored.prescalingReplicas)
+	require.EqualValues(t, 50, restored.prescalingDesiredTrafficWeight)
+	require.Equal(t, zv1.PrescalingPhaseWaiting, restored.prescalingPhase)
+	require.EqualValues(t, 50, restored.drainingTrafficWeight)
+	for _, times := range [][2]time.Time{
+		{running.prescalingLastTrafficIncrease, restored.prescalingLastTrafficIncrease},
+		{running.prescalingReadySince, restored.prescalingReadySince},
+		{running.prescalingStartTime, restored.prescalingStartTime},
+		{running.noTrafficSince, restored.noTrafficSince},
+		{running.autoscalerFrozenSince, restored.autoscalerFrozenSince},
+		{running.drainingSince, restored.drainingSince},
+	} {
+		require.True(t, times[0].Equal(times[1]), "expected %s, got %s", times[0], times[1])
+	}
+}
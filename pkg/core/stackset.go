@@ -3,7 +3,9 @@ package core
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
+	"time"
 
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -57,8 +59,8 @@ func (ssc *StackSetContainer) NewStack() (*StackContainer, string) {
 	stack := ssc.stackByName(stackName)
 
 	// If the current stack doesn't exist, check that we haven't created it before. We shouldn't recreate
-	// it if it was removed for any reason.
-	if stack == nil && observedStackVersion != stackVersion {
+	// it if it was removed for any reason, unless explicitly enabled.
+	if stack == nil && (observedStackVersion != stackVersion || stackset.Spec.RecreateDeletedStacks) {
 		var service *zv1.StackServiceSpec
 		if stackset.Spec.StackTemplate.Spec.Service != nil {
 			service = sanitizeServicePorts(stackset.Spec.StackTemplate.Spec.Service)
@@ -82,6 +84,7 @@ func (ssc *StackSetContainer) NewStack() (*StackContainer, string) {
 						stackset.Labels,
 						map[string]string{StackVersionLabelKey: stackVersion}),
 					Annotations: stackset.Spec.StackTemplate.Annotations,
+					Finalizers:  []string{StackTrafficFinalizer, StackTeardownFinalizer},
 				},
 				Spec: zv1.StackSpec{
 					Replicas:                stackset.Spec.StackTemplate.Spec.Replicas,
@@ -89,6 +92,7 @@ func (ssc *StackSetContainer) NewStack() (*StackContainer, string) {
 					Service:                 service,
 					PodTemplate:             stackset.Spec.StackTemplate.Spec.PodTemplate,
 					Autoscaler:              stackset.Spec.StackTemplate.Spec.Autoscaler,
+					MaxTrafficWeight:        stackset.Spec.StackTemplate.Spec.MaxTrafficWeight,
 				},
 			},
 		}, stackVersion
@@ -97,37 +101,305 @@ func (ssc *StackSetContainer) NewStack() (*StackContainer, string) {
 	return nil, ""
 }
 
-// MarkExpiredStacks marks stacks that should be deleted
-func (ssc *StackSetContainer) MarkExpiredStacks() {
-	historyLimit := defaultStackLifecycleLimit
-	if ssc.StackSet.Spec.StackLifecycle.Limit != nil {
-		historyLimit = int(*ssc.StackSet.Spec.StackLifecycle.Limit)
+// AdoptStack takes over a stack created outside of the controller by setting
+// the owner reference of the stackset.
+func (ssc *StackSetContainer) AdoptStack(stack *zv1.Stack) {
+	stackset := ssc.StackSet
+	stack.OwnerReferences = []metav1.OwnerReference{
+		{
+			APIVersion: stackset.APIVersion,
+			Kind:       stackset.Kind,
+			Name:       stackset.Name,
+			UID:        stackset.UID,
+		},
+	}
+}
+
+// MarkExpiredStacks marks stacks that should be deleted, i.e. the oldest
+// stacks exceeding the history limit and the stacks older than the max age.
+// The minimum number of most recent stacks is always kept, even if it
+// exceeds the history limit.
+func (ssc *StackSetContainer) MarkExpiredStacks(currentTimestamp time.Time) {
+	lifecycle := ssc.StackSet.Spec.StackLifecycle
+
+	historyLimit := DefaultStackLifecycleLimit
+	if lifecycle.Limit != nil {
+		historyLimit = int(*lifecycle.Limit)
+	}
+
+	minStacks := 0
+	if lifecycle.MinStacks != nil {
+		minStacks = int(*lifecycle.MinStacks)
 	}
 
 	gcCandidates := make([]*StackContainer, 0, len(ssc.StackContainers))
 
 	for _, sc := range ssc.StackContainers {
+		// Protected stacks are never cleaned up
+		if sc.gcProtected(lifecycle.ProtectedVersions) {
+			continue
+		}
+
+		// Stacks past their expiry time are deleted regardless of the limit
+		if expiresAt, ok := sc.expiryTime(); ok && !currentTimestamp.Before(expiresAt) {
+			sc.PendingRemoval = true
+			sc.removalReason = fmt.Sprintf("expired at %s (rule: expiry annotation)", expiresAt.Format(time.RFC3339))
+			continue
+		}
+
 		// Stacks are considered for cleanup if we don't have an ingress or if the stack is scaled down because of inactivity
 		if sc.ingressSpec == nil || sc.ScaledDown() {
 			gcCandidates = append(gcCandidates, sc)
 		}
 	}
 
-	// only garbage collect if history limit is reached
-	if len(gcCandidates) <= historyLimit {
-		return
-	}
-
-	// sort candidates by oldest
+	// sort candidates by oldest, or by the longest time without traffic if configured
 	sort.Slice(gcCandidates, func(i, j int) bool {
-		// TODO: maybe we use noTrafficSince instead of CreationTimeStamp to decide oldest
+		if lifecycle.Ordering == zv1.StackLifecycleOrderingNoTrafficSince {
+			iSince, jSince := gcCandidates[i].lastTrafficTime(), gcCandidates[j].lastTrafficTime()
+			if !iSince.Equal(jSince) {
+				return iSince.Before(jSince)
+			}
+		}
 		return gcCandidates[i].Stack.CreationTimestamp.Time.Before(gcCandidates[j].Stack.CreationTimestamp.Time)
 	})
 
+	// garbage collect the stacks exceeding the history limit and the
+	// stacks exceeding the max age as long as the minimum is kept
 	excessStacks := len(gcCandidates) - historyLimit
-	for _, sc := range gcCandidates[:excessStacks] {
-		sc.PendingRemoval = true
+	remaining := len(gcCandidates)
+	for i, sc := range gcCandidates {
+		if remaining <= minStacks {
+			break
+		}
+		switch {
+		case i < excessStacks:
+			sc.PendingRemoval = true
+			sc.removalReason = sc.gcReason(fmt.Sprintf("over the limit of %d stacks, the first %d in %s order are removed (rule: limit)", historyLimit, excessStacks, gcOrdering(lifecycle.Ordering)))
+			remaining--
+		case sc.expired(lifecycle.MaxAge, currentTimestamp):
+			sc.PendingRemoval = true
+			sc.removalReason = sc.gcReason(fmt.Sprintf("older than the maximum age of %s (rule: maxAge)", lifecycle.MaxAge.Duration))
+			remaining--
+		}
+	}
+}
+
+// MarkFailedStacks marks stacks which didn't become ready within the
+// readiness deadline as failed. Failed stacks don't get any traffic and are
+// deleted if enabled. A stack which was ready once is never marked as failed.
+func (ssc *StackSetContainer) MarkFailedStacks(currentTimestamp time.Time) {
+	lifecycle := ssc.StackSet.Spec.StackLifecycle
+
+	for _, sc := range ssc.StackContainers {
+		sc.failed = false
+
+		if lifecycle.ReadinessDeadline == nil || lifecycle.ReadinessDeadline.Duration <= 0 {
+			sc.conditions = removeStackCondition(sc.conditions, zv1.StackConditionFailed)
+			continue
+		}
+		deadline := lifecycle.ReadinessDeadline.Duration
+
+		if sc.IsReady() {
+			sc.conditions = setStackCondition(sc.conditions, failedCondition(false, deadline))
+			continue
+		}
+
+		// stacks which were ready before keep the condition
+		if existing := getStackCondition(sc.conditions, zv1.StackConditionFailed); existing != nil && existing.Status == corev1.ConditionFalse {
+			continue
+		}
+
+		if currentTimestamp.Sub(sc.Stack.CreationTimestamp.Time) > deadline {
+			sc.failed = true
+			sc.conditions = setStackCondition(sc.conditions, failedCondition(true, deadline))
+			if lifecycle.DeleteFailedStacks && sc.actualTrafficWeight == 0 {
+				sc.PendingRemoval = true
+				sc.removalReason = fmt.Sprintf("didn't become ready within %s (rule: deleteFailedStacks)", deadline)
+			}
+		}
+	}
+}
+
+// ApplyRemovalGracePeriod keeps the stacks marked for removal until the
+// removal grace period is over. All the stacks marked for removal, including
+// the ones whose deletion is still blocked, e.g. by a finalizer, are reported
+// in the status of the stackset with the time since when they're pending, so
+// that cleanup backlogs are visible. Returns the stacks which were newly
+// scheduled for removal within the grace period.
+func (ssc *StackSetContainer) ApplyRemovalGracePeriod(currentTimestamp time.Time) []*StackContainer {
+	gracePeriod := ssc.StackSet.Spec.StackLifecycle.RemovalGracePeriod
+	graceful := gracePeriod != nil && gracePeriod.Duration > 0
+
+	since := make(map[string]metav1.Time, len(ssc.pendingRemoval))
+	for _, pending := range ssc.pendingRemoval {
+		since[pending.Name] = pending.Since
+	}
+
+	var scheduled []*StackContainer
+	var pendingRemoval []zv1.PendingStackRemoval
+	for _, sc := range ssc.StackContainers {
+		pendingSince, known := since[sc.Name()]
+		switch {
+		case sc.Stack.DeletionTimestamp != nil:
+			// the stack is being deleted but its teardown didn't finish
+			if !known {
+				pendingSince = *sc.Stack.DeletionTimestamp
+			}
+		case !sc.PendingRemoval:
+			continue
+		case !known:
+			pendingSince = metav1.Time{Time: currentTimestamp}
+			// draining stacks were scheduled before already
+			if graceful && !sc.Draining() {
+				scheduled = append(scheduled, sc)
+			}
+		}
+		pendingRemoval = append(pendingRemoval, zv1.PendingStackRemoval{
+			Name:  sc.Name(),
+			Since: pendingSince,
+		})
+
+		if graceful && sc.PendingRemoval && !sc.Draining() && currentTimestamp.Sub(pendingSince.Time) < gracePeriod.Duration {
+			sc.PendingRemoval = false
+		}
+	}
+
+	// sort by name to have a consistent status
+	sort.Slice(pendingRemoval, func(i, j int) bool {
+		return pendingRemoval[i].Name < pendingRemoval[j].Name
+	})
+	ssc.pendingRemoval = pendingRemoval
+
+	return scheduled
+}
+
+// PendingRemoval returns the stacks pending removal and since when they're
+// pending, as of the last call of ApplyRemovalGracePeriod.
+func (ssc *StackSetContainer) PendingRemoval() []zv1.PendingStackRemoval {
+	return ssc.pendingRemoval
+}
+
+// DrainStacks starts draining the traffic of the stacks which should be
+// deleted but are still getting traffic. Stacks whose desired traffic is
+// raised, e.g. by a rollback, aren't drained and not deleted. The draining
+// is stopped if the stack shouldn't be deleted anymore or if its desired
+// traffic is raised above the traffic it had when the draining started.
+func (ssc *StackSetContainer) DrainStacks(currentTimestamp time.Time) {
+	drainDuration := ssc.StackSet.Spec.StackLifecycle.DrainDuration
+
+	for _, sc := range ssc.StackContainers {
+		if drainDuration == nil || drainDuration.Duration <= 0 {
+			sc.drainingSince = time.Time{}
+			sc.drainingTrafficWeight = 0
+			continue
+		}
+
+		if sc.Draining() {
+			if sc.PendingRemoval && sc.desiredTrafficWeight <= sc.drainingTrafficWeight {
+				continue
+			}
+			sc.drainingSince = time.Time{}
+			sc.drainingTrafficWeight = 0
+			sc.PendingRemoval = false
+			sc.removalReason = ""
+			continue
+		}
+
+		if !sc.PendingRemoval || sc.actualTrafficWeight == 0 {
+			continue
+		}
+		if sc.desiredTrafficWeight > sc.actualTrafficWeight {
+			sc.PendingRemoval = false
+			sc.removalReason = ""
+			continue
+		}
+		sc.drainingSince = currentTimestamp
+		sc.drainingTrafficWeight = sc.actualTrafficWeight
+	}
+}
+
+// drainedTrafficWeight returns the maximum traffic weight of a stack which
+// is being drained, decreasing linearly to zero over the drain duration.
+func (sc *StackContainer) drainedTrafficWeight(drainDuration time.Duration, currentTimestamp time.Time) float64 {
+	elapsed := currentTimestamp.Sub(sc.drainingSince)
+	if elapsed >= drainDuration {
+		return 0
+	}
+	return sc.drainingTrafficWeight * (1 - float64(elapsed)/float64(drainDuration))
+}
+
+// expired returns true if the stack is older than the max age.
+func (sc *StackContainer) expired(maxAge *metav1.Duration, currentTimestamp time.Time) bool {
+	if maxAge == nil || maxAge.Duration <= 0 {
+		return false
+	}
+	return currentTimestamp.Sub(sc.Stack.CreationTimestamp.Time) > maxAge.Duration
+}
+
+// expiryTime returns the time when the stack expires, either set explicitly
+// with an annotation or derived from its creation time and the TTL annotation.
+// Invalid values are ignored.
+func (sc *StackContainer) expiryTime() (time.Time, bool) {
+	if value, ok := sc.Stack.Annotations[ExpiresAtAnnotationKey]; ok {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			return expiresAt, true
+		}
+	}
+	if value, ok := sc.Stack.Annotations[TTLAnnotationKey]; ok {
+		ttl, err := time.ParseDuration(value)
+		if err == nil && ttl > 0 {
+			return sc.Stack.CreationTimestamp.Add(ttl), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// gcProtected returns true if the stack is protected from being cleaned up,
+// either with an annotation or because its version is protected. Pinned
+// versions are protected as well.
+func (sc *StackContainer) gcProtected(protectedVersions []string) bool {
+	if sc.pinned || sc.Stack.Annotations[GCProtectAnnotationKey] == "true" {
+		return true
 	}
+	version, ok := sc.Stack.Labels[StackVersionLabelKey]
+	if !ok {
+		return false
+	}
+	for _, protected := range protectedVersions {
+		if version == protected {
+			return true
+		}
+	}
+	return false
+}
+
+// lastTrafficTime returns the time since when the stack isn't getting traffic,
+// or its creation time if that's unknown.
+func (sc *StackContainer) lastTrafficTime() time.Time {
+	if sc.noTrafficSince.IsZero() {
+		return sc.Stack.CreationTimestamp.Time
+	}
+	return sc.noTrafficSince
+}
+
+// gcReason completes the description of the rule which selected a stack
+// without traffic for the garbage collection.
+func (sc *StackContainer) gcReason(rule string) string {
+	if sc.noTrafficSince.IsZero() {
+		return rule
+	}
+	return fmt.Sprintf("%s, without traffic since %s", rule, sc.noTrafficSince.Format(time.RFC3339))
+}
+
+// gcOrdering returns the name of the ordering of the garbage collection
+// candidates.
+func gcOrdering(ordering zv1.StackLifecycleOrdering) string {
+	if ordering == "" {
+		return string(zv1.StackLifecycleOrderingCreationTimestamp)
+	}
+	return string(ordering)
 }
 
 func (ssc *StackSetContainer) GenerateIngress() (*extensions.Ingress, error) {
@@ -221,12 +493,63 @@ func (ssc *StackSetContainer) GenerateIngress() (*extensions.Ingress, error) {
 	return result, nil
 }
 
+// trafficWeights returns the non-zero traffic weights of the stacks sorted
+// by their names.
+func (ssc *StackSetContainer) trafficWeights(weight func(sc *StackContainer) float64) []zv1.StackTrafficWeight {
+	var result []zv1.StackTrafficWeight
+	for _, sc := range ssc.StackContainers {
+		if weight(sc) > 0 {
+			result = append(result, zv1.StackTrafficWeight{
+				StackName: sc.Name(),
+				Weight:    weight(sc),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StackName < result[j].StackName
+	})
+	return result
+}
+
+// SetLastChanges records the changes made by the current reconciliation. The
+// changes of a previous reconciliation are kept if nothing changed.
+func (ssc *StackSetContainer) SetLastChanges(changes *zv1.ChangeSummary) {
+	ssc.lastChanges = changes
+}
+
+// LastChanges returns the changes made by the last reconciliation which
+// changed anything.
+func (ssc *StackSetContainer) LastChanges() *zv1.ChangeSummary {
+	if ssc.lastChanges == nil {
+		return ssc.StackSet.Status.LastChanges
+	}
+	return ssc.lastChanges
+}
+
 func (ssc *StackSetContainer) GenerateStackSetStatus() *zv1.StackSetStatus {
 	result := &zv1.StackSetStatus{
 		Stacks:               0,
 		ReadyStacks:          0,
 		StacksWithTraffic:    0,
 		ObservedStackVersion: ssc.StackSet.Status.ObservedStackVersion,
+		PendingRemoval:       ssc.pendingRemoval,
+		TrafficRamp:          ssc.trafficRamp,
+		TrafficRollback:      ssc.trafficRollback,
+		TrafficHistory:       ssc.trafficHistory,
+		TrafficSnapshots:     ssc.trafficSnapshots,
+		LastErrors:           ssc.LastErrors(),
+		LastChanges:          ssc.LastChanges(),
+		Conditions:           ssc.stacksetConditions(),
+		Traffic: ssc.trafficWeights(func(sc *StackContainer) float64 {
+			return sc.actualTrafficWeight
+		}),
+	}
+
+	if ssc.DesiredTrafficInSpec() {
+		result.DesiredTraffic = ssc.trafficWeights(func(sc *StackContainer) float64 {
+			return sc.desiredTrafficWeight
+		})
+		result.ObservedTraffic = ssc.StackSet.Spec.Traffic
 	}
 
 	for _, sc := range ssc.StackContainers {
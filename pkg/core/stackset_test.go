@@ -1,6 +1,8 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"testing"
 	"time"
@@ -23,6 +25,10 @@ func TestExpiredStacks(t *testing.T) {
 		name                string
 		limit               int32
 		scaledownTTLSeconds time.Duration
+		maxAge              time.Duration
+		minStacks           int32
+		ordering            zv1.StackLifecycleOrdering
+		protectedVersions   []string
 		ingress             bool
 		stacks              []*StackContainer
 		expected            map[string]bool
@@ -89,6 +95,147 @@ func TestExpiredStacks(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name:     "test GC stack without traffic for the longest time",
+			limit:    1,
+			ordering: zv1.StackLifecycleOrderingNoTrafficSince,
+			ingress:  true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-50 * time.Minute)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-10 * time.Minute)).stack(),
+			},
+			expected: map[string]bool{"stack1": true},
+		},
+		{
+			name:     "test GC by creation time if no-traffic-since is unknown",
+			limit:    1,
+			ordering: zv1.StackLifecycleOrderingNoTrafficSince,
+			ingress:  false,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack2": true},
+		},
+		{
+			name:    "test don't GC stacks protected with an annotation",
+			limit:   1,
+			maxAge:  90 * time.Minute,
+			ingress: true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).gcProtected().stack(),
+				testStack("stack3").createdAt(now.Add(-3 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:              "test don't GC protected versions",
+			limit:             1,
+			protectedVersions: []string{"v2"},
+			ingress:           true,
+			stacks: []*StackContainer{
+				testStack("stack1").version("v1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").version("v2").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack3").version("v3").createdAt(now.Add(-3 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:    "test don't GC pinned stacks",
+			limit:   1,
+			maxAge:  90 * time.Minute,
+			ingress: true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).pinned().stack(),
+				testStack("stack3").createdAt(now.Add(-3 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:    "test GC stacks older than max age below the limit",
+			limit:   3,
+			maxAge:  90 * time.Minute,
+			ingress: true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack2": true},
+		},
+		{
+			name:      "test keep the minimum of stacks older than max age",
+			limit:     3,
+			maxAge:    90 * time.Minute,
+			minStacks: 2,
+			ingress:   true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-3 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack3").createdAt(now.Add(-4 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:      "test the minimum takes precedence over the limit",
+			limit:     1,
+			maxAge:    90 * time.Minute,
+			minStacks: 2,
+			ingress:   true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-2 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-3 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack3").createdAt(now.Add(-4 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:      "test keep the minimum of stacks without ingress",
+			limit:     1,
+			minStacks: 2,
+			ingress:   false,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2 * time.Hour)).stack(),
+				testStack("stack3").createdAt(now.Add(-3 * time.Hour)).stack(),
+			},
+			expected: map[string]bool{"stack3": true},
+		},
+		{
+			name:    "test don't GC stacks older than max age getting traffic",
+			limit:   3,
+			maxAge:  90 * time.Minute,
+			ingress: true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1 * time.Hour)).noTrafficSince(now.Add(-1 * time.Hour)).stack(),
+				testStack("stack2").createdAt(now.Add(-2*time.Hour)).traffic(1, 1).stack(),
+			},
+			expected: nil,
+		},
+		{
+			name:    "test GC expired stacks regardless of the limit",
+			limit:   3,
+			ingress: true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1*time.Hour)).traffic(1, 1).stack(),
+				testStack("stack2").createdAt(now.Add(-2*time.Hour)).traffic(0, 0).annotations(map[string]string{ExpiresAtAnnotationKey: now.Add(-1 * time.Minute).Format(time.RFC3339)}).stack(),
+				testStack("stack3").createdAt(now.Add(-3*time.Hour)).traffic(0, 0).annotations(map[string]string{TTLAnnotationKey: "2h"}).stack(),
+				testStack("stack4").createdAt(now.Add(-3*time.Hour)).traffic(0, 0).annotations(map[string]string{TTLAnnotationKey: "4h"}).stack(),
+				testStack("stack5").createdAt(now.Add(-3*time.Hour)).traffic(0, 0).annotations(map[string]string{TTLAnnotationKey: "invalid"}).stack(),
+			},
+			expected: map[string]bool{"stack2": true, "stack3": true},
+		},
+		{
+			name:      "test GC expired stacks getting traffic below the minimum",
+			limit:     3,
+			minStacks: 2,
+			ingress:   true,
+			stacks: []*StackContainer{
+				testStack("stack1").createdAt(now.Add(-1*time.Hour)).traffic(1, 1).annotations(map[string]string{TTLAnnotationKey: "30m"}).stack(),
+			},
+			expected: map[string]bool{"stack1": true},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			c := StackSetContainer{
@@ -103,9 +250,17 @@ func TestExpiredStacks(t *testing.T) {
 				StackContainers: map[types.UID]*StackContainer{},
 			}
 			c.StackSet.Spec.StackLifecycle.Limit = &tc.limit
+			c.StackSet.Spec.StackLifecycle.Ordering = tc.ordering
+			c.StackSet.Spec.StackLifecycle.ProtectedVersions = tc.protectedVersions
+			if tc.minStacks != 0 {
+				c.StackSet.Spec.StackLifecycle.MinStacks = &tc.minStacks
+			}
+			if tc.maxAge != 0 {
+				c.StackSet.Spec.StackLifecycle.MaxAge = &metav1.Duration{Duration: tc.maxAge}
+			}
 			for _, stack := range tc.stacks {
 				if tc.scaledownTTLSeconds == 0 {
-					stack.scaledownTTL = defaultScaledownTTL
+					stack.scaledownTTL = DefaultScaledownTTL
 				} else {
 					stack.scaledownTTL = time.Second * tc.scaledownTTLSeconds
 				}
@@ -115,7 +270,7 @@ func TestExpiredStacks(t *testing.T) {
 				c.StackContainers[types.UID(stack.Name())] = stack
 			}
 
-			c.MarkExpiredStacks()
+			c.MarkExpiredStacks(now)
 			for _, stack := range tc.stacks {
 				require.Equal(t, tc.expected[stack.Name()], stack.PendingRemoval, "stack %s", stack.Stack.Name)
 			}
@@ -123,6 +278,317 @@ func TestExpiredStacks(t *testing.T) {
 	}
 }
 
+func TestExpiredStacksRemovalReason(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := int32(1)
+
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				StackLifecycle: zv1.StackLifecycle{
+					Limit:  &limit,
+					MaxAge: &metav1.Duration{Duration: 24 * time.Hour},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").createdAt(now.Add(-48 * time.Hour)).noTrafficSince(now.Add(-2 * time.Hour)).stack(),
+			"v2": testStack("foo-v2").createdAt(now.Add(-30 * time.Hour)).stack(),
+			"v3": testStack("foo-v3").createdAt(now.Add(-time.Hour)).stack(),
+			"v4": testStack("foo-v4").createdAt(now.Add(-time.Hour)).traffic(100, 100).annotations(map[string]string{ExpiresAtAnnotationKey: "2020-01-01T11:00:00Z"}).stack(),
+		},
+	}
+	for _, sc := range c.StackContainers {
+		sc.scaledownTTL = DefaultScaledownTTL
+	}
+
+	c.MarkExpiredStacks(now)
+	require.Equal(t, "over the limit of 1 stacks, the first 2 in CreationTimestamp order are removed (rule: limit), without traffic since 2020-01-01T10:00:00Z", c.StackContainers["v1"].RemovalReason())
+	require.Equal(t, "over the limit of 1 stacks, the first 2 in CreationTimestamp order are removed (rule: limit)", c.StackContainers["v2"].RemovalReason())
+	require.Empty(t, c.StackContainers["v3"].RemovalReason())
+	require.Equal(t, "expired at 2020-01-01T11:00:00Z (rule: expiry annotation)", c.StackContainers["v4"].RemovalReason())
+
+	// stacks older than the maximum age are removed even below the limit
+	limit = 5
+	for _, sc := range c.StackContainers {
+		sc.PendingRemoval = false
+		sc.removalReason = ""
+	}
+	c.MarkExpiredStacks(now)
+	require.Equal(t, "older than the maximum age of 24h0m0s (rule: maxAge), without traffic since 2020-01-01T10:00:00Z", c.StackContainers["v1"].RemovalReason())
+	require.Equal(t, "older than the maximum age of 24h0m0s (rule: maxAge)", c.StackContainers["v2"].RemovalReason())
+	require.Empty(t, c.StackContainers["v3"].RemovalReason())
+}
+
+func TestPinnedAndProtectedVersions(t *testing.T) {
+	versionedStack := func(name, version string) *StackContainer {
+		sc := testStack(name).stack()
+		sc.Stack.Labels = map[string]string{StackVersionLabelKey: version}
+		sc.Stack.Status.NoTrafficSince = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+		return sc
+	}
+
+	limit := int32(0)
+	scaledownTTL := int64(60)
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				PinnedVersions: []string{"v1"},
+				StackLifecycle: zv1.StackLifecycle{
+					Limit:               &limit,
+					ScaledownTTLSeconds: &scaledownTTL,
+					ProtectedVersions:   []string{"v2"},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": versionedStack("foo-v1", "v1"),
+			"v2": versionedStack("foo-v2", "v2"),
+			"v3": versionedStack("foo-v3", "v3"),
+		},
+	}
+	require.NoError(t, c.UpdateFromResources())
+	c.MarkExpiredStacks(time.Now())
+
+	pinned, protected, other := c.StackContainers["v1"], c.StackContainers["v2"], c.StackContainers["v3"]
+
+	// pinned stacks keep running without traffic and are never deleted
+	require.False(t, pinned.ScaledDown())
+	require.False(t, pinned.PendingRemoval)
+
+	// protected stacks are scaled down, but never deleted
+	require.True(t, protected.ScaledDown())
+	require.False(t, protected.PendingRemoval)
+
+	require.True(t, other.ScaledDown())
+	require.True(t, other.PendingRemoval)
+}
+
+func TestMarkFailedStacks(t *testing.T) {
+	now := time.Now()
+
+	wasReady := []zv1.StackCondition{failedCondition(false, time.Minute)}
+
+	for _, tc := range []struct {
+		name            string
+		deadline        time.Duration
+		deleteFailed    bool
+		stack           *StackContainer
+		conditions      []zv1.StackCondition
+		expectedFailed  bool
+		expectedStatus  v1.ConditionStatus
+		expectedRemoval bool
+	}{
+		{
+			name:  "no condition without a deadline",
+			stack: testStack("foo-v1").createdAt(now.Add(-time.Hour)).deployment(true, 3, 3, 0).stack(),
+		},
+		{
+			name:           "ready stacks don't fail",
+			deadline:       time.Minute,
+			stack:          testStack("foo-v1").createdAt(now.Add(-time.Hour)).ready(3).stack(),
+			expectedStatus: v1.ConditionFalse,
+		},
+		{
+			name:     "stacks within the deadline don't fail",
+			deadline: time.Minute,
+			stack:    testStack("foo-v1").createdAt(now.Add(-30*time.Second)).deployment(true, 3, 3, 0).stack(),
+		},
+		{
+			name:           "stacks exceeding the deadline fail",
+			deadline:       time.Minute,
+			stack:          testStack("foo-v1").createdAt(now.Add(-time.Hour)).deployment(true, 3, 3, 0).stack(),
+			expectedFailed: true,
+			expectedStatus: v1.ConditionTrue,
+		},
+		{
+			name:            "failed stacks are deleted if enabled",
+			deadline:        time.Minute,
+			deleteFailed:    true,
+			stack:           testStack("foo-v1").createdAt(now.Add(-time.Hour)).deployment(true, 3, 3, 0).stack(),
+			expectedFailed:  true,
+			expectedStatus:  v1.ConditionTrue,
+			expectedRemoval: true,
+		},
+		{
+			name:           "failed stacks with traffic aren't deleted",
+			deadline:       time.Minute,
+			deleteFailed:   true,
+			stack:          testStack("foo-v1").createdAt(now.Add(-time.Hour)).deployment(true, 3, 3, 0).traffic(100, 100).stack(),
+			expectedFailed: true,
+			expectedStatus: v1.ConditionTrue,
+		},
+		{
+			name:           "stacks which were ready don't fail",
+			deadline:       time.Minute,
+			stack:          testStack("foo-v1").createdAt(now.Add(-time.Hour)).deployment(true, 3, 3, 0).stack(),
+			conditions:     wasReady,
+			expectedStatus: v1.ConditionFalse,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						StackLifecycle: zv1.StackLifecycle{
+							DeleteFailedStacks: tc.deleteFailed,
+						},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{"v1": tc.stack},
+			}
+			if tc.deadline != 0 {
+				c.StackSet.Spec.StackLifecycle.ReadinessDeadline = &metav1.Duration{Duration: tc.deadline}
+			}
+			tc.stack.conditions = tc.conditions
+
+			c.MarkFailedStacks(now)
+			require.Equal(t, tc.expectedFailed, tc.stack.failed)
+			require.Equal(t, tc.expectedRemoval, tc.stack.PendingRemoval)
+
+			condition := getStackCondition(tc.stack.conditions, zv1.StackConditionFailed)
+			if tc.expectedStatus == "" {
+				require.Nil(t, condition)
+			} else {
+				require.NotNil(t, condition)
+				require.Equal(t, tc.expectedStatus, condition.Status)
+			}
+		})
+	}
+}
+
+func TestApplyRemovalGracePeriod(t *testing.T) {
+	now := time.Now()
+
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				StackLifecycle: zv1.StackLifecycle{
+					RemovalGracePeriod: &metav1.Duration{Duration: time.Hour},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").pendingRemoval().stack(),
+			"v2": testStack("foo-v2").pendingRemoval().stack(),
+			"v3": testStack("foo-v3").pendingRemoval().stack(),
+			"v4": testStack("foo-v4").stack(),
+		},
+		pendingRemoval: []zv1.PendingStackRemoval{
+			{Name: "foo-v1", Since: metav1.Time{Time: now.Add(-2 * time.Hour)}},
+			{Name: "foo-v2", Since: metav1.Time{Time: now.Add(-30 * time.Minute)}},
+			{Name: "foo-v4", Since: metav1.Time{Time: now.Add(-2 * time.Hour)}},
+		},
+	}
+
+	scheduled := c.ApplyRemovalGracePeriod(now)
+	require.Len(t, scheduled, 1)
+	require.Equal(t, "foo-v3", scheduled[0].Name())
+
+	require.True(t, c.StackContainers["v1"].PendingRemoval)
+	require.False(t, c.StackContainers["v2"].PendingRemoval)
+	require.False(t, c.StackContainers["v3"].PendingRemoval)
+	require.False(t, c.StackContainers["v4"].PendingRemoval)
+
+	expected := []zv1.PendingStackRemoval{
+		{Name: "foo-v1", Since: metav1.Time{Time: now.Add(-2 * time.Hour)}},
+		{Name: "foo-v2", Since: metav1.Time{Time: now.Add(-30 * time.Minute)}},
+		{Name: "foo-v3", Since: metav1.Time{Time: now}},
+	}
+	require.Equal(t, expected, c.GenerateStackSetStatus().PendingRemoval)
+
+	// without a grace period stacks are deleted right away, they're only
+	// reported until they're gone
+	c.StackSet.Spec.StackLifecycle.RemovalGracePeriod = nil
+	c.StackContainers["v3"].PendingRemoval = true
+	require.Empty(t, c.ApplyRemovalGracePeriod(now))
+	require.True(t, c.StackContainers["v3"].PendingRemoval)
+	expected = []zv1.PendingStackRemoval{
+		{Name: "foo-v1", Since: metav1.Time{Time: now.Add(-2 * time.Hour)}},
+		{Name: "foo-v3", Since: metav1.Time{Time: now}},
+	}
+	require.Equal(t, expected, c.GenerateStackSetStatus().PendingRemoval)
+
+	// stacks whose deletion is blocked are reported as well
+	deletionTime := metav1.Time{Time: now.Add(-time.Minute)}
+	c.StackContainers["v4"].Stack.DeletionTimestamp = &deletionTime
+	c.ApplyRemovalGracePeriod(now)
+	expected = append(expected, zv1.PendingStackRemoval{Name: "foo-v4", Since: deletionTime})
+	require.Equal(t, expected, c.GenerateStackSetStatus().PendingRemoval)
+}
+
+func TestDrainStacks(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name             string
+		drainDuration    time.Duration
+		stack            *StackContainer
+		expectedDraining bool
+		expectedRemoval  bool
+	}{
+		{
+			name:            "stacks aren't drained without a drain duration",
+			stack:           testStack("foo-v1").traffic(50, 50).pendingRemoval().stack(),
+			expectedRemoval: true,
+		},
+		{
+			name:             "stacks marked for removal with traffic are drained",
+			drainDuration:    time.Minute,
+			stack:            testStack("foo-v1").traffic(50, 50).pendingRemoval().stack(),
+			expectedDraining: true,
+			expectedRemoval:  true,
+		},
+		{
+			name:            "stacks marked for removal without traffic aren't drained",
+			drainDuration:   time.Minute,
+			stack:           testStack("foo-v1").pendingRemoval().stack(),
+			expectedRemoval: true,
+		},
+		{
+			name:          "stacks with traffic aren't drained",
+			drainDuration: time.Minute,
+			stack:         testStack("foo-v1").traffic(50, 50).stack(),
+		},
+		{
+			name:             "draining stacks stay marked for removal",
+			drainDuration:    time.Minute,
+			stack:            testStack("foo-v1").traffic(50, 20).pendingRemoval().draining(now.Add(-time.Minute), 50).stack(),
+			expectedDraining: true,
+			expectedRemoval:  true,
+		},
+		{
+			name:          "draining stops if the stack shouldn't be removed anymore",
+			drainDuration: time.Minute,
+			stack:         testStack("foo-v1").traffic(50, 20).draining(now.Add(-time.Minute), 50).stack(),
+		},
+		{
+			name:          "draining stops if the desired traffic is raised",
+			drainDuration: time.Minute,
+			stack:         testStack("foo-v1").traffic(100, 20).pendingRemoval().draining(now.Add(-time.Minute), 50).stack(),
+		},
+		{
+			name:          "stacks whose traffic is raised aren't drained",
+			drainDuration: time.Minute,
+			stack:         testStack("foo-v1").traffic(100, 50).pendingRemoval().stack(),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet:        &zv1.StackSet{},
+				StackContainers: map[types.UID]*StackContainer{"v1": tc.stack},
+			}
+			if tc.drainDuration != 0 {
+				c.StackSet.Spec.StackLifecycle.DrainDuration = &metav1.Duration{Duration: tc.drainDuration}
+			}
+
+			c.DrainStacks(now)
+			require.Equal(t, tc.expectedDraining, tc.stack.Draining())
+			require.Equal(t, tc.expectedRemoval, tc.stack.PendingRemoval)
+		})
+	}
+}
+
 func TestSanitizeServicePorts(t *testing.T) {
 	service := &zv1.StackServiceSpec{
 		Ports: []v1.ServicePort{
@@ -182,6 +648,42 @@ func TestStackSetNewStack(t *testing.T) {
 			expectedStack:     nil,
 			expectedStackName: "",
 		},
+		{
+			name: "deleted stack is recreated if enabled",
+			stackset: &zv1.StackSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: zv1.StackSetSpec{
+					StackTemplate: zv1.StackTemplate{
+						Spec: zv1.StackSpecTemplate{
+							Version: "v1",
+						},
+					},
+					RecreateDeletedStacks: true,
+				},
+				Status: zv1.StackSetStatus{
+					ObservedStackVersion: "v1",
+				},
+			},
+			stacks: map[types.UID]*StackContainer{},
+			expectedStack: &StackContainer{
+				Stack: &zv1.Stack{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "foo-v1",
+						Labels: map[string]string{
+							StacksetHeritageLabelKey: "foo",
+							StackVersionLabelKey:     "v1",
+						},
+						Finalizers: []string{StackTrafficFinalizer, StackTeardownFinalizer},
+						OwnerReferences: []metav1.OwnerReference{
+							{
+								Name: "foo",
+							},
+						},
+					},
+				},
+			},
+			expectedStackName: "v1",
+		},
 		{
 			name: "stack needs to be created",
 			stackset: &zv1.StackSet{
@@ -214,6 +716,7 @@ func TestStackSetNewStack(t *testing.T) {
 							"custom":                 "label",
 							StackVersionLabelKey:     "v1",
 						},
+						Finalizers: []string{StackTrafficFinalizer, StackTeardownFinalizer},
 						OwnerReferences: []metav1.OwnerReference{
 							{
 								APIVersion: APIVersion,
@@ -269,7 +772,7 @@ func TestStackSetUpdateFromResources(t *testing.T) {
 	}{
 		{
 			name:                 "no ingress, default scaledown TTL",
-			expectedScaledownTTL: defaultScaledownTTL,
+			expectedScaledownTTL: DefaultScaledownTTL,
 		},
 		{
 			name:                 "explicit scaledown TTL",
@@ -281,7 +784,7 @@ func TestStackSetUpdateFromResources(t *testing.T) {
 			ingress: &zv1.StackSetIngressSpec{
 				Hosts: []string{"foo"},
 			},
-			expectedScaledownTTL: defaultScaledownTTL,
+			expectedScaledownTTL: DefaultScaledownTTL,
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -375,6 +878,45 @@ func TestStackUpdateFromResources(t *testing.T) {
 		container.updateFromResources()
 		require.EqualValues(t, 7, container.desiredReplicas)
 	})
+	runTest("autoscaler status is unset if there's no HPA", func(t *testing.T, container *StackContainer) {
+		container.updateFromResources()
+		require.Nil(t, container.autoscalerStatus)
+	})
+	runTest("autoscaler status is parsed from the HPA", func(t *testing.T, container *StackContainer) {
+		metrics := []autoscaling.MetricStatus{
+			{
+				Type: autoscaling.ResourceMetricSourceType,
+				Resource: &autoscaling.ResourceMetricStatus{
+					Name:                      v1.ResourceCPU,
+					CurrentAverageUtilization: wrapReplicas(63),
+				},
+			},
+		}
+		conditions := []autoscaling.HorizontalPodAutoscalerCondition{
+			{
+				Type:   autoscaling.ScalingLimited,
+				Status: v1.ConditionTrue,
+				Reason: "TooManyReplicas",
+			},
+		}
+		container.Resources.HPA = &autoscaling.HorizontalPodAutoscaler{
+			Status: autoscaling.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: 5,
+				DesiredReplicas: 7,
+				LastScaleTime:   &metav1.Time{Time: hourAgo},
+				CurrentMetrics:  metrics,
+				Conditions:      conditions,
+			},
+		}
+		container.updateFromResources()
+		require.Equal(t, &zv1.AutoscalerStatus{
+			CurrentReplicas: 5,
+			DesiredReplicas: 7,
+			LastScaleTime:   &metav1.Time{Time: hourAgo},
+			CurrentMetrics:  metrics,
+			Conditions:      conditions,
+		}, container.autoscalerStatus)
+	})
 
 	runTest("noTrafficSince can be unset", func(t *testing.T, container *StackContainer) {
 		container.updateFromResources()
@@ -516,10 +1058,12 @@ func TestStackUpdateFromResources(t *testing.T) {
 func TestUpdateTrafficFromIngress(t *testing.T) {
 	for _, tc := range []struct {
 		name                   string
+		specWeights            []zv1.StackTrafficWeight
 		desiredWeights         string
 		actualWeights          string
 		expectedDesiredWeights map[string]float64
 		expectedActualWeights  map[string]float64
+		expectedError          bool
 	}{
 		{
 			name: "no weights are present",
@@ -538,6 +1082,26 @@ func TestUpdateTrafficFromIngress(t *testing.T) {
 			expectedDesiredWeights: map[string]float64{"foo-v2": 50, "foo-v3": 50},
 			expectedActualWeights:  map[string]float64{"foo-v2": 25, "foo-v3": 75},
 		},
+		{
+			name: "desired weights in the spec take precedence over the annotation",
+			specWeights: []zv1.StackTrafficWeight{
+				{StackName: "foo-v1", Weight: 20},
+				{StackName: "foo-v2", Weight: 60},
+				{StackName: "foo-v4", Weight: 20},
+			},
+			desiredWeights:         `{"foo-v1": 100}`,
+			actualWeights:          `{"foo-v1": 100}`,
+			expectedDesiredWeights: map[string]float64{"foo-v1": 25, "foo-v2": 75},
+			expectedActualWeights:  map[string]float64{"foo-v1": 100},
+		},
+		{
+			name: "stacks listed twice in the spec are rejected",
+			specWeights: []zv1.StackTrafficWeight{
+				{StackName: "foo-v1", Weight: 50},
+				{StackName: "foo-v1", Weight: 50},
+			},
+			expectedError: true,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			stack1 := testStack("foo-v1").stack()
@@ -566,6 +1130,9 @@ func TestUpdateTrafficFromIngress(t *testing.T) {
 				},
 			}
 
+			if tc.specWeights != nil {
+				ssc.StackSet.Spec.Traffic = tc.specWeights
+			}
 			if tc.desiredWeights != "" {
 				ssc.Ingress.Annotations[stackTrafficWeightsAnnotationKey] = tc.desiredWeights
 			}
@@ -574,6 +1141,10 @@ func TestUpdateTrafficFromIngress(t *testing.T) {
 			}
 
 			err := ssc.UpdateFromResources()
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
 			require.NoError(t, err)
 			for _, sc := range ssc.StackContainers {
 				require.Equal(t, tc.expectedDesiredWeights[sc.Name()], sc.desiredTrafficWeight, "stack %s", sc.Stack.Name)
@@ -584,6 +1155,135 @@ func TestUpdateTrafficFromIngress(t *testing.T) {
 	}
 }
 
+func TestDesiredTrafficFromStatus(t *testing.T) {
+	specWeights := []zv1.StackTrafficWeight{
+		{StackName: "foo-v1", Weight: 100},
+	}
+	statusWeights := []zv1.StackTrafficWeight{
+		{StackName: "foo-v1", Weight: 60},
+		{StackName: "foo-v2", Weight: 40},
+	}
+
+	for _, tc := range []struct {
+		name            string
+		observedTraffic []zv1.StackTrafficWeight
+		expected        map[string]float64
+	}{
+		{
+			name:            "the weights changed by the controller are kept",
+			observedTraffic: specWeights,
+			expected:        map[string]float64{"foo-v1": 60, "foo-v2": 40},
+		},
+		{
+			name:            "the weights of the spec are used once they change",
+			observedTraffic: []zv1.StackTrafficWeight{{StackName: "foo-v2", Weight: 100}},
+			expected:        map[string]float64{"foo-v1": 100, "foo-v2": 0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ssc := &StackSetContainer{
+				StackSet: &zv1.StackSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+						Traffic: specWeights,
+					},
+					Status: zv1.StackSetStatus{
+						DesiredTraffic:  statusWeights,
+						ObservedTraffic: tc.observedTraffic,
+					},
+				},
+				Ingress: &extensions.Ingress{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"v1": testStack("foo-v1").stack(),
+					"v2": testStack("foo-v2").stack(),
+				},
+			}
+
+			err := ssc.UpdateFromResources()
+			require.NoError(t, err)
+			for _, sc := range ssc.StackContainers {
+				require.Equal(t, tc.expected[sc.Name()], sc.desiredTrafficWeight, "stack %s", sc.Name())
+			}
+		})
+	}
+}
+
+func TestTrafficSwitchPending(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		desiredWeights string
+		actualWeights  string
+		prescaling     bool
+		expected       bool
+	}{
+		{
+			name: "no weights are present",
+		},
+		{
+			name:           "the actual traffic matches the desired traffic",
+			desiredWeights: `{"foo-v1": 25, "foo-v2": 75}`,
+			actualWeights:  `{"foo-v1": 25, "foo-v2": 75}`,
+		},
+		{
+			name:           "the actual traffic differs from the desired traffic",
+			desiredWeights: `{"foo-v1": 25, "foo-v2": 75}`,
+			actualWeights:  `{"foo-v1": 100}`,
+			expected:       true,
+		},
+		{
+			name:           "a stack is being prescaled",
+			desiredWeights: `{"foo-v1": 100}`,
+			actualWeights:  `{"foo-v1": 100}`,
+			prescaling:     true,
+			expected:       true,
+		},
+		{
+			name:           "invalid weights are ignored",
+			desiredWeights: `{"foo-v1": 100}`,
+			actualWeights:  `invalid`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stack1 := testStack("foo-v1").stack()
+			stack2 := testStack("foo-v2").stack()
+			stack2.Stack.Status.Prescaling.Active = tc.prescaling
+
+			ssc := &StackSetContainer{
+				StackSet: &zv1.StackSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "foo",
+					},
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				Ingress: &extensions.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "foo",
+						Annotations: map[string]string{
+							stackTrafficWeightsAnnotationKey: tc.desiredWeights,
+							backendWeightsAnnotationKey:      tc.actualWeights,
+						},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"v1": stack1,
+					"v2": stack2,
+				},
+			}
+			if tc.desiredWeights == "" {
+				delete(ssc.Ingress.Annotations, stackTrafficWeightsAnnotationKey)
+				delete(ssc.Ingress.Annotations, backendWeightsAnnotationKey)
+			}
+
+			require.Equal(t, tc.expected, ssc.TrafficSwitchPending())
+		})
+	}
+}
+
 func TestGenerateStackSetStatus(t *testing.T) {
 	c := &StackSetContainer{
 		StackSet: &zv1.StackSet{
@@ -607,8 +1307,111 @@ func TestGenerateStackSetStatus(t *testing.T) {
 		ReadyStacks:          2,
 		StacksWithTraffic:    1,
 		ObservedStackVersion: "v1",
+		Traffic: []zv1.StackTrafficWeight{
+			{StackName: "v2", Weight: 1},
+		},
+	}
+	status := c.GenerateStackSetStatus()
+	require.Len(t, status.Conditions, 3)
+	status.Conditions = nil
+	require.Equal(t, expected, status)
+}
+
+func TestGenerateStackSetStatusConditions(t *testing.T) {
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").pendingRemoval().stack(),
+			"v2": testStack("foo-v2").ready(3).traffic(50, 100).stack(),
+			"v3": testStack("foo-v3").traffic(50, 0).stack(),
+		},
+	}
+	c.SetIngressReconciled(errors.New("update failed"))
+
+	conditions := c.GenerateStackSetStatus().Conditions
+	require.Len(t, conditions, 4)
+
+	ready := getStackSetCondition(conditions, zv1.StackSetConditionReady)
+	require.Equal(t, v1.ConditionFalse, ready.Status)
+	require.Equal(t, "stacks which should get traffic aren't ready: foo-v3", ready.Message)
+
+	inSync := getStackSetCondition(conditions, zv1.StackSetConditionTrafficInSync)
+	require.Equal(t, v1.ConditionFalse, inSync.Status)
+	require.Equal(t, "foo-v2: 100.0% of 50.0%; foo-v3: 0.0% of 50.0%", inSync.Message)
+
+	collected := getStackSetCondition(conditions, zv1.StackSetConditionStacksGarbageCollected)
+	require.Equal(t, v1.ConditionFalse, collected.Status)
+	require.Equal(t, "stacks are going to be removed: foo-v1", collected.Message)
+
+	ingress := getStackSetCondition(conditions, zv1.StackSetConditionIngressUpToDate)
+	require.Equal(t, v1.ConditionFalse, ingress.Status)
+	require.Equal(t, "update failed", ingress.Message)
+
+	// all the conditions are true once the traffic was switched
+	c.StackContainers = map[types.UID]*StackContainer{
+		"v2": testStack("foo-v2").ready(3).traffic(100, 100).stack(),
+	}
+	c.SetIngressReconciled(nil)
+	for _, condition := range c.GenerateStackSetStatus().Conditions {
+		require.Equal(t, v1.ConditionTrue, condition.Status, string(condition.Type))
+	}
+}
+
+func TestUsesDeprecatedFeaturesCondition(t *testing.T) {
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").ready(3).traffic(100, 100).stack(),
+		},
 	}
-	require.Equal(t, expected, c.GenerateStackSetStatus())
+	c.SetDeprecations([]string{"b is deprecated", "a is deprecated"})
+
+	conditions := c.GenerateStackSetStatus().Conditions
+	deprecated := getStackSetCondition(conditions, zv1.StackSetConditionUsesDeprecatedFeatures)
+	require.NotNil(t, deprecated)
+	require.Equal(t, v1.ConditionTrue, deprecated.Status)
+	require.Equal(t, "a is deprecated; b is deprecated", deprecated.Message)
+
+	// the condition is removed once the features aren't used anymore
+	c.conditions = conditions
+	c.SetDeprecations(nil)
+	conditions = c.GenerateStackSetStatus().Conditions
+	require.Nil(t, getStackSetCondition(conditions, zv1.StackSetConditionUsesDeprecatedFeatures))
+}
+
+func TestGenerateStackSetStatusDesiredTraffic(t *testing.T) {
+	specWeights := []zv1.StackTrafficWeight{
+		{StackName: "foo-v2", Weight: 100},
+	}
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{Traffic: specWeights},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(0, 30).stack(),
+			"v2": testStack("foo-v2").traffic(70, 70).stack(),
+			"v3": testStack("foo-v3").traffic(30, 0).stack(),
+		},
+	}
+
+	// the desired weights adjusted by the controller are kept in the status
+	status := c.GenerateStackSetStatus()
+	expected := []zv1.StackTrafficWeight{
+		{StackName: "foo-v2", Weight: 70},
+		{StackName: "foo-v3", Weight: 30},
+	}
+	require.Equal(t, expected, status.DesiredTraffic)
+	require.Equal(t, specWeights, status.ObservedTraffic)
+
+	// they're only reported if the desired weights are defined in the spec
+	c.StackSet.Spec.Traffic = nil
+	status = c.GenerateStackSetStatus()
+	require.Nil(t, status.DesiredTraffic)
+	require.Nil(t, status.ObservedTraffic)
 }
 
 func TestStackSetGenerateIngress(t *testing.T) {
@@ -745,3 +1548,51 @@ func TestStackSetGenerateIngressNone(t *testing.T) {
 	require.NoError(t, err)
 	require.Nil(t, ingress)
 }
+
+func TestRecordError(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Status: zv1.StackSetStatus{
+				LastErrors: []zv1.ReconcileErrorRecord{
+					{
+						Message:        "failed to create stack",
+						FirstTimestamp: metav1.NewTime(start),
+						LastTimestamp:  metav1.NewTime(start),
+						Count:          2,
+					},
+					{
+						Message:        "failed to update ingress",
+						FirstTimestamp: metav1.NewTime(start),
+						LastTimestamp:  metav1.NewTime(start),
+						Count:          1,
+					},
+				},
+			},
+		},
+	}
+
+	c.RecordError(nil, start)
+	require.Equal(t, c.StackSet.Status.LastErrors, c.LastErrors())
+
+	// A repeated error is counted and moved to the end
+	c.RecordError(errors.New("failed to create stack"), start.Add(time.Minute))
+	lastErrors := c.LastErrors()
+	require.Len(t, lastErrors, 2)
+	require.Equal(t, "failed to update ingress", lastErrors[0].Message)
+	require.Equal(t, zv1.ReconcileErrorRecord{
+		Message:        "failed to create stack",
+		FirstTimestamp: metav1.NewTime(start),
+		LastTimestamp:  metav1.NewTime(start.Add(time.Minute)),
+		Count:          3,
+	}, lastErrors[1])
+
+	// Only the most recent errors are kept
+	for i := 0; i < lastErrorsLimit; i++ {
+		c.RecordError(fmt.Errorf("error %d", i), start.Add(2*time.Minute))
+	}
+	lastErrors = c.GenerateStackSetStatus().LastErrors
+	require.Len(t, lastErrors, lastErrorsLimit)
+	require.Equal(t, "error 0", lastErrors[0].Message)
+	require.Equal(t, fmt.Sprintf("error %d", lastErrorsLimit-1), lastErrors[lastErrorsLimit-1].Message)
+}
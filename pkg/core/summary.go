@@ -0,0 +1,87 @@
+package core
+
+import (
+	"sort"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// StackSetSummary is the state of a StackSet as of its last reconciliation,
+// exposed to deployment dashboards.
+type StackSetSummary struct {
+	Namespace         string                   `json:"namespace"`
+	Name              string                   `json:"name"`
+	Ready             bool                     `json:"ready"`
+	Stacks            int32                    `json:"stacks"`
+	ReadyStacks       int32                    `json:"readyStacks"`
+	StacksWithTraffic int32                    `json:"stacksWithTraffic"`
+	Traffic           []zv1.StackTrafficWeight `json:"traffic"`
+	DesiredTraffic    []zv1.StackTrafficWeight `json:"desiredTraffic"`
+}
+
+// StackSummary is the state of a stack as of the last reconciliation of its
+// StackSet, exposed to deployment dashboards.
+type StackSummary struct {
+	Name                 string  `json:"name"`
+	Version              string  `json:"version"`
+	Ready                bool    `json:"ready"`
+	Replicas             int32   `json:"replicas"`
+	ReadyReplicas        int32   `json:"readyReplicas"`
+	ActualTrafficWeight  float64 `json:"actualTrafficWeight"`
+	DesiredTrafficWeight float64 `json:"desiredTrafficWeight"`
+	PendingRemoval       bool    `json:"pendingRemoval"`
+}
+
+// Summary returns the readiness and the traffic weights of the StackSet.
+// Stacks pending removal aren't counted.
+func (ssc *StackSetContainer) Summary() *StackSetSummary {
+	result := &StackSetSummary{
+		Namespace: ssc.StackSet.Namespace,
+		Name:      ssc.StackSet.Name,
+		Ready:     ssc.readyCondition().Status == v1.ConditionTrue,
+		Traffic: ssc.trafficWeights(func(sc *StackContainer) float64 {
+			return sc.actualTrafficWeight
+		}),
+		DesiredTraffic: ssc.trafficWeights(func(sc *StackContainer) float64 {
+			return sc.desiredTrafficWeight
+		}),
+	}
+
+	for _, sc := range ssc.StackContainers {
+		if sc.PendingRemoval {
+			continue
+		}
+
+		result.Stacks++
+		if sc.HasTraffic() {
+			result.StacksWithTraffic++
+		}
+		if sc.IsReady() {
+			result.ReadyStacks++
+		}
+	}
+	return result
+}
+
+// StackSummaries returns the readiness and the traffic weights of the stacks
+// of the StackSet, sorted by name.
+func (ssc *StackSetContainer) StackSummaries() []*StackSummary {
+	result := make([]*StackSummary, 0, len(ssc.StackContainers))
+	for _, sc := range ssc.StackContainers {
+		result = append(result, &StackSummary{
+			Name:                 sc.Name(),
+			Version:              sc.Stack.Labels[StackVersionLabelKey],
+			Ready:                sc.IsReady(),
+			Replicas:             sc.deploymentReplicas,
+			ReadyReplicas:        sc.readyReplicas,
+			ActualTrafficWeight:  sc.actualTrafficWeight,
+			DesiredTrafficWeight: sc.desiredTrafficWeight,
+			PendingRemoval:       sc.PendingRemoval,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
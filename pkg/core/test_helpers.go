@@ -4,6 +4,7 @@ import (
 	"time"
 
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -56,6 +57,11 @@ func (f *testStackFactory) currentActualTrafficWeight(weight float64) *testStack
 	return f
 }
 
+func (f *testStackFactory) replicas(replicas int32) *testStackFactory {
+	f.container.Stack.Spec.Replicas = &replicas
+	return f
+}
+
 func (f *testStackFactory) maxReplicas(replicas int32) *testStackFactory {
 	f.container.Stack.Spec.HorizontalPodAutoscaler = &zv1.HorizontalPodAutoscaler{
 		MaxReplicas: replicas,
@@ -63,6 +69,24 @@ func (f *testStackFactory) maxReplicas(replicas int32) *testStackFactory {
 	return f
 }
 
+func (f *testStackFactory) maxTrafficWeight(weight float64) *testStackFactory {
+	f.container.Stack.Spec.MaxTrafficWeight = &weight
+	return f
+}
+
+func (f *testStackFactory) requestsPerSecondPerPod(rps int64) *testStackFactory {
+	f.container.Stack.Spec.Autoscaler = &zv1.Autoscaler{
+		MaxReplicas: 100,
+		Metrics: []zv1.AutoscalerMetrics{
+			{
+				Type:    ingressMetricName,
+				Average: resource.NewQuantity(rps, resource.DecimalSI),
+			},
+		},
+	}
+	return f
+}
+
 func (f *testStackFactory) createdAt(creationTime time.Time) *testStackFactory {
 	f.container.Stack.CreationTimestamp = metav1.Time{Time: creationTime}
 	return f
@@ -73,6 +97,42 @@ func (f *testStackFactory) noTrafficSince(since time.Time) *testStackFactory {
 	return f
 }
 
+func (f *testStackFactory) autoscalerFrozenSince(since time.Time) *testStackFactory {
+	f.container.autoscalerFrozenSince = since
+	return f
+}
+
+func (f *testStackFactory) version(version string) *testStackFactory {
+	f.container.Stack.Labels = map[string]string{StackVersionLabelKey: version}
+	return f
+}
+
+func (f *testStackFactory) gcProtected() *testStackFactory {
+	f.container.Stack.Annotations = map[string]string{GCProtectAnnotationKey: "true"}
+	return f
+}
+
+func (f *testStackFactory) annotations(annotations map[string]string) *testStackFactory {
+	f.container.Stack.Annotations = annotations
+	return f
+}
+
+func (f *testStackFactory) pinned() *testStackFactory {
+	f.container.pinned = true
+	return f
+}
+
+func (f *testStackFactory) draining(since time.Time, weight float64) *testStackFactory {
+	f.container.drainingSince = since
+	f.container.drainingTrafficWeight = weight
+	return f
+}
+
+func (f *testStackFactory) failed() *testStackFactory {
+	f.container.failed = true
+	return f
+}
+
 func (f *testStackFactory) pendingRemoval() *testStackFactory {
 	f.container.PendingRemoval = true
 	return f
@@ -86,6 +146,16 @@ func (f *testStackFactory) prescaling(replicas int32, desiredTrafficWeight float
 	return f
 }
 
+func (f *testStackFactory) prescalingReadySince(since time.Time) *testStackFactory {
+	f.container.prescalingReadySince = since
+	return f
+}
+
+func (f *testStackFactory) prescalingStartTime(since time.Time) *testStackFactory {
+	f.container.prescalingStartTime = since
+	return f
+}
+
 func (f *testStackFactory) stack() *StackContainer {
 	return f.container
 }
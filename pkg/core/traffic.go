@@ -1,7 +1,10 @@
 package core
 
 import (
+	"math"
 	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
 )
 
 const (
@@ -62,7 +65,12 @@ func (ssc *StackSetContainer) ManageTraffic(currentTimestamp time.Time) error {
 			sc.prescalingActive = false
 			sc.prescalingReplicas = 0
 			sc.prescalingLastTrafficIncrease = time.Time{}
+			sc.prescalingReadySince = time.Time{}
+			sc.prescalingPhase = ""
+			sc.prescalingStartTime = time.Time{}
+			sc.prescalingCompletionTime = time.Time{}
 		}
+		ssc.updateTrafficSwitchCondition()
 		return nil
 	}
 
@@ -71,12 +79,35 @@ func (ssc *StackSetContainer) ManageTraffic(currentTimestamp time.Time) error {
 		stacks[stack.Name()] = stack
 	}
 
+	// Ignore micro-adjustments of the desired traffic
+	if ssc.StackSet.Spec.TrafficPolicy != nil {
+		ignoreSmallTrafficChanges(stacks, ssc.StackSet.Spec.TrafficPolicy.MinWeightChange)
+	}
+
+	// Record the changes of the desired traffic made outside of the controller
+	ssc.recordExternalTrafficChange(stacks, currentTimestamp)
+
+	var drainDuration time.Duration
+	if ssc.StackSet.Spec.StackLifecycle.DrainDuration != nil {
+		drainDuration = ssc.StackSet.Spec.StackLifecycle.DrainDuration.Duration
+	}
+
 	// Collect the desired weights
 	desiredWeights := make(map[string]float64)
 	actualWeights := make(map[string]float64)
 	for stackName, stack := range stacks {
 		desiredWeights[stackName] = stack.desiredTrafficWeight
 		actualWeights[stackName] = stack.actualTrafficWeight
+
+		// Failed stacks never get traffic, it stays on the previous stacks
+		if stack.failed {
+			desiredWeights[stackName] = 0
+		}
+
+		// Stacks which are being drained gradually lose their traffic
+		if stack.Draining() && drainDuration > 0 {
+			desiredWeights[stackName] = math.Min(desiredWeights[stackName], stack.drainedTrafficWeight(drainDuration, currentTimestamp))
+		}
 	}
 
 	// Normalize the weights and ensure that at least one stack gets traffic. This is done for both desired
@@ -95,10 +126,24 @@ func (ssc *StackSetContainer) ManageTraffic(currentTimestamp time.Time) error {
 			normalizeWeights(weights)
 		}
 	}
+
+	// Restore a traffic snapshot if requested
+	restoreErr := ssc.restoreTrafficSnapshot(stacks, desiredWeights)
+
+	// Move the desired traffic to the target of the traffic ramp step by step
+	rampErr := ssc.rampTraffic(stacks, desiredWeights, actualWeights, currentTimestamp)
+
+	// Roll the desired traffic back if the stack gaining traffic fails
+	guardErr := ssc.guardTraffic(stacks, desiredWeights, currentTimestamp)
+
+	// Keep the stacks within their max traffic weight
+	limitTrafficWeights(stacks, desiredWeights)
+
 	for stackName, stack := range stacks {
 		stack.desiredTrafficWeight = desiredWeights[stackName]
 		stack.actualTrafficWeight = actualWeights[stackName]
 	}
+	ssc.recordTrafficChange(zv1.TrafficChangeSourceController, trafficChangedByController, desiredWeights, currentTimestamp)
 
 	// Run the traffic reconciler which will update the actual weights according to the desired weights. The resulting
 	// weights **must** be normalised.
@@ -128,7 +173,76 @@ func (ssc *StackSetContainer) ManageTraffic(currentTimestamp time.Time) error {
 			stack.noTrafficSince = currentTimestamp
 		}
 	}
-	return err
+	ssc.updateTrafficSwitchCondition()
+
+	if err != nil {
+		return err
+	}
+	if restoreErr != nil {
+		return restoreErr
+	}
+	if rampErr != nil {
+		return rampErr
+	}
+	return guardErr
+}
+
+// ignoreSmallTrafficChanges resets the desired weights of the stacks to their
+// actual weights if none of them differs by at least the minimum change.
+func ignoreSmallTrafficChanges(stacks map[string]*StackContainer, minChange float64) {
+	if minChange <= 0 {
+		return
+	}
+
+	for _, stack := range stacks {
+		if math.Abs(stack.desiredTrafficWeight-stack.actualTrafficWeight) >= minChange {
+			return
+		}
+	}
+	for _, stack := range stacks {
+		stack.desiredTrafficWeight = stack.actualTrafficWeight
+	}
+}
+
+// limitTrafficWeights caps the weights of the stacks defining a max traffic
+// weight and redistributes the excess to the other stacks proportionally to
+// their weights. If none of them gets traffic, the excess goes to the fallback
+// stack among them. The weights are kept if all the stacks define a max
+// traffic weight. The passed weights must be normalized.
+func limitTrafficWeights(stacks map[string]*StackContainer, weights map[string]float64) {
+	uncapped := make(map[string]*StackContainer)
+	for stackName, stack := range stacks {
+		if stack.Stack.Spec.MaxTrafficWeight == nil {
+			uncapped[stackName] = stack
+		}
+	}
+	if len(uncapped) == 0 {
+		return
+	}
+
+	excess := 0.0
+	for stackName, stack := range stacks {
+		maxWeight := stack.Stack.Spec.MaxTrafficWeight
+		if maxWeight != nil && weights[stackName] > *maxWeight {
+			excess += weights[stackName] - *maxWeight
+			weights[stackName] = *maxWeight
+		}
+	}
+	if excess == 0 {
+		return
+	}
+
+	uncappedWeight := 0.0
+	for stackName := range uncapped {
+		uncappedWeight += weights[stackName]
+	}
+	if uncappedWeight == 0 {
+		weights[findFallbackStack(uncapped).Name()] += excess
+		return
+	}
+	for stackName := range uncapped {
+		weights[stackName] += excess * weights[stackName] / uncappedWeight
+	}
 }
 
 // fallbackStack returns a stack that should be the target of traffic if none of the existing stacks get anything
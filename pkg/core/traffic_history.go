@@ -0,0 +1,162 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultTrafficHistoryLimit = 10
+	trafficChangedByController = "stackset-controller"
+	trafficSnapshotNameFormat  = "20060102-150405"
+)
+
+// recordExternalTrafficChange records a change of the desired traffic
+// weights defined in the StackSet spec or the ingress annotation, i.e. the
+// weights read from the resources before the controller adjusts them.
+func (ssc *StackSetContainer) recordExternalTrafficChange(stacks map[string]*StackContainer, currentTimestamp time.Time) {
+	source := zv1.TrafficChangeSourceAnnotation
+	changedBy := ""
+	if ssc.DesiredTrafficInSpec() {
+		source = zv1.TrafficChangeSourceSpec
+		changedBy = ssc.StackSet.Annotations[TrafficChangedByAnnotationKey]
+	} else if ssc.Ingress != nil {
+		changedBy = ssc.Ingress.Annotations[TrafficChangedByAnnotationKey]
+	}
+
+	desiredWeights := make(map[string]float64, len(stacks))
+	for stackName, stack := range stacks {
+		desiredWeights[stackName] = stack.desiredTrafficWeight
+	}
+	ssc.recordTrafficChange(source, changedBy, desiredWeights, currentTimestamp)
+}
+
+// recordTrafficChange appends a change to the traffic history if the
+// desired weights differ from the ones of the last recorded change, and takes
+// a snapshot of the weights before the change. Only the most recent changes
+// and snapshots are kept, up to the history limit.
+func (ssc *StackSetContainer) recordTrafficChange(source zv1.TrafficChangeSource, changedBy string, desiredWeights map[string]float64, currentTimestamp time.Time) {
+	var from []zv1.StackTrafficWeight
+	if len(ssc.trafficHistory) > 0 {
+		from = ssc.trafficHistory[len(ssc.trafficHistory)-1].To
+	}
+	to := trafficWeightList(desiredWeights)
+	if trafficWeightListsEqual(from, to) {
+		return
+	}
+
+	ssc.trafficHistory = append(ssc.trafficHistory, zv1.TrafficChangeRecord{
+		Time:      metav1.NewTime(currentTimestamp),
+		Source:    source,
+		ChangedBy: changedBy,
+		From:      from,
+		To:        to,
+	})
+
+	if len(from) > 0 {
+		ssc.takeTrafficSnapshot(from, currentTimestamp)
+	}
+
+	limit := ssc.trafficHistoryLimit()
+	if len(ssc.trafficHistory) > limit {
+		ssc.trafficHistory = ssc.trafficHistory[len(ssc.trafficHistory)-limit:]
+	}
+	if len(ssc.trafficHistory) == 0 {
+		ssc.trafficHistory = nil
+	}
+}
+
+// trafficHistoryLimit returns the number of changes and snapshots of the
+// traffic kept in the status.
+func (ssc *StackSetContainer) trafficHistoryLimit() int {
+	if ssc.StackSet.Spec.TrafficPolicy != nil && ssc.StackSet.Spec.TrafficPolicy.HistoryLimit != nil {
+		return int(*ssc.StackSet.Spec.TrafficPolicy.HistoryLimit)
+	}
+	return defaultTrafficHistoryLimit
+}
+
+// takeTrafficSnapshot records the weights as a snapshot named after the
+// current time. A snapshot of the same second is kept as it is.
+func (ssc *StackSetContainer) takeTrafficSnapshot(weights []zv1.StackTrafficWeight, currentTimestamp time.Time) {
+	name := currentTimestamp.UTC().Format(trafficSnapshotNameFormat)
+	if ssc.trafficSnapshot(name) != nil {
+		return
+	}
+
+	ssc.trafficSnapshots = append(ssc.trafficSnapshots, zv1.TrafficSnapshot{
+		Name:    name,
+		Time:    metav1.NewTime(currentTimestamp),
+		Weights: weights,
+	})
+
+	limit := ssc.trafficHistoryLimit()
+	if len(ssc.trafficSnapshots) > limit {
+		ssc.trafficSnapshots = ssc.trafficSnapshots[len(ssc.trafficSnapshots)-limit:]
+	}
+	if len(ssc.trafficSnapshots) == 0 {
+		ssc.trafficSnapshots = nil
+	}
+}
+
+// trafficSnapshot returns the snapshot with the name or nil if there is none.
+func (ssc *StackSetContainer) trafficSnapshot(name string) *zv1.TrafficSnapshot {
+	for i := range ssc.trafficSnapshots {
+		if ssc.trafficSnapshots[i].Name == name {
+			return &ssc.trafficSnapshots[i]
+		}
+	}
+	return nil
+}
+
+// restoreTrafficSnapshot sets the desired weights to the ones of the snapshot
+// requested in the StackSet spec. Stacks which no longer exist or failed are
+// left out. The passed weights are kept if the snapshot is unknown or none of
+// its stacks can get traffic.
+func (ssc *StackSetContainer) restoreTrafficSnapshot(stacks map[string]*StackContainer, desiredWeights map[string]float64) error {
+	name := ssc.StackSet.Spec.TrafficSnapshotRestore
+	if name == "" {
+		return nil
+	}
+
+	snapshot := ssc.trafficSnapshot(name)
+	if snapshot == nil {
+		return fmt.Errorf("unknown traffic snapshot %s", name)
+	}
+
+	restored := make(map[string]float64, len(stacks))
+	for stackName := range stacks {
+		restored[stackName] = 0
+	}
+	for _, weight := range snapshot.Weights {
+		if stack, ok := stacks[weight.StackName]; ok && !stack.failed {
+			restored[weight.StackName] = weight.Weight
+		}
+	}
+	if allZero(restored) {
+		return fmt.Errorf("none of the stacks of traffic snapshot %s can get traffic", name)
+	}
+	normalizeWeights(restored)
+
+	for stackName, weight := range restored {
+		desiredWeights[stackName] = weight
+	}
+	return nil
+}
+
+// trafficWeightList returns the non-zero weights sorted by the stack name.
+func trafficWeightList(weights map[string]float64) []zv1.StackTrafficWeight {
+	var result []zv1.StackTrafficWeight
+	for stackName, weight := range weights {
+		if weight > 0 {
+			result = append(result, zv1.StackTrafficWeight{StackName: stackName, Weight: weight})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StackName < result[j].StackName
+	})
+	return result
+}
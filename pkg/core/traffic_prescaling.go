@@ -6,15 +6,219 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	autoscaling "k8s.io/api/autoscaling/v2beta1"
+	v1 "k8s.io/api/core/v1"
 )
 
+// RequestsPerSecondProvider provides the measured number of requests per
+// second received by the stacks of a StackSet. It's only queried when a
+// stack starts being prescaled. Failures are reported by the provider, the
+// prescaling then falls back to the replicas of the stacks getting traffic.
+type RequestsPerSecondProvider interface {
+	StackRequestsPerSecond(namespace, stacksetName, stackName string) (float64, error)
+}
+
+// CapacityProvider reports the number of pods of a stack which can't be
+// scheduled, e.g. because the cluster is out of capacity.
+type CapacityProvider interface {
+	UnschedulablePods(namespace string, selector map[string]string) (int, error)
+}
+
 // PrescalingTrafficReconciler is a traffic reconciler that forcibly scales up the deployment
 // before switching traffic
 type PrescalingTrafficReconciler struct {
 	ResetHPAMinReplicasTimeout time.Duration
+
+	// Cooldown is the duration a prescaled stack has to be ready before
+	// it gets traffic.
+	Cooldown time.Duration
+
+	// BufferPercent is the head-room in percent added to the calculated
+	// number of prescaled replicas.
+	BufferPercent int32
+
+	// ReadinessThresholdPercent is the share in percent of the replicas
+	// needed for the desired traffic weight of a stack, based on the
+	// replicas of the stacks getting traffic, which have to be ready
+	// before its traffic is increased. Zero disables the check.
+	ReadinessThresholdPercent int32
+
+	// RequestsPerSecond is an optional provider of the measured load of the
+	// stacks. If set, stacks are prescaled based on the current requests
+	// per second and the capacity of a single pod of the target stack,
+	// instead of the replicas of the stacks getting traffic.
+	RequestsPerSecond RequestsPerSecondProvider
+
+	// Capacity is an optional provider of the pods which can't be
+	// scheduled. If set, stacks which aren't ready to get more traffic
+	// because of unschedulable pods get the InsufficientCapacity
+	// condition.
+	Capacity CapacityProvider
+}
+
+// lazyTotalRequestsPerSecond returns a function returning the result of
+// totalRequestsPerSecond. The metrics are only queried on its first call, so
+// that they aren't queried at all if no stack is starting to be prescaled.
+func (r PrescalingTrafficReconciler) lazyTotalRequestsPerSecond(stacks map[string]*StackContainer) func() (float64, bool) {
+	var (
+		queried bool
+		total   float64
+		ok      bool
+	)
+	return func() (float64, bool) {
+		if !queried {
+			total, ok = r.totalRequestsPerSecond(stacks)
+			queried = true
+		}
+		return total, ok
+	}
+}
+
+// totalRequestsPerSecond returns the sum of the requests per second received
+// by the stacks currently getting traffic.
+func (r PrescalingTrafficReconciler) totalRequestsPerSecond(stacks map[string]*StackContainer) (float64, bool) {
+	if r.RequestsPerSecond == nil {
+		return 0, false
+	}
+
+	total := 0.0
+	for _, stack := range stacks {
+		if stack.actualTrafficWeight == 0 {
+			continue
+		}
+		rps, err := r.RequestsPerSecond.StackRequestsPerSecond(stack.Namespace(), stack.stacksetName, stack.Name())
+		if err != nil {
+			return 0, false
+		}
+		total += rps
+	}
+	return total, total > 0
+}
+
+// prescalingSourceReplicas returns the number of replicas of a stack getting
+// traffic used to calculate the prescaled replicas of the stacks gaining
+// traffic. Besides the current replicas it considers the replicas the HPA of
+// the stack needs for the current utilization, limited to the max replicas of
+// the HPA, so that a temporarily scaled down stack doesn't cause
+// under-prescaling.
+func (sc *StackContainer) prescalingSourceReplicas() int32 {
+	replicas := sc.deploymentReplicas
+
+	hpa := sc.Resources.HPA
+	if hpa == nil {
+		return replicas
+	}
+
+	needed := hpa.Status.DesiredReplicas
+	if utilizationReplicas := hpaUtilizationReplicas(hpa); utilizationReplicas > needed {
+		needed = utilizationReplicas
+	}
+	if needed > hpa.Spec.MaxReplicas {
+		needed = hpa.Spec.MaxReplicas
+	}
+	if needed > replicas {
+		return needed
+	}
+	return replicas
+}
+
+// hpaUtilizationReplicas returns the number of replicas needed to bring the
+// current utilization of the resource metrics of the HPA down to their
+// target.
+func hpaUtilizationReplicas(hpa *autoscaling.HorizontalPodAutoscaler) int32 {
+	targets := make(map[v1.ResourceName]int32)
+	for _, metric := range hpa.Spec.Metrics {
+		if metric.Type == autoscaling.ResourceMetricSourceType && metric.Resource != nil && metric.Resource.TargetAverageUtilization != nil {
+			targets[metric.Resource.Name] = *metric.Resource.TargetAverageUtilization
+		}
+	}
+
+	var result int32
+	for _, metric := range hpa.Status.CurrentMetrics {
+		if metric.Type != autoscaling.ResourceMetricSourceType || metric.Resource == nil || metric.Resource.CurrentAverageUtilization == nil {
+			continue
+		}
+		target, ok := targets[metric.Resource.Name]
+		if !ok || target <= 0 {
+			continue
+		}
+		replicas := int32(math.Ceil(float64(hpa.Status.CurrentReplicas) * float64(*metric.Resource.CurrentAverageUtilization) / float64(target)))
+		if replicas > result {
+			result = replicas
+		}
+	}
+	return result
+}
+
+// unschedulablePods returns the number of pods of the stack which can't be
+// scheduled. Zero if unknown.
+func (r PrescalingTrafficReconciler) unschedulablePods(stack *StackContainer) int {
+	deployment := stack.Resources.Deployment
+	if r.Capacity == nil || deployment == nil || deployment.Spec.Selector == nil {
+		return 0
+	}
+
+	pods, err := r.Capacity.UnschedulablePods(stack.Namespace(), deployment.Spec.Selector.MatchLabels)
+	if err != nil {
+		return 0
+	}
+	return pods
+}
+
+// updateCapacityCondition sets the InsufficientCapacity condition of the
+// stack if some of its pods can't be scheduled and removes it otherwise.
+func updateCapacityCondition(stack *StackContainer, unschedulablePods int) {
+	if unschedulablePods > 0 {
+		stack.conditions = setStackCondition(stack.conditions, insufficientCapacityCondition(unschedulablePods))
+	} else if getStackCondition(stack.conditions, zv1.StackConditionInsufficientCapacity) != nil {
+		stack.conditions = removeStackCondition(stack.conditions, zv1.StackConditionInsufficientCapacity)
+	}
+}
+
+// requiredReadyReplicas returns the number of ready replicas a stack needs
+// before its traffic is increased to the desired weight, limited to the max
+// replicas of the stack.
+func (r PrescalingTrafficReconciler) requiredReadyReplicas(stack *StackContainer, totalReplicas, totalTraffic float64) int32 {
+	if r.ReadinessThresholdPercent <= 0 || totalTraffic == 0 {
+		return 0
+	}
+
+	replicas := int32(math.Ceil(float64(r.ReadinessThresholdPercent) / 100 * stack.desiredTrafficWeight * totalReplicas / totalTraffic))
+	if replicas > stack.MaxReplicas() {
+		return stack.MaxReplicas()
+	}
+	return replicas
+}
+
+// updatePrescalingPhases updates the progress of the traffic switch to the
+// prescaled stacks.
+func (r PrescalingTrafficReconciler) updatePrescalingPhases(stacks map[string]*StackContainer, currentTimestamp time.Time) {
+	for _, stack := range stacks {
+		switch {
+		case !stack.prescalingActive:
+			stack.prescalingPhase = ""
+		case stack.actualTrafficWeight >= stack.prescalingDesiredTrafficWeight:
+			stack.prescalingPhase = zv1.PrescalingPhaseCompleted
+			if stack.prescalingCompletionTime.IsZero() {
+				stack.prescalingCompletionTime = currentTimestamp
+			}
+		case !stack.prescalingStartTime.IsZero() && currentTimestamp.Sub(stack.prescalingStartTime) > r.ResetHPAMinReplicasTimeout:
+			stack.prescalingPhase = zv1.PrescalingPhaseTimedOut
+		case stack.deploymentReplicas < stack.prescalingReplicas:
+			stack.prescalingPhase = zv1.PrescalingPhasePending
+		case stack.readyReplicas < stack.prescalingReplicas:
+			stack.prescalingPhase = zv1.PrescalingPhaseScaling
+		default:
+			stack.prescalingPhase = zv1.PrescalingPhaseWaiting
+		}
+	}
 }
 
 func (r PrescalingTrafficReconciler) Reconcile(stacks map[string]*StackContainer, currentTimestamp time.Time) error {
+	defer r.updatePrescalingPhases(stacks, currentTimestamp)
+
 	// Calculate how many replicas we need per unit of traffic
 	totalReplicas := 0.0
 	totalTraffic := 0.0
@@ -34,11 +238,13 @@ func (r PrescalingTrafficReconciler) Reconcile(stacks map[string]*StackContainer
 			}
 		} else if stack.actualTrafficWeight > 0 {
 			// Stack has traffic and is not prescaled
-			totalReplicas += float64(stack.deploymentReplicas)
+			totalReplicas += float64(stack.prescalingSourceReplicas())
 			totalTraffic += stack.actualTrafficWeight
 		}
 	}
 
+	totalRequestsPerSecond := r.lazyTotalRequestsPerSecond(stacks)
+
 	// Prescale stacks if needed
 	for _, stack := range stacks {
 		// If traffic needs to be increased
@@ -47,16 +253,30 @@ func (r PrescalingTrafficReconciler) Reconcile(stacks map[string]*StackContainer
 			// the target replica count
 			if !stack.prescalingActive || stack.prescalingDesiredTrafficWeight < stack.desiredTrafficWeight {
 				stack.prescalingDesiredTrafficWeight = stack.desiredTrafficWeight
+				stack.prescalingStartTime = currentTimestamp
+				stack.prescalingCompletionTime = time.Time{}
 
 				if totalTraffic != 0 {
 					stack.prescalingReplicas = int32(math.Ceil(stack.desiredTrafficWeight * totalReplicas / totalTraffic))
 				}
 
+				// Prefer the measured load if the capacity of the stack is known
+				if capacity := stack.requestsPerSecondPerPod(); r.RequestsPerSecond != nil && capacity > 0 {
+					if totalRPS, ok := totalRequestsPerSecond(); ok {
+						stack.prescalingReplicas = int32(math.Ceil(totalRPS * stack.desiredTrafficWeight / 100 / capacity))
+					}
+				}
+
 				// Unable to determine target scale, fallback to stack replicas
 				if stack.prescalingReplicas == 0 {
 					stack.prescalingReplicas = effectiveReplicas(stack.Stack.Spec.Replicas)
 				}
 
+				// Add the configured head-room
+				if r.BufferPercent > 0 {
+					stack.prescalingReplicas = int32(math.Ceil(float64(stack.prescalingReplicas) * float64(100+r.BufferPercent) / 100))
+				}
+
 				// Limit to MaxReplicas
 				if stack.prescalingReplicas > stack.MaxReplicas() {
 					stack.prescalingReplicas = stack.MaxReplicas()
@@ -64,22 +284,34 @@ func (r PrescalingTrafficReconciler) Reconcile(stacks map[string]*StackContainer
 
 			}
 
+			// Scale up to at least the replicas which have to be ready
+			// before the traffic is increased
+			if required := r.requiredReadyReplicas(stack, totalReplicas, totalTraffic); stack.prescalingReplicas < required {
+				stack.prescalingReplicas = required
+			}
+
 			stack.prescalingActive = true
 			stack.prescalingLastTrafficIncrease = currentTimestamp
 		}
 
 		// If prescaling is active and the prescaling timeout has expired then deactivate the prescaling
-		if stack.prescalingActive && !stack.prescalingLastTrafficIncrease.IsZero() && time.Since(stack.prescalingLastTrafficIncrease) > r.ResetHPAMinReplicasTimeout {
+		if stack.prescalingActive && !stack.prescalingLastTrafficIncrease.IsZero() && currentTimestamp.Sub(stack.prescalingLastTrafficIncrease) > r.ResetHPAMinReplicasTimeout {
 			stack.prescalingActive = false
 			stack.prescalingReplicas = 0
 			stack.prescalingDesiredTrafficWeight = 0
 			stack.prescalingLastTrafficIncrease = time.Time{}
+			stack.prescalingReadySince = time.Time{}
+			stack.prescalingStartTime = time.Time{}
+			stack.prescalingCompletionTime = time.Time{}
 		}
 	}
 
 	// Update the traffic weights:
 	// * If prescaling is active on the stack then it only gets traffic if it has readyReplicas >= prescaleReplicas.
+	// * If a readiness threshold is configured then the stack only gets traffic if it has enough ready replicas
+	//   for its desired share of the total replicas.
 	// * If stack is getting traffic but ReadyReplicas < prescaleReplicas, don't remove traffic from it.
+	// * If the stack isn't ready because some of its pods can't be scheduled, it gets the InsufficientCapacity condition.
 	// * If no stacks are currently being prescaled fall back to the current weights.
 	// * If no stacks are getting traffic fall back to desired weight without checking health.
 	var nonReadyStacks []string
@@ -91,10 +323,32 @@ func (r PrescalingTrafficReconciler) Reconcile(stacks map[string]*StackContainer
 			if stack.prescalingActive {
 				desiredReplicas = stack.prescalingReplicas
 			}
-			if !stack.IsReady() || stack.updatedReplicas < desiredReplicas || stack.readyReplicas < desiredReplicas {
-				nonReadyStacks = append(nonReadyStacks, stackName)
+			if !stack.IsReady() || stack.updatedReplicas < desiredReplicas || stack.readyReplicas < desiredReplicas ||
+				stack.readyReplicas < r.requiredReadyReplicas(stack, totalReplicas, totalTraffic) {
+				stack.prescalingReadySince = time.Time{}
+				unschedulablePods := r.unschedulablePods(stack)
+				updateCapacityCondition(stack, unschedulablePods)
+				if unschedulablePods > 0 {
+					nonReadyStacks = append(nonReadyStacks, stackName+" (insufficient capacity)")
+				} else {
+					nonReadyStacks = append(nonReadyStacks, stackName)
+				}
 				continue
 			}
+			updateCapacityCondition(stack, 0)
+
+			// Give the prescaled stack some time to warm up before switching traffic
+			if stack.prescalingActive && r.Cooldown > 0 {
+				if stack.prescalingReadySince.IsZero() {
+					stack.prescalingReadySince = currentTimestamp
+				}
+				if currentTimestamp.Sub(stack.prescalingReadySince) < r.Cooldown {
+					nonReadyStacks = append(nonReadyStacks, stackName)
+					continue
+				}
+			}
+		} else {
+			updateCapacityCondition(stack, 0)
 		}
 
 		actualWeights[stackName] = stack.desiredTrafficWeight
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultTrafficRampStepPercent = 10
+	defaultTrafficRampInterval    = 5 * time.Minute
+
+	// trafficWeightTolerance is the difference between two traffic
+	// weights ignored when comparing them, to account for rounding errors.
+	trafficWeightTolerance = 0.01
+)
+
+// TrafficAnalysisProvider queries the values of the analysis checks of
+// traffic ramps and of the error rate watched for traffic rollbacks.
+type TrafficAnalysisProvider interface {
+	CheckValue(check zv1.TrafficAnalysisCheck, namespace, stacksetName, stackName string) (float64, error)
+}
+
+// rampTraffic moves a step of the desired traffic towards the target weights
+// of the traffic ramp once the interval since the last step is over. The
+// step is planned for all the stacks at once: every stack moves the same
+// share of the way to its target weight, so that the traffic of several
+// stacks can be drained into several others proportionally. The next step is
+// only taken after the previous one was applied and while the target stacks
+// are ready and all the analysis checks pass. If a check fails, the ramp is
+// stopped and the traffic is rolled back if configured. The passed weights
+// must be normalized.
+func (ssc *StackSetContainer) rampTraffic(stacks map[string]*StackContainer, desiredWeights, actualWeights map[string]float64, currentTimestamp time.Time) error {
+	var ramp *zv1.TrafficRampSpec
+	if ssc.StackSet.Spec.TrafficPolicy != nil {
+		ramp = ssc.StackSet.Spec.TrafficPolicy.Ramp
+	}
+	if ramp == nil {
+		ssc.trafficRamp = nil
+		return nil
+	}
+
+	target, targets := trafficRampTargets(ssc.StackSet, ramp)
+	var statusTargets map[string]float64
+	if target == "" {
+		statusTargets = targets
+	}
+
+	// Start over if the target changed
+	if ssc.trafficRamp != nil && (ssc.trafficRamp.Target != target || !trafficWeightsEqual(ssc.trafficRamp.TargetWeights, statusTargets)) {
+		ssc.trafficRamp = nil
+	}
+
+	// Failed ramps keep the traffic until the target is changed
+	if ssc.trafficRamp != nil && ssc.trafficRamp.Failed {
+		return nil
+	}
+
+	targetStacks := trafficRampTargetStacks(targets)
+	for _, stackName := range targetStacks {
+		stack, ok := stacks[stackName]
+		if !ok || stack.failed || !stack.IsReady() {
+			return nil
+		}
+	}
+
+	// The ramp is completed once all the stacks reached their target weight
+	if trafficWeightsEqual(desiredWeights, targets) {
+		return nil
+	}
+
+	// Wait until the previous step is applied
+	for _, stackName := range targetStacks {
+		if actualWeights[stackName] < desiredWeights[stackName]-trafficWeightTolerance {
+			return nil
+		}
+	}
+
+	interval := defaultTrafficRampInterval
+	if ramp.Interval != nil {
+		interval = ramp.Interval.Duration
+	}
+	if ssc.trafficRamp != nil && currentTimestamp.Sub(ssc.trafficRamp.LastStepTime.Time) < interval {
+		return nil
+	}
+
+	// Analyze the traffic of the previous step before taking the next one
+	if ssc.trafficRamp != nil && len(ramp.Analysis) > 0 {
+		for _, stackName := range targetStacks {
+			reason, err := ssc.analyzeTraffic(ramp.Analysis, stacks[stackName])
+			if err != nil {
+				return err
+			}
+			if reason == "" {
+				continue
+			}
+			if target == "" {
+				reason = fmt.Sprintf("stack %s: %s", stackName, reason)
+			}
+			ssc.trafficRamp.Failed = true
+			ssc.trafficRamp.Reason = reason
+			if ramp.OnFailure == zv1.TrafficRampFailurePolicyRollback {
+				restoreTrafficWeights(desiredWeights, ssc.trafficRamp.InitialWeights)
+			}
+			return nil
+		}
+	}
+
+	initialWeights := copyWeights(desiredWeights)
+	if ssc.trafficRamp != nil {
+		initialWeights = ssc.trafficRamp.InitialWeights
+	}
+
+	step := float64(defaultTrafficRampStepPercent)
+	if ramp.StepPercent > 0 {
+		step = float64(ramp.StepPercent)
+	}
+
+	// Move all the stacks the same share of the way to their target
+	// weights, so that no more than a step of the traffic is moved in total
+	shift := 0.0
+	for stackName, weight := range targets {
+		if weight > desiredWeights[stackName] {
+			shift += weight - desiredWeights[stackName]
+		}
+	}
+	for stackName := range stacks {
+		if shift <= step {
+			desiredWeights[stackName] = targets[stackName]
+		} else {
+			desiredWeights[stackName] += (targets[stackName] - desiredWeights[stackName]) * step / shift
+		}
+	}
+
+	ssc.trafficRamp = &zv1.TrafficRampStatus{
+		Target:         target,
+		TargetWeights:  statusTargets,
+		LastStepTime:   metav1.NewTime(currentTimestamp),
+		InitialWeights: initialWeights,
+	}
+	return nil
+}
+
+// trafficRampTargets returns the final traffic weights of a traffic ramp,
+// normalized to a sum of 100. If the traffic is moved to a single stack, its
+// name is returned as well.
+func trafficRampTargets(stackset *zv1.StackSet, ramp *zv1.TrafficRampSpec) (string, map[string]float64) {
+	if len(ramp.Targets) > 0 {
+		targets := make(map[string]float64, len(ramp.Targets))
+		for _, target := range ramp.Targets {
+			targets[target.StackName] = target.Weight
+		}
+		normalizeWeights(targets)
+		return "", targets
+	}
+
+	target := ramp.Target
+	if target == "" {
+		target = generateStackName(stackset, currentStackVersion(stackset))
+	}
+	return target, map[string]float64{target: 100}
+}
+
+// trafficRampTargetStacks returns the sorted names of the stacks which get
+// traffic at the end of a traffic ramp.
+func trafficRampTargetStacks(targets map[string]float64) []string {
+	var result []string
+	for stackName, weight := range targets {
+		if weight > 0 {
+			result = append(result, stackName)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// trafficWeightsEqual returns true if the traffic weights of all the stacks
+// are equal within the tolerance. Missing stacks have no traffic.
+func trafficWeightsEqual(a, b map[string]float64) bool {
+	for stackName, weight := range a {
+		if math.Abs(weight-b[stackName]) > trafficWeightTolerance {
+			return false
+		}
+	}
+	for stackName, weight := range b {
+		if math.Abs(weight-a[stackName]) > trafficWeightTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// analyzeTraffic evaluates the analysis checks for the target stack and
+// returns the reason why the first failing check failed, or an empty string
+// if all of them passed.
+func (ssc *StackSetContainer) analyzeTraffic(checks []zv1.TrafficAnalysisCheck, target *StackContainer) (string, error) {
+	if ssc.TrafficAnalysis == nil {
+		return "", errors.New("traffic analysis is not supported")
+	}
+
+	for _, check := range checks {
+		value, err := ssc.TrafficAnalysis.CheckValue(check, target.Namespace(), ssc.StackSet.Name, target.Name())
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate analysis check %s: %v", check.Name, err)
+		}
+		if check.Min != nil && value < float64(check.Min.MilliValue())/1000 {
+			return fmt.Sprintf("analysis check %s failed: %g is below the minimum of %s", check.Name, value, check.Min.String()), nil
+		}
+		if check.Max != nil && value > float64(check.Max.MilliValue())/1000 {
+			return fmt.Sprintf("analysis check %s failed: %g is above the maximum of %s", check.Name, value, check.Max.String()), nil
+		}
+	}
+	return "", nil
+}
+
+// copyWeights returns a copy of a map of traffic weights.
+func copyWeights(weights map[string]float64) map[string]float64 {
+	result := make(map[string]float64, len(weights))
+	for stackName, weight := range weights {
+		result[stackName] = weight
+	}
+	return result
+}
+
+// restoreTrafficWeights sets the desired weights back to the weights from
+// before a traffic change. Stacks created since then don't get any traffic.
+// The weights are kept if none of the initial stacks are left.
+func restoreTrafficWeights(desiredWeights, initialWeights map[string]float64) {
+	restored := make(map[string]float64, len(desiredWeights))
+	for stackName := range desiredWeights {
+		restored[stackName] = initialWeights[stackName]
+	}
+	if allZero(restored) {
+		return
+	}
+
+	normalizeWeights(restored)
+	for stackName, stackWeight := range restored {
+		desiredWeights[stackName] = stackWeight
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"math"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultTrafficRollbackWindow = 10 * time.Minute
+)
+
+// guardTraffic watches the error rate of the stack gaining the most traffic
+// compared to the last known good distribution. If it exceeds the threshold
+// within the window, the desired weights are rolled back to the known good
+// distribution and a ramp to the stack is stopped. Otherwise the desired
+// weights become the known good distribution once the window is over. The
+// passed weights must be normalized.
+func (ssc *StackSetContainer) guardTraffic(stacks map[string]*StackContainer, desiredWeights map[string]float64, currentTimestamp time.Time) error {
+	var rollback *zv1.TrafficRollbackSpec
+	if ssc.StackSet.Spec.TrafficPolicy != nil {
+		rollback = ssc.StackSet.Spec.TrafficPolicy.Rollback
+	}
+	if rollback == nil {
+		ssc.trafficRollback = nil
+		return nil
+	}
+
+	// The current distribution is considered good when the guard is enabled
+	status := ssc.trafficRollback
+	if status == nil {
+		ssc.trafficRollback = &zv1.TrafficRollbackStatus{KnownGoodWeights: copyWeights(desiredWeights)}
+		return nil
+	}
+
+	gaining, increase := "", trafficWeightTolerance
+	for stackName, weight := range desiredWeights {
+		if weight-status.KnownGoodWeights[stackName] > increase {
+			gaining, increase = stackName, weight-status.KnownGoodWeights[stackName]
+		}
+	}
+	if gaining == "" {
+		resetTrafficRollbackWatch(status)
+		status.KnownGoodWeights = copyWeights(desiredWeights)
+		return nil
+	}
+
+	// Restart the window whenever the traffic of the watched stack changes
+	if status.Stack != gaining || math.Abs(status.Weight-desiredWeights[gaining]) > trafficWeightTolerance || status.Since == nil {
+		since := metav1.NewTime(currentTimestamp)
+		status.Stack = gaining
+		status.Weight = desiredWeights[gaining]
+		status.Since = &since
+	}
+
+	reason, err := ssc.analyzeTraffic([]zv1.TrafficAnalysisCheck{rollback.ErrorRate}, stacks[gaining])
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		restoreTrafficWeights(desiredWeights, status.KnownGoodWeights)
+
+		rolledBack := metav1.NewTime(currentTimestamp)
+		status.LastRollbackTime = &rolledBack
+		status.LastRollbackReason = reason
+		resetTrafficRollbackWatch(status)
+
+		if ssc.trafficRamp != nil && (ssc.trafficRamp.Target == gaining || ssc.trafficRamp.TargetWeights[gaining] > 0) {
+			ssc.trafficRamp.Failed = true
+			ssc.trafficRamp.Reason = "traffic rolled back: " + reason
+		}
+		return nil
+	}
+
+	window := defaultTrafficRollbackWindow
+	if rollback.Window != nil {
+		window = rollback.Window.Duration
+	}
+	if currentTimestamp.Sub(status.Since.Time) >= window {
+		resetTrafficRollbackWatch(status)
+		status.KnownGoodWeights = copyWeights(desiredWeights)
+	}
+	return nil
+}
+
+// resetTrafficRollbackWatch stops watching the error rate of a stack.
+func resetTrafficRollbackWatch(status *zv1.TrafficRollbackStatus) {
+	status.Stack = ""
+	status.Weight = 0
+	status.Since = nil
+}
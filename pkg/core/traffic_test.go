@@ -1,11 +1,18 @@
 package core
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	apps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -97,6 +104,537 @@ func TestTrafficSwitchSimpleNotReady(t *testing.T) {
 	}
 }
 
+func TestTrafficSwitchFailedStack(t *testing.T) {
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(0, 100).ready(3).stack(),
+			"v2": testStack("foo-v2").createdAt(time.Now()).traffic(100, 0).deployment(true, 3, 3, 0).failed().stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+	}
+	err := c.ManageTraffic(time.Now())
+	require.NoError(t, err)
+	require.EqualValues(t, 100, c.StackContainers["v1"].desiredTrafficWeight)
+	require.EqualValues(t, 100, c.StackContainers["v1"].actualTrafficWeight)
+	require.EqualValues(t, 0, c.StackContainers["v2"].desiredTrafficWeight)
+	require.EqualValues(t, 0, c.StackContainers["v2"].actualTrafficWeight)
+}
+
+func TestTrafficSwitchDrainingStack(t *testing.T) {
+	now := time.Now()
+
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+				StackLifecycle: zv1.StackLifecycle{
+					DrainDuration: &metav1.Duration{Duration: time.Minute},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(50, 50).ready(3).stack(),
+			"v2": testStack("foo-v2").traffic(50, 50).ready(3).draining(now.Add(-30*time.Second), 50).stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+	}
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.InDelta(t, 66.67, c.StackContainers["v1"].actualTrafficWeight, 0.01)
+	require.InDelta(t, 33.33, c.StackContainers["v2"].actualTrafficWeight, 0.01)
+
+	// the traffic is gone once the drain duration passed
+	err = c.ManageTraffic(now.Add(30 * time.Second))
+	require.NoError(t, err)
+	require.EqualValues(t, 100, c.StackContainers["v1"].actualTrafficWeight)
+	require.EqualValues(t, 0, c.StackContainers["v2"].actualTrafficWeight)
+}
+
+func TestTrafficSwitchMaxTrafficWeight(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		stacks          map[types.UID]*StackContainer
+		expectedWeights map[string]float64
+	}{
+		{
+			name: "the excess is redistributed proportionally to the other stacks",
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(30, 30).ready(3).stack(),
+				"v2": testStack("foo-v2").traffic(10, 10).ready(3).stack(),
+				"v3": testStack("foo-v3").traffic(60, 60).ready(3).maxTrafficWeight(20).stack(),
+			},
+			expectedWeights: map[string]float64{"foo-v1": 60, "foo-v2": 20, "foo-v3": 20},
+		},
+		{
+			name: "the excess goes to the fallback stack if the other stacks don't get traffic",
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(0, 100).ready(3).noTrafficSince(time.Now()).stack(),
+				"v2": testStack("foo-v2").traffic(100, 0).ready(3).maxTrafficWeight(5).stack(),
+			},
+			expectedWeights: map[string]float64{"foo-v1": 95, "foo-v2": 5},
+		},
+		{
+			name: "the weights are kept if all the stacks are capped",
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50, 50).ready(3).maxTrafficWeight(10).stack(),
+				"v2": testStack("foo-v2").traffic(50, 50).ready(3).maxTrafficWeight(10).stack(),
+			},
+			expectedWeights: map[string]float64{"foo-v1": 50, "foo-v2": 50},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers:   tc.stacks,
+				TrafficReconciler: SimpleTrafficReconciler{},
+			}
+			err := c.ManageTraffic(time.Now())
+			require.NoError(t, err)
+			for _, stack := range c.StackContainers {
+				require.InDelta(t, tc.expectedWeights[stack.Name()], stack.desiredTrafficWeight, 0.01)
+				require.InDelta(t, tc.expectedWeights[stack.Name()], stack.actualTrafficWeight, 0.01)
+			}
+		})
+	}
+}
+
+func TestTrafficSwitchMinWeightChange(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		stacks          map[types.UID]*StackContainer
+		expectedWeights map[string]float64
+	}{
+		{
+			name: "changes below the minimum are ignored",
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(50.4, 50).ready(3).stack(),
+				"v2": testStack("foo-v2").traffic(49.6, 50).ready(3).stack(),
+			},
+			expectedWeights: map[string]float64{"foo-v1": 50, "foo-v2": 50},
+		},
+		{
+			name: "changes reaching the minimum are applied",
+			stacks: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(51, 50).ready(3).stack(),
+				"v2": testStack("foo-v2").traffic(49, 50).ready(3).stack(),
+			},
+			expectedWeights: map[string]float64{"foo-v1": 51, "foo-v2": 49},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress:       &zv1.StackSetIngressSpec{},
+						TrafficPolicy: &zv1.StackSetTrafficSpec{MinWeightChange: 1},
+					},
+				},
+				StackContainers:   tc.stacks,
+				TrafficReconciler: SimpleTrafficReconciler{},
+			}
+			err := c.ManageTraffic(time.Now())
+			require.NoError(t, err)
+			for _, stack := range c.StackContainers {
+				require.InDelta(t, tc.expectedWeights[stack.Name()], stack.desiredTrafficWeight, 0.01)
+				require.InDelta(t, tc.expectedWeights[stack.Name()], stack.actualTrafficWeight, 0.01)
+			}
+		})
+	}
+}
+
+func TestTrafficSwitchRamp(t *testing.T) {
+	now := time.Now()
+
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+				TrafficPolicy: &zv1.StackSetTrafficSpec{
+					Ramp: &zv1.TrafficRampSpec{
+						StepPercent: 40,
+						Interval:    &metav1.Duration{Duration: time.Minute},
+					},
+				},
+				StackTemplate: zv1.StackTemplate{
+					Spec: zv1.StackSpecTemplate{Version: "v2"},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(75, 75).ready(3).stack(),
+			"v2": testStack("foo-v2").traffic(25, 25).ready(3).stack(),
+			"v3": testStack("foo-v3").traffic(0, 0).ready(3).stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+	}
+
+	// the first step is taken right away
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.InDelta(t, 35, c.StackContainers["v1"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 65, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+	require.EqualValues(t, 0, c.StackContainers["v3"].desiredTrafficWeight)
+	require.Equal(t, "foo-v2", c.trafficRamp.Target)
+
+	// the next step waits for the interval
+	err = c.ManageTraffic(now.Add(30 * time.Second))
+	require.NoError(t, err)
+	require.InDelta(t, 65, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+
+	// the last step doesn't exceed the full traffic
+	err = c.ManageTraffic(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 0, c.StackContainers["v1"].desiredTrafficWeight)
+	require.EqualValues(t, 100, c.StackContainers["v2"].desiredTrafficWeight)
+
+	// a new target starts over
+	c.StackSet.Spec.TrafficPolicy.Ramp.Target = "foo-v3"
+	err = c.ManageTraffic(now.Add(90 * time.Second))
+	require.NoError(t, err)
+	require.InDelta(t, 60, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 40, c.StackContainers["v3"].desiredTrafficWeight, 0.01)
+	require.Equal(t, "foo-v3", c.trafficRamp.Target)
+
+	// stacks which aren't ready don't get more traffic
+	c.StackContainers["v3"] = testStack("foo-v3").traffic(40, 40).stack()
+	err = c.ManageTraffic(now.Add(time.Hour))
+	require.NoError(t, err)
+	require.InDelta(t, 40, c.StackContainers["v3"].desiredTrafficWeight, 0.01)
+
+	// the ramp is reset once it's removed
+	c.StackSet.Spec.TrafficPolicy.Ramp = nil
+	err = c.ManageTraffic(now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Nil(t, c.trafficRamp)
+}
+
+type fakeTrafficAnalysis map[string]float64
+
+func (f fakeTrafficAnalysis) CheckValue(check zv1.TrafficAnalysisCheck, namespace, stacksetName, stackName string) (float64, error) {
+	value, ok := f[check.Name]
+	if !ok {
+		return 0, fmt.Errorf("no value for check %s", check.Name)
+	}
+	return value, nil
+}
+
+func TestTrafficSwitchRampMultipleStacks(t *testing.T) {
+	now := time.Now()
+
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+				TrafficPolicy: &zv1.StackSetTrafficSpec{
+					Ramp: &zv1.TrafficRampSpec{
+						Targets: []zv1.StackTrafficWeight{
+							{StackName: "foo-v3", Weight: 70},
+							{StackName: "foo-v4", Weight: 30},
+						},
+						StepPercent: 50,
+						Interval:    &metav1.Duration{Duration: time.Minute},
+					},
+				},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(50, 50).ready(3).stack(),
+			"v2": testStack("foo-v2").traffic(50, 50).ready(3).stack(),
+			"v3": testStack("foo-v3").traffic(0, 0).ready(3).stack(),
+			"v4": testStack("foo-v4").traffic(0, 0).ready(3).stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+	}
+
+	// all the stacks move half of the way in the first step
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.InDelta(t, 25, c.StackContainers["v1"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 25, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 35, c.StackContainers["v3"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 15, c.StackContainers["v4"].desiredTrafficWeight, 0.01)
+	require.Empty(t, c.trafficRamp.Target)
+	require.Equal(t, map[string]float64{"foo-v3": 70, "foo-v4": 30}, c.trafficRamp.TargetWeights)
+
+	// the last step reaches the target weights
+	err = c.ManageTraffic(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 0, c.StackContainers["v1"].desiredTrafficWeight)
+	require.EqualValues(t, 0, c.StackContainers["v2"].desiredTrafficWeight)
+	require.InDelta(t, 70, c.StackContainers["v3"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 30, c.StackContainers["v4"].desiredTrafficWeight, 0.01)
+
+	// the ramp waits for all the target stacks to be ready
+	c.StackSet.Spec.TrafficPolicy.Ramp.Targets = []zv1.StackTrafficWeight{
+		{StackName: "foo-v1", Weight: 50},
+		{StackName: "foo-v3", Weight: 50},
+	}
+	c.StackContainers["v1"] = testStack("foo-v1").traffic(0, 0).stack()
+	err = c.ManageTraffic(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 0, c.StackContainers["v1"].desiredTrafficWeight)
+	require.InDelta(t, 70, c.StackContainers["v3"].desiredTrafficWeight, 0.01)
+}
+
+func TestTrafficSwitchRampAnalysis(t *testing.T) {
+	now := time.Now()
+	maxErrorRate := resource.MustParse("50m")
+
+	for _, tc := range []struct {
+		name            string
+		onFailure       zv1.TrafficRampFailurePolicy
+		values          fakeTrafficAnalysis
+		expectedWeights map[string]float64
+		expectedFailed  bool
+		expectedError   bool
+	}{
+		{
+			name:            "the next step is taken if the checks pass",
+			values:          fakeTrafficAnalysis{"error-rate": 0.01},
+			expectedWeights: map[string]float64{"v1": 70, "v2": 30},
+		},
+		{
+			name:            "the ramp is halted if a check fails",
+			values:          fakeTrafficAnalysis{"error-rate": 0.1},
+			expectedWeights: map[string]float64{"v1": 80, "v2": 20},
+			expectedFailed:  true,
+		},
+		{
+			name:            "the traffic is rolled back if a check fails",
+			onFailure:       zv1.TrafficRampFailurePolicyRollback,
+			values:          fakeTrafficAnalysis{"error-rate": 0.1},
+			expectedWeights: map[string]float64{"v1": 100, "v2": 0},
+			expectedFailed:  true,
+		},
+		{
+			name:            "the ramp waits if a check can't be evaluated",
+			values:          fakeTrafficAnalysis{},
+			expectedWeights: map[string]float64{"v1": 80, "v2": 20},
+			expectedError:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+						TrafficPolicy: &zv1.StackSetTrafficSpec{
+							Ramp: &zv1.TrafficRampSpec{
+								Target: "foo-v2",
+								Analysis: []zv1.TrafficAnalysisCheck{
+									{Name: "error-rate", Max: &maxErrorRate},
+								},
+								OnFailure: tc.onFailure,
+							},
+						},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"v1": testStack("foo-v1").traffic(80, 80).ready(3).stack(),
+					"v2": testStack("foo-v2").traffic(20, 20).ready(3).stack(),
+				},
+				TrafficReconciler: SimpleTrafficReconciler{},
+				TrafficAnalysis:   tc.values,
+				trafficRamp: &zv1.TrafficRampStatus{
+					Target:         "foo-v2",
+					LastStepTime:   metav1.NewTime(now.Add(-time.Hour)),
+					InitialWeights: map[string]float64{"foo-v1": 100},
+				},
+			}
+
+			err := c.ManageTraffic(now)
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			for uid, weight := range tc.expectedWeights {
+				require.InDelta(t, weight, c.StackContainers[types.UID(uid)].desiredTrafficWeight, 0.01, "stack %s", uid)
+			}
+			require.Equal(t, tc.expectedFailed, c.trafficRamp.Failed)
+			if tc.expectedFailed {
+				require.Contains(t, c.trafficRamp.Reason, "error-rate")
+			}
+		})
+	}
+}
+
+func TestTrafficSwitchRollback(t *testing.T) {
+	now := time.Now()
+	maxErrorRate := resource.MustParse("50m")
+
+	newContainer := func(errorRate float64) *StackSetContainer {
+		return &StackSetContainer{
+			StackSet: &zv1.StackSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: zv1.StackSetSpec{
+					Ingress: &zv1.StackSetIngressSpec{},
+					TrafficPolicy: &zv1.StackSetTrafficSpec{
+						Rollback: &zv1.TrafficRollbackSpec{
+							ErrorRate: zv1.TrafficAnalysisCheck{Name: "error-rate", Max: &maxErrorRate},
+							Window:    &metav1.Duration{Duration: 10 * time.Minute},
+						},
+					},
+				},
+			},
+			StackContainers: map[types.UID]*StackContainer{
+				"v1": testStack("foo-v1").traffic(70, 70).ready(3).stack(),
+				"v2": testStack("foo-v2").traffic(30, 30).ready(3).stack(),
+			},
+			TrafficReconciler: SimpleTrafficReconciler{},
+			TrafficAnalysis:   fakeTrafficAnalysis{"error-rate": errorRate},
+		}
+	}
+
+	// the current distribution is considered good when the guard is enabled
+	c := newContainer(0.1)
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.InDelta(t, 70, c.trafficRollback.KnownGoodWeights["foo-v1"], 0.01)
+	require.InDelta(t, 30, c.trafficRollback.KnownGoodWeights["foo-v2"], 0.01)
+	require.Empty(t, c.trafficRollback.Stack)
+
+	// the stack gaining traffic is watched and the distribution becomes
+	// good once the window is over
+	c = newContainer(0.01)
+	c.trafficRollback = &zv1.TrafficRollbackStatus{KnownGoodWeights: map[string]float64{"foo-v1": 100}}
+	err = c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.Equal(t, "foo-v2", c.trafficRollback.Stack)
+	require.InDelta(t, 30, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+
+	err = c.ManageTraffic(now.Add(10 * time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, c.trafficRollback.Stack)
+	require.InDelta(t, 30, c.trafficRollback.KnownGoodWeights["foo-v2"], 0.01)
+
+	// the traffic is rolled back if the error rate is exceeded within the
+	// window, stopping a ramp to the stack
+	c = newContainer(0.1)
+	c.trafficRollback = &zv1.TrafficRollbackStatus{KnownGoodWeights: map[string]float64{"foo-v1": 100}}
+	c.StackSet.Spec.TrafficPolicy.Ramp = &zv1.TrafficRampSpec{Target: "foo-v2", Interval: &metav1.Duration{Duration: time.Hour}}
+	c.trafficRamp = &zv1.TrafficRampStatus{Target: "foo-v2", LastStepTime: metav1.NewTime(now)}
+	err = c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.InDelta(t, 100, c.StackContainers["v1"].desiredTrafficWeight, 0.01)
+	require.InDelta(t, 0, c.StackContainers["v2"].desiredTrafficWeight, 0.01)
+	require.Empty(t, c.trafficRollback.Stack)
+	require.NotNil(t, c.trafficRollback.LastRollbackTime)
+	require.Contains(t, c.trafficRollback.LastRollbackReason, "error-rate")
+	require.True(t, c.trafficRamp.Failed)
+}
+
+func TestTrafficSwitchHistory(t *testing.T) {
+	now := time.Now()
+	historyLimit := int32(2)
+
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: zv1.StackSetSpec{
+				Ingress:       &zv1.StackSetIngressSpec{},
+				TrafficPolicy: &zv1.StackSetTrafficSpec{HistoryLimit: &historyLimit},
+			},
+		},
+		Ingress: &extensions.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{TrafficChangedByAnnotationKey: "jdoe"},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(70, 70).ready(3).stack(),
+			"v2": testStack("foo-v2").traffic(30, 30).ready(3).stack(),
+			"v3": testStack("foo-v3").traffic(0, 0).ready(3).failed().stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+	}
+
+	// the initial weights are recorded
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.Len(t, c.trafficHistory, 1)
+	require.Equal(t, zv1.TrafficChangeSourceAnnotation, c.trafficHistory[0].Source)
+	require.Equal(t, "jdoe", c.trafficHistory[0].ChangedBy)
+	require.Empty(t, c.trafficHistory[0].From)
+	require.Equal(t, []zv1.StackTrafficWeight{{StackName: "foo-v1", Weight: 70}, {StackName: "foo-v2", Weight: 30}}, c.trafficHistory[0].To)
+
+	// unchanged weights aren't recorded
+	err = c.ManageTraffic(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, c.trafficHistory, 1)
+
+	// changes by the controller are recorded after the external ones,
+	// keeping only the most recent changes
+	c.StackContainers["v3"].desiredTrafficWeight = 50
+	c.StackContainers["v1"].desiredTrafficWeight = 50
+	c.StackContainers["v2"].desiredTrafficWeight = 0
+	err = c.ManageTraffic(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, c.trafficHistory, 2)
+	require.Equal(t, zv1.TrafficChangeSourceAnnotation, c.trafficHistory[0].Source)
+	require.Equal(t, []zv1.StackTrafficWeight{{StackName: "foo-v1", Weight: 50}, {StackName: "foo-v3", Weight: 50}}, c.trafficHistory[0].To)
+	require.Equal(t, zv1.TrafficChangeSourceController, c.trafficHistory[1].Source)
+	require.Equal(t, trafficChangedByController, c.trafficHistory[1].ChangedBy)
+	require.Equal(t, c.trafficHistory[0].To, c.trafficHistory[1].From)
+	require.Equal(t, []zv1.StackTrafficWeight{{StackName: "foo-v1", Weight: 100}}, c.trafficHistory[1].To)
+}
+
+func TestTrafficSwitchSnapshot(t *testing.T) {
+	now := time.Date(2019, time.August, 6, 3, 0, 0, 0, time.UTC)
+
+	c := &StackSetContainer{
+		StackSet: &zv1.StackSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"v1": testStack("foo-v1").traffic(0, 100).ready(3).stack(),
+			"v2": testStack("foo-v2").traffic(100, 0).ready(3).stack(),
+		},
+		TrafficReconciler: SimpleTrafficReconciler{},
+		trafficHistory: []zv1.TrafficChangeRecord{
+			{To: []zv1.StackTrafficWeight{{StackName: "foo-v1", Weight: 100}}},
+		},
+	}
+
+	// a snapshot of the weights is taken before they're changed
+	err := c.ManageTraffic(now)
+	require.NoError(t, err)
+	require.Len(t, c.trafficSnapshots, 1)
+	require.Equal(t, "20190806-030000", c.trafficSnapshots[0].Name)
+	require.Equal(t, []zv1.StackTrafficWeight{{StackName: "foo-v1", Weight: 100}}, c.trafficSnapshots[0].Weights)
+
+	// unknown snapshots aren't restored
+	c.StackSet.Spec.TrafficSnapshotRestore = "20190805-030000"
+	err = c.ManageTraffic(now.Add(time.Minute))
+	require.EqualError(t, err, "unknown traffic snapshot 20190805-030000")
+	require.EqualValues(t, 100, c.StackContainers["v2"].desiredTrafficWeight)
+
+	// the snapshot is restored, taking a snapshot of the current weights
+	c.StackSet.Spec.TrafficSnapshotRestore = "20190806-030000"
+	err = c.ManageTraffic(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 100, c.StackContainers["v1"].desiredTrafficWeight)
+	require.EqualValues(t, 0, c.StackContainers["v2"].desiredTrafficWeight)
+	require.Len(t, c.trafficSnapshots, 2)
+	require.Equal(t, "20190806-030200", c.trafficSnapshots[1].Name)
+	require.Equal(t, []zv1.StackTrafficWeight{{StackName: "foo-v2", Weight: 100}}, c.trafficSnapshots[1].Weights)
+}
+
 func TestTrafficSwitchSimple(t *testing.T) {
 	for _, tc := range []struct {
 		name                   string
@@ -703,6 +1241,562 @@ func TestTrafficSwitchPrescaling(t *testing.T) {
 	}
 }
 
+func TestTrafficSwitchPrescalingCooldown(t *testing.T) {
+	now := time.Now()
+	thirtySecondsAgo := now.Add(-30 * time.Second)
+	twoMinutesAgo := now.Add(-2 * time.Minute)
+
+	for _, tc := range []struct {
+		name                  string
+		stacks                map[types.UID]*StackContainer
+		expectedActualWeights map[string]float64
+		expectedReadySince    time.Time
+		expectedError         string
+	}{
+		{
+			name: "traffic is not switched when the prescaled stack just became ready",
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(50, 100).ready(4).stack(),
+				"foo-v2": testStack("foo-v2").traffic(50, 0).ready(2).prescaling(2, 50, now).stack(),
+			},
+			expectedActualWeights: map[string]float64{"foo-v1": 100, "foo-v2": 0},
+			expectedReadySince:    now,
+			expectedError:         "stacks not ready: foo-v2",
+		},
+		{
+			name: "traffic is not switched during the cooldown",
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(50, 100).ready(4).stack(),
+				"foo-v2": testStack("foo-v2").traffic(50, 0).ready(2).prescaling(2, 50, now).prescalingReadySince(thirtySecondsAgo).stack(),
+			},
+			expectedActualWeights: map[string]float64{"foo-v1": 100, "foo-v2": 0},
+			expectedReadySince:    thirtySecondsAgo,
+			expectedError:         "stacks not ready: foo-v2",
+		},
+		{
+			name: "traffic is switched after the cooldown",
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(50, 100).ready(4).stack(),
+				"foo-v2": testStack("foo-v2").traffic(50, 0).ready(2).prescaling(2, 50, now).prescalingReadySince(twoMinutesAgo).stack(),
+			},
+			expectedActualWeights: map[string]float64{"foo-v1": 50, "foo-v2": 50},
+			expectedReadySince:    twoMinutesAgo,
+		},
+		{
+			name: "cooldown is restarted if the prescaled stack is not ready anymore",
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(50, 100).ready(4).stack(),
+				"foo-v2": testStack("foo-v2").traffic(50, 0).deployment(false, 2, 2, 1).prescaling(2, 50, now).prescalingReadySince(twoMinutesAgo).stack(),
+			},
+			expectedActualWeights: map[string]float64{"foo-v1": 100, "foo-v2": 0},
+			expectedError:         "stacks not ready: foo-v2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: tc.stacks,
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+					Cooldown:                   time.Minute,
+				},
+			}
+
+			err := c.ManageTraffic(now)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				require.Equal(t, tc.expectedError, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			actualWeights := map[string]float64{}
+			for name := range tc.expectedActualWeights {
+				actualWeights[name] = c.StackContainers[types.UID(name)].actualTrafficWeight
+			}
+			require.Equal(t, tc.expectedActualWeights, actualWeights)
+			require.Equal(t, tc.expectedReadySince, c.StackContainers["foo-v2"].prescalingReadySince)
+		})
+	}
+}
+
+func TestTrafficSwitchPrescalingBuffer(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		bufferPercent    int32
+		sourceReplicas   int32
+		stack            *StackContainer
+		expectedReplicas int32
+	}{
+		{
+			name:             "no buffer",
+			sourceReplicas:   10,
+			stack:            testStack("foo-v2").traffic(50, 0).stack(),
+			expectedReplicas: 5,
+		},
+		{
+			name:             "buffer is added and rounded up",
+			bufferPercent:    20,
+			sourceReplicas:   10,
+			stack:            testStack("foo-v2").traffic(50, 0).stack(),
+			expectedReplicas: 6,
+		},
+		{
+			name:             "buffer is limited to max replicas",
+			bufferPercent:    20,
+			sourceReplicas:   10,
+			stack:            testStack("foo-v2").traffic(50, 0).maxReplicas(5).stack(),
+			expectedReplicas: 5,
+		},
+		{
+			name:             "buffer is added to the fallback replicas",
+			bufferPercent:    20,
+			sourceReplicas:   0,
+			stack:            testStack("foo-v2").traffic(50, 0).replicas(4).stack(),
+			expectedReplicas: 5,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"foo-v1": testStack("foo-v1").traffic(50, 100).ready(tc.sourceReplicas).stack(),
+					"foo-v2": tc.stack,
+				},
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+					BufferPercent:              tc.bufferPercent,
+				},
+			}
+
+			_ = c.ManageTraffic(time.Now())
+			require.True(t, tc.stack.prescalingActive)
+			require.Equal(t, tc.expectedReplicas, tc.stack.prescalingReplicas)
+		})
+	}
+}
+
+func TestTrafficSwitchPrescalingReadinessThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		name                      string
+		readinessThresholdPercent int32
+		expectedReplicas          int32
+		expectedWeight            float64
+	}{
+		{
+			name:             "traffic is switched once the prescaled replicas are ready",
+			expectedReplicas: 3,
+			expectedWeight:   50,
+		},
+		{
+			name:                      "traffic is not switched without enough ready replicas for the desired weight",
+			readinessThresholdPercent: 100,
+			expectedReplicas:          5,
+			expectedWeight:            0,
+		},
+		{
+			name:                      "traffic is switched once the threshold is reached",
+			readinessThresholdPercent: 50,
+			expectedReplicas:          3,
+			expectedWeight:            50,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stack := testStack("foo-v2").traffic(50, 0).ready(3).prescaling(3, 50, time.Now()).stack()
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"foo-v1": testStack("foo-v1").traffic(50, 100).ready(10).stack(),
+					"foo-v2": stack,
+				},
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+					ReadinessThresholdPercent:  tc.readinessThresholdPercent,
+				},
+			}
+
+			_ = c.ManageTraffic(time.Now())
+			require.Equal(t, tc.expectedReplicas, stack.prescalingReplicas)
+			require.InDelta(t, tc.expectedWeight, stack.actualTrafficWeight, 0.01)
+		})
+	}
+}
+
+func TestTrafficSwitchPrescalingSourceHPA(t *testing.T) {
+	hpa := func(maxReplicas, currentReplicas, desiredReplicas, utilization int32) *autoscaling.HorizontalPodAutoscaler {
+		target := int32(50)
+		return &autoscaling.HorizontalPodAutoscaler{
+			Spec: autoscaling.HorizontalPodAutoscalerSpec{
+				MaxReplicas: maxReplicas,
+				Metrics: []autoscaling.MetricSpec{
+					{
+						Type: autoscaling.ResourceMetricSourceType,
+						Resource: &autoscaling.ResourceMetricSource{
+							Name:                     corev1.ResourceCPU,
+							TargetAverageUtilization: &target,
+						},
+					},
+				},
+			},
+			Status: autoscaling.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: currentReplicas,
+				DesiredReplicas: desiredReplicas,
+				CurrentMetrics: []autoscaling.MetricStatus{
+					{
+						Type: autoscaling.ResourceMetricSourceType,
+						Resource: &autoscaling.ResourceMetricStatus{
+							Name:                      corev1.ResourceCPU,
+							CurrentAverageUtilization: &utilization,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name             string
+		hpa              *autoscaling.HorizontalPodAutoscaler
+		expectedReplicas int32
+	}{
+		{
+			name:             "the current replicas are used without an HPA",
+			expectedReplicas: 2,
+		},
+		{
+			name:             "the current replicas are used if the utilization is below the target",
+			hpa:              hpa(10, 4, 4, 40),
+			expectedReplicas: 2,
+		},
+		{
+			name:             "the replicas needed for the current utilization are used",
+			hpa:              hpa(20, 4, 4, 150),
+			expectedReplicas: 6,
+		},
+		{
+			name:             "the desired replicas of the HPA are used",
+			hpa:              hpa(10, 4, 8, 50),
+			expectedReplicas: 4,
+		},
+		{
+			name:             "the needed replicas are limited to the max replicas of the HPA",
+			hpa:              hpa(6, 4, 4, 150),
+			expectedReplicas: 3,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			source := testStack("foo-v1").traffic(50, 100).ready(4).stack()
+			source.Resources.HPA = tc.hpa
+			target := testStack("foo-v2").traffic(50, 0).stack()
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"foo-v1": source,
+					"foo-v2": target,
+				},
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+				},
+			}
+
+			_ = c.ManageTraffic(time.Now())
+			require.True(t, target.prescalingActive)
+			require.Equal(t, tc.expectedReplicas, target.prescalingReplicas)
+		})
+	}
+}
+
+type fakeCapacity int
+
+func (f fakeCapacity) UnschedulablePods(namespace string, selector map[string]string) (int, error) {
+	return int(f), nil
+}
+
+func TestTrafficSwitchPrescalingCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		unschedulablePods int
+		expectedError     string
+		expectedCondition bool
+	}{
+		{
+			name:              "the switch is held if pods can't be scheduled",
+			unschedulablePods: 2,
+			expectedError:     "stacks not ready: foo-v2 (insufficient capacity)",
+			expectedCondition: true,
+		},
+		{
+			name:          "the condition is removed once the pods are scheduled",
+			expectedError: "stacks not ready: foo-v2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stack := testStack("foo-v2").traffic(50, 0).deployment(true, 5, 5, 3).stack()
+			stack.Resources.Deployment = &apps.Deployment{
+				Spec: apps.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"stack-version": "v2"}},
+				},
+			}
+			stack.conditions = []zv1.StackCondition{insufficientCapacityCondition(1)}
+
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"foo-v1": testStack("foo-v1").traffic(50, 100).ready(10).stack(),
+					"foo-v2": stack,
+				},
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+					Capacity:                   fakeCapacity(tc.unschedulablePods),
+				},
+			}
+
+			err := c.ManageTraffic(time.Now())
+			require.EqualError(t, err, tc.expectedError)
+			require.EqualValues(t, 0, stack.actualTrafficWeight)
+			condition := getStackCondition(stack.conditions, zv1.StackConditionInsufficientCapacity)
+			if tc.expectedCondition {
+				require.NotNil(t, condition)
+				require.Contains(t, condition.Message, "2 pods")
+			} else {
+				require.Nil(t, condition)
+			}
+		})
+	}
+}
+
+func TestTrafficSwitchPrescalingPhases(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name              string
+		stack             *StackContainer
+		expectedPhase     zv1.PrescalingPhase
+		expectedStatus    corev1.ConditionStatus
+		expectedReason    string
+		expectedMessage   string
+		expectedCompleted bool
+	}{
+		{
+			name:            "prescaling starts with the stack pending",
+			stack:           testStack("foo-v2").traffic(50, 0).ready(1).stack(),
+			expectedPhase:   zv1.PrescalingPhasePending,
+			expectedStatus:  corev1.ConditionTrue,
+			expectedReason:  "Pending",
+			expectedMessage: "foo-v2: Pending, 1/5 replicas ready",
+		},
+		{
+			name:            "the prescaled replicas aren't ready yet",
+			stack:           testStack("foo-v2").traffic(50, 0).deployment(true, 5, 5, 3).prescaling(5, 50, now).stack(),
+			expectedPhase:   zv1.PrescalingPhaseScaling,
+			expectedStatus:  corev1.ConditionTrue,
+			expectedReason:  "Scaling",
+			expectedMessage: "foo-v2: Scaling, 3/5 replicas ready",
+		},
+		{
+			name:            "the switch takes longer than the prescaling timeout",
+			stack:           testStack("foo-v2").traffic(50, 0).deployment(true, 5, 5, 3).prescaling(5, 50, now).prescalingStartTime(now.Add(-10 * time.Minute)).stack(),
+			expectedPhase:   zv1.PrescalingPhaseTimedOut,
+			expectedStatus:  corev1.ConditionTrue,
+			expectedReason:  "TimedOut",
+			expectedMessage: "foo-v2: TimedOut, 3/5 replicas ready",
+		},
+		{
+			name:              "the traffic is switched",
+			stack:             testStack("foo-v2").traffic(50, 0).ready(5).prescaling(5, 50, now).stack(),
+			expectedPhase:     zv1.PrescalingPhaseCompleted,
+			expectedStatus:    corev1.ConditionFalse,
+			expectedReason:    "Completed",
+			expectedCompleted: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: map[types.UID]*StackContainer{
+					"foo-v1": testStack("foo-v1").traffic(50, 100).ready(10).stack(),
+					"foo-v2": tc.stack,
+				},
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+				},
+			}
+
+			_ = c.ManageTraffic(now)
+			require.Equal(t, tc.expectedPhase, tc.stack.prescalingPhase)
+			require.Equal(t, tc.expectedCompleted, !tc.stack.prescalingCompletionTime.IsZero())
+
+			status := c.GenerateStackSetStatus()
+			condition := getStackSetCondition(status.Conditions, zv1.StackSetConditionTrafficSwitchProgressing)
+			require.NotNil(t, condition)
+			require.Equal(t, tc.expectedStatus, condition.Status)
+			require.Equal(t, tc.expectedReason, condition.Reason)
+			require.Equal(t, tc.expectedMessage, condition.Message)
+		})
+	}
+}
+
+func TestTrafficSwitchConditionRemoved(t *testing.T) {
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"foo-v1": testStack("foo-v1").traffic(100, 100).ready(10).stack(),
+		},
+		TrafficReconciler: PrescalingTrafficReconciler{
+			ResetHPAMinReplicasTimeout: 5 * time.Minute,
+		},
+		conditions: []zv1.StackSetCondition{
+			{Type: zv1.StackSetConditionTrafficSwitchProgressing, Status: corev1.ConditionFalse, Reason: "Completed"},
+		},
+	}
+
+	require.NoError(t, c.ManageTraffic(time.Now()))
+	require.Nil(t, getStackSetCondition(c.GenerateStackSetStatus().Conditions, zv1.StackSetConditionTrafficSwitchProgressing))
+}
+
+type fakeRequestsPerSecond map[string]float64
+
+func (f fakeRequestsPerSecond) StackRequestsPerSecond(namespace, stacksetName, stackName string) (float64, error) {
+	rps, ok := f[stackName]
+	if !ok {
+		return 0, fmt.Errorf("no metrics for stack %s", stackName)
+	}
+	return rps, nil
+}
+
+func TestTrafficSwitchPrescalingByRPS(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		name             string
+		requests         fakeRequestsPerSecond
+		stacks           map[types.UID]*StackContainer
+		expectedReplicas map[string]int32
+	}{
+		{
+			name:     "replicas are calculated from the requests per second and the capacity per pod",
+			requests: fakeRequestsPerSecond{"foo-v1": 300, "foo-v2": 100},
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(25, 75).ready(30).stack(),
+				"foo-v2": testStack("foo-v2").traffic(25, 25).ready(10).stack(),
+				"foo-v3": testStack("foo-v3").traffic(50, 0).requestsPerSecondPerPod(50).stack(),
+			},
+			// 400 rps * 50% / 50 rps per pod
+			expectedReplicas: map[string]int32{"foo-v3": 4},
+		},
+		{
+			name:     "fall back to the replica sum if the capacity is unknown",
+			requests: fakeRequestsPerSecond{"foo-v1": 300, "foo-v2": 100},
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(25, 75).ready(30).stack(),
+				"foo-v2": testStack("foo-v2").traffic(25, 25).ready(10).stack(),
+				"foo-v3": testStack("foo-v3").traffic(50, 0).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v3": 20},
+		},
+		{
+			name:     "fall back to the replica sum if the metrics are not available",
+			requests: fakeRequestsPerSecond{"foo-v1": 300},
+			stacks: map[types.UID]*StackContainer{
+				"foo-v1": testStack("foo-v1").traffic(25, 75).ready(30).stack(),
+				"foo-v2": testStack("foo-v2").traffic(25, 25).ready(10).stack(),
+				"foo-v3": testStack("foo-v3").traffic(50, 0).requestsPerSecondPerPod(50).stack(),
+			},
+			expectedReplicas: map[string]int32{"foo-v3": 20},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := StackSetContainer{
+				StackSet: &zv1.StackSet{
+					Spec: zv1.StackSetSpec{
+						Ingress: &zv1.StackSetIngressSpec{},
+					},
+				},
+				StackContainers: tc.stacks,
+				TrafficReconciler: PrescalingTrafficReconciler{
+					ResetHPAMinReplicasTimeout: 5 * time.Minute,
+					RequestsPerSecond:          tc.requests,
+				},
+			}
+
+			_ = c.ManageTraffic(now)
+			for name, replicas := range tc.expectedReplicas {
+				stack := c.StackContainers[types.UID(name)]
+				require.True(t, stack.prescalingActive, "stack %s", name)
+				require.Equal(t, replicas, stack.prescalingReplicas, "stack %s", name)
+			}
+		})
+	}
+}
+
+type countingRequestsPerSecond struct {
+	fakeRequestsPerSecond
+	calls int
+}
+
+func (f *countingRequestsPerSecond) StackRequestsPerSecond(namespace, stacksetName, stackName string) (float64, error) {
+	f.calls++
+	return f.fakeRequestsPerSecond.StackRequestsPerSecond(namespace, stacksetName, stackName)
+}
+
+func TestTrafficSwitchPrescalingByRPSQueries(t *testing.T) {
+	requests := &countingRequestsPerSecond{fakeRequestsPerSecond: fakeRequestsPerSecond{"foo-v1": 300}}
+	c := StackSetContainer{
+		StackSet: &zv1.StackSet{
+			Spec: zv1.StackSetSpec{
+				Ingress: &zv1.StackSetIngressSpec{},
+			},
+		},
+		StackContainers: map[types.UID]*StackContainer{
+			"foo-v1": testStack("foo-v1").traffic(100, 100).ready(3).stack(),
+			"foo-v2": testStack("foo-v2").traffic(0, 0).requestsPerSecondPerPod(50).stack(),
+		},
+		TrafficReconciler: PrescalingTrafficReconciler{
+			ResetHPAMinReplicasTimeout: 5 * time.Minute,
+			RequestsPerSecond:          requests,
+		},
+	}
+
+	// the metrics aren't queried without a traffic switch
+	require.NoError(t, c.ManageTraffic(time.Now()))
+	require.Zero(t, requests.calls)
+
+	// but once a stack starts being prescaled
+	c.StackContainers["foo-v1"].desiredTrafficWeight = 50
+	c.StackContainers["foo-v2"].desiredTrafficWeight = 50
+	_ = c.ManageTraffic(time.Now())
+	require.Equal(t, 1, requests.calls)
+	require.EqualValues(t, 3, c.StackContainers["foo-v2"].prescalingReplicas)
+}
+
 func TestTrafficSwitchNoTrafficSince(t *testing.T) {
 	for reconcilerName, reconciler := range map[string]TrafficReconciler{
 		"simple": SimpleTrafficReconciler{},
@@ -16,9 +16,16 @@ import (
 )
 
 const (
-	defaultVersion             = "default"
-	defaultStackLifecycleLimit = 10
-	defaultScaledownTTL        = 300 * time.Second
+	defaultVersion      = "default"
+	defaultWarmReplicas = 1
+
+	// DefaultStackLifecycleLimit is the number of stacks kept if the
+	// limit isn't set in the StackSet.
+	DefaultStackLifecycleLimit = 10
+
+	// DefaultScaledownTTL is the time after which stacks without traffic
+	// are scaled down if it isn't set in the StackSet.
+	DefaultScaledownTTL = 300 * time.Second
 )
 
 // StackSetContainer is a container for storing the full state of a StackSet
@@ -44,6 +51,88 @@ type StackSetContainer struct {
 	// switching traffic between stacks. E.g. for prescaling stacks before
 	// switching traffic.
 	TrafficReconciler TrafficReconciler
+
+	// AutoscalerFreezeWindow is the maximum duration for which the HPAs
+	// of the stacks are pinned to their current number of replicas while
+	// traffic is being switched. Zero disables freezing.
+	AutoscalerFreezeWindow time.Duration
+
+	// AggregateAutoscaling enables treating the autoscalers of all the
+	// stacks getting traffic as a single logical autoscaler. The total
+	// number of replicas is distributed between the stacks proportionally
+	// to their traffic weight.
+	AggregateAutoscaling bool
+
+	// AutoscalerProfile is the name of the active autoscaler profile
+	// defined in the StackSet. If empty, the stacks are autoscaled based
+	// on the metrics defined in their own autoscaler.
+	AutoscalerProfile string
+
+	// HPATolerance overrides the tolerance of the autoscalers of the
+	// stacks, i.e. the relative difference between the current and the
+	// target metric value within which the replicas aren't changed. Zero
+	// keeps the default of the HPA controller.
+	HPATolerance float64
+
+	// HPACPUInitializationPeriod is the period after the HPA of a stack
+	// scaled during which the HPA isn't allowed to scale again based on
+	// the CPU of the started pods. Zero keeps the default of the HPA
+	// controller.
+	HPACPUInitializationPeriod time.Duration
+
+	// ResourceUsage is an optional provider of the measured resource usage
+	// of the containers of the stacks. If set, recommended resource
+	// requests are reported in the status of the stacks.
+	ResourceUsage ResourceUsageProvider
+
+	// HPAFieldOwnership restricts the reconciliation of the HPAs of the
+	// stacks to the fields generated by the controller, leaving fields
+	// added by other controllers untouched.
+	HPAFieldOwnership bool
+
+	// AdoptResources enables taking over Stacks, Deployments and Services
+	// which were created outside of the controller.
+	AdoptResources bool
+
+	// TrafficAnalysis queries the analysis checks of the traffic ramp and
+	// the error rate watched for traffic rollbacks.
+	TrafficAnalysis TrafficAnalysisProvider
+
+	// pendingRemoval are the stacks which are going to be deleted once
+	// the removal grace period is over.
+	pendingRemoval []zv1.PendingStackRemoval
+
+	// trafficRamp is the progress of the traffic ramp.
+	trafficRamp *zv1.TrafficRampStatus
+
+	// trafficRollback is the state of the automatic traffic rollback.
+	trafficRollback *zv1.TrafficRollbackStatus
+
+	// trafficHistory are the last changes of the desired traffic weights.
+	trafficHistory []zv1.TrafficChangeRecord
+
+	// trafficSnapshots are the last desired traffic weights taken before
+	// they were changed.
+	trafficSnapshots []zv1.TrafficSnapshot
+
+	// conditions are the conditions of the StackSet.
+	conditions []zv1.StackSetCondition
+
+	// deprecations are the deprecated features used by the StackSet.
+	deprecations []string
+
+	// lastErrors are the last errors which occurred while reconciling the
+	// StackSet, nil until an error is recorded.
+	lastErrors []zv1.ReconcileErrorRecord
+
+	// lastChanges are the changes made by the current reconciliation, nil
+	// if it didn't change anything.
+	lastChanges *zv1.ChangeSummary
+
+	// ingressReconciled is true once the ingress of the StackSet was
+	// reconciled, with ingressErr being the result.
+	ingressReconciled bool
+	ingressErr        error
 }
 
 // StackContainer is a container for storing the full state of a Stack
@@ -60,9 +149,17 @@ type StackContainer struct {
 	Resources StackResources
 
 	// Fields from the parent stackset
-	stacksetName string
-	ingressSpec  *zv1.StackSetIngressSpec
-	scaledownTTL time.Duration
+	stacksetName      string
+	ingressSpec       *zv1.StackSetIngressSpec
+	scaledownTTL      time.Duration
+	scaleDownBehavior zv1.ScaleDownBehavior
+	warmReplicas      int32
+	pinned            bool
+
+	autoscalerProfile          string
+	autoscalerProfiles         []zv1.AutoscalerProfile
+	hpaTolerance               float64
+	hpaCPUInitializationPeriod time.Duration
 
 	// Fields from the stack itself, with some defaults applied
 	stackReplicas int32
@@ -74,6 +171,14 @@ type StackContainer struct {
 	readyReplicas      int32
 	updatedReplicas    int32
 	desiredReplicas    int32
+	autoscalerStatus   *zv1.AutoscalerStatus
+
+	// Resource requests recommended based on the observed usage
+	resourceRecommendations           []zv1.ContainerResourceRecommendation
+	resourceRecommendationsUpdateTime time.Time
+
+	// Result of the calls of the pre-deletion hook
+	preDeleteHookStatus *zv1.LifecycleHookStatus
 
 	// Traffic & scaling
 	currentActualTrafficWeight     float64
@@ -84,6 +189,36 @@ type StackContainer struct {
 	prescalingReplicas             int32
 	prescalingDesiredTrafficWeight float64
 	prescalingLastTrafficIncrease  time.Time
+	prescalingReadySince           time.Time
+	prescalingPhase                zv1.PrescalingPhase
+	prescalingStartTime            time.Time
+	prescalingCompletionTime       time.Time
+	trafficSwitching               bool
+	drainingSince                  time.Time
+	drainingTrafficWeight          float64
+	autoscalerFrozen               bool
+	autoscalerHeld                 bool
+	autoscalerFrozenSince          time.Time
+
+	// Conditions of the stack
+	conditions []zv1.StackCondition
+
+	// Error of the last reconciliation of the resources of the stack
+	resourcesErr error
+
+	// Why the stack was selected to be deleted, empty if it wasn't
+	removalReason string
+
+	// Whether the stack didn't become ready within the readiness deadline
+	failed bool
+
+	// Number of replicas assigned to the stack by the aggregated autoscaler
+	// of the stackset. Zero if the stack is autoscaled on its own.
+	aggregatedReplicas int32
+
+	// Minimum number of replicas required by the traffic forecast of the
+	// stackset. Zero if no forecasted traffic spike is coming up.
+	forecastMinReplicas int32
 }
 
 // TrafficChange contains information about a traffic change event
@@ -101,12 +236,51 @@ func (sc *StackContainer) HasTraffic() bool {
 	return sc.actualTrafficWeight > 0 || sc.desiredTrafficWeight > 0
 }
 
+// ActualTrafficWeight returns the amount of traffic currently routed to the
+// stack.
+func (sc *StackContainer) ActualTrafficWeight() float64 {
+	return sc.actualTrafficWeight
+}
+
+// DesiredTrafficWeight returns the amount of traffic which should be routed
+// to the stack.
+func (sc *StackContainer) DesiredTrafficWeight() float64 {
+	return sc.desiredTrafficWeight
+}
+
+// RemovalReason describes why the stack was selected to be deleted and by
+// which rule, or returns an empty string if it wasn't.
+func (sc *StackContainer) RemovalReason() string {
+	return sc.removalReason
+}
+
+// Draining returns true if the traffic of the stack is being drained before
+// it's deleted.
+func (sc *StackContainer) Draining() bool {
+	return !sc.drainingSince.IsZero()
+}
+
+// PreDeleteHookStatus returns the result of the previous calls of the
+// pre-deletion hook for the stack, nil if it wasn't called yet.
+func (sc *StackContainer) PreDeleteHookStatus() *zv1.LifecycleHookStatus {
+	return sc.preDeleteHookStatus
+}
+
+// SetPreDeleteHookStatus records the result of a call of the pre-deletion
+// hook for the stack, which is persisted in the stack status.
+func (sc *StackContainer) SetPreDeleteHookStatus(status *zv1.LifecycleHookStatus) {
+	sc.preDeleteHookStatus = status
+}
+
 func (sc *StackContainer) IsReady() bool {
 	// Stacks are considered ready when all subresources have been updated, and we have enough replicas
 	return sc.resourcesUpdated && sc.deploymentReplicas == sc.updatedReplicas && sc.deploymentReplicas == sc.readyReplicas
 }
 
 func (sc *StackContainer) MaxReplicas() int32 {
+	if sc.autoscalerDisabled() {
+		return math.MaxInt32
+	}
 	if sc.Stack.Spec.Autoscaler != nil {
 		return sc.Stack.Spec.Autoscaler.MaxReplicas
 	}
@@ -116,12 +290,33 @@ func (sc *StackContainer) MaxReplicas() int32 {
 	return math.MaxInt32
 }
 
+// minReplicas returns the lower bound of the autoscaler of the stack.
+func (sc *StackContainer) minReplicas() int32 {
+	var minReplicas *int32
+	if sc.Stack.Spec.Autoscaler != nil {
+		minReplicas = sc.Stack.Spec.Autoscaler.MinReplicas
+	} else if sc.Stack.Spec.HorizontalPodAutoscaler != nil {
+		minReplicas = sc.Stack.Spec.HorizontalPodAutoscaler.MinReplicas
+	}
+	if minReplicas == nil {
+		return 1
+	}
+	return *minReplicas
+}
+
 func (sc *StackContainer) IsAutoscaled() bool {
-	return sc.Stack.Spec.HorizontalPodAutoscaler != nil || sc.Stack.Spec.Autoscaler != nil
+	return (sc.Stack.Spec.HorizontalPodAutoscaler != nil || sc.Stack.Spec.Autoscaler != nil) && !sc.autoscalerDisabled()
+}
+
+// autoscalerDisabled returns true if the HPA generation is disabled for the
+// stack with an annotation.
+func (sc *StackContainer) autoscalerDisabled() bool {
+	_, ok := sc.Stack.Annotations[DisableAutoscalerAnnotationKey]
+	return ok
 }
 
 func (sc *StackContainer) ScaledDown() bool {
-	if sc.HasTraffic() {
+	if sc.HasTraffic() || sc.pinned {
 		return false
 	}
 	return !sc.noTrafficSince.IsZero() && time.Since(sc.noTrafficSince) > sc.scaledownTTL
@@ -152,8 +347,57 @@ func (ssc *StackSetContainer) stackByName(name string) *StackContainer {
 	return nil
 }
 
-// UpdateTrafficFromIngress updates traffic weights of stack containers from the ingress object
+// DesiredTrafficInSpec returns true if the desired traffic weights are
+// defined in the stackset spec instead of the ingress annotation.
+func (ssc *StackSetContainer) DesiredTrafficInSpec() bool {
+	return len(ssc.StackSet.Spec.Traffic) > 0
+}
+
+// desiredTrafficFromSpec returns the desired traffic weights defined in the
+// stackset spec, including the changes made by the controller, which are
+// kept in the status as long as the weights of the spec stay the same.
+func (ssc *StackSetContainer) desiredTrafficFromSpec() []zv1.StackTrafficWeight {
+	status := ssc.StackSet.Status
+	if len(status.DesiredTraffic) > 0 && trafficWeightListsEqual(status.ObservedTraffic, ssc.StackSet.Spec.Traffic) {
+		return status.DesiredTraffic
+	}
+	return ssc.StackSet.Spec.Traffic
+}
+
+// trafficWeightListsEqual returns true if both lists contain the same stacks
+// with the same weights within the tolerance.
+func trafficWeightListsEqual(a, b []zv1.StackTrafficWeight) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].StackName != b[i].StackName || math.Abs(a[i].Weight-b[i].Weight) > trafficWeightTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateTrafficFromIngress updates traffic weights of stack containers from the ingress object.
+// The desired weights are taken from the stackset spec if defined there.
 func (ssc *StackSetContainer) updateTrafficFromIngress() error {
+	desired, actual, err := ssc.readTrafficWeights()
+	if err != nil {
+		return err
+	}
+
+	for _, container := range ssc.StackContainers {
+		container.desiredTrafficWeight = desired[container.Name()]
+		container.actualTrafficWeight = actual[container.Name()]
+		container.currentActualTrafficWeight = actual[container.Name()]
+	}
+
+	return nil
+}
+
+// readTrafficWeights returns the normalized desired and actual traffic
+// weights of the stacks.
+func (ssc *StackSetContainer) readTrafficWeights() (map[string]float64, map[string]float64, error) {
 	desired := make(map[string]float64)
 	actual := make(map[string]float64)
 
@@ -163,17 +407,27 @@ func (ssc *StackSetContainer) updateTrafficFromIngress() error {
 			stacksetNames[sc.Name()] = struct{}{}
 		}
 
-		if weights, ok := ssc.Ingress.Annotations[stackTrafficWeightsAnnotationKey]; ok {
+		if ssc.DesiredTrafficInSpec() {
+			for _, weight := range ssc.desiredTrafficFromSpec() {
+				if _, ok := desired[weight.StackName]; ok {
+					return nil, nil, fmt.Errorf("invalid desired Stack traffic weights: stack %s is listed more than once", weight.StackName)
+				}
+				if weight.Weight < 0 {
+					return nil, nil, fmt.Errorf("invalid desired Stack traffic weights: negative weight for stack %s", weight.StackName)
+				}
+				desired[weight.StackName] = weight.Weight
+			}
+		} else if weights, ok := ssc.Ingress.Annotations[stackTrafficWeightsAnnotationKey]; ok {
 			err := json.Unmarshal([]byte(weights), &desired)
 			if err != nil {
-				return fmt.Errorf("failed to get current desired Stack traffic weights: %v", err)
+				return nil, nil, fmt.Errorf("failed to get current desired Stack traffic weights: %v", err)
 			}
 		}
 
 		if weights, ok := ssc.Ingress.Annotations[backendWeightsAnnotationKey]; ok {
 			err := json.Unmarshal([]byte(weights), &actual)
 			if err != nil {
-				return fmt.Errorf("failed to get current actual Stack traffic weights: %v", err)
+				return nil, nil, fmt.Errorf("failed to get current actual Stack traffic weights: %v", err)
 			}
 		}
 
@@ -191,25 +445,74 @@ func (ssc *StackSetContainer) updateTrafficFromIngress() error {
 		}
 	}
 
-	for _, container := range ssc.StackContainers {
-		container.desiredTrafficWeight = desired[container.Name()]
-		container.actualTrafficWeight = actual[container.Name()]
-		container.currentActualTrafficWeight = actual[container.Name()]
+	return desired, actual, nil
+}
+
+// TrafficSwitchPending returns true if the desired traffic of the StackSet
+// differs from the actual traffic or if stacks are being prescaled, i.e. if
+// its reconciliation affects the traffic. It only looks at the collected
+// resources and can be used before UpdateFromResources.
+func (ssc *StackSetContainer) TrafficSwitchPending() bool {
+	for _, sc := range ssc.StackContainers {
+		if sc.Stack.Status.Prescaling.Active {
+			return true
+		}
 	}
 
-	return nil
+	desired, actual, err := ssc.readTrafficWeights()
+	if err != nil {
+		return false
+	}
+	for _, weights := range []map[string]float64{desired, actual} {
+		for name := range weights {
+			if desired[name] != actual[name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pinnedVersion returns true if the stack version is pinned in the stackset.
+func (ssc *StackSetContainer) pinnedVersion(version string) bool {
+	if version == "" {
+		return false
+	}
+	for _, pinned := range ssc.StackSet.Spec.PinnedVersions {
+		if version == pinned {
+			return true
+		}
+	}
+	return false
 }
 
 // UpdateFromResources populates stack state information (e.g. replica counts or traffic) from related resources
 func (ssc *StackSetContainer) UpdateFromResources() error {
+	ssc.pendingRemoval = ssc.StackSet.Status.PendingRemoval
+	ssc.trafficRamp = ssc.StackSet.Status.TrafficRamp.DeepCopy()
+	ssc.trafficRollback = ssc.StackSet.Status.TrafficRollback.DeepCopy()
+	ssc.trafficHistory = ssc.StackSet.Status.TrafficHistory
+	ssc.trafficSnapshots = ssc.StackSet.Status.TrafficSnapshots
+	ssc.conditions = ssc.StackSet.Status.Conditions
+
 	for _, sc := range ssc.StackContainers {
 		sc.stacksetName = ssc.StackSet.Name
 		sc.ingressSpec = ssc.StackSet.Spec.Ingress
 		if ssc.StackSet.Spec.StackLifecycle.ScaledownTTLSeconds == nil {
-			sc.scaledownTTL = defaultScaledownTTL
+			sc.scaledownTTL = DefaultScaledownTTL
 		} else {
 			sc.scaledownTTL = time.Duration(*ssc.StackSet.Spec.StackLifecycle.ScaledownTTLSeconds) * time.Second
 		}
+		sc.scaleDownBehavior = ssc.StackSet.Spec.StackLifecycle.ScaleDownBehavior
+		sc.warmReplicas = defaultWarmReplicas
+		if ssc.StackSet.Spec.StackLifecycle.WarmReplicas != nil {
+			sc.warmReplicas = *ssc.StackSet.Spec.StackLifecycle.WarmReplicas
+		}
+		sc.pinned = ssc.pinnedVersion(sc.Stack.Labels[StackVersionLabelKey])
+		sc.autoscalerProfile = ssc.AutoscalerProfile
+		sc.autoscalerProfiles = ssc.StackSet.Spec.AutoscalerProfiles
+		sc.hpaTolerance = ssc.HPATolerance
+		sc.hpaCPUInitializationPeriod = ssc.HPACPUInitializationPeriod
 		sc.updateFromResources()
 	}
 
@@ -267,6 +570,13 @@ func (sc *StackContainer) updateFromResources() {
 	if sc.Resources.HPA != nil {
 		hpa := sc.Resources.HPA
 		sc.desiredReplicas = hpa.Status.DesiredReplicas
+		sc.autoscalerStatus = &zv1.AutoscalerStatus{
+			CurrentReplicas: hpa.Status.CurrentReplicas,
+			DesiredReplicas: hpa.Status.DesiredReplicas,
+			LastScaleTime:   hpa.Status.LastScaleTime,
+			CurrentMetrics:  hpa.Status.CurrentMetrics,
+			Conditions:      hpa.Status.Conditions,
+		}
 	}
 	if sc.IsAutoscaled() {
 		hpaUpdated = sc.Resources.HPA != nil && IsResourceUpToDate(sc.Stack, sc.Resources.HPA.ObjectMeta)
@@ -279,10 +589,35 @@ func (sc *StackContainer) updateFromResources() {
 
 	status := sc.Stack.Status
 	sc.noTrafficSince = unwrapTime(status.NoTrafficSince)
+	sc.autoscalerFrozenSince = unwrapTime(status.AutoscalerFrozenSince)
+	sc.drainingSince = unwrapTime(status.DrainingSince)
+	sc.drainingTrafficWeight = status.DrainingTrafficWeight
+	sc.resourceRecommendations = status.ResourceRecommendations
+	sc.resourceRecommendationsUpdateTime = unwrapTime(status.ResourceRecommendationsUpdateTime)
+	sc.preDeleteHookStatus = status.PreDeleteHook.DeepCopy()
+
+	// autoscaler validation
+	sc.conditions = status.Conditions
+	if sc.Stack.Spec.Autoscaler != nil {
+		sc.conditions = setStackCondition(sc.conditions, autoscalerCondition(sc.validateAutoscaler()))
+	} else {
+		sc.conditions = removeStackCondition(sc.conditions, zv1.StackConditionAutoscalerValid)
+	}
+
+	// replicas conflicting with the autoscaler
+	if sc.IsAutoscaled() && sc.Stack.Spec.Replicas != nil {
+		sc.conditions = setStackCondition(sc.conditions, replicasConflictCondition(*sc.Stack.Spec.Replicas, sc.minReplicas(), sc.MaxReplicas()))
+	} else {
+		sc.conditions = removeStackCondition(sc.conditions, zv1.StackConditionReplicasConflict)
+	}
 	if status.Prescaling.Active {
 		sc.prescalingActive = true
 		sc.prescalingReplicas = status.Prescaling.Replicas
 		sc.prescalingDesiredTrafficWeight = status.Prescaling.DesiredTrafficWeight
 		sc.prescalingLastTrafficIncrease = unwrapTime(status.Prescaling.LastTrafficIncrease)
+		sc.prescalingReadySince = unwrapTime(status.Prescaling.ReadySince)
+		sc.prescalingPhase = status.Prescaling.Phase
+		sc.prescalingStartTime = unwrapTime(status.Prescaling.StartTime)
+		sc.prescalingCompletionTime = unwrapTime(status.Prescaling.CompletionTime)
 	}
 }
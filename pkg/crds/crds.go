@@ -0,0 +1,113 @@
+// Package crds checks and installs the CustomResourceDefinitions of the
+// StackSet and Stack resources.
+package crds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	zv1 "github.com/zalando-incubator/stackset-controller/pkg/apis/zalando.org/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	crdResource = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1beta1",
+		Resource: "customresourcedefinitions",
+	}
+
+	// requiredResources are the resources of the zalando.org/v1 API
+	// used by the controller.
+	requiredResources = []string{"stacksets", "stacks"}
+)
+
+// Manifests returns the CustomResourceDefinitions embedded from docs/.
+func Manifests() ([]*unstructured.Unstructured, error) {
+	var result []*unstructured.Unstructured
+	for _, manifest := range []string{stackSetCRD, stackCRD} {
+		crd := &unstructured.Unstructured{}
+		err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096).Decode(&crd.Object)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, crd)
+	}
+	return result, nil
+}
+
+// Check returns an error if the StackSet and Stack resources aren't served
+// in the version expected by the controller, e.g. because the
+// CustomResourceDefinitions aren't installed.
+func Check(client discovery.DiscoveryInterface) error {
+	groupVersion := zv1.SchemeGroupVersion.String()
+	resources, err := client.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return fmt.Errorf("API %s is not available, are the StackSet and Stack CRDs installed? %v", groupVersion, err)
+	}
+
+	served := make(map[string]bool)
+	for _, resource := range resources.APIResources {
+		served[resource.Name] = true
+	}
+	for _, resource := range requiredResources {
+		if !served[resource] {
+			return fmt.Errorf("resource %s of API %s is not available, is the CRD installed?", resource, groupVersion)
+		}
+	}
+	return nil
+}
+
+// Install creates the CustomResourceDefinitions or updates the existing ones
+// to the embedded version.
+func Install(client dynamic.Interface) error {
+	manifests, err := Manifests()
+	if err != nil {
+		return err
+	}
+
+	crds := client.Resource(crdResource)
+	for _, crd := range manifests {
+		existing, err := crds.Get(crd.GetName(), metav1.GetOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+
+			_, err = crds.Create(crd, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to create CRD %s: %v", crd.GetName(), err)
+			}
+			continue
+		}
+
+		crd.SetResourceVersion(existing.GetResourceVersion())
+		_, err = crds.Update(crd, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to update CRD %s: %v", crd.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// WaitForResources waits until the StackSet and Stack resources are served,
+// e.g. after the CustomResourceDefinitions were installed.
+func WaitForResources(client discovery.DiscoveryInterface, timeout time.Duration) error {
+	var lastErr error
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		lastErr = Check(client)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return lastErr
+	}
+	return nil
+}
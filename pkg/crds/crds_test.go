@@ -0,0 +1,61 @@
+package crds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestManifests(t *testing.T) {
+	manifests, err := Manifests()
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	require.Equal(t, "stacksets.zalando.org", manifests[0].GetName())
+	require.Equal(t, "stacks.zalando.org", manifests[1].GetName())
+	for _, crd := range manifests {
+		require.Equal(t, "CustomResourceDefinition", crd.GetKind())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	discovery := client.Discovery()
+
+	// the API isn't available without the CRDs
+	require.Error(t, Check(discovery))
+	require.Error(t, WaitForResources(discovery, 10*time.Millisecond))
+
+	// all the resources must be available
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "zalando.org/v1",
+			APIResources: []metav1.APIResource{{Name: "stacksets"}},
+		},
+	}
+	require.Error(t, Check(discovery))
+
+	client.Resources[0].APIResources = append(client.Resources[0].APIResources, metav1.APIResource{Name: "stacks"})
+	require.NoError(t, Check(discovery))
+	require.NoError(t, WaitForResources(discovery, time.Second))
+}
+
+func TestInstall(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+
+	// the CRDs are created
+	err := Install(client)
+	require.NoError(t, err)
+	for _, name := range []string{"stacksets.zalando.org", "stacks.zalando.org"} {
+		_, err := client.Resource(crdResource).Get(name, metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+
+	// existing CRDs are updated
+	err = Install(client)
+	require.NoError(t, err)
+}
@@ -12,14 +12,24 @@ import (
 )
 
 const (
-	stacksetHeritageLabelKey         = "stackset"
-	stackTrafficWeightsAnnotationKey = "zalando.org/stack-traffic-weights"
-	backendWeightsAnnotationKey      = "zalando.org/backend-weights"
+	stacksetHeritageLabelKey          = "stackset"
+	stackTrafficWeightsAnnotationKey  = "zalando.org/stack-traffic-weights"
+	backendWeightsAnnotationKey       = "zalando.org/backend-weights"
+	trafficChangedByAnnotationKey     = "stackset-controller.zalando.org/traffic-changed-by"
+	instantTrafficSwitchAnnotationKey = "alpha.stackset-controller.zalando.org/instant-traffic-switch"
 )
 
 // Switcher is able to switch traffic between stacks.
 type Switcher struct {
 	client clientset.Interface
+
+	// ChangedBy is recorded as the author of the traffic changes in the
+	// traffic history of the stackset, if set.
+	ChangedBy string
+
+	// Instant switches the traffic without prescaling the stacks gaining
+	// traffic, e.g. for emergency rollbacks.
+	Instant bool
 }
 
 // NewSwitcher initializes a new traffic switcher.
@@ -29,7 +39,7 @@ func NewSwitcher(client clientset.Interface) *Switcher {
 
 // Switch changes traffic weight for a stack.
 func (t *Switcher) Switch(stackset, stack, namespace string, weight float64) ([]StackTrafficWeight, error) {
-	stacks, err := t.getStacks(stackset, namespace)
+	stacksetResource, stacks, err := t.getStacks(stackset, namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -49,17 +59,48 @@ func (t *Switcher) Switch(stackset, stack, namespace string, weight float64) ([]
 		stackWeights[stack.Name] = stack.Weight
 	}
 
-	if changeNeeded {
+	// the desired weights are updated in the spec if they're defined there
+	if changeNeeded && desiredTrafficInSpec(stacksetResource) {
+		var specWeights []zv1.StackTrafficWeight
+		for _, stack := range newWeights {
+			if stack.Weight > 0 {
+				specWeights = append(specWeights, zv1.StackTrafficWeight{StackName: stack.Name, Weight: stack.Weight})
+			}
+		}
+		stacksetResource.Spec.Traffic = specWeights
+		if t.ChangedBy != "" {
+			if stacksetResource.Annotations == nil {
+				stacksetResource.Annotations = make(map[string]string)
+			}
+			stacksetResource.Annotations[trafficChangedByAnnotationKey] = t.ChangedBy
+		}
+		if t.Instant {
+			if stacksetResource.Annotations == nil {
+				stacksetResource.Annotations = make(map[string]string)
+			}
+			stacksetResource.Annotations[instantTrafficSwitchAnnotationKey] = "true"
+		}
+
+		_, err = t.client.ZalandoV1().StackSets(namespace).Update(stacksetResource)
+		if err != nil {
+			return nil, err
+		}
+	} else if changeNeeded {
 		stackWeightsData, err := json.Marshal(&stackWeights)
 		if err != nil {
 			return nil, err
 		}
 
+		annotations := map[string]string{
+			stackTrafficWeightsAnnotationKey: string(stackWeightsData),
+		}
+		if t.ChangedBy != "" {
+			annotations[trafficChangedByAnnotationKey] = t.ChangedBy
+		}
+
 		annotation := map[string]map[string]map[string]string{
 			"metadata": map[string]map[string]string{
-				"annotations": map[string]string{
-					stackTrafficWeightsAnnotationKey: string(stackWeightsData),
-				},
+				"annotations": annotations,
 			},
 		}
 
@@ -72,6 +113,26 @@ func (t *Switcher) Switch(stackset, stack, namespace string, weight float64) ([]
 		if err != nil {
 			return nil, err
 		}
+
+		// the stackset is annotated after the weights were changed, so the
+		// controller doesn't consume the annotation before the switch
+		if t.Instant {
+			instantData, err := json.Marshal(map[string]map[string]map[string]string{
+				"metadata": {
+					"annotations": {
+						instantTrafficSwitchAnnotationKey: "true",
+					},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = t.client.ZalandoV1().StackSets(namespace).Patch(stackset, types.MergePatchType, instantData)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return newWeights, nil
@@ -85,15 +146,15 @@ type StackTrafficWeight struct {
 
 // TrafficWeights returns a list of stacks with their current traffic weight.
 func (t *Switcher) TrafficWeights(stackset, namespace string) ([]StackTrafficWeight, error) {
-	stacks, err := t.getStacks(stackset, namespace)
+	_, stacks, err := t.getStacks(stackset, namespace)
 	if err != nil {
 		return nil, err
 	}
 	return normalizeWeights(stacks), nil
 }
 
-// getStacks returns the stacks of the stackset.
-func (t *Switcher) getStacks(stackset, namespace string) ([]StackTrafficWeight, error) {
+// getStacks returns the stackset and the traffic weights of its stacks.
+func (t *Switcher) getStacks(stackset, namespace string) (*zv1.StackSet, []StackTrafficWeight, error) {
 	heritageLabels := map[string]string{
 		stacksetHeritageLabelKey: stackset,
 	}
@@ -103,12 +164,24 @@ func (t *Switcher) getStacks(stackset, namespace string) ([]StackTrafficWeight,
 
 	stacks, err := t.client.ZalandoV1().Stacks(namespace).List(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list stacks of stackset %s/%s: %v", namespace, stackset, err)
+		return nil, nil, fmt.Errorf("failed to list stacks of stackset %s/%s: %v", namespace, stackset, err)
 	}
 
 	desired, actual, err := t.getIngressTraffic(stackset, namespace, stacks.Items)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get Ingress traffic for StackSet %s/%s: %v", namespace, stackset, err)
+		return nil, nil, fmt.Errorf("failed to get Ingress traffic for StackSet %s/%s: %v", namespace, stackset, err)
+	}
+
+	// the desired weights in the spec take precedence over the annotation
+	stacksetResource, err := t.client.ZalandoV1().StackSets(namespace).Get(stackset, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get StackSet %s/%s: %v", namespace, stackset, err)
+	}
+	if desiredTrafficInSpec(stacksetResource) {
+		desired = make(map[string]float64, len(stacksetResource.Spec.Traffic))
+		for _, weight := range stacksetResource.Spec.Traffic {
+			desired[weight.StackName] = weight.Weight
+		}
 	}
 
 	stackWeights := make([]StackTrafficWeight, 0, len(stacks.Items))
@@ -121,7 +194,13 @@ func (t *Switcher) getStacks(stackset, namespace string) ([]StackTrafficWeight,
 
 		stackWeights = append(stackWeights, stackWeight)
 	}
-	return stackWeights, nil
+	return stacksetResource, stackWeights, nil
+}
+
+// desiredTrafficInSpec returns true if the desired traffic weights are
+// defined in the stackset spec instead of the ingress annotation.
+func desiredTrafficInSpec(stackset *zv1.StackSet) bool {
+	return len(stackset.Spec.Traffic) > 0
 }
 
 func (t *Switcher) getIngressTraffic(name, namespace string, stacks []zv1.Stack) (map[string]float64, map[string]float64, error) {